@@ -0,0 +1,114 @@
+package mysqlmeta
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// treat as const - the number of statements kept per table
+var ACTIVITY_LOG_SIZE = 100
+
+// StatementRecord describes one statement run for a table, see RecentStatements.
+type StatementRecord struct {
+	Time        time.Time     `json:"time"`
+	Fingerprint string        `json:"fingerprint"`
+	Duration    time.Duration `json:"duration"`
+	// Rows is the number of rows affected, or -1 for queries, whose rows are read later
+	Rows  int64  `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
+
+// activityLog is a ring buffer of the latest statements of a table. Like stmtCache
+// it is shared by pointer between copies of a TableMetadata.
+type activityLog struct {
+	mutex   sync.Mutex
+	records []activityRecord
+	next    int
+	full    bool
+}
+
+type activityRecord struct {
+	time     time.Time
+	query    string
+	duration time.Duration
+	rows     int64
+	err      error
+}
+
+func newActivityLog(size int) *activityLog {
+	return &activityLog{records: make([]activityRecord, size)}
+}
+
+func (log *activityLog) add(record activityRecord) {
+	// Only the raw query is kept here - fingerprinting waits until someone looks.
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.records[log.next] = record
+	log.next++
+	if len(log.records) == log.next {
+		log.next = 0
+		log.full = true
+	}
+}
+
+func (log *activityLog) list() []activityRecord {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	if !log.full {
+		return append([]activityRecord{}, log.records[:log.next]...)
+	}
+	return append(append([]activityRecord{}, log.records[log.next:]...), log.records[:log.next]...)
+}
+
+var (
+	fingerprintComment = regexp.MustCompile(`/\*.*?\*/`)
+	fingerprintString  = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	fingerprintNumber  = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	fingerprintList    = regexp.MustCompile(`\(\?(?:\s*,\s*\?)+\)`)
+	fingerprintSpace   = regexp.MustCompile(`\s+`)
+)
+
+func Fingerprint(query string) string {
+	// Reduces a statement to its shape, so that statements differing only in their
+	// values, tag comments or the length of IN lists have the same fingerprint.
+	query = fingerprintComment.ReplaceAllString(query, "")
+	query = fingerprintString.ReplaceAllString(query, "?")
+	query = fingerprintNumber.ReplaceAllString(query, "?")
+	query = fingerprintList.ReplaceAllString(query, "(?+)")
+	return strings.TrimSpace(fingerprintSpace.ReplaceAllString(query, " "))
+}
+
+func (metadata TableMetadata) recordStatement(start time.Time, query string, args []interface{}, rows int64, err error) {
+	duration := time.Since(start)
+	if nil != metadata.Observer {
+		metadata.Observer.OnQuery(query, args, duration, rows, err)
+	}
+	if nil == metadata.activity {
+		return
+	}
+	metadata.activity.add(activityRecord{time: start, query: query, duration: duration, rows: rows, err: err})
+}
+
+func (metadata TableMetadata) RecentStatements() []StatementRecord {
+	// Returns the latest statements run for the table, oldest first, ex. for an
+	// admin endpoint to show what a misbehaving service has been doing.
+	if nil == metadata.activity {
+		return nil
+	}
+	records := metadata.activity.list()
+	statements := make([]StatementRecord, len(records))
+	for i, record := range records {
+		statements[i] = StatementRecord{
+			Time:        record.time,
+			Fingerprint: Fingerprint(record.query),
+			Duration:    record.duration,
+			Rows:        record.rows,
+		}
+		if nil != record.err {
+			statements[i].Error = record.err.Error()
+		}
+	}
+	return statements
+}
@@ -0,0 +1,110 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// treat as const
+var SQL_INTEGER_WIDTH = regexp.MustCompile("(?i)^(tiny|small|medium|big)?int\\b.*?( unsigned)?( zerofill)?$")
+
+type AutoIncrementUsage struct {
+	TableName string `json:"table_name"`
+	Column    string `json:"column"`
+	// CurrentValue is the next value the table will hand out
+	CurrentValue uint64 `json:"current_value"`
+	// MaxUsed is the largest value actually present in the column
+	MaxUsed uint64 `json:"max_used"`
+	// ColumnMax is the largest value the column type can hold
+	ColumnMax   uint64  `json:"column_max"`
+	PercentUsed float64 `json:"percent_used"`
+	// Gap counts values handed out above MaxUsed, ex. by rolled back inserts
+	Gap uint64 `json:"gap"`
+}
+
+func IntegerColumnMax(columnType string) (uint64, bool) {
+	// Returns the largest value of an integer column type, ex. 4294967295 for "int unsigned"
+	m := SQL_INTEGER_WIDTH.FindStringSubmatch(columnType)
+	if nil == m {
+		return 0, false
+	}
+	bits := map[string]uint{"tiny": 8, "small": 16, "medium": 24, "": 32, "big": 64}[strings.ToLower(m[1])]
+	if "" != m[2] {
+		if 64 == bits {
+			return math.MaxUint64, true
+		}
+		return 1<<bits - 1, true
+	}
+	return 1<<(bits-1) - 1, true
+}
+
+func (col ColumnMetadata) IsAutoIncrement() bool {
+	return strings.Contains(strings.ToLower(col.Extra), "auto_increment")
+}
+
+func (metadata TableMetadata) AutoIncrementColumn() (ColumnMetadata, bool) {
+	for _, col := range metadata.Columns {
+		if col.IsAutoIncrement() {
+			return col, true
+		}
+	}
+	return ColumnMetadata{}, false
+}
+
+func (metadata TableMetadata) AutoIncrementStatus(ctx context.Context) (AutoIncrementUsage, error) {
+	// Reports how much of the auto_increment column's range has been consumed.
+	col, ok := metadata.AutoIncrementColumn()
+	if !ok {
+		return AutoIncrementUsage{}, fmt.Errorf("%w: %s has no auto_increment column", ErrNoPrimaryKey, metadata.Name)
+	}
+	usage := AutoIncrementUsage{TableName: metadata.Name, Column: col.Field}
+	usage.ColumnMax, _ = IntegerColumnMax(col.ColumnType)
+	// information_schema statistics may be cached on MySQL 8 - see information_schema_stats_expiry
+	current := sql.NullInt64{}
+	err := metadata.DB.QueryRowContext(ctx,
+		"SELECT AUTO_INCREMENT FROM information_schema.TABLES WHERE "+SQL_TABLE_IS,
+		tableIsArgs(metadata.Name)...,
+	).Scan(&current)
+	if nil != err {
+		return usage, fmt.Errorf("auto_increment status of %s: %w", metadata.Name, err)
+	}
+	maxUsed := sql.NullInt64{}
+	err = metadata.DB.QueryRowContext(ctx, "SELECT MAX("+QuoteIdentifier(col.Field)+") FROM "+QuoteTableName(metadata.Name)).Scan(&maxUsed)
+	if nil != err {
+		return usage, fmt.Errorf("auto_increment status of %s: %w", metadata.Name, err)
+	}
+	usage.CurrentValue = uint64(current.Int64)
+	usage.MaxUsed = uint64(maxUsed.Int64)
+	if usage.CurrentValue > usage.MaxUsed+1 {
+		usage.Gap = usage.CurrentValue - usage.MaxUsed - 1
+	}
+	if 0 < usage.ColumnMax {
+		usage.PercentUsed = 100 * float64(usage.CurrentValue) / float64(usage.ColumnMax)
+	}
+	return usage, nil
+}
+
+func AutoIncrementReport(ctx context.Context, tables ...*TableMetadata) ([]AutoIncrementUsage, error) {
+	// Collects AutoIncrementStatus for each table that has an auto_increment column,
+	// most consumed first.
+	report := []AutoIncrementUsage{}
+	for _, metadata := range tables {
+		if _, ok := metadata.AutoIncrementColumn(); !ok {
+			continue
+		}
+		usage, err := metadata.AutoIncrementStatus(ctx)
+		if nil != err {
+			return report, err
+		}
+		report = append(report, usage)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].PercentUsed > report[j].PercentUsed
+	})
+	return report, nil
+}
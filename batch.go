@@ -0,0 +1,60 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// BatchProgress tells how far ForEachBatch got, so that a job that stopped can resume.
+type BatchProgress struct {
+	// LastId is the id of the last row of the last batch processed, to resume after
+	LastId  uint
+	Batches int
+	Rows    int
+}
+
+func (table *Table[T]) ForEachBatch(ctx context.Context, batchSize int, condition string, fn func([]T) error, args ...interface{}) (BatchProgress, error) {
+	return table.ForEachBatchAfter(ctx, 0, batchSize, condition, fn, args...)
+}
+
+func (table *Table[T]) ForEachBatchAfter(ctx context.Context, after uint, batchSize int, condition string, fn func([]T) error, args ...interface{}) (BatchProgress, error) {
+	// Calls fn with the rows matching the condition, ex. "status = ?" or "" for every
+	// row, in batches of up to batchSize rows in order of id, each read with a range
+	// on the primary key rather than an offset. Rows with ids up to after are skipped.
+	// The progress is returned also on error: resume with its LastId as after.
+	progress := BatchProgress{LastId: after}
+	if 0 >= batchSize {
+		return progress, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	if _, ok := table.Metadata.FieldByColumn["id"]; !ok {
+		return progress, fmt.Errorf("%w: %s has no id column", ErrNoPrimaryKey, table.Metadata.Name)
+	}
+	clause := " WHERE `id` > ?"
+	if "" != condition {
+		clause += " AND (" + condition + ")"
+	}
+	clause += " ORDER BY `id` LIMIT ?"
+	for {
+		if err := ctx.Err(); nil != err {
+			return progress, err
+		}
+		batch := make([]T, 0, batchSize)
+		v := append(append([]interface{}{progress.LastId}, args...), batchSize)
+		if err := table.Metadata.GetEntitiesContext(ctx, &batch, clause, v...); nil != err {
+			return progress, err
+		}
+		if 0 == len(batch) {
+			return progress, nil
+		}
+		if err := fn(batch); nil != err {
+			return progress, err
+		}
+		progress.LastId = GetValueId(reflect.ValueOf(&batch[len(batch)-1]).Elem())
+		progress.Batches++
+		progress.Rows += len(batch)
+		if len(batch) < batchSize {
+			return progress, nil
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package mysqlmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// treat as const
+var BREAKER_THRESHOLD = 5
+var BREAKER_COOL_DOWN = 30 * time.Second
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails statements fast with ErrCircuitOpen until the cool-down is over
+	CircuitOpen
+	// CircuitHalfOpen lets one statement through, whose outcome closes or opens the circuit
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker stops the statements of the tables it is set on after Threshold
+// failures in a row, so that callers fail fast with ErrCircuitOpen rather than pile
+// up on a database that is down, and lets one through again after CoolDown. Only
+// failures of the database count, ex. timeouts and dropped connections, not errors
+// such as a duplicate key. The zero value uses BREAKER_THRESHOLD and BREAKER_COOL_DOWN.
+// A breaker may be shared by the tables of a database.
+type CircuitBreaker struct {
+	Threshold int
+	CoolDown  time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func (breaker *CircuitBreaker) State() CircuitState {
+	if nil == breaker {
+		return CircuitClosed
+	}
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if CircuitOpen == breaker.state && time.Since(breaker.openedAt) >= breaker.coolDown() {
+		return CircuitHalfOpen
+	}
+	return breaker.state
+}
+
+func (breaker *CircuitBreaker) coolDown() time.Duration {
+	if 0 >= breaker.CoolDown {
+		return BREAKER_COOL_DOWN
+	}
+	return breaker.CoolDown
+}
+
+func (breaker *CircuitBreaker) allow() error {
+	// Returns ErrCircuitOpen while the circuit is open, or half-open with the trial
+	// statement still running.
+	if nil == breaker {
+		return nil
+	}
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if CircuitOpen == breaker.state && time.Since(breaker.openedAt) >= breaker.coolDown() {
+		breaker.state = CircuitHalfOpen
+		breaker.trial = false
+	}
+	switch breaker.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if breaker.trial {
+			return ErrCircuitOpen
+		}
+		breaker.trial = true
+	}
+	return nil
+}
+
+func (breaker *CircuitBreaker) record(err error) {
+	// Counts the outcome of a statement let through by allow.
+	if nil == breaker {
+		return
+	}
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if !isDatabaseFailure(err) {
+		breaker.state = CircuitClosed
+		breaker.failures = 0
+		breaker.trial = false
+		return
+	}
+	breaker.failures++
+	threshold := breaker.Threshold
+	if 0 >= threshold {
+		threshold = BREAKER_THRESHOLD
+	}
+	if CircuitHalfOpen == breaker.state || breaker.failures >= threshold {
+		breaker.state = CircuitOpen
+		breaker.openedAt = time.Now()
+		breaker.trial = false
+	}
+}
+
+func (breaker *CircuitBreaker) Reset() {
+	// Closes the circuit, ex. once the database is known to be back.
+	breaker.record(nil)
+}
+
+func isDatabaseFailure(err error) bool {
+	// Errors of the statement itself, ex. a duplicate key, show that the database is up.
+	return nil != err && (IsTransientError(err) || errors.Is(err, context.DeadlineExceeded))
+}
+
+func (metadata TableMetadata) Health(ctx context.Context, validateSchema bool) error {
+	// Pings the database, and with validateSchema reads the columns of the table again
+	// to check that they still match the metadata, returning ErrColumnMismatch with
+	// the drift otherwise. A successful ping closes the circuit of the Breaker.
+	if err := metadata.DB.PingContext(ctx); nil != err {
+		return fmt.Errorf("ping for %s: %w", metadata.Name, err)
+	}
+	metadata.Breaker.Reset()
+	if !validateSchema {
+		return nil
+	}
+	cols, err := metadata.getColumnsWithIndexes(metadata.DB, metadata.Name)
+	if nil != err {
+		return fmt.Errorf("read columns of %s: %w", metadata.Name, err)
+	}
+	return SchemaReport{Drifts: metadata.drift(cols)}.Err()
+}
@@ -0,0 +1,95 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+func (metadata TableMetadata) UpdateWhere(assignments map[string]interface{}, clause string, v ...interface{}) (int64, error) {
+	return metadata.UpdateWhereContext(context.Background(), assignments, clause, v...)
+}
+
+func (metadata TableMetadata) UpdateWhereContext(ctx context.Context, assignments map[string]interface{}, clause string, v ...interface{}) (int64, error) {
+	// Sets the columns of every row matching the clause in one statement, ex.
+	// UpdateWhere(map[string]interface{}{"status": "expired"}, " WHERE expires_at < ?", now),
+	// and returns the number of rows changed. Soft deleted rows are left alone, the
+	// auto-update-time columns are set unless assigned, and the version is bumped.
+	// An empty clause updates every row. Only the columns that UpdateEntity writes may
+	// be assigned, and neither the primary key nor the tenant.
+	if 0 == len(assignments) {
+		return 0, nil
+	}
+	updatable := map[string]bool{}
+	for _, col := range metadata.Columns {
+		// neither the key of a row nor its tenant may change, as that would move it
+		updatable[col.Field] = col.AllowUpdate(reflect.Value{}) && "PRI" != col.Key && metadata.TenantColumn != col.Field
+	}
+	colnames := make([]string, 0, len(assignments))
+	for colname := range assignments {
+		if !metadata.IsColumn(colname) {
+			return 0, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+		if !updatable[colname] {
+			return 0, fmt.Errorf("%w: %s.%s cannot be updated", ErrInvalidColumn, metadata.Name, colname)
+		}
+		colnames = append(colnames, colname)
+	}
+	// sorted so that the same assignments always make the same statement
+	sort.Strings(colnames)
+	now := time.Now()
+	for _, col := range metadata.Columns {
+		if _, ok := assignments[col.Field]; !ok && col.AutoUpdateTime {
+			colnames = append(colnames, col.Field)
+		}
+	}
+	set := ""
+	separator := ""
+	values := make([]interface{}, 0, len(colnames)+len(v))
+	for _, colname := range colnames {
+		set += separator + QuoteIdentifier(colname) + "=?"
+		separator = ", "
+		if value, ok := assignments[colname]; ok {
+			values = append(values, value)
+		} else {
+			values = append(values, now)
+		}
+	}
+	if _, ok := assignments[metadata.VersionColumn]; "" != metadata.VersionColumn && !ok {
+		set += ", " + QuoteIdentifier(metadata.VersionColumn) + "=" + QuoteIdentifier(metadata.VersionColumn) + "+1"
+	}
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := "UPDATE " + QuoteTableName(metadata.Name) + " SET " + set + " " + clause
+	result, err := metadata.exec(ctx, query, append(values, v...)...)
+	if nil != err {
+		return 0, fmt.Errorf("update %s: %w", metadata.Name, err)
+	}
+	return result.RowsAffected()
+}
+
+func (metadata TableMetadata) DeleteWhere(clause string, v ...interface{}) (int64, error) {
+	return metadata.DeleteWhereContext(context.Background(), clause, v...)
+}
+
+func (metadata TableMetadata) DeleteWhereContext(ctx context.Context, clause string, v ...interface{}) (int64, error) {
+	// Deletes every row matching the clause in one statement, or with soft delete
+	// marks them deleted, and returns the number of rows affected. An empty clause
+	// deletes every row.
+	if metadata.unscoped || "" == metadata.SoftDeleteColumn {
+		clause, v = metadata.scopeTenant(ctx, clause, v)
+		result, err := metadata.exec(ctx, "DELETE FROM "+QuoteTableName(metadata.Name)+" "+clause, v...)
+		if nil != err {
+			return 0, fmt.Errorf("delete from %s: %w", metadata.Name, err)
+		}
+		return result.RowsAffected()
+	}
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := "UPDATE " + QuoteTableName(metadata.Name) + " SET " + QuoteIdentifier(metadata.SoftDeleteColumn) + " = ? " + clause
+	result, err := metadata.exec(ctx, query, append([]interface{}{time.Now()}, v...)...)
+	if nil != err {
+		return 0, fmt.Errorf("soft delete from %s: %w", metadata.Name, err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,70 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+func (metadata TableMetadata) GetEntityMapByIds(ids []uint) (map[uint]interface{}, error) {
+	return metadata.GetEntityMapByIdsContext(context.Background(), ids)
+}
+
+func (metadata TableMetadata) GetEntityMapByIdsContext(ctx context.Context, ids []uint) (map[uint]interface{}, error) {
+	// Returns pointers to the entities with the ids, keyed by id, in one query per
+	// MAX_IN_VALUES ids. Ids with no row are simply missing from the map.
+	if nil == metadata.EntityType {
+		return nil, fmt.Errorf("%w: no entity type for %s", ErrInvalidEntity, metadata.Name)
+	}
+	found := make(map[uint]interface{}, len(ids))
+	for start := 0; start < len(ids); start += MAX_IN_VALUES {
+		end := start + MAX_IN_VALUES
+		if end > len(ids) {
+			end = len(ids)
+		}
+		v := make([]interface{}, end-start)
+		for i, id := range ids[start:end] {
+			v[i] = id
+		}
+		entities := reflect.New(reflect.SliceOf(reflect.PtrTo(metadata.EntityType)))
+		err := metadata.GetEntitiesContext(ctx, entities.Interface(), " WHERE id IN "+InPlaceholders(len(v)), v...)
+		if nil != err {
+			return nil, err
+		}
+		for i := 0; i < entities.Elem().Len(); i++ {
+			entity := entities.Elem().Index(i)
+			found[GetValueId(entity.Elem())] = entity.Interface()
+		}
+	}
+	return found, nil
+}
+
+func (metadata TableMetadata) GetEntitiesByIds(dest interface{}, ids []uint) error {
+	return metadata.GetEntitiesByIdsContext(context.Background(), dest, ids)
+}
+
+func (metadata TableMetadata) GetEntitiesByIdsContext(ctx context.Context, dest interface{}, ids []uint) error {
+	// Appends the entities with the ids to dest, a pointer to a slice of entities, in
+	// the order of ids. Ids with no row are skipped, so compare lengths, or use
+	// GetEntityMapByIds, to find them.
+	slice, _, err := GetSliceValue(dest)
+	if nil != err {
+		return err
+	}
+	found, err := metadata.GetEntityMapByIdsContext(ctx, ids)
+	if nil != err {
+		return err
+	}
+	for _, id := range ids {
+		entity, ok := found[id]
+		if !ok {
+			continue
+		}
+		if reflect.Ptr == slice.Type().Elem().Kind() {
+			slice.Set(reflect.Append(slice, reflect.ValueOf(entity)))
+		} else {
+			slice.Set(reflect.Append(slice, reflect.ValueOf(entity).Elem()))
+		}
+	}
+	return nil
+}
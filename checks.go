@@ -0,0 +1,88 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CheckConstraint is a CHECK constraint of the table, ex. {Name: "positive_price",
+// Clause: "(`price` > 0)", Enforced: true}. The clause is as the server writes it,
+// so it is SQL to mirror in validation rather than to evaluate in Go.
+type CheckConstraint struct {
+	Name     string `json:"name"`
+	Clause   string `json:"clause"`
+	Enforced bool   `json:"enforced"`
+}
+
+func GetCheckConstraints(db *sql.DB, tableName string) ([]CheckConstraint, error) {
+	// Returns the CHECK constraints of the table in the order of their names, none on
+	// servers that parse but ignore them, ex. MySQL before 8.0.16.
+	err := CheckTableName(tableName)
+	if nil != err {
+		return nil, err
+	}
+	version, err := GetServerVersion(context.Background(), db)
+	if nil != err {
+		return nil, err
+	}
+	checks := []CheckConstraint{}
+	if !version.Supports(FEATURE_CHECK_CONSTRAINTS) {
+		return checks, nil
+	}
+	// only MySQL has NOT ENFORCED, so the constraints of the others are always enforced
+	enforced := "'YES'"
+	if FLAVOR_MYSQL == version.Flavor {
+		enforced = "t.ENFORCED"
+	}
+	rows, err := db.Query(
+		"SELECT t.CONSTRAINT_NAME, c.CHECK_CLAUSE, "+enforced+" = 'YES' "+
+			"FROM information_schema.TABLE_CONSTRAINTS t "+
+			"JOIN information_schema.CHECK_CONSTRAINTS c "+
+			"ON c.CONSTRAINT_SCHEMA = t.CONSTRAINT_SCHEMA AND c.CONSTRAINT_NAME = t.CONSTRAINT_NAME "+
+			"WHERE t.TABLE_SCHEMA = IFNULL(?, DATABASE()) AND t.TABLE_NAME = ? AND t.CONSTRAINT_TYPE = 'CHECK' "+
+			"ORDER BY t.CONSTRAINT_NAME",
+		tableIsArgs(tableName)...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("check constraints for %s: %w", tableName, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var check CheckConstraint
+		if err = rows.Scan(&check.Name, &check.Clause, &check.Enforced); nil != err {
+			return nil, fmt.Errorf("check constraints for %s: %w", tableName, err)
+		}
+		checks = append(checks, check)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("check constraints for %s: %w", tableName, err)
+	}
+	return checks, nil
+}
+
+func (options TableOptions) checkConstraints() []CheckConstraint {
+	// The CHECK constraints of the CREATE TABLE the options were parsed from, with the
+	// clause of SHOW CREATE TABLE unwrapped to that of information_schema.
+	checks := []CheckConstraint{}
+	for _, constraint := range options.Constraints {
+		if "CHECK" == constraint.Kind {
+			checks = append(checks, CheckConstraint{
+				Name:     constraint.Name,
+				Clause:   stripParentheses(constraint.Definition),
+				Enforced: !constraint.NotEnforced,
+			})
+		}
+	}
+	return checks
+}
+
+func (metadata TableMetadata) CheckConstraint(name string) (CheckConstraint, bool) {
+	// Returns the CHECK constraint with the given name.
+	for _, check := range metadata.CheckConstraints {
+		if name == check.Name {
+			return check, true
+		}
+	}
+	return CheckConstraint{}, false
+}
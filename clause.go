@@ -0,0 +1,122 @@
+package mysqlmeta
+
+import (
+	"strings"
+)
+
+// clauseKeywords are the top-level keywords that may follow the WHERE condition
+// in a clause passed to GetEntity and friends.
+var clauseKeywords = []string{"GROUP BY", "HAVING", "WINDOW", "ORDER BY", "LIMIT", "FOR UPDATE", "FOR SHARE", "LOCK IN SHARE MODE"}
+
+func findTopLevel(clause string, keywords ...string) (int, string) {
+	// Returns the position of the first of the keywords found outside of quotes
+	// and parentheses, or -1 if there is none.
+	depth := 0
+	var quote byte
+	upper := strings.ToUpper(clause)
+	for i := 0; i < len(clause); i++ {
+		c := clause[i]
+		switch {
+		case 0 != quote:
+			if '\\' == c && '`' != quote {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case '\'' == c || '"' == c || '`' == c:
+			quote = c
+			continue
+		case '(' == c:
+			depth++
+			continue
+		case ')' == c:
+			depth--
+			continue
+		}
+		if 0 != depth || (0 < i && isWordByte(clause[i-1])) {
+			continue
+		}
+		for _, keyword := range keywords {
+			end := i + len(keyword)
+			if strings.HasPrefix(upper[i:], keyword) && (end == len(clause) || !isWordByte(clause[end])) {
+				return i, keyword
+			}
+		}
+	}
+	return -1, ""
+}
+
+func isWordByte(c byte) bool {
+	return '_' == c || '$' == c || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func scopePosition(clause string) (int, bool) {
+	// Returns where a condition goes in the clause: at its top-level WHERE, and
+	// true, or ahead of any ORDER BY, LIMIT etc. if it has no WHERE.
+	if where, _ := findTopLevel(clause, "WHERE"); 0 <= where {
+		return where, true
+	}
+	at, _ := findTopLevel(clause, clauseKeywords...)
+	if 0 > at {
+		at = len(clause)
+	}
+	return at, false
+}
+
+func countPlaceholders(clause string) int {
+	// Counts the ? placeholders outside of quotes.
+	count := 0
+	var quote byte
+	for i := 0; i < len(clause); i++ {
+		c := clause[i]
+		switch {
+		case 0 != quote:
+			if '\\' == c && '`' != quote {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case '\'' == c || '"' == c || '`' == c:
+			quote = c
+		case '?' == c:
+			count++
+		}
+	}
+	return count
+}
+
+func ScopeClause(clause string, condition string) string {
+	// Adds condition to the WHERE of a clause, ex. " WHERE a = ? OR b = ? LIMIT 1"
+	// becomes " WHERE (condition) AND (a = ? OR b = ?) LIMIT 1". Use ScopeClauseArgs
+	// when the condition has placeholders.
+	if "" == condition {
+		return clause
+	}
+	at, where := scopePosition(clause)
+	if !where {
+		return strings.TrimRight(clause[:at], " ") + " WHERE " + condition + " " + clause[at:]
+	}
+	rest := clause[at+len("WHERE"):]
+	end, _ := findTopLevel(rest, clauseKeywords...)
+	if 0 > end {
+		end = len(rest)
+	}
+	return clause[:at] + "WHERE (" + condition + ") AND (" + strings.TrimSpace(rest[:end]) + ") " + rest[end:]
+}
+
+func ScopeClauseArgs(clause string, condition string, v []interface{}, conditionArgs ...interface{}) (string, []interface{}) {
+	// Like ScopeClause, also placing the arguments of the condition among v after
+	// those of any placeholders ahead of the WHERE, ex. in a JOIN ... ON a = ?.
+	if "" == condition {
+		return clause, v
+	}
+	at, _ := scopePosition(clause)
+	n := countPlaceholders(clause[:at])
+	if n > len(v) {
+		n = len(v)
+	}
+	args := make([]interface{}, 0, len(v)+len(conditionArgs))
+	args = append(append(append(args, v[:n]...), conditionArgs...), v[n:]...)
+	return ScopeClause(clause, condition), args
+}
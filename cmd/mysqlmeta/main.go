@@ -0,0 +1,76 @@
+// Command mysqlmeta generates Go entity structs from the tables of a MySQL database.
+//
+//	mysqlmeta -dsn 'user:password@tcp(localhost:3306)/shop' -package shop -out entities.go order order_line
+//
+// Without table names, it generates a struct for every table of the database.
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/johnhanjukim/mysqlmeta"
+)
+
+func main() {
+	dsn := flag.String("dsn", os.Getenv("MYSQL_DSN"), "data source name of the database, or $MYSQL_DSN")
+	pkg := flag.String("package", "main", "package clause of the generated file")
+	out := flag.String("out", "", "file to write, or standard output if empty")
+	initialisms := flag.Bool("initialisms", false, "name fields with Go initialisms, ex. OrderID rather than OrderId")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: mysqlmeta [flags] [table ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if "" == *dsn {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*dsn, *pkg, *out, *initialisms, flag.Args()); nil != err {
+		fmt.Fprintln(os.Stderr, "mysqlmeta:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dsn string, pkg string, out string, initialisms bool, tableNames []string) error {
+	db, err := sql.Open("mysql", dsn)
+	if nil != err {
+		return err
+	}
+	defer db.Close()
+	tables, err := mysqlmeta.GetAllTableMetadata(db)
+	if nil != err {
+		return err
+	}
+	if 0 == len(tableNames) {
+		for name := range tables {
+			tableNames = append(tableNames, name)
+		}
+		sort.Strings(tableNames)
+	}
+	selected := []*mysqlmeta.TableMetadata{}
+	for _, name := range tableNames {
+		metadata, ok := tables[name]
+		if !ok {
+			return fmt.Errorf("no table %s in the database", name)
+		}
+		selected = append(selected, metadata)
+	}
+	options := mysqlmeta.CodegenOptions{Package: pkg}
+	if initialisms {
+		options.Naming = mysqlmeta.GoNaming
+	}
+	source, err := mysqlmeta.GenerateStructs(options, selected...)
+	if nil != err {
+		return err
+	}
+	if "" == out {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return os.WriteFile(out, source, 0644)
+}
@@ -0,0 +1,188 @@
+package mysqlmeta
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CodegenOptions controls the Go source made by GenerateStructs.
+type CodegenOptions struct {
+	// Package is the package clause of the file, or none if empty
+	Package string
+	// Naming makes the field names, DefaultNaming if nil - use GoNaming for OrderID
+	Naming NamingStrategy
+	// TypeNames overrides the struct name of a table, otherwise the field name of the
+	// table name by Naming, ex. OrderLine for order_line
+	TypeNames map[string]string
+}
+
+// treat as const
+var SQL_TYPE_NAME = regexp.MustCompile("^(\\w+)")
+var GO_IDENTIFIER = regexp.MustCompile("^[\\p{L}_][\\p{L}\\p{Nd}_]*$")
+
+func GoFieldType(col ColumnMetadata) string {
+	// Returns the Go type for a column, a pointer when the column is nullable so that
+	// CheckFieldTypes accepts it. Types outside of what CheckFieldTypes knows, ex.
+	// decimal and json, are strings so that no precision is lost.
+	columnType := strings.ToLower(col.ColumnType)
+	unsigned := strings.HasSuffix(columnType, " unsigned")
+	goType := "string"
+	switch SQL_TYPE_NAME.FindString(columnType) {
+	case "tinyint":
+		goType = "int8"
+		if "tinyint(1) unsigned" == columnType {
+			goType = "bool"
+		}
+	case "smallint":
+		goType = "int16"
+	case "mediumint", "int", "integer":
+		goType = "int"
+	case "bigint":
+		goType = "int64"
+	case "float":
+		goType = "float32"
+	case "double", "real":
+		goType = "float64"
+	case "datetime", "timestamp", "date":
+		goType = "time.Time"
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		goType = "[]byte"
+	case "set":
+		// split into its values, with NULL as nil
+		return "[]string"
+	}
+	if unsigned && strings.HasPrefix(goType, "int") {
+		goType = "u" + goType
+	}
+	if "YES" == col.Nullable {
+		goType = "*" + goType
+	}
+	return goType
+}
+
+func (options CodegenOptions) naming() NamingStrategy {
+	if nil == options.Naming {
+		return DefaultNaming
+	}
+	return options.Naming
+}
+
+func (options CodegenOptions) typeName(tableName string) string {
+	if name, ok := options.TypeNames[tableName]; ok {
+		return name
+	}
+	return options.naming().FieldName(tableName)
+}
+
+func goComment(indent string, text string) string {
+	// Makes a // comment of each line of text.
+	comment := ""
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		comment += indent + "// " + strings.TrimSpace(line) + "\n"
+	}
+	return comment
+}
+
+func (options CodegenOptions) writeStruct(buf *bytes.Buffer, metadata *TableMetadata) error {
+	typeName := options.typeName(metadata.Name)
+	if !GO_IDENTIFIER.MatchString(typeName) || token.IsKeyword(typeName) {
+		return fmt.Errorf("%w: no Go type name for table %s", ErrInvalidTableName, metadata.Name)
+	}
+	if "" != metadata.Comment {
+		buf.WriteString(goComment("", typeName+" is "+metadata.Comment))
+	}
+	for _, check := range metadata.CheckConstraints {
+		// mirrored as comments, to be checked by the validation of the entity
+		comment := "CHECK " + check.Name + ": " + check.Clause
+		if !check.Enforced {
+			comment += " (not enforced)"
+		}
+		buf.WriteString(goComment("", comment))
+	}
+	fmt.Fprintf(buf, "type %s struct {\n", typeName)
+	fieldNames := map[string]bool{}
+	for i, col := range metadata.Columns {
+		fieldName := options.naming().FieldName(col.Field)
+		if "id" == col.Field {
+			// entities are always read and written by their Id field, see GetValueId
+			fieldName = "Id"
+		}
+		tags := []string{}
+		if !GO_IDENTIFIER.MatchString(fieldName) || token.IsKeyword(fieldName) || fieldNames[fieldName] {
+			// columns such as 2fa_secret need a field name of their own, and the sql tag
+			// to match it to the column
+			if !SQL_COLUMN_NAME.MatchString(col.Field) {
+				return fmt.Errorf("%w: no Go field name for column %s.%s", ErrInvalidColumn, metadata.Name, col.Field)
+			}
+			fieldName = fmt.Sprintf("Column%d", i+1)
+			tags = append(tags, col.Field)
+		}
+		fieldNames[fieldName] = true
+		if col.IsGenerated() {
+			// the server computes generated columns, and refuses values for them
+			tags = append(tags, "no-insert", "no-update")
+		}
+		if col.Version {
+			tags = append(tags, "version")
+		}
+		if col.SoftDelete {
+			tags = append(tags, "soft-delete")
+		}
+		if col.NaturalKey {
+			tags = append(tags, "natural-key")
+		}
+		if "" != col.Comment {
+			buf.WriteString(goComment("\t", col.Comment))
+		}
+		if col.Invisible {
+			buf.WriteString(goComment("\t", fieldName+" is an invisible column, which SELECT * leaves out."))
+		}
+		fmt.Fprintf(buf, "\t%s %s", fieldName, GoFieldType(col))
+		if 0 < len(tags) {
+			fmt.Fprintf(buf, " `sql:\"%s\"`", strings.Join(tags, ","))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+func GenerateStructs(options CodegenOptions, tables ...*TableMetadata) ([]byte, error) {
+	// Returns gofmt'ed Go source with an entity struct for each table, in order of
+	// table name, with a field of the matching type for each column, the sql tags
+	// that the columns need, and the table and column comments and CHECK constraints as doc comments.
+	sorted := append([]*TableMetadata{}, tables...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	body := &bytes.Buffer{}
+	for _, metadata := range sorted {
+		body.WriteString("\n")
+		if err := options.writeStruct(body, metadata); nil != err {
+			return nil, err
+		}
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Code generated by mysqlmeta. DO NOT EDIT.\n\n")
+	if "" != options.Package {
+		fmt.Fprintf(buf, "package %s\n\n", options.Package)
+	}
+	if strings.Contains(body.String(), "time.Time") {
+		buf.WriteString("import \"time\"\n")
+	}
+	buf.Write(body.Bytes())
+	source, err := format.Source(buf.Bytes())
+	if nil != err {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return source, nil
+}
+
+func (metadata *TableMetadata) GenerateStruct(options CodegenOptions) ([]byte, error) {
+	return GenerateStructs(options, metadata)
+}
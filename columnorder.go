@@ -0,0 +1,91 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// ColumnOrder chooses the order of TableMetadata.Columns, which is also the order
+// of the columns in generated statements and in JSON.
+type ColumnOrder int
+
+const (
+	// ColumnOrderTable keeps the order of SHOW COLUMNS
+	ColumnOrderTable ColumnOrder = iota
+	// ColumnOrderOrdinal orders by ORDINAL_POSITION in information_schema.COLUMNS
+	ColumnOrderOrdinal
+	// ColumnOrderName orders by column name, so ALTERs that move columns change nothing
+	ColumnOrderName
+)
+
+func GetColumnPositions(db *sql.DB, tableName string) (map[string]uint, error) {
+	rows, err := db.Query(
+		"SELECT COLUMN_NAME, ORDINAL_POSITION FROM information_schema.COLUMNS "+
+			"WHERE "+SQL_TABLE_IS,
+		tableIsArgs(tableName)...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("column positions for %s: %w", tableName, err)
+	}
+	defer rows.Close()
+	positions := map[string]uint{}
+	for rows.Next() {
+		var name string
+		var position uint
+		if err = rows.Scan(&name, &position); nil != err {
+			return nil, fmt.Errorf("column positions for %s: %w", tableName, err)
+		}
+		positions[name] = position
+	}
+	return positions, rows.Err()
+}
+
+func orderColumns(db *sql.DB, tableName string, cols []ColumnMetadata, order ColumnOrder) ([]ColumnMetadata, error) {
+	switch order {
+	case ColumnOrderOrdinal:
+		positions, err := GetColumnPositions(db, tableName)
+		if nil != err {
+			return nil, err
+		}
+		sort.SliceStable(cols, func(i, j int) bool {
+			return positions[cols[i].Field] < positions[cols[j].Field]
+		})
+	case ColumnOrderName:
+		sort.SliceStable(cols, func(i, j int) bool {
+			return cols[i].Field < cols[j].Field
+		})
+	}
+	return cols, nil
+}
+
+func (metadata *TableMetadata) ReorderColumns(names []string) error {
+	// Moves the named columns to the front, in the given order, followed by the rest
+	// in their current order, and regenerates the statements to match. Call this
+	// before the metadata is shared, as it is not safe alongside queries.
+	byName := make(map[string]ColumnMetadata, len(metadata.Columns))
+	for _, col := range metadata.Columns {
+		byName[col.Field] = col
+	}
+	cols := make([]ColumnMetadata, 0, len(metadata.Columns))
+	placed := map[string]bool{}
+	for _, name := range names {
+		col, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, name)
+		}
+		if placed[name] {
+			return fmt.Errorf("%w: %s.%s is listed twice", ErrInvalidColumn, metadata.Name, name)
+		}
+		placed[name] = true
+		cols = append(cols, col)
+	}
+	for _, col := range metadata.Columns {
+		if !placed[col.Field] {
+			cols = append(cols, col)
+		}
+	}
+	metadata.Columns = cols
+	metadata.buildStatements()
+	return nil
+}
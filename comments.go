@@ -0,0 +1,63 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func GetTableComments(db *sql.DB, tableNames ...string) (map[string]string, error) {
+	// Reads the comments of several tables of the current schema, by table name.
+	// Tables without a comment are left out.
+	if 0 == len(tableNames) {
+		return map[string]string{}, nil
+	}
+	filter, args, names, err := tableArgs(tableNames)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT "+SQL_TABLE_OF+", TABLE_COMMENT FROM information_schema.TABLES "+
+		"WHERE "+filter, args...)
+	if nil != err {
+		return nil, fmt.Errorf("comments of %v: %w", tableNames, err)
+	}
+	defer rows.Close()
+	comments := map[string]string{}
+	for rows.Next() {
+		var schema, table string
+		var current bool
+		var comment sql.NullString
+		if err = rows.Scan(&schema, &table, &current, &comment); nil != err {
+			return nil, fmt.Errorf("comments of %v: %w", tableNames, err)
+		}
+		if "" == comment.String {
+			continue
+		}
+		for _, name := range names.lookup(schema, table, current) {
+			comments[name] = comment.String
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("comments of %v: %w", tableNames, err)
+	}
+	return comments, nil
+}
+
+func GetTableComment(db *sql.DB, tableName string) (string, error) {
+	comments, err := GetTableComments(db, tableName)
+	if nil != err {
+		return "", err
+	}
+	return comments[tableName], nil
+}
+
+func (metadata TableMetadata) ColumnComments() map[string]string {
+	// Returns the comments of the columns that have one, by column name, ex. for
+	// generated documentation.
+	comments := map[string]string{}
+	for _, col := range metadata.Columns {
+		if "" != col.Comment {
+			comments[col.Field] = col.Comment
+		}
+	}
+	return comments
+}
@@ -0,0 +1,55 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func namedTargets(rows *sql.Rows, dest map[string]interface{}) ([]interface{}, error) {
+	// Returns the arguments for rows.Scan that put the columns into dest by name,
+	// ignoring case, and discard the others, ex. the Visible and Expression of SHOW
+	// INDEXES on MySQL 8, Ignored on MariaDB or Clustered on TiDB, which the output of
+	// SHOW statements differs by. Each column of dest must be in the result.
+	names, err := rows.Columns()
+	if nil != err {
+		return nil, err
+	}
+	targets := make([]interface{}, len(names))
+	found := 0
+	for i, name := range names {
+		if target, ok := dest[strings.ToLower(name)]; ok {
+			targets[i] = target
+			found++
+		} else {
+			targets[i] = new(sql.RawBytes)
+		}
+	}
+	if found < len(dest) {
+		return nil, fmt.Errorf("expected the columns %v, got %v", sortedKeys(dest), names)
+	}
+	return targets, nil
+}
+
+func (version *ServerVersion) normalizeDefault(raw sql.NullString, extra string) (sql.NullString, string) {
+	// MariaDB quotes literal defaults in information_schema.COLUMNS, shows DEFAULT NULL
+	// as the text NULL and expressions as they are, so these are brought to the form of
+	// MySQL: literals unquoted and expressions marked DEFAULT_GENERATED in extra.
+	if nil == version || FLAVOR_MARIADB != version.Flavor || !raw.Valid {
+		return raw, extra
+	}
+	text := raw.String
+	switch {
+	case "NULL" == text:
+		return sql.NullString{}, extra
+	case 2 <= len(text) && '\'' == text[0] && '\'' == text[len(text)-1]:
+		return sql.NullString{String: strings.ReplaceAll(text[1:len(text)-1], "''", "'"), Valid: true}, extra
+	case SQL_BIT_LITERAL.MatchString(text):
+		return raw, extra
+	}
+	if _, err := strconv.ParseFloat(text, 64); nil == err {
+		return raw, extra
+	}
+	return raw, strings.TrimSpace(extra + " DEFAULT_GENERATED")
+}
@@ -0,0 +1,175 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Config gathers the tunable behaviour of the package. Configs are layered: the
+// package config set with SetConfig, then a Registry's Config and its TableConfigs,
+// then the Config of a TableMetadata, with each layer overriding the fields it sets.
+// The options set directly on a TableMetadata, ex. Logger, override them all.
+type Config struct {
+	Logger Logger
+	// Observer is told of each statement run - see QueryObserver
+	Observer QueryObserver
+	// Tracer starts a span around each statement run - see Tracer
+	Tracer Tracer
+	// QueryTagger adds tags to the statements of TagQueries - see TableMetadata
+	QueryTagger func(ctx context.Context) []QueryTag
+	// NamingStrategy matches columns to struct fields, DefaultNaming if nil
+	NamingStrategy NamingStrategy
+	// Replicas take the reads of the tables - see TableMetadata
+	Replicas []*sql.DB
+	// Breaker fails the statements of the tables fast while the database is down
+	Breaker *CircuitBreaker
+	// Dialect adapts the tables to the server, DialectMySQL if not set
+	Dialect Dialect
+	// Policies sets the timeouts and retries of each class of operation
+	Policies map[OperationClass]OperationPolicy
+	// Strict makes mismatched column types fail FetchTableMetadata rather than warn
+	Strict          *bool
+	CacheStatements *bool
+	TagQueries      *bool
+	SQLCommenter    *bool
+	// AllowUnmappedColumns and AllowExtraFields relax the matching of columns and
+	// fields - see the TableMetadata options of the same names
+	AllowUnmappedColumns *bool
+	AllowExtraFields     *bool
+}
+
+func Bool(b bool) *bool {
+	// For the optional flags of a Config, ex. Config{Strict: mysqlmeta.Bool(true)}.
+	return &b
+}
+
+func (config Config) Merge(override Config) Config {
+	// Returns config with the fields set in override replacing its own.
+	// Policies are merged per class of operation.
+	if nil != override.Logger {
+		config.Logger = override.Logger
+	}
+	if nil != override.Observer {
+		config.Observer = override.Observer
+	}
+	if nil != override.Replicas {
+		config.Replicas = override.Replicas
+	}
+	if nil != override.Breaker {
+		config.Breaker = override.Breaker
+	}
+	if nil != override.Tracer {
+		config.Tracer = override.Tracer
+	}
+	if DialectMySQL != override.Dialect {
+		config.Dialect = override.Dialect
+	}
+	if nil != override.QueryTagger {
+		config.QueryTagger = override.QueryTagger
+	}
+	if nil != override.NamingStrategy {
+		config.NamingStrategy = override.NamingStrategy
+	}
+	if 0 < len(override.Policies) {
+		policies := make(map[OperationClass]OperationPolicy, len(config.Policies)+len(override.Policies))
+		for class, policy := range config.Policies {
+			policies[class] = policy
+		}
+		for class, policy := range override.Policies {
+			policies[class] = policy
+		}
+		config.Policies = policies
+	}
+	if nil != override.Strict {
+		config.Strict = override.Strict
+	}
+	if nil != override.CacheStatements {
+		config.CacheStatements = override.CacheStatements
+	}
+	if nil != override.TagQueries {
+		config.TagQueries = override.TagQueries
+	}
+	if nil != override.SQLCommenter {
+		config.SQLCommenter = override.SQLCommenter
+	}
+	if nil != override.AllowUnmappedColumns {
+		config.AllowUnmappedColumns = override.AllowUnmappedColumns
+	}
+	if nil != override.AllowExtraFields {
+		config.AllowExtraFields = override.AllowExtraFields
+	}
+	return config
+}
+
+var (
+	configMutex   sync.RWMutex
+	packageConfig Config
+)
+
+func SetConfig(config Config) {
+	// Replaces the package-wide config, used by metadata fetched from then on.
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	packageConfig = config
+}
+
+func GetConfig() Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+	return packageConfig
+}
+
+func (config Config) naming() NamingStrategy {
+	if nil == config.NamingStrategy {
+		return DefaultNaming
+	}
+	return config.NamingStrategy
+}
+
+func (config Config) strict() bool {
+	return nil != config.Strict && *config.Strict
+}
+
+func (metadata *TableMetadata) applyConfig() Config {
+	// Resolves the layers of config and fills in the options not set directly.
+	config := GetConfig().Merge(metadata.Config)
+	if nil == metadata.Logger {
+		metadata.Logger = config.Logger
+	}
+	if nil == metadata.Observer {
+		metadata.Observer = config.Observer
+	}
+	if nil == metadata.Replicas {
+		metadata.Replicas = config.Replicas
+	}
+	if nil == metadata.Breaker {
+		metadata.Breaker = config.Breaker
+	}
+	if nil == metadata.Tracer {
+		metadata.Tracer = config.Tracer
+	}
+	if DialectMySQL == metadata.Dialect {
+		metadata.Dialect = config.Dialect
+	}
+	if nil == metadata.QueryTagger {
+		metadata.QueryTagger = config.QueryTagger
+	}
+	metadata.Policies = config.Merge(Config{Policies: metadata.Policies}).Policies
+	if nil != config.CacheStatements && !metadata.CacheStatements {
+		metadata.CacheStatements = *config.CacheStatements
+	}
+	if nil != config.TagQueries && !metadata.TagQueries {
+		metadata.TagQueries = *config.TagQueries
+	}
+	if nil != config.SQLCommenter && !metadata.SQLCommenter {
+		metadata.SQLCommenter = *config.SQLCommenter
+	}
+	if nil != config.AllowUnmappedColumns && !metadata.AllowUnmappedColumns {
+		metadata.AllowUnmappedColumns = *config.AllowUnmappedColumns
+	}
+	if nil != config.AllowExtraFields && !metadata.AllowExtraFields {
+		metadata.AllowExtraFields = *config.AllowExtraFields
+	}
+	return config
+}
@@ -0,0 +1,91 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Session tracks the writes made through a request's context, so that reads later
+// in the same request can be sure to see them: a replica is only used once it
+// has executed the GTIDs captured after the last write, otherwise the read
+// should go to the primary.
+type Session struct {
+	mu    sync.Mutex
+	wrote bool
+	gtid  string
+}
+
+type sessionKey struct{}
+
+func WithSession(ctx context.Context) context.Context {
+	// Returns a context whose writes are recorded in a new Session.
+	return context.WithValue(ctx, sessionKey{}, &Session{})
+}
+
+func GetSession(ctx context.Context) *Session {
+	// Returns the Session of the context, or nil if it did not come from WithSession.
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+func (session *Session) Token() string {
+	// Returns the GTID set captured after the latest write, empty if there was none
+	// or the primary does not have GTIDs enabled.
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.gtid
+}
+
+func (session *Session) Wrote() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.wrote
+}
+
+func (session *Session) capture(ctx context.Context, primary *sql.DB) error {
+	// Records the primary's executed GTIDs, which include the write just made.
+	var gtid string
+	err := primary.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtid)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.wrote = true
+	if nil != err {
+		// without a token, reads must fall back to the primary
+		session.gtid = ""
+		return fmt.Errorf("capture gtid: %w", err)
+	}
+	session.gtid = gtid
+	return nil
+}
+
+func (session *Session) ReplicaReady(ctx context.Context, replica *sql.DB, timeout time.Duration) (bool, error) {
+	// Reports whether the replica has caught up with the session's writes, waiting up
+	// to timeout for it with WAIT_FOR_EXECUTED_GTID_SET. When it returns false the
+	// read should use the primary instead.
+	if nil == session || !session.Wrote() {
+		return true, nil
+	}
+	gtid := session.Token()
+	if "" == gtid {
+		return false, nil
+	}
+	var timedOut int
+	err := replica.QueryRowContext(ctx, "SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)", gtid, timeout.Seconds()).Scan(&timedOut)
+	if nil != err {
+		return false, fmt.Errorf("wait for gtid set: %w", err)
+	}
+	return 0 == timedOut, nil
+}
+
+func (metadata TableMetadata) recordWrite(ctx context.Context) {
+	session := GetSession(ctx)
+	if nil == session {
+		return
+	}
+	if err := session.capture(ctx, metadata.DB); nil != err {
+		metadata.logf(LogWarn, "read-your-writes for %s falls back to the primary: %v", metadata.Name, err)
+	}
+}
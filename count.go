@@ -0,0 +1,56 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+)
+
+func (metadata TableMetadata) Count(clause string, v ...interface{}) (int64, error) {
+	return metadata.CountContext(context.Background(), clause, v...)
+}
+
+func (metadata TableMetadata) CountContext(ctx context.Context, clause string, v ...interface{}) (int64, error) {
+	// Returns the number of rows matching the clause, ex. " WHERE price < ?".
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := "SELECT COUNT(*) FROM " + QuoteTableName(metadata.Name) + " " + clause
+	var count int64
+	err := metadata.queryScalar(ctx, &count, query, v...)
+	if nil != err {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (metadata TableMetadata) Exists(clause string, v ...interface{}) (bool, error) {
+	return metadata.ExistsContext(context.Background(), clause, v...)
+}
+
+func (metadata TableMetadata) ExistsContext(ctx context.Context, clause string, v ...interface{}) (bool, error) {
+	// Reports whether any row matches the clause, which must not have its own LIMIT.
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := "SELECT 1 FROM " + QuoteTableName(metadata.Name) + " " + clause + " LIMIT 1"
+	var one int
+	err := metadata.queryScalar(ctx, &one, query, v...)
+	if nil != err {
+		return false, err
+	}
+	return 1 == one, nil
+}
+
+func (metadata TableMetadata) queryScalar(ctx context.Context, dest interface{}, query string, v ...interface{}) error {
+	// Scans the single value of the first row into dest, leaving it as is if there is no row.
+	rows, err := metadata.query(ctx, query, v...)
+	if nil != err {
+		return fmt.Errorf("error making given query %s: %w", query, err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(dest); nil != err {
+			return fmt.Errorf("error making given query %s: %w", query, err)
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return fmt.Errorf("error making given query %s: %w", query, err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TableOptions holds what SHOW CREATE TABLE tells beyond the columns and indexes.
+type TableOptions struct {
+	Engine        string `json:"engine,omitempty"`
+	Charset       string `json:"charset,omitempty"`
+	Collation     string `json:"collation,omitempty"`
+	AutoIncrement uint64 `json:"auto_increment,omitempty"`
+	RowFormat     string `json:"row_format,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+	// Partitioning is the PARTITION BY clause as written by the server, if any
+	Partitioning string            `json:"partitioning,omitempty"`
+	Constraints  []TableConstraint `json:"constraints,omitempty"`
+}
+
+// TableConstraint is a named CONSTRAINT clause, ex. {Name: "positive_price",
+// Kind: "CHECK", Definition: "((`price` > 0))"}.
+type TableConstraint struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Definition string `json:"definition"`
+	// NotEnforced is set for CHECK constraints the server does not enforce
+	NotEnforced bool `json:"not_enforced,omitempty"`
+}
+
+// treat as const
+var SQL_TABLE_OPTION = regexp.MustCompile("(?i)(ENGINE|AUTO_INCREMENT|(?:DEFAULT )?CHARSET|COLLATE|ROW_FORMAT)=(\\w+)")
+var SQL_TABLE_COMMENT = regexp.MustCompile("(?i)COMMENT='((?:[^'\\\\]|''|\\\\.)*)'")
+var SQL_VERSION_COMMENT = regexp.MustCompile("/\\*!\\d+\\s*$")
+var SQL_CONSTRAINT = regexp.MustCompile("(?is)^CONSTRAINT `([^`]+)` (FOREIGN KEY|CHECK) (.*?)( /\\*!\\d+ NOT ENFORCED \\*/)?$")
+
+func GetCreateTable(db *sql.DB, tableName string) (string, error) {
+	// Returns the CREATE TABLE statement that SHOW CREATE TABLE gives for the table.
+	err := CheckTableName(tableName)
+	if nil != err {
+		return "", err
+	}
+	var name, ddl string
+	err = db.QueryRow("SHOW CREATE TABLE "+QuoteTableName(tableName)).Scan(&name, &ddl)
+	if nil != err {
+		return "", fmt.Errorf("show create table %s: %w", tableName, err)
+	}
+	return ddl, nil
+}
+
+func splitTopLevel(s string) []string {
+	// Splits s at the commas outside of quotes and parentheses.
+	parts := []string{}
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 0 != quote:
+			if '\\' == c && '`' != quote {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case '\'' == c || '"' == c || '`' == c:
+			quote = c
+		case '(' == c:
+			depth++
+		case ')' == c:
+			depth--
+		case ',' == c && 0 == depth:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func splitCreateTable(ddl string) (string, string, error) {
+	// Returns the definitions between the outer parentheses, and the options after them.
+	open := strings.Index(ddl, "(")
+	if 0 > open {
+		return "", "", fmt.Errorf("%w: no column definitions in CREATE TABLE", ErrInvalidDDL)
+	}
+	depth := 0
+	var quote byte
+	for i := open; i < len(ddl); i++ {
+		c := ddl[i]
+		switch {
+		case 0 != quote:
+			if '\\' == c && '`' != quote {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case '\'' == c || '"' == c || '`' == c:
+			quote = c
+		case '(' == c:
+			depth++
+		case ')' == c:
+			depth--
+			if 0 == depth {
+				return ddl[open+1 : i], ddl[i+1:], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("%w: unbalanced parentheses in CREATE TABLE", ErrInvalidDDL)
+}
+
+func ParseTableOptions(ddl string) (TableOptions, error) {
+	// Parses the table options, partitioning and named constraints of a CREATE TABLE
+	// statement as written by SHOW CREATE TABLE.
+	options := TableOptions{}
+	body, rest, err := splitCreateTable(ddl)
+	if nil != err {
+		return options, err
+	}
+	if at := strings.Index(strings.ToUpper(rest), "PARTITION BY"); 0 <= at {
+		partitioning := rest[at:]
+		// MySQL wraps partitioning in a version comment, ex. /*!50100 PARTITION BY ... */
+		partitioning = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(partitioning), "*/"))
+		options.Partitioning = partitioning
+		rest = SQL_VERSION_COMMENT.ReplaceAllString(rest[:at], "")
+	}
+	if m := SQL_TABLE_COMMENT.FindStringSubmatchIndex(rest); nil != m {
+		options.Comment = strings.ReplaceAll(rest[m[2]:m[3]], "''", "'")
+		// the comment may itself contain text that looks like options
+		rest = rest[:m[0]] + rest[m[1]:]
+	}
+	for _, m := range SQL_TABLE_OPTION.FindAllStringSubmatch(rest, -1) {
+		switch strings.ToUpper(m[1]) {
+		case "ENGINE":
+			options.Engine = m[2]
+		case "AUTO_INCREMENT":
+			options.AutoIncrement, _ = strconv.ParseUint(m[2], 10, 64)
+		case "CHARSET", "DEFAULT CHARSET":
+			options.Charset = m[2]
+		case "COLLATE":
+			options.Collation = m[2]
+		case "ROW_FORMAT":
+			options.RowFormat = m[2]
+		}
+	}
+	for _, definition := range splitTopLevel(body) {
+		if m := SQL_CONSTRAINT.FindStringSubmatch(definition); nil != m {
+			options.Constraints = append(options.Constraints, TableConstraint{
+				Name:        m[1],
+				Kind:        strings.ToUpper(m[2]),
+				Definition:  m[3],
+				NotEnforced: "" != m[4],
+			})
+		}
+	}
+	return options, nil
+}
+
+func (metadata *TableMetadata) LoadTableOptions() error {
+	// Reads SHOW CREATE TABLE for the table into CreateTable and TableOptions.
+	ddl, err := GetCreateTable(metadata.DB, metadata.Name)
+	if nil != err {
+		return err
+	}
+	options, err := ParseTableOptions(ddl)
+	if nil != err {
+		return fmt.Errorf("create table of %s: %w", metadata.Name, err)
+	}
+	metadata.CreateTable = ddl
+	metadata.TableOptions = &options
+	return nil
+}
@@ -0,0 +1,243 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// treat as const
+var CSV_NULL = `\N`
+var CSV_BATCH_SIZE = 100
+
+// CSVOptions configures ImportCSV.
+type CSVOptions struct {
+	// Comma separates the fields, ',' if zero
+	Comma rune
+	// Null is the field for NULL, CSV_NULL as with LOAD DATA if empty
+	Null string
+	// BatchSize is the number of rows per INSERT, CSV_BATCH_SIZE if zero
+	BatchSize int
+	// Ignore skips rows that would duplicate a unique key, with INSERT IGNORE
+	Ignore bool
+}
+
+func (options CSVOptions) null() string {
+	if "" == options.Null {
+		return CSV_NULL
+	}
+	return options.Null
+}
+
+func (metadata TableMetadata) StreamCSV(w io.Writer, clause string, v ...interface{}) error {
+	return metadata.StreamCSVContext(context.Background(), w, clause, v...)
+}
+
+func (metadata TableMetadata) StreamCSVContext(ctx context.Context, w io.Writer, clause string, v ...interface{}) error {
+	// Writes the rows matching the clause as CSV, one row at a time, with a header of
+	// the selected column names in the order of SelectString. NULL is written as
+	// CSV_NULL, so that ImportCSV and LOAD DATA read it back as NULL.
+	rows, err := metadata.GetRowsContext(ctx, clause, v...)
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+	cols := metadata.scanTargets()
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.Field
+	}
+	writer := csv.NewWriter(w)
+	if err = writer.Write(header); nil != err {
+		return fmt.Errorf("csv %s: %w", metadata.Name, err)
+	}
+	values := make([]sql.RawBytes, len(cols))
+	pointers := make([]interface{}, len(cols))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(cols))
+	for rows.Next() {
+		if err = rows.Scan(pointers...); nil != err {
+			return fmt.Errorf("csv %s: %w", metadata.Name, err)
+		}
+		for i, value := range values {
+			if nil == value {
+				record[i] = CSV_NULL
+			} else {
+				record[i] = string(value)
+			}
+		}
+		if err = writer.Write(record); nil != err {
+			return fmt.Errorf("csv %s: %w", metadata.Name, err)
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return fmt.Errorf("csv %s: %w", metadata.Name, err)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvValue(col ColumnMetadata, field string) (interface{}, error) {
+	// Converts a CSV field to a value of the column type, so that a malformed number
+	// fails the import rather than being truncated by MySQL.
+	columnType := strings.ToLower(col.ColumnType)
+	switch {
+	case strings.HasPrefix(columnType, "tinyint(1)"):
+		switch strings.ToLower(field) {
+		case "1", "true":
+			return true, nil
+		case "0", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("not a boolean: %q", field)
+	case isIntegerColumn(columnType):
+		if strings.Contains(columnType, "unsigned") {
+			return strconv.ParseUint(field, 10, 64)
+		}
+		return strconv.ParseInt(field, 10, 64)
+	case strings.HasPrefix(columnType, "float") || strings.HasPrefix(columnType, "double"):
+		return strconv.ParseFloat(field, 64)
+	case strings.HasPrefix(columnType, "decimal"):
+		// passed as text to keep the precision
+		if _, err := strconv.ParseFloat(field, 64); nil != err {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+func (metadata TableMetadata) ImportCSV(r io.Reader, options CSVOptions) (int64, error) {
+	return metadata.ImportCSVContext(context.Background(), r, options)
+}
+
+func (metadata TableMetadata) ImportCSVContext(ctx context.Context, r io.Reader, options CSVOptions) (int64, error) {
+	// Inserts the rows of CSV whose header names the columns, in any order, in batches
+	// of multi-row INSERTs in one transaction, and returns the number of rows inserted.
+	// Fields are converted to the column types; a field that does not convert, or a
+	// header that is not an insertable column, fails the whole import. On a table
+	// scoped by tenant, the rows are those of the tenant of ctx.
+	reader := csv.NewReader(r)
+	if 0 != options.Comma {
+		reader.Comma = options.Comma
+	}
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, nil
+	} else if nil != err {
+		return 0, fmt.Errorf("csv %s: %w", metadata.Name, err)
+	}
+	byName := map[string]ColumnMetadata{}
+	for _, col := range metadata.Columns {
+		if !col.IsGenerated() {
+			byName[col.Field] = col
+		}
+	}
+	cols := make([]ColumnMetadata, len(header))
+	colnames := make([]string, len(header))
+	for i, name := range header {
+		col, ok := byName[name]
+		if !ok {
+			return 0, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, name)
+		}
+		cols[i] = col
+		colnames[i] = QuoteIdentifier(name)
+	}
+	// the rows of a table scoped by tenant are those of the tenant of the context,
+	// added when the CSV has no tenant column
+	tenantIndex := -1
+	if !metadata.unscoped && "" != metadata.TenantColumn {
+		if err = metadata.checkTenant(ctx); nil != err {
+			return 0, err
+		}
+		tenantIndex = len(header)
+		for i, name := range header {
+			if metadata.TenantColumn == name {
+				tenantIndex = i
+			}
+		}
+		if len(header) == tenantIndex {
+			cols = append(cols, byName[metadata.TenantColumn])
+			colnames = append(colnames, QuoteIdentifier(metadata.TenantColumn))
+		}
+	}
+	batchSize := options.BatchSize
+	if 0 >= batchSize {
+		batchSize = CSV_BATCH_SIZE
+	}
+	verb := "INSERT"
+	if options.Ignore {
+		verb = "INSERT IGNORE"
+	}
+	prefix := verb + " INTO " + QuoteTableName(metadata.Name) + " (" + strings.Join(colnames, ", ") + ") VALUES "
+	placeholders := "(?" + strings.Repeat(", ?", len(cols)-1) + ")"
+	null := options.null()
+	var imported int64
+	err = metadata.RunInTx(ctx, func(ctx context.Context) error {
+		values := []interface{}{}
+		count := 0
+		flush := func() error {
+			if 0 == count {
+				return nil
+			}
+			query := prefix + placeholders + strings.Repeat(", "+placeholders, count-1)
+			result, err := metadata.exec(ctx, query, values...)
+			if nil != err {
+				return fmt.Errorf("csv %s: %w", metadata.Name, err)
+			}
+			affected, _ := result.RowsAffected()
+			imported += affected
+			values = values[:0]
+			count = 0
+			return nil
+		}
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				return flush()
+			} else if nil != err {
+				return fmt.Errorf("csv %s: %w", metadata.Name, err)
+			}
+			line, _ := reader.FieldPos(0)
+			start := len(values)
+			for i, field := range record {
+				if null == field {
+					values = append(values, nil)
+					continue
+				}
+				value, err := csvValue(cols[i], field)
+				if nil != err {
+					return fmt.Errorf("csv %s line %d, column %s: %w", metadata.Name, line, cols[i].Field, err)
+				}
+				values = append(values, value)
+			}
+			if 0 <= tenantIndex {
+				if len(record) == tenantIndex {
+					values = append(values, nil)
+				}
+				tenant, err := metadata.fillTenantValue(ctx, values[start+tenantIndex])
+				if nil != err {
+					return fmt.Errorf("csv %s line %d: %w", metadata.Name, line, err)
+				}
+				values[start+tenantIndex] = tenant
+			}
+			count++
+			if count == batchSize {
+				if err = flush(); nil != err {
+					return err
+				}
+			}
+		}
+	})
+	if nil != err {
+		return 0, err
+	}
+	return imported, nil
+}
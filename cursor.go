@@ -0,0 +1,148 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A cursor records the keyset columns, the direction and the keys of the last row
+// of a page, as base64 of JSON so that callers treat it as opaque. Each key keeps
+// its type, so that binary keys and times survive the round trip.
+type cursor struct {
+	Cols       []string    `json:"c"`
+	Descending bool        `json:"d,omitempty"`
+	Keys       []cursorKey `json:"k"`
+}
+
+type cursorKey struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+const cursorTimeFormat = "2006-01-02 15:04:05.999999"
+
+func encodeCursorKey(v interface{}) (cursorKey, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if nil != err {
+			return cursorKey{}, err
+		}
+		v = value
+	}
+	switch k := v.(type) {
+	case int, int8, int16, int32, int64:
+		return cursorKey{"i", fmt.Sprint(k)}, nil
+	case uint, uint8, uint16, uint32, uint64:
+		return cursorKey{"u", fmt.Sprint(k)}, nil
+	case float32, float64:
+		return cursorKey{"f", fmt.Sprint(k)}, nil
+	case string:
+		return cursorKey{"s", k}, nil
+	case []byte:
+		return cursorKey{"b", base64.RawURLEncoding.EncodeToString(k)}, nil
+	case time.Time:
+		return cursorKey{"t", k.UTC().Format(cursorTimeFormat)}, nil
+	}
+	return cursorKey{}, fmt.Errorf("%w: cannot page by a key of type %T", ErrInvalidCursor, v)
+}
+
+func (key cursorKey) decode() (interface{}, error) {
+	var v interface{}
+	var err error
+	switch key.Type {
+	case "i":
+		v, err = strconv.ParseInt(key.Value, 10, 64)
+	case "u":
+		v, err = strconv.ParseUint(key.Value, 10, 64)
+	case "f":
+		v, err = strconv.ParseFloat(key.Value, 64)
+	case "s":
+		v = key.Value
+	case "b":
+		v, err = base64.RawURLEncoding.DecodeString(key.Value)
+	case "t":
+		v, err = time.Parse(cursorTimeFormat, key.Value)
+	default:
+		err = fmt.Errorf("unknown key type %q", key.Type)
+	}
+	if nil != err {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return v, nil
+}
+
+func EncodeCursor(cols []string, descending bool, keys []interface{}) (string, error) {
+	c := cursor{Cols: cols, Descending: descending}
+	for _, v := range keys {
+		key, err := encodeCursorKey(v)
+		if nil != err {
+			return "", err
+		}
+		c.Keys = append(c.Keys, key)
+	}
+	b, err := json.Marshal(c)
+	if nil != err {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func DecodeCursor(s string, cols []string, descending bool) ([]interface{}, error) {
+	// Returns the keys of a cursor, checking it was made for the same columns and direction.
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if nil != err {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err = json.Unmarshal(b, &c); nil != err {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if strings.Join(c.Cols, ",") != strings.Join(cols, ",") || c.Descending != descending || len(c.Keys) != len(cols) {
+		return nil, fmt.Errorf("%w: cursor is for (%s), not (%s)", ErrInvalidCursor,
+			strings.Join(c.Cols, ", "), strings.Join(cols, ", "))
+	}
+	keys := make([]interface{}, len(c.Keys))
+	for i, key := range c.Keys {
+		if keys[i], err = key.decode(); nil != err {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func (metadata TableMetadata) GetEntitiesAfterCursor(dest interface{}, after string, limit int, descending bool, cols ...string) (string, error) {
+	return metadata.GetEntitiesAfterCursorContext(context.Background(), dest, after, limit, descending, cols...)
+}
+
+func (metadata TableMetadata) GetEntitiesAfterCursorContext(ctx context.Context, dest interface{}, after string, limit int, descending bool, cols ...string) (string, error) {
+	// Pages through the table like GetEntitiesAfterKeys, in order of cols (the primary
+	// key if none are given), taking and returning an opaque cursor instead of keys.
+	// Pass "" for the first page. The returned cursor is "" after the last page.
+	if 0 == len(cols) {
+		cols = []string{"id"}
+	}
+	var keys []interface{}
+	if "" != after {
+		var err error
+		if keys, err = DecodeCursor(after, cols, descending); nil != err {
+			return "", err
+		}
+	}
+	slice, _, err := GetSliceValue(dest)
+	if nil != err {
+		return "", err
+	}
+	start := slice.Len()
+	next, err := metadata.GetEntitiesAfterKeysContext(ctx, dest, cols, keys, limit, descending)
+	if nil != err || nil == next || slice.Len()-start < limit {
+		// a short page is the last one
+		return "", err
+	}
+	return EncodeCursor(cols, descending, next)
+}
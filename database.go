@@ -0,0 +1,90 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+func GetTableNames(db *sql.DB) ([]string, error) {
+	// Lists the base tables of the current schema, leaving out views.
+	rows, err := db.Query("SELECT TABLE_NAME FROM information_schema.TABLES " +
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME")
+	if nil != err {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); nil != err {
+			return nil, fmt.Errorf("list tables: %w", err)
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	return names, nil
+}
+
+func GetAllTableMetadata(db *sql.DB) (map[string]*TableMetadata, error) {
+	// Returns the metadata of every table of the current schema, by table name, for
+	// tools that work on the database rather than on entities, ex. documentation,
+	// diffs or code generation. Without entity structs there are no fields, so the
+	// metadata describes columns, indexes, foreign keys and statements, but cannot
+	// scan entities. Tables whose names this package does not accept are skipped.
+	names, err := GetTableNames(db)
+	if nil != err {
+		return nil, err
+	}
+	valid := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := CheckTableName(name); nil != err {
+			logf(LogWarn, "skipping table %s: %v", name, err)
+			continue
+		}
+		valid = append(valid, name)
+	}
+	tables := make(map[string]*TableMetadata, len(valid))
+	for start := 0; start < len(valid); start += MAX_IN_VALUES {
+		end := start + MAX_IN_VALUES
+		if end > len(valid) {
+			end = len(valid)
+		}
+		chunk := valid[start:end]
+		columns, err := GetColumnsContext(context.Background(), db, chunk...)
+		if nil != err {
+			return nil, err
+		}
+		comments, err := GetTableComments(db, chunk...)
+		if nil != err {
+			return nil, err
+		}
+		for _, name := range chunk {
+			foreignKeys, err := GetForeignKeys(db, name)
+			if nil != err {
+				return nil, err
+			}
+			checks, err := GetCheckConstraints(db, name)
+			if nil != err {
+				return nil, err
+			}
+			cols := columns[name]
+			metadata := &TableMetadata{
+				DB:          db,
+				Name:        name,
+				BaseName:    name,
+				Comment:     comments[name],
+				Columns:     cols,
+				Indexes:     GroupIndexes(cols),
+				ForeignKeys: foreignKeys,
+
+				CheckConstraints: checks,
+			}
+			metadata.buildStatements()
+			tables[name] = metadata
+		}
+	}
+	return tables, nil
+}
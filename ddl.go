@@ -0,0 +1,334 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// treat as const
+var SQL_ON_UPDATE = regexp.MustCompile("(?i)on update (current_timestamp(\\(\\d*\\))?)")
+var nullStringType = reflect.TypeOf(sql.NullString{})
+var nullInt64Type = reflect.TypeOf(sql.NullInt64{})
+var nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
+var nullBoolType = reflect.TypeOf(sql.NullBool{})
+var jsonRawType = reflect.TypeOf(json.RawMessage{})
+
+func sqlColumnType(fieldType reflect.Type) (string, bool) {
+	// Returns the column type for a field type, and whether NULL is allowed, so that
+	// CheckFieldTypes accepts the field. Returns "" for types it does not know.
+	nullable := false
+	if reflect.Ptr == fieldType.Kind() {
+		fieldType = fieldType.Elem()
+		nullable = true
+	}
+	switch fieldType {
+	case timeType:
+		return "datetime", nullable
+	case nullTimeType:
+		return "datetime", true
+	case nullStringType:
+		return "varchar(255)", true
+	case nullInt64Type:
+		return "bigint", true
+	case nullFloat64Type:
+		return "double", true
+	case nullBoolType:
+		return "tinyint(1) unsigned", true
+	case jsonRawType:
+		return "text", nullable
+	}
+	if IsPassThroughType(fieldType) {
+		return "", nullable
+	}
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		return "tinyint(1) unsigned", nullable
+	case reflect.Int8:
+		return "tinyint", nullable
+	case reflect.Uint8:
+		return "tinyint unsigned", nullable
+	case reflect.Int16:
+		return "smallint", nullable
+	case reflect.Uint16:
+		return "smallint unsigned", nullable
+	case reflect.Int, reflect.Int32:
+		return "int", nullable
+	case reflect.Uint, reflect.Uint32:
+		return "int unsigned", nullable
+	case reflect.Int64:
+		return "bigint", nullable
+	case reflect.Uint64:
+		return "bigint unsigned", nullable
+	case reflect.Float32:
+		return "float", nullable
+	case reflect.Float64:
+		return "double", nullable
+	case reflect.String:
+		return "varchar(255)", nullable
+	case reflect.Slice:
+		if reflect.Uint8 == fieldType.Elem().Kind() {
+			return "blob", nullable
+		}
+	case reflect.Struct:
+		// JSON documents
+		return "text", nullable
+	}
+	return "", nullable
+}
+
+func unquoteTagValue(value string) string {
+	if 2 <= len(value) && '\'' == value[0] && '\'' == value[len(value)-1] {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+func readDdlTag(col *ColumnMetadata, tableName string, field reflect.StructField, indexes map[string]int) error {
+	// Reads the ddl tag of a field, ex. ddl:"type=decimal(10,2),default=0,index" or
+	// ddl:"unique=uq_sku,comment='stock keeping unit, as printed'". Values with commas
+	// are quoted. Fields with the same index name make one index, in field order.
+	tagString := field.Tag.Get("ddl")
+	if "" == tagString {
+		return nil
+	}
+	defaultValue := sql.NullString{}
+	for _, tag := range splitTopLevel(tagString) {
+		name, value, _ := strings.Cut(tag, "=")
+		value = unquoteTagValue(strings.TrimSpace(value))
+		switch name {
+		case "type":
+			col.ColumnType = value
+		case "null":
+			col.Nullable = "YES"
+		case "not-null":
+			col.Nullable = "NO"
+		case "default":
+			defaultValue = sql.NullString{String: value, Valid: true}
+		case "comment":
+			col.Comment = value
+		case "index", "unique":
+			if "" == value {
+				value = col.Field
+			}
+			indexes[value]++
+			col.Indexes = append(col.Indexes, IndexMetadata{
+				TableName:  tableName,
+				NonUnique:  "index" == name,
+				KeyName:    value,
+				SeqInIndex: uint(indexes[value]),
+				ColumnName: col.Field,
+				IndexType:  "BTREE",
+			})
+		default:
+			return fmt.Errorf("%w: ddl tag %q of field %s", ErrInvalidEntity, tag, field.Name)
+		}
+	}
+	if defaultValue.Valid {
+		col.DefaultValue = defaultValue.String
+		col.Default = ParseColumnDefault(defaultValue, col.ColumnType, "")
+	}
+	return nil
+}
+
+func (metadata *TableMetadata) DescribeStruct(tableName string, entity interface{}) error {
+	// Fills in the metadata from the struct alone, as FetchTableMetadata would from
+	// an existing table, for making the table with CreateTableStatement. Columns are
+	// named as the fields are matched, and typed so that CheckFieldTypes accepts them.
+	// The ddl tag sets what a field type cannot tell: the exact column type,
+	// nullability, default, comment and indexes. The id column is the primary key.
+	baseName := tableName
+	tableName = metadata.PrefixedName(tableName)
+	if err := CheckTableName(tableName); nil != err {
+		return err
+	}
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	config := metadata.applyConfig()
+	entityType := value.Type()
+	cols := []ColumnMetadata{}
+	fieldByColumn := map[string]int{}
+	indexes := map[string]int{}
+	for i := 0; i < entityType.NumField(); i++ {
+		field := entityType.Field(i)
+		if "" != field.PkgPath || isExcludedField(field) || "" != field.Tag.Get("sqlexpr") {
+			continue
+		}
+		name := sqlTagColumn(field)
+		if "" == name {
+			name = config.naming().ColumnName(field.Name)
+		}
+		col := ColumnMetadata{Field: name, Nullable: "NO"}
+		columnType, nullable := sqlColumnType(field.Type)
+		col.ColumnType = columnType
+		if nullable {
+			col.Nullable = "YES"
+		}
+		if "id" == name {
+			col.Key = "PRI"
+			col.Extra = "auto_increment"
+			col.Indexes = append(col.Indexes, IndexMetadata{TableName: tableName, KeyName: "PRIMARY",
+				SeqInIndex: 1, ColumnName: name, IndexType: "BTREE"})
+		}
+		if err = readDdlTag(&col, tableName, field, indexes); nil != err {
+			return err
+		}
+		if "" == col.ColumnType {
+			return fmt.Errorf("%w: no column type for field %s of type %v, set one with the ddl tag",
+				ErrInvalidEntity, field.Name, field.Type)
+		}
+		col.ReadSqlStructTags(field)
+		fieldByColumn[name] = i
+		cols = append(cols, col)
+	}
+	if _, ok := fieldByColumn["id"]; !ok {
+		return fmt.Errorf("%w: %s has no Id field", ErrNoPrimaryKey, entityType.Name())
+	}
+	detectAutoTimes(cols, entityType, fieldByColumn)
+	virtualCols, fieldByVirtual, err := readVirtualColumns(entityType)
+	if nil != err {
+		return err
+	}
+	*metadata = TableMetadata{
+		Name:           tableName,
+		BaseName:       baseName,
+		Comment:        metadata.Comment,
+		Columns:        cols,
+		EntityType:     entityType,
+		EntityTypeName: entityType.Name(),
+		FieldByColumn:  fieldByColumn,
+		VirtualColumns: virtualCols,
+		FieldByVirtual: fieldByVirtual,
+		Indexes:        GroupIndexes(cols),
+		TableOptions:   metadata.TableOptions,
+
+		Logger:           metadata.Logger,
+		Observer:         metadata.Observer,
+		Tracer:           metadata.Tracer,
+		QueryTagger:      metadata.QueryTagger,
+		TagQueries:       metadata.TagQueries,
+		SQLCommenter:     metadata.SQLCommenter,
+		Dialect:          metadata.Dialect,
+		SoftDelete:       metadata.SoftDelete,
+		TablePrefix:      metadata.TablePrefix,
+		SoftDeleteColumn: findSoftDeleteColumn(cols, metadata.SoftDelete),
+		VersionColumn:    findVersionColumn(cols),
+		TenantColumn:     findTenantColumn(cols),
+		Config:           metadata.Config,
+	}
+	metadata.buildStatements()
+	return nil
+}
+
+func (col ColumnMetadata) ColumnDefinition() string {
+	// Renders the column as in CREATE TABLE, ex. "`price` decimal(10,2) NOT NULL DEFAULT 0".
+	definition := QuoteIdentifier(col.Field) + " " + col.ColumnType
+	extra := strings.ToLower(col.Extra)
+	if col.IsGenerated() {
+		storage := "VIRTUAL"
+		if strings.Contains(extra, "stored") {
+			storage = "STORED"
+		}
+		definition += " GENERATED ALWAYS AS (" + col.GenerationExpression + ") " + storage
+	}
+	if "YES" == col.Nullable {
+		definition += " NULL"
+	} else {
+		definition += " NOT NULL"
+	}
+	if DefaultNone != col.Default.Kind {
+		definition += " DEFAULT " + col.Default.String()
+	}
+	if strings.Contains(extra, "auto_increment") {
+		definition += " AUTO_INCREMENT"
+	}
+	if m := SQL_ON_UPDATE.FindStringSubmatch(col.Extra); nil != m {
+		definition += " ON UPDATE " + strings.ToUpper(m[1])
+	}
+	if col.Invisible {
+		// in a versioned comment, as servers before MySQL 8.0.23 do not know it
+		definition += " /*!80023 INVISIBLE */"
+	}
+	if "" != col.Comment {
+		definition += " COMMENT '" + strings.ReplaceAll(col.Comment, "'", "''") + "'"
+	}
+	return definition
+}
+
+func (index IndexDefinition) Definition() string {
+	// Renders the index as in CREATE TABLE, ex. "UNIQUE KEY `uq_sku` (`sku`)".
+	columns := ""
+	for i, column := range index.Columns {
+		if 0 < i {
+			columns += ","
+		}
+		columns += QuoteIdentifier(column)
+		if i < len(index.SubParts) && 0 < index.SubParts[i] {
+			columns += fmt.Sprintf("(%d)", index.SubParts[i])
+		}
+	}
+	definition := ""
+	switch {
+	case index.IsPrimary():
+		definition = "PRIMARY KEY (" + columns + ")"
+	case "FULLTEXT" == index.IndexType || "SPATIAL" == index.IndexType:
+		definition = index.IndexType + " KEY " + QuoteIdentifier(index.Name) + " (" + columns + ")"
+	case index.Unique:
+		definition = "UNIQUE KEY " + QuoteIdentifier(index.Name) + " (" + columns + ")"
+	default:
+		definition = "KEY " + QuoteIdentifier(index.Name) + " (" + columns + ")"
+	}
+	if "" != index.Comment {
+		definition += " COMMENT '" + strings.ReplaceAll(index.Comment, "'", "''") + "'"
+	}
+	return definition
+}
+
+func (metadata TableMetadata) CreateTableStatement() string {
+	// Renders CREATE TABLE for the columns and indexes of the metadata, with the
+	// engine, charset, collation and comment of TableOptions when set.
+	definitions := []string{}
+	for _, col := range metadata.Columns {
+		definitions = append(definitions, col.ColumnDefinition())
+	}
+	for _, index := range metadata.indexes() {
+		definitions = append(definitions, index.Definition())
+	}
+	ddl := "CREATE TABLE " + QuoteTableName(metadata.Name) + " (\n  " + strings.Join(definitions, ",\n  ") + "\n)"
+	options := TableOptions{}
+	if nil != metadata.TableOptions {
+		options = *metadata.TableOptions
+	}
+	if "" != options.Engine {
+		ddl += " ENGINE=" + options.Engine
+	}
+	if "" != options.Charset {
+		ddl += " DEFAULT CHARSET=" + options.Charset
+	}
+	if "" != options.Collation {
+		ddl += " COLLATE=" + options.Collation
+	}
+	comment := metadata.Comment
+	if "" != options.Comment {
+		comment = options.Comment
+	}
+	if "" != comment {
+		ddl += " COMMENT='" + strings.ReplaceAll(comment, "'", "''") + "'"
+	}
+	return ddl
+}
+
+func (metadata TableMetadata) ExecCreateTable(ctx context.Context, db *sql.DB) error {
+	// Executes CreateTableStatement on the database, ex. for tests.
+	_, err := db.ExecContext(ctx, metadata.CreateTableStatement())
+	if nil != err {
+		return fmt.Errorf("create table %s: %w", metadata.Name, err)
+	}
+	return nil
+}
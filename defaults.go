@@ -0,0 +1,173 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DefaultKind int
+
+const (
+	// DefaultNone is a column without a DEFAULT clause, or with DEFAULT NULL
+	DefaultNone DefaultKind = iota
+	// DefaultLiteral is a constant - Value holds a string, int64, uint64 or float64
+	DefaultLiteral
+	// DefaultCurrentTimestamp is CURRENT_TIMESTAMP, with optional fractional seconds Precision
+	DefaultCurrentTimestamp
+	// DefaultExpression is a MySQL 8 expression default, kept as text in Expression
+	DefaultExpression
+)
+
+type ColumnDefault struct {
+	Kind       DefaultKind `json:"kind"`
+	Value      interface{} `json:"value,omitempty"`
+	Precision  int         `json:"precision,omitempty"`
+	Expression string      `json:"expression,omitempty"`
+}
+
+// treat as const
+var SQL_CURRENT_TIMESTAMP = regexp.MustCompile("(?i)^(current_timestamp|now|localtime|localtimestamp)(\\((\\d*)\\))?$")
+var SQL_BIT_LITERAL = regexp.MustCompile("^[bB]'([01]*)'$")
+var SQL_DECIMAL_TYPE = regexp.MustCompile("(?i)^(decimal|numeric)(\\(\\d+(,\\d+)?\\))?( unsigned)?$")
+
+func ParseColumnDefault(raw sql.NullString, columnType string, extra string) ColumnDefault {
+	// Interprets the Default of SHOW COLUMNS (or COLUMN_DEFAULT of information_schema),
+	// which is unquoted for literals on MySQL, and quoted on MariaDB.
+	if !raw.Valid || "NULL" == raw.String {
+		return ColumnDefault{Kind: DefaultNone}
+	}
+	text := strings.TrimSpace(raw.String)
+	// literals are unquoted, so DEFAULT 'now' of a string column looks like NOW
+	temporal := SQL_TIME_TYPE.MatchString(columnType) || strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED")
+	if m := SQL_CURRENT_TIMESTAMP.FindStringSubmatch(text); nil != m && temporal {
+		precision, _ := strconv.Atoi(m[3])
+		return ColumnDefault{Kind: DefaultCurrentTimestamp, Precision: precision}
+	}
+	if m := SQL_BIT_LITERAL.FindStringSubmatch(text); nil != m {
+		bits, err := strconv.ParseUint("0"+m[1], 2, 64)
+		if nil == err {
+			return ColumnDefault{Kind: DefaultLiteral, Value: bits}
+		}
+	}
+	if strings.Contains(strings.ToUpper(extra), "DEFAULT_GENERATED") {
+		return ColumnDefault{Kind: DefaultExpression, Expression: text}
+	}
+	if 2 <= len(text) && '\'' == text[0] && '\'' == text[len(text)-1] {
+		text = strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+		if !isNumericColumnType(columnType) {
+			return ColumnDefault{Kind: DefaultLiteral, Value: text}
+		}
+	}
+	switch {
+	case SQL_UINT_TYPE.MatchString(columnType):
+		if n, err := strconv.ParseUint(text, 10, 64); nil == err {
+			return ColumnDefault{Kind: DefaultLiteral, Value: n}
+		}
+	case SQL_INT_TYPE.MatchString(columnType):
+		if n, err := strconv.ParseInt(text, 10, 64); nil == err {
+			return ColumnDefault{Kind: DefaultLiteral, Value: n}
+		}
+	case SQL_FLOAT_TYPE.MatchString(columnType), SQL_DECIMAL_TYPE.MatchString(columnType):
+		if f, err := strconv.ParseFloat(text, 64); nil == err {
+			return ColumnDefault{Kind: DefaultLiteral, Value: f}
+		}
+	case 2 < len(text) && '(' == text[0] && ')' == text[len(text)-1]:
+		// MariaDB and MySQL 8 show expression defaults in parentheses
+		return ColumnDefault{Kind: DefaultExpression, Expression: text}
+	}
+	return ColumnDefault{Kind: DefaultLiteral, Value: text}
+}
+
+func isNumericColumnType(columnType string) bool {
+	return SQL_INT_TYPE.MatchString(columnType) || SQL_UINT_TYPE.MatchString(columnType) ||
+		SQL_FLOAT_TYPE.MatchString(columnType) || SQL_DECIMAL_TYPE.MatchString(columnType)
+}
+
+func (def ColumnDefault) String() string {
+	// Renders the default as it would appear in a DEFAULT clause of DDL.
+	switch def.Kind {
+	case DefaultLiteral:
+		switch v := def.Value.(type) {
+		case string:
+			return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return fmt.Sprint(def.Value)
+	case DefaultCurrentTimestamp:
+		if 0 < def.Precision {
+			return fmt.Sprintf("CURRENT_TIMESTAMP(%d)", def.Precision)
+		}
+		return "CURRENT_TIMESTAMP"
+	case DefaultExpression:
+		if strings.HasPrefix(def.Expression, "(") {
+			return def.Expression
+		}
+		return "(" + def.Expression + ")"
+	}
+	return "NULL"
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setFieldDefault(field reflect.Value, def ColumnDefault) error {
+	switch def.Kind {
+	case DefaultLiteral:
+		if timeType == field.Type() || (reflect.Ptr == field.Kind() && timeType == field.Type().Elem()) {
+			// literal dates such as '0000-00-00' have no useful time.Time value
+			return nil
+		}
+		return setFieldExprValue(field, def.Value)
+	case DefaultCurrentTimestamp:
+		now := reflect.ValueOf(time.Now().Truncate(time.Second / time.Duration(pow10(def.Precision))))
+		if timeType == field.Type() {
+			field.Set(now)
+		} else if reflect.Ptr == field.Kind() && timeType == field.Type().Elem() {
+			field.Set(reflect.New(timeType))
+			field.Elem().Set(now)
+		}
+	}
+	// expression defaults can only be evaluated by the server
+	return nil
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+func (metadata TableMetadata) ApplyDefaults(entity interface{}) error {
+	// Sets each field to the literal or CURRENT_TIMESTAMP default of its column.
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	for _, col := range metadata.Columns {
+		j, ok := metadata.FieldByColumn[col.Field]
+		if !ok || 0 > j {
+			continue
+		}
+		err = setFieldDefault(value.Field(j), col.Default)
+		if nil != err {
+			return fmt.Errorf("default for %s.%s: %w", metadata.Name, col.Field, err)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) NewEntity() (interface{}, error) {
+	// Returns a pointer to a new entity struct with the column defaults filled in.
+	if nil == metadata.EntityType {
+		return nil, fmt.Errorf("%w: no entity type for %s", ErrInvalidEntity, metadata.Name)
+	}
+	entity := reflect.New(metadata.EntityType).Interface()
+	return entity, metadata.ApplyDefaults(entity)
+}
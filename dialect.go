@@ -0,0 +1,53 @@
+package mysqlmeta
+
+import "reflect"
+
+// Dialect adapts the statements of the package to what a server in front of MySQL
+// supports.
+type Dialect int
+
+const (
+	DialectMySQL Dialect = iota
+	// DialectVitess is for Vitess, ex. PlanetScale, whose vtgate answers SHOW COLUMNS
+	// and SHOW INDEXES differently from MySQL: introspection only reads
+	// information_schema, and an insert the server returns no id for, as for a table
+	// without an auto-increment sequence, leaves the id of the entity as it was.
+	DialectVitess
+)
+
+func (dialect Dialect) String() string {
+	switch dialect {
+	case DialectMySQL:
+		return "mysql"
+	case DialectVitess:
+		return "vitess"
+	}
+	return "unknown"
+}
+
+func (metadata TableMetadata) informationSchemaOnly() bool {
+	return metadata.InformationSchema || DialectVitess == metadata.Dialect
+}
+
+func (metadata TableMetadata) setInsertedId(value reflect.Value, id uint) {
+	// Sets the id the server assigned to the inserted row into the field of the
+	// auto_increment column, unless Vitess assigned none. Without such a column, the
+	// row was inserted with the id of the entity.
+	if 0 == id && DialectVitess == metadata.Dialect {
+		return
+	}
+	col, ok := metadata.AutoIncrementColumn()
+	if !ok {
+		return
+	}
+	i, ok := metadata.FieldByColumn[col.Field]
+	if !ok || 0 > i {
+		return
+	}
+	switch field := value.Field(i); field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(int64(id))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	}
+}
@@ -0,0 +1,120 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SchemaDrift is one difference between a table as its entity was registered and as
+// it is now, ex. {Table: "product", Column: "price", Kind: "type", Expected: "int",
+// Actual: "decimal(10,2)"}. Kind is one of "missing table", "missing column",
+// "unmapped column", "type" or "nullable".
+type SchemaDrift struct {
+	Table    string `json:"table"`
+	Column   string `json:"column,omitempty"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (drift SchemaDrift) String() string {
+	s := drift.Table
+	if "" != drift.Column {
+		s += "." + drift.Column
+	}
+	s += ": " + drift.Kind
+	switch {
+	case "" != drift.Expected && "" != drift.Actual:
+		s += " (expected " + drift.Expected + ", got " + drift.Actual + ")"
+	case "" != drift.Expected:
+		s += " (expected " + drift.Expected + ")"
+	case "" != drift.Actual:
+		s += " (got " + drift.Actual + ")"
+	}
+	return s
+}
+
+// SchemaReport lists the drift found by ValidateSchema, in the order of the entities.
+type SchemaReport struct {
+	Drifts []SchemaDrift `json:"drifts"`
+}
+
+func (report SchemaReport) Err() error {
+	// Returns nil if there is no drift, otherwise an ErrColumnMismatch listing it, ex.
+	// to fail at startup with if err := report.Err(); nil != err { log.Fatal(err) }.
+	if 0 == len(report.Drifts) {
+		return nil
+	}
+	drifts := make([]string, len(report.Drifts))
+	for i, drift := range report.Drifts {
+		drifts[i] = drift.String()
+	}
+	return fmt.Errorf("%w: %s", ErrColumnMismatch, strings.Join(drifts, "; "))
+}
+
+func ValidateSchema(db *sql.DB, entities ...interface{}) (SchemaReport, error) {
+	return DefaultRegistry.ValidateSchema(db, entities...)
+}
+
+func (registry *Registry) ValidateSchema(db *sql.DB, entities ...interface{}) (SchemaReport, error) {
+	// Reads the tables of the registered entities again, or of all registered
+	// entities if none are given, and reports how they differ from when the entities
+	// were registered. Errors are only returned when the tables cannot be read.
+	tables := registry.Tables()
+	if 0 < len(entities) {
+		tables = make([]*TableMetadata, 0, len(entities))
+		for _, entity := range entities {
+			metadata, ok := registry.Lookup(entity)
+			if !ok {
+				return SchemaReport{}, fmt.Errorf("%w: %T is not registered", ErrInvalidEntity, entity)
+			}
+			tables = append(tables, metadata)
+		}
+	}
+	report := SchemaReport{Drifts: []SchemaDrift{}}
+	for _, metadata := range tables {
+		cols, err := metadata.getColumnsWithIndexes(db, metadata.Name)
+		if isMySQLError(err, ER_NO_SUCH_TABLE) || errors.Is(err, ErrInvalidTableName) {
+			report.Drifts = append(report.Drifts, SchemaDrift{Table: metadata.Name, Kind: "missing table"})
+			continue
+		} else if nil != err {
+			return report, err
+		}
+		report.Drifts = append(report.Drifts, metadata.drift(cols)...)
+	}
+	return report, nil
+}
+
+func (metadata TableMetadata) drift(live []ColumnMetadata) []SchemaDrift {
+	// Compares the columns read now with those read when the metadata was fetched.
+	drifts := []SchemaDrift{}
+	liveCols := map[string]ColumnMetadata{}
+	for _, col := range live {
+		liveCols[col.Field] = col
+	}
+	for _, col := range metadata.Columns {
+		current, ok := liveCols[col.Field]
+		switch {
+		case !ok:
+			drifts = append(drifts, SchemaDrift{Table: metadata.Name, Column: col.Field, Kind: "missing column",
+				Expected: col.ColumnType})
+			continue
+		case comparableType(col.ColumnType) != comparableType(current.ColumnType):
+			drifts = append(drifts, SchemaDrift{Table: metadata.Name, Column: col.Field, Kind: "type",
+				Expected: col.ColumnType, Actual: current.ColumnType})
+		}
+		if col.Nullable != current.Nullable {
+			drifts = append(drifts, SchemaDrift{Table: metadata.Name, Column: col.Field, Kind: "nullable",
+				Expected: col.Nullable, Actual: current.Nullable})
+		}
+	}
+	for _, col := range live {
+		if !metadata.IsColumn(col.Field) {
+			drifts = append(drifts, SchemaDrift{Table: metadata.Name, Column: col.Field, Kind: "unmapped column",
+				Actual: col.ColumnType})
+		}
+	}
+	return drifts
+}
@@ -0,0 +1,203 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Integer is any integer type, including Go enum types such as type Status int8.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+var enums sync.Map // reflect.Type -> map[int64]string
+
+func RegisterEnum[E Integer](names map[E]string) {
+	// Registers the allowed values of an integer enum type, with their names, ex.
+	// RegisterEnum(map[Status]string{StatusActive: "active", StatusClosed: "closed"}).
+	// Fields of the type are then checked on insert and update, and the names are
+	// listed in the column metadata. Register enums before fetching their tables.
+	values := make(map[int64]string, len(names))
+	for value, name := range names {
+		values[int64(value)] = name
+	}
+	var zero E
+	enums.Store(reflect.TypeOf(zero), values)
+}
+
+func enumNames(fieldType reflect.Type) map[int64]string {
+	if names, ok := enums.Load(fieldType); ok {
+		return names.(map[int64]string)
+	}
+	return nil
+}
+
+func enumInt(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	}
+	return v.Int()
+}
+
+func EnumName(value interface{}) (string, bool) {
+	// Returns the registered name of an enum value, ex. "active" for StatusActive.
+	v := reflect.ValueOf(value)
+	names := enumNames(v.Type())
+	if nil == names {
+		return "", false
+	}
+	name, ok := names[enumInt(v)]
+	return name, ok
+}
+
+func detectEnums(cols []ColumnMetadata, entityType reflect.Type, fieldByColumn map[string]int) {
+	for i, col := range cols {
+		if j, ok := fieldByColumn[col.Field]; ok && 0 <= j {
+			cols[i].Enum = enumNames(entityType.Field(j).Type)
+		}
+	}
+}
+
+func (col ColumnMetadata) checkEnum(tableName string, field reflect.Value) error {
+	if 0 == len(col.Enum) {
+		return nil
+	}
+	if _, ok := col.Enum[enumInt(field)]; !ok {
+		return fmt.Errorf("%w: %v for %s.%s", ErrInvalidEnumValue, field.Interface(), tableName, col.Field)
+	}
+	return nil
+}
+
+// treat as const
+var SQL_ENUM_TYPE = regexp.MustCompile("(?is)^(enum|set)\\((.*)\\)$")
+
+var stringSliceType = reflect.TypeOf([]string(nil))
+
+func parseAllowedValues(columnType string) []string {
+	// Returns the values of an ENUM or SET column type in order, ex. ["new", "it's"]
+	// for enum('new','it''s'), or nil for other types.
+	m := SQL_ENUM_TYPE.FindStringSubmatch(columnType)
+	if nil == m {
+		return nil
+	}
+	values := []string{}
+	list := m[2]
+	for 0 < len(list) && '\'' == list[0] {
+		value := strings.Builder{}
+		i := 1
+		for ; i < len(list); i++ {
+			if '\'' == list[i] {
+				if i+1 < len(list) && '\'' == list[i+1] {
+					value.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			value.WriteByte(list[i])
+		}
+		values = append(values, value.String())
+		if i < len(list) {
+			i++
+		}
+		list = strings.TrimPrefix(list[i:], ",")
+	}
+	return values
+}
+
+func (col ColumnMetadata) IsSet() bool {
+	// Tells whether the column is a SET, whose value is a comma-separated list of
+	// its allowed values.
+	return strings.HasPrefix(strings.ToLower(col.ColumnType), "set(")
+}
+
+func (col ColumnMetadata) allows(value string) bool {
+	// ENUM and SET values compare like the collation of the column, which is nearly
+	// always case-insensitive.
+	for _, allowed := range col.AllowedValues {
+		if strings.EqualFold(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (col ColumnMetadata) checkAllowedValues(tableName string, v interface{}) error {
+	// Rejects the values the server would refuse, or truncate to '' with warning 1265
+	// outside of strict mode. NULL is left for the server to check.
+	if 0 == len(col.AllowedValues) {
+		return nil
+	}
+	var values []string
+	switch s := v.(type) {
+	case string:
+		values = []string{s}
+	case *string:
+		if nil == s {
+			return nil
+		}
+		values = []string{*s}
+	case sql.NullString:
+		if !s.Valid {
+			return nil
+		}
+		values = []string{s.String}
+	case []string:
+		values = s
+	default:
+		return nil
+	}
+	if _, ok := v.([]string); !ok && col.IsSet() {
+		values = splitSet(values[0])
+	}
+	for _, value := range values {
+		if !col.allows(value) {
+			return fmt.Errorf("%w: %q for %s.%s", ErrInvalidEnumValue, value, tableName, col.Field)
+		}
+	}
+	return nil
+}
+
+func splitSet(s string) []string {
+	if "" == s {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// setField scans a SET column into a []string field, ex. "a,b" into ["a", "b"], and
+// NULL into nil.
+type setField struct {
+	values *[]string
+}
+
+func (field setField) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		*field.values = nil
+	case []byte:
+		*field.values = splitSet(string(s))
+	case string:
+		*field.values = splitSet(s)
+	default:
+		return fmt.Errorf("%w: cannot scan %T into a SET field", ErrInvalidColumn, src)
+	}
+	return nil
+}
+
+func isSetField(col ColumnMetadata, fieldType reflect.Type) bool {
+	return stringSliceType == fieldType && col.IsSet()
+}
+
+func scanDestination(col ColumnMetadata, field reflect.Value) interface{} {
+	// The address of the field to scan the column into, or of what converts it.
+	if isSetField(col, field.Type()) {
+		return setField{values: field.Addr().Interface().(*[]string)}
+	}
+	return field.Addr().Interface()
+}
@@ -0,0 +1,34 @@
+package mysqlmeta
+
+import (
+	"errors"
+)
+
+// Sentinel errors returned (usually wrapped with more detail) by this package.
+// Test for them with errors.Is, ex. errors.Is(err, mysqlmeta.ErrNotFound).
+var (
+	ErrNotFound          = errors.New("entity not found")
+	ErrMultipleRows      = errors.New("more than one row matched")
+	ErrInvalidTableName  = errors.New("invalid table name")
+	ErrInvalidColumn     = errors.New("invalid column name")
+	ErrInvalidEntity     = errors.New("invalid pointer argument")
+	ErrColumnMismatch    = errors.New("column does not match entity struct")
+	ErrNoPrimaryKey      = errors.New("no defined id")
+	ErrNotImplemented    = errors.New("not implemented yet")
+	ErrAlreadyRegistered = errors.New("entity type already registered")
+	ErrInvalidBinaryId   = errors.New("invalid binary id")
+	ErrNotIndexed        = errors.New("columns are not covered by an index")
+	ErrStaleEntity       = errors.New("entity was modified since it was read")
+	ErrInvalidEnumValue  = errors.New("value is not one of the enum's values")
+	ErrInvalidCursor     = errors.New("invalid pagination cursor")
+	ErrNoNaturalKey      = errors.New("no natural key declared")
+	ErrNoTransaction     = errors.New("not in a transaction")
+	ErrShutdown          = errors.New("registry is shut down")
+	ErrInvalidDDL        = errors.New("cannot parse DDL")
+	ErrInvalidFixtures   = errors.New("cannot parse fixtures")
+	ErrInvalidIndexHint  = errors.New("invalid index hint")
+	ErrCircuitOpen       = errors.New("circuit breaker is open")
+	ErrNoTenant          = errors.New("no tenant in context")
+	ErrNotRegistered     = errors.New("entity type not registered")
+	ErrUnsupported       = errors.New("not supported by the server version")
+)
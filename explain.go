@@ -0,0 +1,150 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExplainRow is one row of the plan EXPLAIN shows for a SELECT, for one table of it.
+type ExplainRow struct {
+	Id         int64
+	SelectType string
+	Table      string
+	Partitions string
+	// Type is the join type, from "system" and "const" down to "index" and "ALL"
+	Type         string
+	PossibleKeys []string
+	Key          string
+	KeyLen       string
+	Ref          string
+	// Rows is the number of rows MySQL estimates it must examine
+	Rows int64
+	// Filtered is the estimated percentage of the examined rows that match
+	Filtered float64
+	Extra    string
+}
+
+func (row ExplainRow) IsFullTableScan() bool {
+	// Reports whether every row of the table is read, which an index would avoid.
+	return "ALL" == row.Type
+}
+
+func (row ExplainRow) IsFullIndexScan() bool {
+	return "index" == row.Type
+}
+
+// ExplainPlan is the rows of EXPLAIN, in the order MySQL shows them.
+type ExplainPlan []ExplainRow
+
+func (plan ExplainPlan) FullTableScans() []ExplainRow {
+	scans := []ExplainRow{}
+	for _, row := range plan {
+		if row.IsFullTableScan() {
+			scans = append(scans, row)
+		}
+	}
+	return scans
+}
+
+func (metadata TableMetadata) Explain(clause string, v ...interface{}) (ExplainPlan, error) {
+	return metadata.ExplainContext(context.Background(), clause, v...)
+}
+
+func (metadata TableMetadata) ExplainContext(ctx context.Context, clause string, v ...interface{}) (ExplainPlan, error) {
+	// Returns the plan of the SELECT that GetEntities would run with the clause, ex.
+	// to check in a test that a clause does not scan the whole table:
+	//
+	//	plan, err := metadata.Explain(" WHERE sku = ?", "A-1")
+	//	if 0 < len(plan.FullTableScans()) { ... }
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := "EXPLAIN " + metadata.SelectString + clause
+	rows, err := metadata.query(ctx, query, v...)
+	if nil != err {
+		return nil, fmt.Errorf("explain %s: %w", metadata.Name, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if nil != err {
+		return nil, fmt.Errorf("explain %s: %w", metadata.Name, err)
+	}
+	// the columns differ between versions, ex. partitions and filtered are from 5.7
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	plan := ExplainPlan{}
+	for rows.Next() {
+		if err = rows.Scan(pointers...); nil != err {
+			return nil, fmt.Errorf("explain %s: %w", metadata.Name, err)
+		}
+		row := ExplainRow{}
+		for i, column := range columns {
+			value := values[i].String
+			switch strings.ToLower(column) {
+			case "id":
+				row.Id, _ = strconv.ParseInt(value, 10, 64)
+			case "select_type":
+				row.SelectType = value
+			case "table":
+				row.Table = value
+			case "partitions":
+				row.Partitions = value
+			case "type":
+				row.Type = value
+			case "possible_keys":
+				if "" != value {
+					row.PossibleKeys = strings.Split(value, ",")
+				}
+			case "key":
+				row.Key = value
+			case "key_len":
+				row.KeyLen = value
+			case "ref":
+				row.Ref = value
+			case "rows":
+				row.Rows, _ = strconv.ParseInt(value, 10, 64)
+			case "filtered":
+				row.Filtered, _ = strconv.ParseFloat(value, 64)
+			case "extra":
+				row.Extra = value
+			}
+		}
+		plan = append(plan, row)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("explain %s: %w", metadata.Name, err)
+	}
+	return plan, nil
+}
+
+func (metadata TableMetadata) ExplainAnalyze(ctx context.Context, clause string, v ...interface{}) (string, error) {
+	// Runs the SELECT with EXPLAIN ANALYZE (MySQL 8.0.18 and later), and returns the
+	// tree of the plan with the actual rows and timings. The query is executed, so
+	// beware of running it against large tables in production.
+	if err := metadata.checkFeature(FEATURE_EXPLAIN_ANALYZE); nil != err {
+		return "", err
+	}
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := "EXPLAIN ANALYZE " + metadata.SelectString + clause
+	rows, err := metadata.query(ctx, query, v...)
+	if nil != err {
+		return "", fmt.Errorf("explain analyze %s: %w", metadata.Name, err)
+	}
+	defer rows.Close()
+	tree := []string{}
+	for rows.Next() {
+		var line string
+		if err = rows.Scan(&line); nil != err {
+			return "", fmt.Errorf("explain analyze %s: %w", metadata.Name, err)
+		}
+		tree = append(tree, line)
+	}
+	if err = rows.Err(); nil != err {
+		return "", fmt.Errorf("explain analyze %s: %w", metadata.Name, err)
+	}
+	return strings.Join(tree, "\n"), nil
+}
@@ -0,0 +1,36 @@
+package mysqlmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+func (metadata TableMetadata) FindOrCreate(entity interface{}, match map[string]interface{}) (bool, error) {
+	return metadata.FindOrCreateContext(context.Background(), entity, match)
+}
+
+func (metadata TableMetadata) FindOrCreateContext(ctx context.Context, entity interface{}, match map[string]interface{}) (bool, error) {
+	// Fills in entity from the row matching the column values of match, which should
+	// be covered by a unique index, or inserts entity if there is none, and reports
+	// whether it was inserted. The entity must already hold the match values. When a
+	// concurrent caller inserts the same row first, the duplicate key error is caught
+	// and that row is read instead. Inside a REPEATABLE READ transaction the row of
+	// the other caller may not be visible yet, in which case ErrNotFound is returned.
+	_, err := metadata.GetEntityByKeyContext(ctx, entity, match)
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	_, err = metadata.InsertEntityContext(ctx, entity)
+	if nil == err {
+		return true, nil
+	}
+	if !isMySQLError(err, ER_DUP_ENTRY) {
+		return false, err
+	}
+	_, err = metadata.GetEntityByKeyContext(ctx, entity, match)
+	if nil != err {
+		return false, fmt.Errorf("find %s after duplicate insert: %w", metadata.Name, err)
+	}
+	return false, nil
+}
@@ -0,0 +1,341 @@
+package mysqlmeta
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FixtureTable is the rows of one table in a fixture file, each by column name.
+type FixtureTable struct {
+	Table string
+	Rows  []map[string]interface{}
+}
+
+// Fixtures are tables of rows, in the order they are inserted, so that rows
+// referenced by foreign keys come first.
+type Fixtures []FixtureTable
+
+func ParseFixtures(data []byte) (Fixtures, error) {
+	// Reads fixtures keyed by table, as JSON, ex. {"product": [{"sku": "A-1"}]}, or as
+	// YAML in block style, ex.
+	//
+	//	product:
+	//	  - sku: A-1
+	//	    name: ~
+	//
+	// Tables keep the order of the document. YAML scalars are null (~ or null), true,
+	// false, quoted strings or plain strings, which MySQL converts to the column type.
+	// Only this subset of YAML is read: each row is a "-" item of column: value lines
+	// at one indentation, with single-line values and plain keys. Flow collections
+	// other than [] and {}, block and multi-line scalars, anchors, aliases, tags and
+	// quoted keys fail with ErrInvalidFixtures rather than being read wrong.
+	trimmed := bytes.TrimSpace(data)
+	if 0 < len(trimmed) && '{' == trimmed[0] {
+		return parseJSONFixtures(trimmed)
+	}
+	return parseYAMLFixtures(data)
+}
+
+func parseJSONFixtures(data []byte) (Fixtures, error) {
+	// Decodes token by token to keep the order of the tables.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	fixtures := Fixtures{}
+	if _, err := decoder.Token(); nil != err {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFixtures, err)
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if nil != err {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFixtures, err)
+		}
+		table := FixtureTable{Table: token.(string)}
+		if err = decoder.Decode(&table.Rows); nil != err {
+			return nil, fmt.Errorf("%w: table %s: %v", ErrInvalidFixtures, table.Table, err)
+		}
+		fixtures = append(fixtures, table)
+	}
+	return fixtures, nil
+}
+
+func yamlUnsupported(text string) bool {
+	// Reports the YAML outside of the subset that ParseFixtures reads: flow
+	// collections, anchors, aliases, tags, block scalars, merge keys and the
+	// indicators of complex keys and directives.
+	return "" != text && (strings.ContainsRune("{[&*!|>?%@`", rune(text[0])) || strings.HasPrefix(text, "<<"))
+}
+
+func yamlScalar(value string) (interface{}, error) {
+	if yamlUnsupported(value) {
+		return nil, fmt.Errorf("unsupported YAML value %q", value)
+	}
+	switch {
+	case "~" == value || "null" == value || "" == value:
+		return nil, nil
+	case "true" == value:
+		return true, nil
+	case "false" == value:
+		return false, nil
+	case '"' == value[0]:
+		if unquoted, err := strconv.Unquote(value); nil == err {
+			return unquoted, nil
+		}
+		return nil, fmt.Errorf("unterminated or multi-line string %s", value)
+	case '\'' == value[0]:
+		if 2 > len(value) || '\'' != value[len(value)-1] {
+			return nil, fmt.Errorf("unterminated or multi-line string %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if i := strings.Index(value, " #"); 0 <= i {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+func parseYAMLFixtures(data []byte) (Fixtures, error) {
+	// Only the block style of a mapping of tables to sequences of mappings.
+	fixtures := Fixtures{}
+	var row map[string]interface{}
+	// the indentation of the columns of the row, -1 until its first column
+	indent := -1
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if "" == trimmed || '#' == trimmed[0] || "---" == trimmed {
+			continue
+		}
+		if len(trimmed) == len(line) {
+			name, rest, ok := strings.Cut(trimmed, ":")
+			rest = strings.TrimSpace(rest)
+			if !ok || ("" != rest && "[]" != rest) || yamlUnsupported(name) || strings.ContainsAny(name[:1], "'\"") {
+				return nil, fmt.Errorf("%w: line %d: expected a table name", ErrInvalidFixtures, number)
+			}
+			fixtures = append(fixtures, FixtureTable{Table: strings.TrimSpace(name), Rows: []map[string]interface{}{}})
+			row = nil
+			continue
+		}
+		if 0 == len(fixtures) {
+			return nil, fmt.Errorf("%w: line %d: row outside of a table", ErrInvalidFixtures, number)
+		}
+		table := &fixtures[len(fixtures)-1]
+		if strings.HasPrefix(trimmed, "-") {
+			row = map[string]interface{}{}
+			table.Rows = append(table.Rows, row)
+			indent = -1
+			item := strings.TrimLeft(trimmed[1:], " ")
+			if "" == item || "{}" == item {
+				continue
+			}
+			trimmed = item
+		}
+		if -1 == indent {
+			indent = len(line) - len(trimmed)
+		} else if len(line)-len(trimmed) != indent {
+			return nil, fmt.Errorf("%w: line %d: unsupported indentation, ex. a multi-line value", ErrInvalidFixtures, number)
+		}
+		column, value, ok := strings.Cut(trimmed, ":")
+		if !ok || nil == row || yamlUnsupported(column) || strings.ContainsAny(column[:1], "'\"-") {
+			return nil, fmt.Errorf("%w: line %d: expected column: value", ErrInvalidFixtures, number)
+		}
+		scalar, err := yamlScalar(strings.TrimSpace(value))
+		if nil != err {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidFixtures, number, err)
+		}
+		row[strings.TrimSpace(column)] = scalar
+	}
+	if err := scanner.Err(); nil != err {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFixtures, err)
+	}
+	return fixtures, nil
+}
+
+func fixtureValue(value interface{}) (interface{}, error) {
+	// JSON numbers are passed as written, and objects or arrays as JSON documents.
+	switch v := value.(type) {
+	case json.Number:
+		return v.String(), nil
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+	return value, nil
+}
+
+func LoadFixtures(ctx context.Context, db *sql.DB, r io.Reader) error {
+	// Inserts the rows of the fixtures read from r, in one transaction, table by table
+	// in the order of the document. Every table and column must exist in the current
+	// schema, else nothing is inserted.
+	data, err := io.ReadAll(r)
+	if nil != err {
+		return fmt.Errorf("read fixtures: %w", err)
+	}
+	fixtures, err := ParseFixtures(data)
+	if nil != err || 0 == len(fixtures) {
+		return err
+	}
+	names := []string{}
+	for _, table := range fixtures {
+		if err = CheckTableName(table.Table); nil != err {
+			return err
+		}
+		names = append(names, table.Table)
+	}
+	columns, err := GetSchemaColumns(db, names...)
+	if nil != err {
+		return err
+	}
+	for _, table := range fixtures {
+		if _, ok := columns[table.Table]; !ok {
+			return fmt.Errorf("%w: no table %s in the current schema", ErrInvalidTableName, table.Table)
+		}
+	}
+	return RunInTx(ctx, db, func(ctx context.Context) error {
+		for _, table := range fixtures {
+			metadata := TableMetadata{DB: db, Name: table.Table, BaseName: table.Table, Columns: columns[table.Table]}
+			for i, row := range table.Rows {
+				if err := metadata.insertFixture(ctx, row); nil != err {
+					return fmt.Errorf("fixture %s[%d]: %w", table.Table, i, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (metadata TableMetadata) insertFixture(ctx context.Context, row map[string]interface{}) error {
+	// Generated columns count as unknown, since they cannot be inserted.
+	insertable := map[string]bool{}
+	for _, col := range metadata.Columns {
+		insertable[col.Field] = !col.IsGenerated()
+	}
+	colnames := make([]string, 0, len(row))
+	for colname := range row {
+		if !insertable[colname] {
+			return fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+		colnames = append(colnames, colname)
+	}
+	sort.Strings(colnames)
+	values := make([]interface{}, len(colnames))
+	placeholders := make([]string, len(colnames))
+	for i, colname := range colnames {
+		value, err := fixtureValue(row[colname])
+		if nil != err {
+			return err
+		}
+		values[i] = value
+		placeholders[i] = "?"
+		colnames[i] = QuoteIdentifier(colname)
+	}
+	query := "INSERT INTO " + QuoteTableName(metadata.Name) + " (" + strings.Join(colnames, ", ") +
+		") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	_, err := metadata.exec(ctx, query, values...)
+	return err
+}
+
+func isIntegerColumn(columnType string) bool {
+	for _, prefix := range []string{"tinyint", "smallint", "mediumint", "int", "bigint"} {
+		if strings.HasPrefix(columnType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumericColumn(columnType string) bool {
+	for _, prefix := range []string{"decimal", "float", "double"} {
+		if strings.HasPrefix(columnType, prefix) {
+			return true
+		}
+	}
+	return isIntegerColumn(columnType)
+}
+
+func DumpFixtures(ctx context.Context, db *sql.DB, w io.Writer, tableNames ...string) error {
+	// Writes the rows of the tables as JSON fixtures that LoadFixtures reads back,
+	// in the order given and by primary key, with columns in table order. Generated
+	// columns are left out as they cannot be inserted.
+	for _, name := range tableNames {
+		if err := CheckTableName(name); nil != err {
+			return err
+		}
+	}
+	columns, err := GetSchemaColumns(db, tableNames...)
+	if nil != err {
+		return err
+	}
+	out := bytes.Buffer{}
+	out.WriteString("{")
+	for i, name := range tableNames {
+		cols, ok := columns[name]
+		if !ok {
+			return fmt.Errorf("%w: no table %s in the current schema", ErrInvalidTableName, name)
+		}
+		if 0 < i {
+			out.WriteString(",")
+		}
+		key, _ := json.Marshal(name)
+		out.WriteString("\n  " + string(key) + ": [")
+		if err = dumpTable(ctx, db, &out, name, cols); nil != err {
+			return err
+		}
+		out.WriteString("]")
+	}
+	out.WriteString("\n}\n")
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func dumpTable(ctx context.Context, db *sql.DB, out *bytes.Buffer, name string, cols []ColumnMetadata) error {
+	selected := []ColumnMetadata{}
+	colnames := []string{}
+	order := []string{}
+	for _, col := range cols {
+		if col.IsGenerated() {
+			continue
+		}
+		selected = append(selected, col)
+		colnames = append(colnames, QuoteIdentifier(col.Field))
+		if "PRI" == col.Key {
+			order = append(order, QuoteIdentifier(col.Field))
+		}
+	}
+	query := "SELECT " + strings.Join(colnames, ", ") + " FROM " + QuoteTableName(name)
+	if 0 < len(order) {
+		query += " ORDER BY " + strings.Join(order, ", ")
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if nil != err {
+		return fmt.Errorf("dump %s: %w", name, err)
+	}
+	defer rows.Close()
+	values := make([]sql.RawBytes, len(selected))
+	pointers := make([]interface{}, len(selected))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for count := 0; rows.Next(); count++ {
+		if err = rows.Scan(pointers...); nil != err {
+			return fmt.Errorf("dump %s: %w", name, err)
+		}
+		if 0 < count {
+			out.WriteString(",")
+		}
+		out.WriteString("\n    ")
+		out.Write(appendJSONObject(nil, selected, values))
+	}
+	if err = rows.Err(); nil != err {
+		return fmt.Errorf("dump %s: %w", name, err)
+	}
+	return nil
+}
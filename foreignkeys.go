@@ -0,0 +1,79 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type ForeignKeyMetadata struct {
+	ConstraintName    string   `json:"constraint_name"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	// UpdateRule and DeleteRule are one of CASCADE, SET NULL, RESTRICT, NO ACTION or SET DEFAULT
+	UpdateRule string `json:"update_rule,omitempty"`
+	DeleteRule string `json:"delete_rule,omitempty"`
+}
+
+func GetForeignKeys(db *sql.DB, tableName string) ([]ForeignKeyMetadata, error) {
+	// Returns the foreign keys of the table, with the columns of composite keys in order.
+	err := CheckTableName(tableName)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.Query(
+		"SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_SCHEMA <> k.TABLE_SCHEMA, k.REFERENCED_TABLE_SCHEMA, "+
+			"k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, "+
+			"r.UPDATE_RULE, r.DELETE_RULE "+
+			"FROM information_schema.KEY_COLUMN_USAGE k "+
+			"JOIN information_schema.REFERENTIAL_CONSTRAINTS r "+
+			"ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME "+
+			"AND r.TABLE_NAME = k.TABLE_NAME "+
+			"WHERE k.TABLE_SCHEMA = IFNULL(?, DATABASE()) AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL "+
+			"ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION",
+		tableIsArgs(tableName)...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("foreign keys for %s: %w", tableName, err)
+	}
+	defer rows.Close()
+	keys := []ForeignKeyMetadata{}
+	for rows.Next() {
+		var name, column, refSchema, refTable, refColumn, updateRule, deleteRule string
+		var otherSchema bool
+		err = rows.Scan(&name, &column, &otherSchema, &refSchema, &refTable, &refColumn, &updateRule, &deleteRule)
+		if nil != err {
+			return nil, fmt.Errorf("foreign keys for %s: %w", tableName, err)
+		}
+		// a table in another database is qualified with it
+		if otherSchema {
+			refTable = refSchema + "." + refTable
+		}
+		if n := len(keys); 0 == n || keys[n-1].ConstraintName != name {
+			keys = append(keys, ForeignKeyMetadata{
+				ConstraintName:  name,
+				ReferencedTable: refTable,
+				UpdateRule:      updateRule,
+				DeleteRule:      deleteRule,
+			})
+		}
+		key := &keys[len(keys)-1]
+		key.Columns = append(key.Columns, column)
+		key.ReferencedColumns = append(key.ReferencedColumns, refColumn)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("foreign keys for %s: %w", tableName, err)
+	}
+	return keys, nil
+}
+
+func (metadata TableMetadata) ForeignKeysTo(tableName string) []ForeignKeyMetadata {
+	// Returns the foreign keys of this table that reference the given table.
+	keys := []ForeignKeyMetadata{}
+	for _, key := range metadata.ForeignKeys {
+		if tableName == key.ReferencedTable {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,701 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GeneratedExpr is a parsed generated-column expression, limited to the simple
+// subset that can be evaluated client-side: column references, string and
+// numeric literals, + - * /, parentheses and a handful of string functions.
+type GeneratedExpr interface {
+	Eval(values map[string]interface{}) (interface{}, error)
+	Columns() []string
+}
+
+var errUnsupportedExpr = errors.New("unsupported generated column expression")
+
+type literalExpr struct {
+	value interface{}
+}
+
+type columnExpr struct {
+	name string
+}
+
+type unaryExpr struct {
+	operand GeneratedExpr
+}
+
+type binaryExpr struct {
+	op          byte
+	left, right GeneratedExpr
+}
+
+type funcExpr struct {
+	name string
+	args []GeneratedExpr
+}
+
+func (e literalExpr) Columns() []string { return nil }
+func (e columnExpr) Columns() []string  { return []string{e.name} }
+func (e unaryExpr) Columns() []string   { return e.operand.Columns() }
+func (e binaryExpr) Columns() []string  { return append(e.left.Columns(), e.right.Columns()...) }
+func (e funcExpr) Columns() []string {
+	cols := []string{}
+	for _, arg := range e.args {
+		cols = append(cols, arg.Columns()...)
+	}
+	return cols
+}
+
+func (e literalExpr) Eval(values map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e columnExpr) Eval(values map[string]interface{}) (interface{}, error) {
+	v, ok := values[e.name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown column %s", errUnsupportedExpr, e.name)
+	}
+	return v, nil
+}
+
+func (e unaryExpr) Eval(values map[string]interface{}) (interface{}, error) {
+	v, err := e.operand.Eval(values)
+	if nil != err || nil == v {
+		return nil, err
+	}
+	return arithmetic('-', int64(0), v)
+}
+
+func (e binaryExpr) Eval(values map[string]interface{}) (interface{}, error) {
+	left, err := e.left.Eval(values)
+	if nil != err {
+		return nil, err
+	}
+	right, err := e.right.Eval(values)
+	if nil != err {
+		return nil, err
+	}
+	// As in MySQL, arithmetic on NULL is NULL
+	if nil == left || nil == right {
+		return nil, nil
+	}
+	return arithmetic(e.op, left, right)
+}
+
+func (e funcExpr) Eval(values map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.Eval(values)
+		if nil != err {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch e.name {
+	case "coalesce", "ifnull":
+		for _, arg := range args {
+			if nil != arg {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}
+	for _, arg := range args {
+		if nil == arg {
+			return nil, nil
+		}
+	}
+	switch e.name {
+	case "concat":
+		result := ""
+		for _, arg := range args {
+			result += exprString(arg)
+		}
+		return result, nil
+	case "lower":
+		return strings.ToLower(exprString(args[0])), nil
+	case "upper":
+		return strings.ToUpper(exprString(args[0])), nil
+	case "trim":
+		// TRIM only removes spaces, not tabs or newlines
+		return strings.Trim(exprString(args[0]), " "), nil
+	case "abs":
+		n, err := exprNumber(args[0])
+		if nil != err {
+			return nil, err
+		}
+		if f, ok := n.(float64); ok {
+			if f < 0 {
+				return -f, nil
+			}
+			return f, nil
+		}
+		if i := n.(int64); i < 0 {
+			return -i, nil
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%w: function %s", errUnsupportedExpr, e.name)
+}
+
+var exprFuncArity = map[string]int{
+	"concat":   -1,
+	"coalesce": -1,
+	"ifnull":   2,
+	"lower":    1,
+	"upper":    1,
+	"trim":     1,
+	"abs":      1,
+}
+
+func exprString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func exprNumber(v interface{}) (interface{}, error) {
+	// Returns v as either int64 or float64, converting strings the way MySQL would.
+	switch t := v.(type) {
+	case int64, float64:
+		return t, nil
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t), nil
+		}
+		return float64(t), nil
+	case bool:
+		if t {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case string, []byte:
+		s := strings.TrimSpace(exprString(t))
+		if i, err := strconv.ParseInt(s, 10, 64); nil == err {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); nil == err {
+			return f, nil
+		}
+		return int64(0), nil
+	}
+	return nil, fmt.Errorf("%w: non-numeric operand %T", errUnsupportedExpr, v)
+}
+
+func arithmetic(op byte, left, right interface{}) (interface{}, error) {
+	l, err := exprNumber(left)
+	if nil != err {
+		return nil, err
+	}
+	r, err := exprNumber(right)
+	if nil != err {
+		return nil, err
+	}
+	li, lok := l.(int64)
+	ri, rok := r.(int64)
+	if lok && rok && '/' != op {
+		switch op {
+		case '+':
+			return li + ri, nil
+		case '-':
+			return li - ri, nil
+		case '*':
+			return li * ri, nil
+		}
+	}
+	lf, rf := toFloat(l), toFloat(r)
+	switch op {
+	case '+':
+		return lf + rf, nil
+	case '-':
+		return lf - rf, nil
+	case '*':
+		return lf * rf, nil
+	case '/':
+		if 0 == rf {
+			// MySQL returns NULL on division by zero
+			return nil, nil
+		}
+		return lf / rf, nil
+	}
+	return nil, fmt.Errorf("%w: operator %c", errUnsupportedExpr, op)
+}
+
+func toFloat(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func ParseGeneratedExpr(expr string) (GeneratedExpr, error) {
+	// Parses the GENERATION_EXPRESSION text from information_schema.COLUMNS,
+	// ex. concat(`first_name`,_utf8mb4' ',`last_name`)
+	p := &exprParser{input: expr}
+	e, err := p.parseSum()
+	if nil != err {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.input) {
+		return nil, fmt.Errorf("%w: unexpected %q", errUnsupportedExpr, p.input[p.pos:])
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) parseSum() (GeneratedExpr, error) {
+	left, err := p.parseProduct()
+	if nil != err {
+		return nil, err
+	}
+	for c := p.peek(); '+' == c || '-' == c; c = p.peek() {
+		p.pos++
+		right, err := p.parseProduct()
+		if nil != err {
+			return nil, err
+		}
+		left = binaryExpr{op: c, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseProduct() (GeneratedExpr, error) {
+	left, err := p.parseUnary()
+	if nil != err {
+		return nil, err
+	}
+	for c := p.peek(); '*' == c || '/' == c; c = p.peek() {
+		p.pos++
+		right, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		left = binaryExpr{op: c, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (GeneratedExpr, error) {
+	if '-' == p.peek() {
+		p.pos++
+		operand, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		return unaryExpr{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (GeneratedExpr, error) {
+	c := p.peek()
+	switch {
+	case 0 == c:
+		return nil, fmt.Errorf("%w: unexpected end of expression", errUnsupportedExpr)
+	case '(' == c:
+		p.pos++
+		e, err := p.parseSum()
+		if nil != err {
+			return nil, err
+		}
+		if ')' != p.peek() {
+			return nil, fmt.Errorf("%w: missing )", errUnsupportedExpr)
+		}
+		p.pos++
+		return e, nil
+	case '`' == c:
+		end := strings.IndexByte(p.input[p.pos+1:], '`')
+		if 0 > end {
+			return nil, fmt.Errorf("%w: unterminated identifier", errUnsupportedExpr)
+		}
+		name := p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return columnExpr{name: name}, nil
+	case '\'' == c || '"' == c:
+		s, err := p.parseString()
+		if nil != err {
+			return nil, err
+		}
+		return literalExpr{value: s}, nil
+	case '.' == c || ('0' <= c && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.input) && strings.IndexByte("0123456789.eE", p.input[p.pos]) >= 0 {
+			p.pos++
+		}
+		text := p.input[start:p.pos]
+		if i, err := strconv.ParseInt(text, 10, 64); nil == err {
+			return literalExpr{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if nil != err {
+			return nil, fmt.Errorf("%w: bad number %q", errUnsupportedExpr, text)
+		}
+		return literalExpr{value: f}, nil
+	case '_' == c || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.input) && ('_' == p.input[p.pos] || unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		word := p.input[start:p.pos]
+		// a character set introducer, ex. _utf8mb4'text'
+		if '_' == word[0] && p.pos < len(p.input) && '\'' == p.input[p.pos] {
+			s, err := p.parseString()
+			if nil != err {
+				return nil, err
+			}
+			return literalExpr{value: s}, nil
+		}
+		if '(' != p.peek() {
+			if strings.EqualFold("null", word) {
+				return literalExpr{value: nil}, nil
+			}
+			return columnExpr{name: word}, nil
+		}
+		return p.parseCall(strings.ToLower(word))
+	}
+	return nil, fmt.Errorf("%w: unexpected %q", errUnsupportedExpr, c)
+}
+
+func (p *exprParser) parseCall(name string) (GeneratedExpr, error) {
+	arity, ok := exprFuncArity[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: function %s", errUnsupportedExpr, name)
+	}
+	p.pos++ // skip (
+	args := []GeneratedExpr{}
+	for ')' != p.peek() {
+		if 0 < len(args) {
+			if ',' != p.peek() {
+				return nil, fmt.Errorf("%w: expected , in %s()", errUnsupportedExpr, name)
+			}
+			p.pos++
+		}
+		arg, err := p.parseSum()
+		if nil != err {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.pos++ // skip )
+	if (0 <= arity && len(args) != arity) || 0 == len(args) {
+		return nil, fmt.Errorf("%w: wrong argument count for %s()", errUnsupportedExpr, name)
+	}
+	return funcExpr{name: name, args: args}, nil
+}
+
+func (p *exprParser) parseString() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		p.pos++
+		switch {
+		case '\\' == c && p.pos < len(p.input):
+			sb.WriteByte(p.input[p.pos])
+			p.pos++
+		case quote == c && p.pos < len(p.input) && quote == p.input[p.pos]:
+			sb.WriteByte(quote)
+			p.pos++
+		case quote == c:
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("%w: unterminated string", errUnsupportedExpr)
+}
+
+// treat as const - the Extra of a generated column, but not DEFAULT_GENERATED, which
+// marks an expression default of MySQL 8
+var SQL_GENERATED_EXTRA = regexp.MustCompile("(?i)\\b(VIRTUAL|STORED|PERSISTENT) GENERATED\\b")
+
+func (col ColumnMetadata) IsGenerated() bool {
+	// Generated columns are computed by the server, so they are never inserted or
+	// updated.
+	return SQL_GENERATED_EXTRA.MatchString(col.Extra)
+}
+
+func GetGenerationExpressions(db *sql.DB, tableName string) (map[string]string, error) {
+	// Reads generation expressions for the generated columns of a table in the current schema.
+	err := CheckTableName(tableName)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.Query(
+		"SELECT COLUMN_NAME, GENERATION_EXPRESSION FROM information_schema.COLUMNS "+
+			"WHERE "+SQL_TABLE_IS+" AND GENERATION_EXPRESSION <> ''",
+		tableIsArgs(tableName)...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("generation expressions for %s: %w", tableName, err)
+	}
+	defer rows.Close()
+	exprs := map[string]string{}
+	for rows.Next() {
+		var name, expr string
+		if err = rows.Scan(&name, &expr); nil != err {
+			return nil, fmt.Errorf("generation expressions for %s: %w", tableName, err)
+		}
+		exprs[name] = expr
+	}
+	return exprs, rows.Err()
+}
+
+func fieldExprValue(field reflect.Value) interface{} {
+	// Converts a struct field to one of nil, int64, float64 or string for evaluation.
+	if valuer, ok := field.Interface().(driver.Valuer); ok {
+		if reflect.Ptr == field.Kind() && field.IsNil() {
+			return nil
+		}
+		v, err := valuer.Value()
+		if nil != err {
+			return nil
+		}
+		return v
+	}
+	if reflect.Ptr == field.Kind() {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		return field.Float()
+	case reflect.Bool:
+		return field.Bool()
+	case reflect.String:
+		return field.String()
+	}
+	return field.Interface()
+}
+
+func setFieldExprValue(field reflect.Value, v interface{}) error {
+	if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(v)
+	}
+	if reflect.Ptr == field.Kind() {
+		if nil == v {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if nil == v {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(exprString(v))
+		return nil
+	case reflect.Bool:
+		n, err := exprNumber(v)
+		if nil != err {
+			return err
+		}
+		field.SetBool(0 != toFloat(n))
+		return nil
+	}
+	if u, ok := v.(uint64); ok && reflect.Uint <= field.Kind() && field.Kind() <= reflect.Uint64 {
+		field.SetUint(u)
+		return nil
+	}
+	n, err := exprNumber(v)
+	if nil != err {
+		return err
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(int64(toFloat(n)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(toFloat(n)))
+	case reflect.Float32, reflect.Float64:
+		field.SetFloat(toFloat(n))
+	default:
+		return fmt.Errorf("%w: cannot assign to %v", errUnsupportedExpr, field.Type())
+	}
+	return nil
+}
+
+func (metadata TableMetadata) computeGeneratedColumns(value reflect.Value) error {
+	// Fills in the fields of generated columns that were left out of the SELECT.
+	if 0 == len(metadata.ComputedColumns) {
+		return nil
+	}
+	values := map[string]interface{}{}
+	for _, col := range metadata.selectColumns() {
+		values[col.Field] = fieldExprValue(value.Field(metadata.FieldByColumn[col.Field]))
+	}
+	for i, col := range metadata.ComputedColumns {
+		var expr GeneratedExpr
+		if i < len(metadata.computed) {
+			expr = metadata.computed[i]
+		} else {
+			// ComputedColumns set by hand rather than by buildStatements
+			var err error
+			if expr, err = ParseGeneratedExpr(col.GenerationExpression); nil != err {
+				return err
+			}
+		}
+		result, err := expr.Eval(values)
+		if nil != err {
+			return fmt.Errorf("evaluating %s.%s: %w", metadata.Name, col.Field, err)
+		}
+		err = setFieldExprValue(value.Field(metadata.FieldByColumn[col.Field]), result)
+		if nil != err {
+			return fmt.Errorf("evaluating %s.%s: %w", metadata.Name, col.Field, err)
+		}
+	}
+	return nil
+}
+
+// the kinds of value computed client-side, for which Go gives what MySQL stores
+type valueKind int
+
+const (
+	kindUnsupported valueKind = iota
+	kindNull
+	kindInteger
+	kindString
+)
+
+// treat as const
+var SQL_TEXT_TYPE = regexp.MustCompile("(?i)^((var)?char(\\(\\d+\\))?|(tiny|medium|long)?text|enum\\(.*|set\\(.*)$")
+
+func columnExprKind(columnType string) valueKind {
+	switch {
+	case SQL_INT_TYPE.MatchString(columnType), SQL_UINT_TYPE.MatchString(columnType):
+		return kindInteger
+	case SQL_TEXT_TYPE.MatchString(columnType):
+		return kindString
+	}
+	return kindUnsupported
+}
+
+func exprKind(expr GeneratedExpr, kinds map[string]valueKind) valueKind {
+	// The kind of value of the expression, or kindUnsupported where MySQL would compute
+	// a DECIMAL, float or temporal value that Go formats or rounds differently.
+	switch e := expr.(type) {
+	case literalExpr:
+		switch e.value.(type) {
+		case nil:
+			return kindNull
+		case int64:
+			return kindInteger
+		case string:
+			return kindString
+		}
+	case columnExpr:
+		return kinds[e.name]
+	case unaryExpr:
+		if kind := exprKind(e.operand, kinds); kindInteger == kind || kindNull == kind {
+			return kind
+		}
+	case binaryExpr:
+		// division makes a DECIMAL, and strings are added as floats
+		if '/' == e.op {
+			return kindUnsupported
+		}
+		left, right := exprKind(e.left, kinds), exprKind(e.right, kinds)
+		if (kindInteger == left || kindNull == left) && (kindInteger == right || kindNull == right) {
+			return kindInteger
+		}
+	case funcExpr:
+		kind := kindNull
+		for _, arg := range e.args {
+			argKind := exprKind(arg, kinds)
+			switch {
+			case kindUnsupported == argKind:
+				return kindUnsupported
+			case kindNull == kind:
+				kind = argKind
+			case kindNull != argKind && kind != argKind:
+				kind = kindString
+			}
+		}
+		switch e.name {
+		case "concat", "lower", "upper", "trim":
+			return kindString
+		case "abs":
+			if kindString == kind {
+				return kindUnsupported
+			}
+		}
+		return kind
+	}
+	return kindUnsupported
+}
+
+func computableGenerated(col ColumnMetadata, cols []ColumnMetadata) GeneratedExpr {
+	// Returns the parsed expression of a generated column that can be computed
+	// client-side, or nil: only simple expressions over the integer and string
+	// values of non-generated columns, into an integer or string column.
+	if "" == col.GenerationExpression {
+		return nil
+	}
+	expr, err := ParseGeneratedExpr(col.GenerationExpression)
+	if nil != err {
+		return nil
+	}
+	kinds := map[string]valueKind{}
+	for _, c := range cols {
+		if !c.IsGenerated() {
+			kinds[c.Field] = columnExprKind(c.ColumnType)
+		}
+	}
+	kind := exprKind(expr, kinds)
+	switch columnExprKind(col.ColumnType) {
+	case kindInteger:
+		if kindInteger == kind || kindNull == kind {
+			return expr
+		}
+	case kindString:
+		if kindUnsupported != kind {
+			return expr
+		}
+	}
+	return nil
+}
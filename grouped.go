@@ -0,0 +1,67 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// treat as const - IN lists longer than this are split over several queries
+var MAX_IN_VALUES = 1000
+
+func InPlaceholders(n int) string {
+	// Returns "(?, ?, ?)" with n placeholders.
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+}
+
+func groupKey(v reflect.Value) interface{} {
+	// Map keys must be comparable, so byte slices are grouped by their string value.
+	if reflect.Ptr == v.Kind() {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if b, ok := v.Interface().([]byte); ok {
+		return string(b)
+	}
+	return v.Interface()
+}
+
+func (metadata TableMetadata) GetEntitiesGroupedBy(colname string, values []interface{}) (map[interface{}][]interface{}, error) {
+	return metadata.GetEntitiesGroupedByContext(context.Background(), colname, values)
+}
+
+func (metadata TableMetadata) GetEntitiesGroupedByContext(ctx context.Context, colname string, values []interface{}) (map[interface{}][]interface{}, error) {
+	// Fetches the rows whose colname is any of values, ex. all order lines for a set
+	// of order ids, in one query per MAX_IN_VALUES values. The result maps each
+	// value of the column, as held in the entity field, to pointers to its entities.
+	if !metadata.IsColumn(colname) {
+		return nil, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+	}
+	if nil == metadata.EntityType {
+		return nil, fmt.Errorf("%w: no entity type for %s", ErrInvalidEntity, metadata.Name)
+	}
+	grouped := map[interface{}][]interface{}{}
+	j := metadata.FieldByColumn[colname]
+	for start := 0; start < len(values); start += MAX_IN_VALUES {
+		end := start + MAX_IN_VALUES
+		if end > len(values) {
+			end = len(values)
+		}
+		chunk := values[start:end]
+		entities := reflect.New(reflect.SliceOf(reflect.PtrTo(metadata.EntityType)))
+		clause := " WHERE " + QuoteIdentifier(colname) + " IN " + InPlaceholders(len(chunk))
+		err := metadata.GetEntitiesContext(ctx, entities.Interface(), clause, chunk...)
+		if nil != err {
+			return nil, err
+		}
+		for i := 0; i < entities.Elem().Len(); i++ {
+			entity := entities.Elem().Index(i)
+			key := groupKey(entity.Elem().Field(j))
+			grouped[key] = append(grouped[key], entity.Interface())
+		}
+	}
+	return grouped, nil
+}
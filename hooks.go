@@ -0,0 +1,75 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+)
+
+// Entities can implement any of these to be called back by InsertEntity,
+// UpdateEntity, SaveEntity and ScanEntity (so also GetEntity and friends).
+// An error from a Before hook stops the statement from running.
+
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+type AfterLoader interface {
+	AfterLoad() error
+}
+
+func (metadata TableMetadata) beforeInsert(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(BeforeInserter); ok {
+		if err := hook.BeforeInsert(ctx); nil != err {
+			return fmt.Errorf("before insert into %s: %w", metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) afterInsert(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(AfterInserter); ok {
+		if err := hook.AfterInsert(ctx); nil != err {
+			return fmt.Errorf("after insert into %s: %w", metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) beforeUpdate(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(BeforeUpdater); ok {
+		if err := hook.BeforeUpdate(ctx); nil != err {
+			return fmt.Errorf("before update of %s: %w", metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) afterUpdate(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(AfterUpdater); ok {
+		if err := hook.AfterUpdate(ctx); nil != err {
+			return fmt.Errorf("after update of %s: %w", metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) afterLoad(entity interface{}) error {
+	if hook, ok := entity.(AfterLoader); ok {
+		if err := hook.AfterLoad(); nil != err {
+			return fmt.Errorf("after load of %s: %w", metadata.Name, err)
+		}
+	}
+	return nil
+}
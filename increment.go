@@ -0,0 +1,66 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+func (metadata TableMetadata) IncrementColumn(id uint, colname string, delta interface{}) error {
+	return metadata.IncrementColumnContext(context.Background(), id, colname, delta)
+}
+
+func (metadata TableMetadata) IncrementColumnContext(ctx context.Context, id uint, colname string, delta interface{}) error {
+	// Adds delta (negative to decrement) to a numeric column of the row in a single
+	// UPDATE, so that concurrent increments are never lost the way they are with a
+	// read, modify and UpdateEntity. The version is bumped, so entities read before
+	// are seen as stale. A NULL counts as 0. Returns ErrNotFound if there is no such
+	// row. Columns UpdateEntity leaves alone, ex. the primary key, generated, no-update
+	// and tenant columns, cannot be incremented either.
+	var col *ColumnMetadata
+	for i := range metadata.Columns {
+		if colname == metadata.Columns[i].Field {
+			col = &metadata.Columns[i]
+		}
+	}
+	if nil == col || !metadata.IsColumn(colname) {
+		return fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+	}
+	if !col.AllowUpdate(reflect.Value{}) || metadata.TenantColumn == colname {
+		return fmt.Errorf("%w: %s.%s cannot be updated", ErrInvalidColumn, metadata.Name, colname)
+	}
+	value := reflect.ValueOf(delta)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return fmt.Errorf("%w: increment of %s.%s by %T", ErrInvalidColumn, metadata.Name, colname, delta)
+	}
+	if value.IsZero() {
+		// nothing would change, and MySQL would report no rows affected
+		return nil
+	}
+	current := QuoteIdentifier(colname)
+	if "YES" == col.Nullable {
+		// NULL + ? is NULL, which would leave the row as it is
+		current = "COALESCE(" + current + ", 0)"
+	}
+	set := QuoteIdentifier(colname) + " = " + current + " + ?"
+	if "" != metadata.VersionColumn && colname != metadata.VersionColumn {
+		set += ", " + QuoteIdentifier(metadata.VersionColumn) + "=" + QuoteIdentifier(metadata.VersionColumn) + "+1"
+	}
+	clause, v := metadata.scopeClause(ctx, "WHERE id = ?", []interface{}{id})
+	result, err := metadata.exec(ctx, "UPDATE "+QuoteTableName(metadata.Name)+" SET "+set+" "+clause, append([]interface{}{delta}, v...)...)
+	if nil != err {
+		return fmt.Errorf("increment %s.%s: %w", metadata.Name, colname, err)
+	}
+	rows, err := result.RowsAffected()
+	if nil != err {
+		return fmt.Errorf("increment %s.%s: %w", metadata.Name, colname, err)
+	}
+	if 0 == rows {
+		return fmt.Errorf("%w: %s id %d", ErrNotFound, metadata.Name, id)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package mysqlmeta
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// treat as const
+var SQL_EQUALITY = regexp.MustCompile("(?i)(?:^|[\\s(.,])`?(\\w+)`?\\s*(?:<=>|=|\\bIN\\b)")
+var SQL_WHERE_END = regexp.MustCompile("(?i)\\b(?:ORDER\\s+BY|GROUP\\s+BY|HAVING|LIMIT|FOR\\s+UPDATE|FOR\\s+SHARE)\\b")
+
+func (metadata TableMetadata) equalityColumns(clause string) []string {
+	// Returns the columns of the table compared with =, <=> or IN in the clause, up
+	// to ORDER BY and the like, in order and without repeats.
+	if loc := SQL_WHERE_END.FindStringIndex(clause); nil != loc {
+		clause = clause[:loc[0]]
+	}
+	cols := []string{}
+	seen := map[string]bool{}
+	for _, match := range SQL_EQUALITY.FindAllStringSubmatch(clause, -1) {
+		colname := match[1]
+		if seen[colname] || !metadata.IsColumn(colname) {
+			continue
+		}
+		seen[colname] = true
+		cols = append(cols, colname)
+	}
+	return cols
+}
+
+func (metadata TableMetadata) AdviseIndex(clause string) error {
+	// Returns ErrNotIndexed when the clause compares columns for equality but none of
+	// them leads an index, so that MySQL would have to scan the table. The clause is
+	// only matched against simple patterns such as "sku = ?" and "id IN (?, ?)", and
+	// clauses without them pass.
+	cols := metadata.equalityColumns(clause)
+	if 0 == len(cols) {
+		return nil
+	}
+	for _, colname := range cols {
+		if _, ok := metadata.IndexCovering(colname); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (%s)", ErrNotIndexed, metadata.Name, strings.Join(cols, ", "))
+}
+
+func (metadata TableMetadata) adviseIndex(clause string) {
+	if !metadata.AdviseIndexes {
+		return
+	}
+	if err := metadata.AdviseIndex(clause); nil != err {
+		metadata.logf(LogWarn, "%v, the query may scan the table: %s", err, strings.TrimSpace(clause))
+	}
+}
@@ -0,0 +1,88 @@
+package mysqlmeta
+
+import (
+	"sort"
+)
+
+// IndexDefinition is one index of a table, with its columns in index order. The
+// same index is also listed, one part per column, in ColumnMetadata.Indexes.
+type IndexDefinition struct {
+	Name      string   `json:"name"`
+	Unique    bool     `json:"unique,omitempty"`
+	IndexType string   `json:"index_type,omitempty"`
+	Columns   []string `json:"columns"`
+	// SubParts holds the prefix length of each column, 0 where the whole column is indexed
+	SubParts []uint `json:"sub_parts,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+func (index IndexDefinition) IsPrimary() bool {
+	return "PRIMARY" == index.Name
+}
+
+func GroupIndexes(cols []ColumnMetadata) []IndexDefinition {
+	// Gathers the index parts attached to the columns into indexes, the primary key
+	// first and the rest by name.
+	parts := map[string][]IndexMetadata{}
+	for _, col := range cols {
+		for _, ind := range col.Indexes {
+			parts[ind.KeyName] = append(parts[ind.KeyName], ind)
+		}
+	}
+	indexes := make([]IndexDefinition, 0, len(parts))
+	for name, indexParts := range parts {
+		sort.Slice(indexParts, func(i, j int) bool { return indexParts[i].SeqInIndex < indexParts[j].SeqInIndex })
+		index := IndexDefinition{
+			Name:      name,
+			Unique:    !indexParts[0].NonUnique,
+			IndexType: indexParts[0].IndexType,
+			Comment:   indexParts[0].IndexComment,
+		}
+		prefixed := false
+		for _, part := range indexParts {
+			index.Columns = append(index.Columns, part.ColumnName)
+			subPart := uint(0)
+			if nil != part.SubPart {
+				subPart = *part.SubPart
+				prefixed = true
+			}
+			index.SubParts = append(index.SubParts, subPart)
+		}
+		if !prefixed {
+			index.SubParts = nil
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].IsPrimary() || (!indexes[j].IsPrimary() && indexes[i].Name < indexes[j].Name)
+	})
+	return indexes
+}
+
+func (metadata TableMetadata) indexes() []IndexDefinition {
+	// Metadata built by hand may only have the indexes attached to its columns.
+	if nil == metadata.Indexes {
+		return GroupIndexes(metadata.Columns)
+	}
+	return metadata.Indexes
+}
+
+func (metadata TableMetadata) Index(name string) (IndexDefinition, bool) {
+	for _, index := range metadata.indexes() {
+		if name == index.Name {
+			return index, true
+		}
+	}
+	return IndexDefinition{}, false
+}
+
+func (metadata TableMetadata) UniqueIndexes() []IndexDefinition {
+	// Returns the primary key and unique indexes.
+	unique := []IndexDefinition{}
+	for _, index := range metadata.indexes() {
+		if index.Unique {
+			unique = append(unique, index)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,83 @@
+package mysqlmeta
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// treat as const
+var SQL_INDEX_HINT = regexp.MustCompile("(?i)^\\s*(USE|FORCE|IGNORE)\\s+(?:INDEX|KEY)(?:\\s+FOR\\s+(JOIN|ORDER\\s+BY|GROUP\\s+BY))?\\s*\\(([^()]*)\\)")
+
+func (metadata TableMetadata) IndexHint(hint string) (string, error) {
+	// Returns the index hints, ex. "FORCE INDEX (idx_org_created)" or "USE INDEX
+	// (ix_a) IGNORE INDEX FOR ORDER BY (ix_b)", in the form MySQL takes after the
+	// table name, checking that the indexes are those of the table.
+	hints := []string{}
+	rest := strings.TrimSpace(hint)
+	for "" != rest {
+		match := SQL_INDEX_HINT.FindStringSubmatch(rest)
+		if nil == match {
+			return "", fmt.Errorf("%w: %q", ErrInvalidIndexHint, hint)
+		}
+		rest = strings.TrimSpace(rest[len(match[0]):])
+		kind := strings.ToUpper(match[1])
+		names := []string{}
+		for _, name := range strings.Split(match[3], ",") {
+			name = strings.Trim(strings.TrimSpace(name), "`")
+			if "" == name {
+				continue
+			}
+			if _, ok := metadata.Index(name); !ok {
+				return "", fmt.Errorf("%w: %s has no index %s", ErrInvalidIndexHint, metadata.Name, name)
+			}
+			if "PRIMARY" == name {
+				names = append(names, name)
+			} else {
+				names = append(names, QuoteIdentifier(name))
+			}
+		}
+		// only USE INDEX () may be empty, to use no index
+		if 0 == len(names) && "USE" != kind {
+			return "", fmt.Errorf("%w: %s INDEX needs an index", ErrInvalidIndexHint, kind)
+		}
+		scope := ""
+		if "" != match[2] {
+			scope = " FOR " + strings.Join(strings.Fields(strings.ToUpper(match[2])), " ")
+		}
+		hints = append(hints, kind+" INDEX"+scope+" ("+strings.Join(names, ", ")+")")
+	}
+	if 0 == len(hints) {
+		return "", fmt.Errorf("%w: empty hint", ErrInvalidIndexHint)
+	}
+	return strings.Join(hints, " "), nil
+}
+
+func (metadata TableMetadata) SelectStringWithHint(hint string) (string, error) {
+	// Returns SelectString with the index hints after the table name, for a clause
+	// on which the optimizer picks a bad plan.
+	hint, err := metadata.IndexHint(hint)
+	if nil != err {
+		return "", err
+	}
+	from := " FROM " + QuoteTableName(metadata.Name) + " "
+	if !strings.HasSuffix(metadata.SelectString, from) {
+		return "", fmt.Errorf("%w: the select of %s already has a hint", ErrInvalidIndexHint, metadata.Name)
+	}
+	return metadata.SelectString + hint + " ", nil
+}
+
+func (metadata TableMetadata) WithIndexHint(hint string) (TableMetadata, error) {
+	// Returns a copy of the metadata whose queries select with the index hints, ex.
+	//
+	//	byOrg, err := metadata.WithIndexHint("FORCE INDEX (idx_org_created)")
+	//	err = byOrg.GetEntities(&orders, " WHERE org_id = ? ORDER BY created_at", orgId)
+	//
+	// Call Select, if needed, before WithIndexHint.
+	selectString, err := metadata.SelectStringWithHint(hint)
+	if nil != err {
+		return metadata, err
+	}
+	metadata.SelectString = selectString
+	return metadata, nil
+}
@@ -0,0 +1,275 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+func splitTableName(tableName string) (sql.NullString, string) {
+	// Returns the database of a qualified table name, or NULL for the current database,
+	// and the name of the table in it.
+	if schema, table, ok := strings.Cut(tableName, "."); ok {
+		return sql.NullString{String: schema, Valid: true}, table
+	}
+	return sql.NullString{}, tableName
+}
+
+// treat as const - matches a table of information_schema by the arguments of tableIsArgs
+var SQL_TABLE_IS = "TABLE_SCHEMA = IFNULL(?, DATABASE()) AND TABLE_NAME = ?"
+
+func tableIsArgs(tableName string) []interface{} {
+	schema, table := splitTableName(tableName)
+	return []interface{}{schema, table}
+}
+
+// tableNameSet finds the names by which tables were asked for from the rows of
+// information_schema, as a table of the current database may be asked for with or
+// without its database.
+type tableNameSet map[[2]string][]string
+
+func tableArgs(tableNames []string) (string, []interface{}, tableNameSet, error) {
+	// Returns the condition matching the tables in information_schema, its arguments,
+	// and the names to map the rows back to.
+	args := make([]interface{}, 0, 2*len(tableNames))
+	pairs := make([]string, len(tableNames))
+	names := tableNameSet{}
+	for i, tableName := range tableNames {
+		if err := CheckTableName(tableName); nil != err {
+			return "", nil, nil, err
+		}
+		schema, table := splitTableName(tableName)
+		args = append(args, schema, table)
+		pairs[i] = "(IFNULL(?, DATABASE()), ?)"
+		names[[2]string{schema.String, table}] = append(names[[2]string{schema.String, table}], tableName)
+	}
+	return "(TABLE_SCHEMA, TABLE_NAME) IN (" + strings.Join(pairs, ", ") + ")", args, names, nil
+}
+
+func (names tableNameSet) lookup(schema, table string, current bool) []string {
+	// Returns the names asked for of the table of a row.
+	found := names[[2]string{schema, table}]
+	if current {
+		found = append(found, names[[2]string{"", table}]...)
+	}
+	return found
+}
+
+// treat as const - selects the columns that tableNameSet.lookup takes
+var SQL_TABLE_OF = "TABLE_SCHEMA, TABLE_NAME, TABLE_SCHEMA = DATABASE()"
+
+func GetSchemaColumns(db *sql.DB, tableNames ...string) (map[string][]ColumnMetadata, error) {
+	return GetSchemaColumnsContext(context.Background(), db, tableNames...)
+}
+
+func GetSchemaColumnsContext(ctx context.Context, db *sql.DB, tableNames ...string) (map[string][]ColumnMetadata, error) {
+	// Reads the columns of several tables of the current schema in one query on
+	// information_schema.COLUMNS, which has more than SHOW FULL COLUMNS: the precision,
+	// character set and generation expression of each column.
+	if 0 == len(tableNames) {
+		return map[string][]ColumnMetadata{}, nil
+	}
+	filter, args, names, err := tableArgs(tableNames)
+	if nil != err {
+		return nil, err
+	}
+	version, err := GetServerVersion(ctx, db)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.QueryContext(
+		ctx,
+		"SELECT "+SQL_TABLE_OF+", COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, COLUMN_DEFAULT, EXTRA, "+
+			"NUMERIC_PRECISION, NUMERIC_SCALE, CHARACTER_MAXIMUM_LENGTH, CHARACTER_SET_NAME, COLLATION_NAME, "+
+			"COLUMN_COMMENT, GENERATION_EXPRESSION FROM information_schema.COLUMNS "+
+			"WHERE "+filter+" "+
+			"ORDER BY TABLE_SCHEMA, TABLE_NAME, ORDINAL_POSITION",
+		args...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("columns of %v: %w", tableNames, err)
+	}
+	defer rows.Close()
+	tables := map[string][]ColumnMetadata{}
+	for rows.Next() {
+		var schema, tableName string
+		var current bool
+		var defaultValue, charset, collation, generation sql.NullString
+		var precision, scale, maxLength sql.NullInt64
+		col := ColumnMetadata{}
+		err = rows.Scan(&schema, &tableName, &current, &col.Field, &col.ColumnType, &col.Nullable, &col.Key, &defaultValue, &col.Extra,
+			&precision, &scale, &maxLength, &charset, &collation, &col.Comment, &generation)
+		if nil != err {
+			return nil, fmt.Errorf("problem parsing column metadata for %s: %w", tableName, err)
+		}
+		defaultValue, col.Extra = version.normalizeDefault(defaultValue, col.Extra)
+		col.Invisible = isInvisibleExtra(col.Extra)
+		col.AllowedValues = parseAllowedValues(col.ColumnType)
+		col.DefaultValue = defaultValue.String
+		col.Default = ParseColumnDefault(defaultValue, col.ColumnType, col.Extra)
+		col.NumericPrecision = uint(precision.Int64)
+		col.NumericScale = uint(scale.Int64)
+		col.CharacterMaxLength = uint64(maxLength.Int64)
+		col.CharacterSet = charset.String
+		col.Collation = collation.String
+		col.GenerationExpression = generation.String
+		for _, name := range names.lookup(schema, tableName, current) {
+			tables[name] = append(tables[name], col)
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("columns of %v: %w", tableNames, err)
+	}
+	return tables, nil
+}
+
+func GetSchemaIndexes(db *sql.DB, tableNames ...string) (map[string][]IndexMetadata, error) {
+	return GetSchemaIndexesContext(context.Background(), db, tableNames...)
+}
+
+func GetSchemaIndexesContext(ctx context.Context, db *sql.DB, tableNames ...string) (map[string][]IndexMetadata, error) {
+	// Reads the index parts of several tables of the current schema in one query on
+	// information_schema.STATISTICS, with the same attributes as SHOW INDEXES.
+	if 0 == len(tableNames) {
+		return map[string][]IndexMetadata{}, nil
+	}
+	filter, args, names, err := tableArgs(tableNames)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.QueryContext(
+		ctx,
+		"SELECT "+SQL_TABLE_OF+", NON_UNIQUE, INDEX_NAME, SEQ_IN_INDEX, COLUMN_NAME, COLLATION, CARDINALITY, "+
+			"SUB_PART, PACKED, NULLABLE, INDEX_TYPE, COMMENT, INDEX_COMMENT FROM information_schema.STATISTICS "+
+			"WHERE "+filter+" "+
+			"ORDER BY TABLE_SCHEMA, TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX",
+		args...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("indexes of %v: %w", tableNames, err)
+	}
+	defer rows.Close()
+	tables := map[string][]IndexMetadata{}
+	for rows.Next() {
+		ind := IndexMetadata{}
+		// functional indexes of MySQL 8 have no column, and cardinality may be unknown
+		var columnName sql.NullString
+		var cardinality sql.NullInt64
+		var schema string
+		var current bool
+		err = rows.Scan(&schema, &ind.TableName, &current, &ind.NonUnique, &ind.KeyName, &ind.SeqInIndex, &columnName,
+			&ind.Collation, &cardinality, &ind.SubPart, &ind.Packed, &ind.Null, &ind.IndexType,
+			&ind.Comment, &ind.IndexComment)
+		if nil != err {
+			return nil, fmt.Errorf("problem parsing index metadata for %s: %w", ind.TableName, err)
+		}
+		ind.ColumnName = columnName.String
+		ind.Cardinality = uint(cardinality.Int64)
+		for _, name := range names.lookup(schema, ind.TableName, current) {
+			tables[name] = append(tables[name], ind)
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("indexes of %v: %w", tableNames, err)
+	}
+	return tables, nil
+}
+
+func GetSchemaChecksums(ctx context.Context, db *sql.DB, tableNames ...string) (map[string]string, error) {
+	// Returns a checksum of the columns and indexes of each table, by table name, from
+	// one query on information_schema, which changes when the table is altered in a way
+	// that changes its metadata. Tables that do not exist are missing from the map.
+	if 0 == len(tableNames) {
+		return map[string]string{}, nil
+	}
+	filter, args, names, err := tableArgs(tableNames)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.QueryContext(
+		ctx,
+		"SELECT "+SQL_TABLE_OF+", SUM(part) FROM ("+
+			"SELECT TABLE_SCHEMA, TABLE_NAME, CRC32(CONCAT_WS('|', ORDINAL_POSITION, COLUMN_NAME, COLUMN_TYPE, "+
+			"IS_NULLABLE, COLUMN_KEY, COLUMN_DEFAULT, EXTRA, GENERATION_EXPRESSION)) AS part "+
+			"FROM information_schema.COLUMNS WHERE "+filter+" "+
+			"UNION ALL SELECT TABLE_SCHEMA, TABLE_NAME, CRC32(CONCAT_WS('|', INDEX_NAME, NON_UNIQUE, SEQ_IN_INDEX, "+
+			"COLUMN_NAME)) FROM information_schema.STATISTICS WHERE "+filter+
+			") AS parts GROUP BY TABLE_SCHEMA, TABLE_NAME",
+		append(args, args...)...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("checksums of %v: %w", tableNames, err)
+	}
+	defer rows.Close()
+	checksums := map[string]string{}
+	for rows.Next() {
+		var schema, tableName, checksum string
+		var current bool
+		if err = rows.Scan(&schema, &tableName, &current, &checksum); nil != err {
+			return nil, fmt.Errorf("problem parsing checksum for %s: %w", tableName, err)
+		}
+		for _, name := range names.lookup(schema, tableName, current) {
+			checksums[name] = checksum
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("checksums of %v: %w", tableNames, err)
+	}
+	return checksums, nil
+}
+
+func attachIndexes(cols []ColumnMetadata, indexes []IndexMetadata) []ColumnMetadata {
+	// Appends each index part to the column it covers, as GetIndexes does.
+	imap := map[string]int{}
+	for i := range cols {
+		imap[cols[i].Field] = i
+	}
+	for _, ind := range indexes {
+		if i, ok := imap[ind.ColumnName]; ok {
+			cols[i].Indexes = append(cols[i].Indexes, ind)
+		}
+	}
+	return cols
+}
+
+func GetColumnsContext(ctx context.Context, db *sql.DB, tableNames ...string) (map[string][]ColumnMetadata, error) {
+	// Returns the columns of several tables by table name, with their indexes as
+	// GetIndexes appends them, in two queries on information_schema for up to
+	// MAX_IN_VALUES tables, in place of SHOW COLUMNS and SHOW INDEXES for each table,
+	// ex. to introspect the tables of every entity at startup.
+	tables := make(map[string][]ColumnMetadata, len(tableNames))
+	for start := 0; start < len(tableNames); start += MAX_IN_VALUES {
+		end := start + MAX_IN_VALUES
+		if end > len(tableNames) {
+			end = len(tableNames)
+		}
+		chunk := tableNames[start:end]
+		columns, err := GetSchemaColumnsContext(ctx, db, chunk...)
+		if nil != err {
+			return nil, err
+		}
+		indexes, err := GetSchemaIndexesContext(ctx, db, chunk...)
+		if nil != err {
+			return nil, err
+		}
+		for _, tableName := range chunk {
+			cols, ok := columns[tableName]
+			if !ok {
+				return nil, fmt.Errorf("%w: no table %s in the current schema", ErrInvalidTableName, tableName)
+			}
+			tables[tableName] = attachIndexes(cols, indexes[tableName])
+		}
+	}
+	return tables, nil
+}
+
+func getSchemaTable(db *sql.DB, tableName string) ([]ColumnMetadata, error) {
+	// The columns of a table, with their indexes and generation expressions, from
+	// information_schema in place of SHOW COLUMNS and SHOW INDEXES.
+	tables, err := GetColumnsContext(context.Background(), db, tableName)
+	if nil != err {
+		return nil, err
+	}
+	return tables[tableName], nil
+}
@@ -0,0 +1,79 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// InsertMode chooses what an insert does when the row conflicts with an existing
+// one on the primary key or a unique index.
+type InsertMode int
+
+const (
+	// InsertDefault fails with a duplicate key error
+	InsertDefault InsertMode = iota
+	// InsertIgnore keeps the existing row and skips the new one. Note that INSERT
+	// IGNORE also turns some other errors, ex. truncated values, into warnings.
+	InsertIgnore
+	// InsertReplace deletes the conflicting rows and then inserts the new one
+	InsertReplace
+)
+
+// InsertResult tells what an insert did with the row.
+type InsertResult struct {
+	// Id is the id of the inserted row, 0 if it was ignored
+	Id uint `json:"id"`
+	// Ignored is set when InsertIgnore skipped a conflicting row
+	Ignored bool `json:"ignored,omitempty"`
+	// Replaced is set when InsertReplace deleted conflicting rows first
+	Replaced bool `json:"replaced,omitempty"`
+}
+
+func (mode InsertMode) statement(insert string) string {
+	switch mode {
+	case InsertIgnore:
+		return "INSERT IGNORE " + strings.TrimPrefix(insert, "INSERT ")
+	case InsertReplace:
+		return "REPLACE " + strings.TrimPrefix(insert, "INSERT ")
+	}
+	return insert
+}
+
+func (mode InsertMode) interpret(result sql.Result) (InsertResult, error) {
+	// For INSERT IGNORE, no rows affected means the row was skipped. For REPLACE,
+	// each conflicting row deleted counts as a row affected on top of the insert.
+	id, err := result.LastInsertId()
+	if nil != err {
+		return InsertResult{}, err
+	}
+	if InsertDefault == mode {
+		return InsertResult{Id: uint(id)}, nil
+	}
+	rows, err := result.RowsAffected()
+	if nil != err {
+		return InsertResult{}, err
+	}
+	switch {
+	case InsertIgnore == mode && 0 == rows:
+		return InsertResult{Ignored: true}, nil
+	case InsertReplace == mode && 1 < rows:
+		return InsertResult{Id: uint(id), Replaced: true}, nil
+	}
+	return InsertResult{Id: uint(id)}, nil
+}
+
+func (metadata TableMetadata) InsertEntityWithMode(entity interface{}, mode InsertMode) (InsertResult, error) {
+	return metadata.InsertEntityWithModeContext(context.Background(), entity, mode)
+}
+
+func (metadata TableMetadata) InsertEntityWithModeContext(ctx context.Context, entity interface{}, mode InsertMode) (InsertResult, error) {
+	// Inserts like InsertEntity, with INSERT IGNORE or REPLACE semantics for rows
+	// that conflict with existing ones, ex. for ingestion that tolerates duplicates.
+	// An ignored row leaves the entity unchanged and skips the AfterInsert hook.
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return InsertResult{}, err
+	}
+	return metadata.insertEntityMode(ctx, entity, value, mode)
+}
@@ -0,0 +1,85 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+func (metadata TableMetadata) EntityIter(clause string, v ...interface{}) iter.Seq2[interface{}, error] {
+	return metadata.EntityIterContext(context.Background(), clause, v...)
+}
+
+func (metadata TableMetadata) EntityIterContext(ctx context.Context, clause string, v ...interface{}) iter.Seq2[interface{}, error] {
+	// Returns an iterator over the rows matching the clause, scanning each into a new
+	// entity (a pointer to the struct) as it is read:
+	//
+	//	for entity, err := range metadata.EntityIter(" WHERE status = ?", "open") {
+	//		if nil != err {
+	//			return err
+	//		}
+	//		order := entity.(*Order)
+	//	}
+	//
+	// The rows are closed when the loop ends, also by break or return. An error is
+	// yielded once, with a nil entity, and ends the iteration.
+	return func(yield func(interface{}, error) bool) {
+		if nil == metadata.EntityType {
+			yield(nil, fmt.Errorf("%w: metadata for %s has no entity type", ErrInvalidEntity, metadata.Name))
+			return
+		}
+		rows, err := metadata.GetRowsContext(ctx, clause, v...)
+		if nil != err {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			entity := reflect.New(metadata.EntityType).Interface()
+			if err = metadata.ScanEntity(entity, rows); nil != err {
+				yield(nil, err)
+				return
+			}
+			if !yield(entity, nil) {
+				return
+			}
+		}
+		if err = rows.Err(); nil != err {
+			yield(nil, fmt.Errorf("error making given query %s: %w", metadata.SelectString+clause, err))
+		}
+	}
+}
+
+func (metadata TableMetadata) ForEachEntity(fn func(entity interface{}) error, clause string, v ...interface{}) error {
+	return metadata.ForEachEntityContext(context.Background(), fn, clause, v...)
+}
+
+func (metadata TableMetadata) ForEachEntityContext(ctx context.Context, fn func(entity interface{}) error, clause string, v ...interface{}) error {
+	// Calls fn with each entity matching the clause, one row at a time, stopping at
+	// the first error, which is returned.
+	for entity, err := range metadata.EntityIterContext(ctx, clause, v...) {
+		if nil == err {
+			err = fn(entity)
+		}
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (table *Table[T]) Iter(ctx context.Context, clause string, args ...interface{}) iter.Seq2[*T, error] {
+	// Like EntityIter, yielding *T.
+	return func(yield func(*T, error) bool) {
+		for entity, err := range table.Metadata.EntityIterContext(ctx, clause, args...) {
+			if nil != err {
+				yield(nil, err)
+				return
+			}
+			if !yield(entity.(*T), nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func appendJSONValue(buf []byte, col ColumnMetadata, value sql.RawBytes) []byte {
+	// Numbers and JSON documents are written as they are, booleans as true or false,
+	// and everything else as a string.
+	columnType := strings.ToLower(col.ColumnType)
+	switch {
+	case nil == value:
+		return append(buf, "null"...)
+	case strings.HasPrefix(columnType, "tinyint(1)"):
+		if "0" == string(value) {
+			return append(buf, "false"...)
+		}
+		return append(buf, "true"...)
+	case isNumericColumn(columnType) || ("json" == columnType && json.Valid(value)):
+		return append(buf, value...)
+	}
+	text, _ := json.Marshal(string(value))
+	return append(buf, text...)
+}
+
+func appendJSONObject(buf []byte, cols []ColumnMetadata, values []sql.RawBytes) []byte {
+	// Writes a row as a JSON object with the columns in order, by column name.
+	buf = append(buf, '{')
+	for i, col := range cols {
+		if 0 < i {
+			buf = append(buf, ',')
+		}
+		key, _ := json.Marshal(col.Field)
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, col, values[i])
+	}
+	return append(buf, '}')
+}
+
+// jsonRow marshals as the object of a row, for EncodeJSON.
+type jsonRow []byte
+
+func (row jsonRow) MarshalJSON() ([]byte, error) {
+	return row, nil
+}
+
+func (metadata TableMetadata) StreamJSON(w io.Writer, clause string, v ...interface{}) error {
+	return metadata.StreamJSONContext(context.Background(), w, clause, v...)
+}
+
+func (metadata TableMetadata) StreamJSONContext(ctx context.Context, w io.Writer, clause string, v ...interface{}) error {
+	// Writes the rows matching the clause as JSON Lines, one object per row keyed by
+	// column name, as each row is read rather than after reading them all.
+	return metadata.EncodeJSONContext(ctx, json.NewEncoder(w), clause, v...)
+}
+
+func (metadata TableMetadata) EncodeJSON(encoder *json.Encoder, clause string, v ...interface{}) error {
+	return metadata.EncodeJSONContext(context.Background(), encoder, clause, v...)
+}
+
+func (metadata TableMetadata) EncodeJSONContext(ctx context.Context, encoder *json.Encoder, clause string, v ...interface{}) error {
+	// Encodes each row matching the clause with the encoder, as an object keyed by
+	// column name with the columns in the order of SelectString. Numbers and JSON
+	// columns are encoded as they are, tinyint(1) as booleans, the rest as strings.
+	rows, err := metadata.GetRowsContext(ctx, clause, v...)
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+	cols := metadata.scanTargets()
+	values := make([]sql.RawBytes, len(cols))
+	pointers := make([]interface{}, len(cols))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	var buf []byte
+	for rows.Next() {
+		if err = rows.Scan(pointers...); nil != err {
+			return fmt.Errorf("json %s: %w", metadata.Name, err)
+		}
+		buf = appendJSONObject(buf[:0], cols, values)
+		if err = encoder.Encode(jsonRow(buf)); nil != err {
+			return fmt.Errorf("json %s: %w", metadata.Name, err)
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return fmt.Errorf("json %s: %w", metadata.Name, err)
+	}
+	return nil
+}
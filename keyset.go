@@ -0,0 +1,144 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+func (metadata TableMetadata) indexColumns() map[string][]string {
+	// Returns the ordered column names of each index, keyed by index name.
+	indexes := map[string][]string{}
+	for _, index := range metadata.indexes() {
+		indexes[index.Name] = index.Columns
+	}
+	return indexes
+}
+
+func (metadata TableMetadata) IndexCovering(cols ...string) (string, bool) {
+	// Returns the name of an index whose leading columns are cols, in order,
+	// so that it can be used to order and seek by them.
+	names := []string{}
+	for name, indexCols := range metadata.indexColumns() {
+		if len(indexCols) >= len(cols) && strings.Join(indexCols[:len(cols)], ",") == strings.Join(cols, ",") {
+			names = append(names, name)
+		}
+	}
+	if 0 == len(names) {
+		return "", false
+	}
+	// prefer the primary key, then the lowest name, so the choice is stable
+	sort.Slice(names, func(i, j int) bool {
+		return "PRIMARY" == names[i] || ("PRIMARY" != names[j] && names[i] < names[j])
+	})
+	return names[0], true
+}
+
+func KeysetCondition(cols []string, descending bool) string {
+	// Builds the expanded form of (a, b, c) > (?, ?, ?), which MySQL can always
+	// use an index for: a > ? OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c > ?)
+	op := " > ?"
+	if descending {
+		op = " < ?"
+	}
+	terms := []string{}
+	for i := range cols {
+		parts := []string{}
+		for _, eq := range cols[:i] {
+			parts = append(parts, QuoteIdentifier(eq)+" = ?")
+		}
+		parts = append(parts, QuoteIdentifier(cols[i])+op)
+		terms = append(terms, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+func keysetArgs(after []interface{}) []interface{} {
+	// The placeholders of KeysetCondition repeat the leading values in each term.
+	args := []interface{}{}
+	for i := range after {
+		args = append(args, after[:i+1]...)
+	}
+	return args
+}
+
+func (metadata TableMetadata) GetEntitiesAfter(dest interface{}, colname string, after interface{}, limit int) (interface{}, error) {
+	return metadata.GetEntitiesAfterContext(context.Background(), dest, colname, after, limit)
+}
+
+func (metadata TableMetadata) GetEntitiesAfterContext(ctx context.Context, dest interface{}, colname string, after interface{}, limit int) (interface{}, error) {
+	// Pages through the table in order of an indexed column such as a ULID key,
+	// appending up to limit rows with colname > after to dest. Pass a nil after for
+	// the first page. This returns the key of the last row, to pass as the next after,
+	// or nil once a page has fewer than limit rows, as there are no more after it.
+	var afterKeys []interface{}
+	if nil != after {
+		afterKeys = []interface{}{after}
+	}
+	next, err := metadata.GetEntitiesAfterKeysContext(ctx, dest, []string{colname}, afterKeys, limit, false)
+	if nil != err || nil == next {
+		return nil, err
+	}
+	return next[0], nil
+}
+
+func (metadata TableMetadata) GetEntitiesAfterKeys(dest interface{}, cols []string, after []interface{}, limit int, descending bool) ([]interface{}, error) {
+	return metadata.GetEntitiesAfterKeysContext(context.Background(), dest, cols, after, limit, descending)
+}
+
+func (metadata TableMetadata) GetEntitiesAfterKeysContext(ctx context.Context, dest interface{}, cols []string, after []interface{}, limit int, descending bool) ([]interface{}, error) {
+	// Pages through the table in order of several columns, ex. (created_at, id),
+	// which must lead an existing index. Pass nil after for the first page, and
+	// the returned keys of the last row for the next one. The returned keys are nil
+	// when the page has fewer than limit rows, so the last page needs no extra query.
+	// A full page may still be followed by an empty one.
+	if 0 >= limit {
+		return nil, fmt.Errorf("page of %s: limit must be positive, got %d", metadata.Name, limit)
+	}
+	if 0 == len(cols) {
+		return nil, fmt.Errorf("%w: no keyset columns for %s", ErrInvalidColumn, metadata.Name)
+	}
+	for _, colname := range cols {
+		if !metadata.IsColumn(colname) {
+			return nil, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+	}
+	if _, ok := metadata.IndexCovering(cols...); !ok {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrNotIndexed, metadata.Name, strings.Join(cols, ", "))
+	}
+	if nil != after && len(after) != len(cols) {
+		return nil, fmt.Errorf("%w: %d keys given for %d keyset columns", ErrInvalidColumn, len(after), len(cols))
+	}
+	slice, _, err := GetSliceValue(dest)
+	if nil != err {
+		return nil, err
+	}
+	direction := ""
+	if descending {
+		direction = " DESC"
+	}
+	orderBy := []string{}
+	for _, colname := range cols {
+		orderBy = append(orderBy, QuoteIdentifier(colname)+direction)
+	}
+	clause := " ORDER BY " + strings.Join(orderBy, ", ") + " LIMIT ?"
+	args := []interface{}{}
+	if nil != after {
+		clause = " WHERE " + KeysetCondition(cols, descending) + clause
+		args = keysetArgs(after)
+	}
+	args = append(args, limit)
+	start := slice.Len()
+	err = metadata.GetEntitiesContext(ctx, dest, clause, args...)
+	if nil != err || slice.Len() == start || slice.Len()-start < limit {
+		return nil, err
+	}
+	last := reflect.Indirect(slice.Index(slice.Len() - 1))
+	next := make([]interface{}, len(cols))
+	for i, colname := range cols {
+		next[i] = last.Field(metadata.FieldByColumn[colname]).Interface()
+	}
+	return next, nil
+}
@@ -0,0 +1,72 @@
+package mysqlmeta
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// treat as const
+var SQL_COLUMN_NAME = regexp.MustCompile("^[a-zA-Z0-9_$]+$")
+var SQL_COLLATION_NAME = regexp.MustCompile("^[a-zA-Z0-9_]+$")
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func EscapeLike(term string) string {
+	// Escapes the LIKE wildcards in user input, so that it only matches itself.
+	return likeEscaper.Replace(term)
+}
+
+// LikeCondition matches a column against a pattern built from an escaped term.
+type LikeCondition struct {
+	Column  string
+	Pattern string
+	// Collation, if set, is applied to the comparison, ex. utf8mb4_0900_ai_ci
+	// to match regardless of case and accents on a binary or case sensitive column
+	Collation string
+}
+
+func Contains(colname, term string) LikeCondition {
+	return LikeCondition{Column: colname, Pattern: "%" + EscapeLike(term) + "%"}
+}
+
+func StartsWith(colname, term string) LikeCondition {
+	// Only StartsWith can use an index on the column.
+	return LikeCondition{Column: colname, Pattern: EscapeLike(term) + "%"}
+}
+
+func EndsWith(colname, term string) LikeCondition {
+	return LikeCondition{Column: colname, Pattern: "%" + EscapeLike(term)}
+}
+
+func (cond LikeCondition) Collate(collation string) LikeCondition {
+	cond.Collation = collation
+	return cond
+}
+
+func (cond LikeCondition) SQL() (string, []interface{}, error) {
+	// Returns the condition, ex. "`name` LIKE ?", and its argument.
+	if !SQL_COLUMN_NAME.MatchString(cond.Column) {
+		return "", nil, fmt.Errorf("%w %q", ErrInvalidColumn, cond.Column)
+	}
+	sql := QuoteIdentifier(cond.Column) + " LIKE ?"
+	if "" != cond.Collation {
+		if !SQL_COLLATION_NAME.MatchString(cond.Collation) {
+			return "", nil, fmt.Errorf("%w: invalid collation %q", ErrInvalidColumn, cond.Collation)
+		}
+		sql += " COLLATE " + cond.Collation
+	}
+	return sql, []interface{}{cond.Pattern}, nil
+}
+
+func (metadata TableMetadata) WhereLike(cond LikeCondition) (string, []interface{}, error) {
+	// Returns a clause for GetEntities and friends, checking the column is in the table.
+	if !metadata.IsColumn(cond.Column) {
+		return "", nil, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, cond.Column)
+	}
+	sql, args, err := cond.SQL()
+	if nil != err {
+		return "", nil, err
+	}
+	return " WHERE " + sql, args, nil
+}
@@ -0,0 +1,188 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// treat as const - how long a Loader waits for more ids before running its query
+var LOADER_WAIT = 2 * time.Millisecond
+
+// Loader coalesces GetEntityById calls made within a short window, ex. by the
+// resolvers of one GraphQL request, into a single IN query, and remembers the
+// entities it has loaded. A Loader is meant to live for one request - use
+// WithLoaders and LoaderFor rather than sharing one between requests.
+type Loader struct {
+	Metadata *TableMetadata
+	// Wait is how long to collect ids before querying, LOADER_WAIT if zero
+	Wait time.Duration
+	// MaxBatch caps the ids per query, MAX_IN_VALUES if zero
+	MaxBatch int
+
+	mu      sync.Mutex
+	results map[uint]*loaderResult
+	batch   *loaderBatch
+}
+
+type loaderResult struct {
+	done   chan struct{}
+	entity interface{}
+	err    error
+}
+
+type loaderBatch struct {
+	ctx     context.Context
+	ids     []uint
+	results []*loaderResult
+	timer   *time.Timer
+}
+
+func NewLoader(metadata *TableMetadata) *Loader {
+	return &Loader{Metadata: metadata, results: map[uint]*loaderResult{}}
+}
+
+func (loader *Loader) Load(ctx context.Context, id uint) (interface{}, error) {
+	// Returns a pointer to the entity with the id, or an error wrapping ErrNotFound.
+	// The batch runs with the ctx of the first Load that joined it.
+	result := loader.enqueue(ctx, id)
+	select {
+	case <-result.done:
+		return result.entity, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (loader *Loader) LoadMany(ctx context.Context, ids []uint) ([]interface{}, error) {
+	// Loads all of the ids in as few queries as possible, returning the first error.
+	results := make([]*loaderResult, len(ids))
+	for i, id := range ids {
+		results[i] = loader.enqueue(ctx, id)
+	}
+	entities := make([]interface{}, len(ids))
+	for i, result := range results {
+		select {
+		case <-result.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if nil != result.err {
+			return nil, result.err
+		}
+		entities[i] = result.entity
+	}
+	return entities, nil
+}
+
+func (loader *Loader) Prime(entity interface{}) error {
+	// Remembers an entity that was loaded some other way, ex. just inserted.
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	result := &loaderResult{done: make(chan struct{}), entity: entity}
+	close(result.done)
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	loader.results[GetValueId(value)] = result
+	return nil
+}
+
+func (loader *Loader) Clear(id uint) {
+	// Forgets the entity, ex. after it is updated, so that the next Load reads it again.
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	delete(loader.results, id)
+}
+
+func (loader *Loader) enqueue(ctx context.Context, id uint) *loaderResult {
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	if result, ok := loader.results[id]; ok {
+		return result
+	}
+	result := &loaderResult{done: make(chan struct{})}
+	loader.results[id] = result
+	if nil == loader.batch {
+		batch := &loaderBatch{ctx: ctx}
+		wait := loader.Wait
+		if 0 == wait {
+			wait = LOADER_WAIT
+		}
+		batch.timer = time.AfterFunc(wait, func() { loader.dispatch(batch) })
+		loader.batch = batch
+	}
+	batch := loader.batch
+	batch.ids = append(batch.ids, id)
+	batch.results = append(batch.results, result)
+	maxBatch := loader.MaxBatch
+	if 0 == maxBatch {
+		maxBatch = MAX_IN_VALUES
+	}
+	if len(batch.ids) >= maxBatch {
+		loader.batch = nil
+		if batch.timer.Stop() {
+			go loader.dispatch(batch)
+		}
+	}
+	return result
+}
+
+func (loader *Loader) dispatch(batch *loaderBatch) {
+	loader.mu.Lock()
+	if loader.batch == batch {
+		loader.batch = nil
+	}
+	loader.mu.Unlock()
+
+	found, err := loader.Metadata.GetEntityMapByIdsContext(batch.ctx, batch.ids)
+	loader.mu.Lock()
+	defer loader.mu.Unlock()
+	for i, id := range batch.ids {
+		result := batch.results[i]
+		switch entity, ok := found[id]; {
+		case nil != err:
+			result.err = err
+		case ok:
+			result.entity = entity
+		default:
+			result.err = fmt.Errorf("%w: %s id %d", ErrNotFound, loader.Metadata.Name, id)
+		}
+		if nil != result.err && loader.results[id] == result {
+			// errors are not remembered, so that a later Load tries again
+			delete(loader.results, id)
+		}
+		close(result.done)
+	}
+}
+
+type loadersKey struct{}
+
+type loaders struct {
+	mu      sync.Mutex
+	loaders map[*TableMetadata]*Loader
+}
+
+func WithLoaders(ctx context.Context) context.Context {
+	// Returns a context holding a fresh set of Loaders, ex. at the start of each request.
+	return context.WithValue(ctx, loadersKey{}, &loaders{loaders: map[*TableMetadata]*Loader{}})
+}
+
+func LoaderFor(ctx context.Context, metadata *TableMetadata) *Loader {
+	// Returns the context's Loader for the table, or a new unshared one
+	// if the context did not come from WithLoaders.
+	set, ok := ctx.Value(loadersKey{}).(*loaders)
+	if !ok {
+		return NewLoader(metadata)
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	loader, ok := set.loaders[metadata]
+	if !ok {
+		loader = NewLoader(metadata)
+		set.loaders[metadata] = loader
+	}
+	return loader
+}
@@ -0,0 +1,56 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+)
+
+// LockMode chooses the row locks a SELECT takes, which only last as long as the
+// transaction, so locking reads need a context from WithTx or RunInTx.
+type LockMode int
+
+const (
+	LockNone LockMode = iota
+	// LockForUpdate blocks other writers and locking readers until the transaction ends
+	LockForUpdate
+	// LockForShare blocks other writers only
+	LockForShare
+)
+
+func (lock LockMode) String() string {
+	switch lock {
+	case LockForUpdate:
+		return "FOR UPDATE"
+	case LockForShare:
+		return "FOR SHARE"
+	}
+	return ""
+}
+
+func (metadata TableMetadata) GetEntityLockedContext(ctx context.Context, lock LockMode, entity interface{}, clause string, v ...interface{}) (interface{}, error) {
+	// Like GetEntity, but locks the matching rows for the rest of the transaction,
+	// ex. to read, modify and update an entity without another writer in between.
+	if LockNone != lock {
+		if nil == GetTx(ctx) {
+			return nil, fmt.Errorf("%w: %s read of %s", ErrNoTransaction, lock, metadata.Name)
+		}
+		clause += " " + lock.String()
+	}
+	return metadata.GetEntityContext(ctx, entity, clause, v...)
+}
+
+func (metadata TableMetadata) GetEntityForUpdate(ctx context.Context, entity interface{}, clause string, v ...interface{}) (interface{}, error) {
+	return metadata.GetEntityLockedContext(ctx, LockForUpdate, entity, clause, v...)
+}
+
+func (metadata TableMetadata) GetEntityForShare(ctx context.Context, entity interface{}, clause string, v ...interface{}) (interface{}, error) {
+	return metadata.GetEntityLockedContext(ctx, LockForShare, entity, clause, v...)
+}
+
+func (metadata TableMetadata) GetEntityByIdForUpdate(ctx context.Context, entity interface{}, id uint) (interface{}, error) {
+	return metadata.GetEntityLockedContext(ctx, LockForUpdate, entity, " WHERE id = ?", id)
+}
+
+func (metadata TableMetadata) GetEntityByIdForShare(ctx context.Context, entity interface{}, id uint) (interface{}, error) {
+	return metadata.GetEntityLockedContext(ctx, LockForShare, entity, " WHERE id = ?", id)
+}
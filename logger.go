@@ -0,0 +1,122 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+)
+
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (level LogLevel) String() string {
+	switch level {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+	return "unknown"
+}
+
+func (level LogLevel) MarshalText() ([]byte, error) {
+	return []byte(level.String()), nil
+}
+
+func (level *LogLevel) UnmarshalText(text []byte) error {
+	for _, l := range []LogLevel{LogDebug, LogInfo, LogWarn, LogError} {
+		if l.String() == string(text) {
+			*level = l
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log level %q", text)
+}
+
+// Logger receives the warnings and diagnostics this package produces.
+// Set it globally with SetLogger, or per table with TableMetadata.Logger.
+type Logger interface {
+	Logf(level LogLevel, format string, v ...interface{})
+}
+
+// StdLogger writes to the standard library logger, dropping messages below MinLevel.
+type StdLogger struct {
+	MinLevel LogLevel
+}
+
+func (l StdLogger) Logf(level LogLevel, format string, v ...interface{}) {
+	if level >= l.MinLevel {
+		log.Printf(level.String()+": "+format, v...)
+	}
+}
+
+// NopLogger discards everything.
+type NopLogger struct{}
+
+func (NopLogger) Logf(level LogLevel, format string, v ...interface{}) {}
+
+// SlogLogger adapts a *slog.Logger.
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+func (l SlogLogger) Logf(level LogLevel, format string, v ...interface{}) {
+	slogLevel := slog.LevelInfo
+	switch level {
+	case LogDebug:
+		slogLevel = slog.LevelDebug
+	case LogWarn:
+		slogLevel = slog.LevelWarn
+	case LogError:
+		slogLevel = slog.LevelError
+	}
+	l.Logger.Log(context.Background(), slogLevel, fmt.Sprintf(format, v...), "package", "mysqlmeta")
+}
+
+var (
+	loggerMutex   sync.RWMutex
+	packageLogger Logger = StdLogger{MinLevel: LogInfo}
+)
+
+func SetLogger(logger Logger) {
+	// Replaces the package-wide logger. A nil logger silences all output.
+	if nil == logger {
+		logger = NopLogger{}
+	}
+	loggerMutex.Lock()
+	defer loggerMutex.Unlock()
+	packageLogger = logger
+}
+
+func GetLogger() Logger {
+	loggerMutex.RLock()
+	defer loggerMutex.RUnlock()
+	return packageLogger
+}
+
+func logf(level LogLevel, format string, v ...interface{}) {
+	GetLogger().Logf(level, format, v...)
+}
+
+func (metadata TableMetadata) logger() Logger {
+	if nil != metadata.Logger {
+		return metadata.Logger
+	}
+	return GetLogger()
+}
+
+func (metadata TableMetadata) logf(level LogLevel, format string, v ...interface{}) {
+	metadata.logger().Logf(level, format, v...)
+}
@@ -0,0 +1,59 @@
+package mysqlmeta
+
+import (
+	"reflect"
+	"strings"
+)
+
+func isExcludedField(field reflect.StructField) bool {
+	// A field tagged sql:"-" only lives in Go, ex. a cache, and is never matched to a
+	// column, selected, inserted, updated or scanned.
+	return "-" == field.Tag.Get("sql")
+}
+
+func sqlTagColumn(field reflect.StructField) string {
+	// Returns the column named by the first token of the field's sql tag, ex. "usr_nm"
+	// for sql:"usr_nm,no-update", or "" if the tag starts with an option instead.
+	// Options other than "version" and "tenant" cannot be column names, as they
+	// contain - or =.
+	name := strings.Split(field.Tag.Get("sql"), ",")[0]
+	if "version" == name || "tenant" == name || !SQL_COLUMN_NAME.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+func dropUnmappedColumns(cols []ColumnMetadata, fieldByColumn map[string]int) []ColumnMetadata {
+	// Leaves out the columns without a field, which are then never selected, inserted
+	// or updated - inserts rely on their defaults.
+	mapped := make([]ColumnMetadata, 0, len(cols))
+	for _, col := range cols {
+		if 0 > fieldByColumn[col.Field] {
+			delete(fieldByColumn, col.Field)
+			continue
+		}
+		mapped = append(mapped, col)
+	}
+	return mapped
+}
+
+func findExtraFields(entityType reflect.Type, fieldByColumn, fieldByVirtual map[string]int) []string {
+	// Lists the exported fields that are neither mapped to a column nor virtual, nor
+	// excluded with sql:"-".
+	used := map[int]bool{}
+	for _, j := range fieldByColumn {
+		used[j] = true
+	}
+	for _, j := range fieldByVirtual {
+		used[j] = true
+	}
+	extra := []string{}
+	for j := 0; j < entityType.NumField(); j++ {
+		field := entityType.Field(j)
+		if used[j] || "" != field.PkgPath || isExcludedField(field) {
+			continue
+		}
+		extra = append(extra, field.Name)
+	}
+	return extra
+}
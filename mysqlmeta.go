@@ -1,13 +1,15 @@
 package mysqlmeta
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
-	"errors"
-	"log"
+	"fmt"
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -15,7 +17,7 @@ import (
 var SQL_INT_TYPE = regexp.MustCompile("(?i)^(tiny|small|medium||big)int(\\(\\d+\\))?$")
 var SQL_UINT_TYPE = regexp.MustCompile("(?i)^(tiny|small|medium||big)int(\\(\\d+\\))? unsigned$")
 var SQL_FLOAT_TYPE = regexp.MustCompile("(?i)^(float|double)(\\(\\d+\\))?( unsigned)?$")
-var SQL_STRING_TYPE = regexp.MustCompile("(?i)^((char|varchar|binary|varbinary)(\\(\\d+\\))?|text|blob|enum.*)$")
+var SQL_STRING_TYPE = regexp.MustCompile("(?i)^((char|varchar|binary|varbinary)(\\(\\d+\\))?|text|blob|enum.*|set\\(.*)$")
 
 type IndexMetadata struct {
 	TableName    string  `json:"table_name"`
@@ -34,22 +36,55 @@ type IndexMetadata struct {
 }
 
 type ColumnMetadata struct {
-	Field        string          `json:"field,omitempty"`
-	ColumnType   string          `json:"column_type,omitempty"`
-	Nullable     string          `json:"nullable,omitempty"`
-	Key          string          `json:"key,omitempty"`
-	DefaultValue string          `json:"default_value,omitempty"`
-	Extra        string          `json:"extra,omitempty"`
-	StructField  string          `json:"struct_field,omitempty"`
-	NoInsert     bool            `json:"no_insert,omitempty"`
-	NoUpdate     bool            `json:"no_update,omitempty"`
-	Indexes      []IndexMetadata `json:"indexes,omitempty"`
+	Field        string `json:"field,omitempty"`
+	ColumnType   string `json:"column_type,omitempty"`
+	Nullable     string `json:"nullable,omitempty"`
+	Key          string `json:"key,omitempty"`
+	DefaultValue string `json:"default_value,omitempty"`
+	Extra        string `json:"extra,omitempty"`
+	StructField  string `json:"struct_field,omitempty"`
+	NoInsert     bool   `json:"no_insert,omitempty"`
+	ForceInsert  bool   `json:"force_insert,omitempty"`
+	NoUpdate     bool   `json:"no_update,omitempty"`
+	SoftDelete   bool   `json:"soft_delete,omitempty"`
+	Version      bool   `json:"version,omitempty"`
+	Tenant       bool   `json:"tenant,omitempty"`
+	NaturalKey   bool   `json:"natural_key,omitempty"`
+	Invisible    bool   `json:"invisible,omitempty"`
+	// InsertDefault and UpdateDefault are written when the field is zero - see WithDefaultValue
+	InsertDefault string `json:"insert_default,omitempty"`
+	UpdateDefault string `json:"update_default,omitempty"`
+	// AutoCreateTime and AutoUpdateTime columns are set to the current time on insert and update
+	AutoCreateTime bool            `json:"auto_create_time,omitempty"`
+	AutoUpdateTime bool            `json:"auto_update_time,omitempty"`
+	Indexes        []IndexMetadata `json:"indexes,omitempty"`
+	// Default is DefaultValue parsed into a typed Go value
+	Default ColumnDefault `json:"default"`
+	// GenerationExpression is only filled in for generated columns
+	GenerationExpression string `json:"generation_expression,omitempty"`
+	// Expression is only filled in for virtual columns - see the sqlexpr tag
+	Expression string `json:"expression,omitempty"`
+	// Enum names the allowed values of fields of a registered enum type - see RegisterEnum
+	Enum map[int64]string `json:"enum,omitempty"`
+	// AllowedValues are the values of an ENUM or SET column, in order
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	// These are only filled in from information_schema - see InformationSchema
+	NumericPrecision   uint   `json:"numeric_precision,omitempty"`
+	NumericScale       uint   `json:"numeric_scale,omitempty"`
+	CharacterMaxLength uint64 `json:"character_max_length,omitempty"`
+	CharacterSet       string `json:"character_set,omitempty"`
+	// Collation is empty for columns that are not strings
+	Collation string `json:"collation,omitempty"`
+	Comment   string `json:"comment,omitempty"`
 }
 
 type TableMetadata struct {
 	DB             *sql.DB          `json:"-"`
 	Name           string           `json:"name,omitempty"`
+	BaseName       string           `json:"base_name,omitempty"`
+	Comment        string           `json:"comment,omitempty"`
 	Columns        []ColumnMetadata `json:"columns,omitempty"`
+	SelectColumns  []ColumnMetadata `json:"-"`
 	InsertColumns  []ColumnMetadata `json:"-"`
 	UpdateColumns  []ColumnMetadata `json:"-"`
 	ColumnNames    string           `json:"column_names,omitempty"`
@@ -59,15 +94,122 @@ type TableMetadata struct {
 	EntityType     reflect.Type     `json:"-"`
 	EntityTypeName string           `json:"type_name,omitempty"`
 	FieldByColumn  map[string]int   `json:"field_by_name,omitempty"`
-	Warn           string           `json:"warn,omitempty"`
+	// Warnings lists the mismatches between the entity struct and the table
+	Warnings SchemaWarnings `json:"warnings,omitempty"`
+	// VirtualColumns are selected from the SQL expressions of sqlexpr tagged fields
+	VirtualColumns []ColumnMetadata `json:"virtual_columns,omitempty"`
+	FieldByVirtual map[string]int   `json:"-"`
+	// Indexes groups the index parts of the columns by index
+	Indexes []IndexDefinition `json:"indexes,omitempty"`
+	// ServerVersion is the version of the server the table was fetched from, nil if
+	// unknown - see ServerVersion.Supports
+	ServerVersion *ServerVersion `json:"server_version,omitempty"`
+	// CreateTable and TableOptions are only filled in by LoadTableOptions
+	CreateTable  string        `json:"create_table,omitempty"`
+	TableOptions *TableOptions `json:"table_options,omitempty"`
+	// Status is only filled in by LoadTableStatus
+	Status *TableStatus `json:"status,omitempty"`
+	// ForeignKeys lists the constraints from this table to others
+	ForeignKeys []ForeignKeyMetadata `json:"foreign_keys,omitempty"`
+	// CheckConstraints lists the CHECK constraints of the table
+	CheckConstraints []CheckConstraint `json:"check_constraints,omitempty"`
+	// SoftDeleteColumn is set when deletes only mark rows as deleted - see SoftDelete
+	SoftDeleteColumn string `json:"soft_delete_column,omitempty"`
+	// VersionColumn is set when updates use optimistic locking - see the sql:"version" tag
+	VersionColumn string `json:"version_column,omitempty"`
+	// TenantColumn is set when statements are scoped to the tenant of their context -
+	// see the sql:"tenant" tag and WithTenant
+	TenantColumn string `json:"tenant_column,omitempty"`
+
+	// Options - these are set before FetchTableMetadata and kept by it.
+	// Config supplies the options that are not set here - see Config.
+	Config     Config                             `json:"-"`
+	TagQueries bool                               `json:"-"`
+	Policies   map[OperationClass]OperationPolicy `json:"-"`
+	Logger     Logger                             `json:"-"`
+	// Observer is told of each statement run for the table - see QueryObserver
+	Observer QueryObserver `json:"-"`
+	// Tracer starts a span around each statement run for the table - see Tracer
+	Tracer Tracer `json:"-"`
+	// QueryTagger adds tags to those of WithQueryTag for each statement, ex. the
+	// trace of the context - see TagQueries
+	QueryTagger func(ctx context.Context) []QueryTag `json:"-"`
+	// SQLCommenter formats the tags as sqlcommenter does, ex.
+	// /*app='checkout',traceparent='00-...-01'*/, for the tools that parse them
+	SQLCommenter bool `json:"-"`
+	// TablePrefix is put in front of the table name given to FetchTableMetadata,
+	// for databases shared by several applications
+	TablePrefix string `json:"table_prefix,omitempty"`
+	// SoftDelete makes a deleted_at column mark deleted rows, which queries then skip
+	SoftDelete bool `json:"-"`
+	// CacheStatements prepares each distinct query once and reuses it - call Close when done
+	CacheStatements bool `json:"-"`
+	// StatementCacheSize is the most statements CacheStatements keeps, closing the
+	// least recently used beyond it - STMT_CACHE_SIZE if zero
+	StatementCacheSize int `json:"-"`
+	// ComputeGenerated leaves simple generated columns, integer and string ones over
+	// integer and string columns, out of SELECT and evaluates them after scan instead
+	ComputeGenerated bool             `json:"-"`
+	ComputedColumns  []ColumnMetadata `json:"-"`
+	// ColumnOrder sets the order of Columns, and so of generated statements and JSON
+	ColumnOrder ColumnOrder `json:"-"`
+	// NaturalKey lists the columns of a business key, ex. (tenant_id, external_id),
+	// otherwise it is made of the columns tagged sql:"natural-key"
+	NaturalKey []string `json:"natural_key,omitempty"`
+	// Transforms are applied to fields as they are read and written, by column name
+	Transforms map[string]ColumnTransform `json:"-"`
+	// ScanByName makes ScanEntity match result columns to fields by name rather than
+	// by position, for rows from hand-written SELECTs - see ScanEntityByName
+	ScanByName bool `json:"-"`
+	// AllowUnmappedColumns leaves out columns without a field, ex. audit or legacy
+	// columns, rather than failing FetchTableMetadata
+	AllowUnmappedColumns bool `json:"-"`
+	// AllowExtraFields silences the warning for exported fields without a column,
+	// which otherwise fails FetchTableMetadata in strict mode - or tag them sql:"-"
+	AllowExtraFields bool `json:"-"`
+	// InformationSchema reads the columns and indexes from information_schema rather
+	// than with SHOW FULL COLUMNS and SHOW INDEXES, adding their precision and character
+	// set
+	InformationSchema bool `json:"-"`
+	// Dialect adapts introspection and inserts to the server, ex. DialectVitess
+	Dialect Dialect `json:"-"`
+	// AdviseIndexes logs a warning when the clause of GetEntity or GetEntities compares
+	// columns for equality but none of them is indexed - see AdviseIndex
+	AdviseIndexes bool `json:"-"`
+	// Replicas take the SELECTs run outside transactions, in turn, while the writes go
+	// to DB - see WithPrimary
+	Replicas []*sql.DB `json:"-"`
+	// ReplicaWait is how long a read waits for a replica to catch up with the writes
+	// of the context's Session, before going to the primary - see WithSession
+	ReplicaWait time.Duration `json:"-"`
+	// Breaker fails statements fast after repeated failures of the database - see
+	// CircuitBreaker
+	Breaker *CircuitBreaker `json:"-"`
+	// TableResolver picks the table of each statement from its context, for tables
+	// split per tenant or shard, ex. ShardedTable("events_%04d", 16). The metadata is
+	// fetched from one of them, and the statements of the others use its columns.
+	TableResolver TableResolver `json:"-"`
+
+	scan     *scanPlan
+	computed []GeneratedExpr
+	stmts    *stmtCache
+	activity *activityLog
+	gate     *operationGate
+	unscoped bool
 }
 
 func CamelCaseToSnakeCase(snakeCaseName string) string {
 	// This matches MySQL snake-case (ex. "order_id") to Golang camelcase (ex. "OrderId").
+	// A run of capitals is one word, so "OrderID" and "APIKey" become "order_id" and "api_key".
+	runes := []rune(snakeCaseName)
 	result := ""
-	for i, c := range snakeCaseName {
-		if (0 != i) && unicode.IsUpper(c) {
-			result += "_"
+	for i, c := range runes {
+		if 0 != i && unicode.IsUpper(c) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(previous) || nextIsLower {
+				result += "_"
+			}
 		}
 		result += string(unicode.ToLower(c))
 	}
@@ -87,13 +229,30 @@ func SnakeCaseToCamelCase(snakeCaseName string) string {
 func (col ColumnMetadata) AllowInsert(val reflect.Value) bool {
 	// Struct fields can use StructTag of sql:"no-insert" to disallow insert of that field
 	// cf. https://golang.org/pkg/reflect/#example_StructTag
-	return "id" != col.Field && !col.NoInsert
+	// Columns the server fills in, auto_increment ones and those with a default like
+	// CURRENT_TIMESTAMP, are left out unless tagged sql:"insert", or set by the package.
+	if col.NoInsert || col.IsGenerated() {
+		return false
+	}
+	if col.ForceInsert {
+		return true
+	}
+	if col.IsAutoIncrement() {
+		return false
+	}
+	serverDefault := DefaultCurrentTimestamp == col.Default.Kind || DefaultExpression == col.Default.Kind
+	return !serverDefault || col.AutoCreateTime || col.AutoUpdateTime || "" != col.InsertDefault
 }
 
 func (col ColumnMetadata) AllowUpdate(val reflect.Value) bool {
 	// Struct fields can use StructTag of sql:"no-update" to disallow update of that field
 	// cf. https://golang.org/pkg/reflect/#example_StructTag
-	return "id" != col.Field && !col.NoUpdate
+	// Creation times are never updated, and neither are the primary key, by which rows
+	// are updated, nor columns the server updates or generates itself
+	if "id" == col.Field || "PRI" == col.Key || col.IsAutoIncrement() {
+		return false
+	}
+	return !col.NoUpdate && !col.AutoCreateTime && !col.IsOnUpdateCurrentTimestamp() && !col.IsGenerated()
 }
 
 func GetValueId(value reflect.Value) uint {
@@ -104,25 +263,58 @@ func SetValueId(value reflect.Value, id uint) {
 	value.FieldByName("Id").SetUint(uint64(id))
 }
 
+func isInvisibleExtra(extra string) bool {
+	// MySQL 8.0.23 and MariaDB 10.3 can hide columns from SELECT *, though a SELECT
+	// naming them still reads them.
+	return strings.Contains(strings.ToUpper(extra), "INVISIBLE")
+}
+
 func GetColumns(db *sql.DB, tableName string) ([]ColumnMetadata, error) {
-	rows, err := db.Query("SHOW COLUMNS FROM `" + tableName + "`")
+	err := CheckTableName(tableName)
 	if nil != err {
-		log.Printf("sql query failed: %v", err)
 		return nil, err
 	}
+	rows, err := db.Query("SHOW FULL COLUMNS FROM " + QuoteTableName(tableName))
+	if nil != err {
+		return nil, fmt.Errorf("show columns from %s: %w", tableName, err)
+	}
 	defer rows.Close()
 	cols := []ColumnMetadata{}
+	// SHOW FULL COLUMNS returns field, type, collation, nullable, key, default, extra,
+	// privileges, comment
+	col := ColumnMetadata{}
+	defaultValue := sql.NullString{}
+	collation := sql.NullString{}
+	targets, err := namedTargets(rows, map[string]interface{}{
+		"field":     &col.Field,
+		"type":      &col.ColumnType,
+		"collation": &collation,
+		"null":      &col.Nullable,
+		"key":       &col.Key,
+		"default":   &defaultValue,
+		"extra":     &col.Extra,
+		"comment":   &col.Comment,
+	})
+	if nil != err {
+		return nil, fmt.Errorf("show columns from %s: %w", tableName, err)
+	}
 	for rows.Next() {
-		// SHOW COLUMNS returns field, type, nullable, key, default, extra
-		col := ColumnMetadata{}
-		rows.Scan(&col.Field, &col.ColumnType, &col.Nullable, &col.Key, &col.DefaultValue, &col.Extra)
+		col = ColumnMetadata{}
+		err = rows.Scan(targets...)
 		if nil != err {
-			log.Printf("problem parsing column metadata for %v\n%v", tableName, err)
-			return nil, err
+			return nil, fmt.Errorf("problem parsing column metadata for %s: %w", tableName, err)
 		} else {
+			col.Collation = collation.String
+			col.Invisible = isInvisibleExtra(col.Extra)
+			col.AllowedValues = parseAllowedValues(col.ColumnType)
+			col.DefaultValue = defaultValue.String
+			col.Default = ParseColumnDefault(defaultValue, col.ColumnType, col.Extra)
 			cols = append(cols, col)
 		}
 	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("show columns from %s: %w", tableName, err)
+	}
 	return cols, nil
 }
 
@@ -131,10 +323,9 @@ func GetIndexes(db *sql.DB, tableName string, cols []ColumnMetadata) ([]ColumnMe
 	if nil != err {
 		return nil, err
 	}
-	rows, err := db.Query("SHOW INDEXES FROM `" + tableName + "`")
+	rows, err := db.Query("SHOW INDEXES FROM " + QuoteTableName(tableName))
 	if nil != err {
-		log.Printf("sql query failed\n%v", err)
-		return nil, err
+		return nil, fmt.Errorf("show indexes from %s: %w", tableName, err)
 	}
 	defer rows.Close()
 	// Create a map of column names to column indexes
@@ -142,29 +333,39 @@ func GetIndexes(db *sql.DB, tableName string, cols []ColumnMetadata) ([]ColumnMe
 	for i, _ := range cols {
 		imap[cols[i].Field] = i
 	}
+	// SHOW INDEXES returns Table, Non_unique, Key_name, Seq_in_index, Column_name,
+	// Collation, Cardinality, Sub_part, Packed, Null, Index_type, Comment, Index_comment,
+	// and more columns depending on the server. Functional indexes of MySQL 8 have no
+	// column, and cardinality may be unknown.
+	ind := IndexMetadata{}
+	var columnName sql.NullString
+	var cardinality sql.NullInt64
+	targets, err := namedTargets(rows, map[string]interface{}{
+		"table":         &ind.TableName,
+		"non_unique":    &ind.NonUnique,
+		"key_name":      &ind.KeyName,
+		"seq_in_index":  &ind.SeqInIndex,
+		"column_name":   &columnName,
+		"collation":     &ind.Collation,
+		"cardinality":   &cardinality,
+		"sub_part":      &ind.SubPart,
+		"packed":        &ind.Packed,
+		"null":          &ind.Null,
+		"index_type":    &ind.IndexType,
+		"comment":       &ind.Comment,
+		"index_comment": &ind.IndexComment,
+	})
+	if nil != err {
+		return nil, fmt.Errorf("show indexes from %s: %w", tableName, err)
+	}
 	for rows.Next() {
-		// SHOW INDEXES returns Table, Non_unique, Key_name, Seq_in_index, Column_name,
-		// Collation, Cardinality, Sub_part, Packed, Null, Index_type, Comment, Index_comment
-		ind := IndexMetadata{}
-		err = rows.Scan(
-			&ind.TableName,
-			&ind.NonUnique,
-			&ind.KeyName,
-			&ind.SeqInIndex,
-			&ind.ColumnName,
-			&ind.Collation,
-			&ind.Cardinality,
-			&ind.SubPart,
-			&ind.Packed,
-			&ind.Null,
-			&ind.IndexType,
-			&ind.Comment,
-			&ind.IndexComment,
-		)
+		ind = IndexMetadata{}
+		err = rows.Scan(targets...)
 		if nil != err {
-			log.Printf("problem parsing index metadata\n%v", err)
-			return nil, err
+			return nil, fmt.Errorf("problem parsing index metadata for %s: %w", tableName, err)
 		} else {
+			ind.ColumnName = columnName.String
+			ind.Cardinality = uint(cardinality.Int64)
 			// find the correct column to append this to
 			i, ok := imap[ind.ColumnName]
 			if ok {
@@ -175,16 +376,37 @@ func GetIndexes(db *sql.DB, tableName string, cols []ColumnMetadata) ([]ColumnMe
 			}
 		}
 	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("show indexes from %s: %w", tableName, err)
+	}
 	return cols, nil
 }
 
+// treat as const - a table name, ex. "orders2024", optionally qualified with its
+// database, ex. "analytics.events". Quoted names may hold more, but are not accepted.
+var VALID_TABLE_NAME = regexp.MustCompile("^(?:[a-zA-Z0-9_$]+\\.)?[a-zA-Z0-9_$]+$")
+
 func CheckTableName(tableName string) error {
-	validTableName := regexp.MustCompile("^[a-zA-Z_]+$")
-	if validTableName.MatchString(tableName) {
+	if VALID_TABLE_NAME.MatchString(tableName) {
 		return nil
 	} else {
-		return errors.New("invalid table name")
+		return fmt.Errorf("%w %q", ErrInvalidTableName, tableName)
+	}
+}
+
+func QuoteIdentifier(name string) string {
+	// Quotes a column, index or other name for SQL, ex. `order`, doubling any backquote
+	// in it so that the name cannot end the quoting.
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func QuoteTableName(tableName string) string {
+	// Quotes a table name, quoting its database apart if qualified, ex.
+	// `analytics`.`events`.
+	if schema, table, ok := strings.Cut(tableName, "."); ok {
+		return QuoteIdentifier(schema) + "." + QuoteIdentifier(table)
 	}
+	return QuoteIdentifier(tableName)
 }
 
 func GetStructValue(entity interface{}) (reflect.Value, error) {
@@ -199,29 +421,72 @@ func GetStructValue(entity interface{}) (reflect.Value, error) {
 			return e, nil
 		}
 	}
-	log.Printf("invalid input to internal call - require pointer to struct\n%v", v.Kind())
-	return reflect.ValueOf(nil), errors.New("invalid pointer argument")
+	return reflect.ValueOf(nil), fmt.Errorf("%w: require pointer to struct, got %v", ErrInvalidEntity, v.Kind())
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// returns true if values of the type are handed to database/sql as they are,
+// ex. sql.NullString, rather than being converted to and from JSON. A struct must be
+// both a Valuer and a Scanner, as it is written and read the same way.
+func IsPassThroughType(fieldType reflect.Type) bool {
+	valuer := fieldType.Implements(valuerType)
+	scanner := reflect.PtrTo(fieldType).Implements(scannerType)
+	if reflect.Struct == fieldType.Kind() {
+		return timeType == fieldType || (valuer && scanner)
+	}
+	return valuer || scanner
+}
+
+// returns true if the struct field holds a JSON document stored as a string column
+func IsJsonType(fieldType reflect.Type) bool {
+	return reflect.Struct == fieldType.Kind() && !IsPassThroughType(fieldType)
 }
 
 // returns true if field matches db column, or false if there is a mismatch warning
 func (col ColumnMetadata) CheckFieldType(tableName string, field reflect.StructField) bool {
-	valid := true
+	return nil == col.fieldWarning(GetLogger(), tableName, field)
+}
+
+func (col ColumnMetadata) fieldWarning(logger Logger, tableName string, field reflect.StructField) *SchemaWarning {
+	// Logs and returns the mismatch between the field and the column, or nil if none.
 	fieldType := field.Type
+	if isSetField(col, fieldType) {
+		// SET columns are split into []string fields, and NULL scans into nil
+		return nil
+	}
+	if timeType != fieldType && IsPassThroughType(fieldType) {
+		// Scanner and Valuer types (ex. sql.NullInt64) handle NULL and conversion
+		// themselves, so neither nullability nor type can be checked here.
+		return nil
+	}
+	warning := &SchemaWarning{Column: col.Field, Field: field.Name, Kind: WARNING_NULLABLE, Severity: LogWarn}
 	if reflect.Ptr == fieldType.Kind() {
 		fieldType = fieldType.Elem()
 		if "YES" != col.Nullable {
-			valid = false
+			// a pointer is never nil, which is harmless
+			warning.Expected, warning.Actual, warning.Severity = "NULL", "NOT NULL", LogInfo
 		}
 	} else {
 		if "NO" != col.Nullable {
-			valid = false
+			// scanning NULL into the field fails
+			warning.Expected, warning.Actual, warning.Severity = "NOT NULL", "NULL", LogError
 		}
 	}
-	if !valid {
-		log.Printf("mismatch of nullable for column %s.%s", tableName, col.Field)
-		return false
+	if "" != warning.Expected {
+		logger.Logf(LogWarn, "mismatch of nullable for column %s.%s", tableName, col.Field)
+		return warning
 	}
+	valid := true
 	switch fieldType.Kind() {
+	case reflect.Struct:
+		// only time.Time gets here - other structs are JSON documents
+		if timeType == fieldType {
+			valid = SQL_TIME_TYPE.MatchString(col.ColumnType)
+		} else {
+			valid = SQL_STRING_TYPE.MatchString(col.ColumnType)
+		}
 	case reflect.Bool:
 		valid = (col.ColumnType == "tinyint(1) unsigned")
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
@@ -230,58 +495,72 @@ func (col ColumnMetadata) CheckFieldType(tableName string, field reflect.StructF
 		valid = SQL_UINT_TYPE.MatchString(col.ColumnType)
 	case reflect.Float32, reflect.Float64:
 		valid = SQL_FLOAT_TYPE.MatchString(col.ColumnType)
-	case reflect.String, reflect.Struct:
+	case reflect.String:
 		valid = SQL_STRING_TYPE.MatchString(col.ColumnType)
 	}
 	if !valid {
-		log.Printf("mismatch of type for column")
-		// "tableName":     tableName,
-		// "sqlColumnName": col.Field,
-		// "sqlColumnType": col.ColumnType,
-		// "fieldName":     field.Name,
-		// "fieldType":     fieldType.Kind(),
-		return false
+		logger.Logf(LogWarn, "mismatch of type for column %s.%s of type %s with field %s of type %v",
+			tableName, col.Field, col.ColumnType, field.Name, fieldType.Kind())
+		warning.Kind, warning.Expected, warning.Actual = WARNING_TYPE, field.Type.String(), col.ColumnType
+		return warning
 	}
-	return true
+	return nil
 }
 
 func (metadata TableMetadata) CheckFieldTypes(entity interface{}) (string, error) {
+	// Returns the mismatched types in the old string form - see FieldTypeWarnings.
+	warnings, err := metadata.FieldTypeWarnings(entity)
+	return warnings.String(), err
+}
+
+func (metadata TableMetadata) FieldTypeWarnings(entity interface{}) (SchemaWarnings, error) {
 	value, err := GetStructValue(entity)
 	if nil != err {
-		return "", err
+		return nil, err
 	}
 	entityType := value.Type()
-	warn := ""
-	sep := ""
+	warnings := SchemaWarnings{}
 	for _, col := range metadata.Columns {
 		field := entityType.Field(metadata.FieldByColumn[col.Field])
-		if !col.CheckFieldType(metadata.Name, field) {
-			warn += (sep + col.Field)
-			sep = ","
+		if warning := col.fieldWarning(metadata.logger(), metadata.Name, field); nil != warning {
+			warnings = append(warnings, *warning)
 		}
 	}
-	if "" != warn {
-		return "Warning: mismatched type in columns " + warn, nil
-	} else {
-		return "", nil
-	}
+	return warnings, nil
 }
 
 func (col ColumnMetadata) GetMatchingFieldIndex(entityType reflect.Type) int {
 	// Given an SQL column and a struct Type, this returns the index of the
 	// corresponding field in the struct for that SQL column.
+	match := col.matchingFieldIndex(entityType, DefaultNaming)
+	if -1 == match {
+		logf(LogWarn, "failed to match column %s into entity type %v", col.Field, entityType.Name())
+	}
+	return match
+}
+
+func (col ColumnMetadata) matchingFieldIndex(entityType reflect.Type, naming NamingStrategy) int {
+	// A column named in the sql tag of a field always goes to that field, and such
+	// fields are not matched by name to any other column.
+	for i := 0; i < entityType.NumField(); i++ {
+		field := entityType.Field(i)
+		if !isExcludedField(field) && col.Field == sqlTagColumn(field) {
+			return i
+		}
+	}
 	match := -1
-	camelCaseName := SnakeCaseToCamelCase(col.Field)
+	fieldName := naming.FieldName(col.Field)
 	for i := 0; i < entityType.NumField(); i++ {
-		if camelCaseName == entityType.Field(i).Name {
+		field := entityType.Field(i)
+		if isExcludedField(field) || "" != sqlTagColumn(field) {
+			continue
+		}
+		if fieldName == field.Name || col.Field == naming.ColumnName(field.Name) {
 			// This records the index of the matching struct field
 			match = i
 			break
 		}
 	}
-	if -1 == match {
-		log.Printf("failed to match column %s into entity type %v", col.Field, entityType.Name())
-	}
 	return match
 }
 
@@ -294,11 +573,33 @@ func (col *ColumnMetadata) ReadSqlStructTags(field reflect.StructField) error {
 				col.NoInsert = true
 			case "no-update":
 				col.NoUpdate = true
+			case "insert":
+				col.ForceInsert = true
+			case "soft-delete":
+				col.SoftDelete = true
+			case "version":
+				col.Version = true
+			case "tenant":
+				// a row never moves to another tenant
+				col.Tenant = true
+				col.NoUpdate = true
+			case "natural-key":
+				col.NaturalKey = true
+			case "auto-create-time":
+				col.AutoCreateTime = true
+			case "auto-update-time":
+				col.AutoUpdateTime = true
 			default:
-				if 0 == i {
+				switch {
+				case strings.HasPrefix(tag, "insert-default="):
+					col.InsertDefault = strings.TrimPrefix(tag, "insert-default=")
+				case strings.HasPrefix(tag, "update-default="):
+					col.UpdateDefault = strings.TrimPrefix(tag, "update-default=")
+				case 0 == i:
 					col.StructField = tag
-				} else {
-					log.Printf(
+				default:
+					logf(
+						LogWarn,
 						"unrecognized tag in sql StructTag for col %v\n%v\n%v",
 						col.Field,
 						tagString,
@@ -311,12 +612,43 @@ func (col *ColumnMetadata) ReadSqlStructTags(field reflect.StructField) error {
 	return nil
 }
 
+func (metadata TableMetadata) getColumnsWithIndexes(db *sql.DB, tableName string) ([]ColumnMetadata, error) {
+	if metadata.informationSchemaOnly() {
+		return getSchemaTable(db, tableName)
+	}
+	cols, err := GetColumns(db, tableName)
+	if nil != err {
+		return nil, err
+	}
+	// append index information into the column metadata
+	cols, err = GetIndexes(db, tableName, cols)
+	if nil != err {
+		return nil, err
+	}
+	// generation expressions are only in information_schema, so look them up if needed
+	for _, col := range cols {
+		if col.IsGenerated() {
+			exprs, err := GetGenerationExpressions(db, tableName)
+			if nil != err {
+				return nil, err
+			}
+			for i := range cols {
+				cols[i].GenerationExpression = exprs[cols[i].Field]
+			}
+			break
+		}
+	}
+	return cols, nil
+}
+
 func (metadata *TableMetadata) FetchTableMetadata(db *sql.DB, tableName string, entity interface{}) error {
 	// check if metadata is already filled in - if so, do nothing
 	if (nil != metadata) && ("" != metadata.Name) {
 		return nil
 	}
-	// check that there is a valid tableName
+	// apply any table prefix, and check that there is a valid tableName
+	baseName := tableName
+	tableName = metadata.PrefixedName(tableName)
 	err := CheckTableName(tableName)
 	if nil != err {
 		return err
@@ -326,90 +658,246 @@ func (metadata *TableMetadata) FetchTableMetadata(db *sql.DB, tableName string,
 	if nil != err {
 		return err
 	}
+	config := metadata.applyConfig()
+	_, end := startSpan(context.Background(), metadata.Tracer, Span{Table: tableName, Operation: "fetch"})
+	err = metadata.fetch(db, tableName, baseName, value, config)
+	end(-1, err)
+	return err
+}
+
+func (metadata *TableMetadata) fetch(db *sql.DB, tableName string, baseName string, value reflect.Value, config Config) error {
+	// The queries of FetchTableMetadata, then describe.
 	// store the database for future use
 	metadata.DB = db
+	version, err := GetServerVersion(context.Background(), db)
+	if nil != err {
+		return err
+	}
+	metadata.ServerVersion = version
 	// access the database and get the column definitions for this table
-	cols, err := GetColumns(db, tableName)
+	cols, err := metadata.getColumnsWithIndexes(db, tableName)
 	if nil != err {
 		return err
 	}
-	// append index information into the column metadata
-	cols, err = GetIndexes(db, tableName, cols)
+	foreignKeys, err := GetForeignKeys(db, tableName)
 	if nil != err {
 		return err
 	}
-	// get the column names as a comma-separated list for use in SQL statements
-	selectColNames := ""
-	separator := ""
-	for _, col := range cols {
-		selectColNames += (separator + "`" + col.Field + "`")
-		separator = ", "
+	metadata.CheckConstraints, err = GetCheckConstraints(db, tableName)
+	if nil != err {
+		return err
 	}
-	selectString := "SELECT " + selectColNames + " FROM `" + tableName + "` "
+	comment, err := GetTableComment(db, tableName)
+	if nil != err {
+		return err
+	}
+	cols, err = orderColumns(db, tableName, cols, metadata.ColumnOrder)
+	if nil != err {
+		return err
+	}
+	return metadata.describe(db, tableName, baseName, value, cols, foreignKeys, comment, config)
+}
 
+func (metadata *TableMetadata) describe(db *sql.DB, tableName string, baseName string, value reflect.Value,
+	cols []ColumnMetadata, foreignKeys []ForeignKeyMetadata, comment string, config Config) error {
+	// Fills in the metadata from the columns of the table and the entity struct.
 	// Use reflect to create a map of SQL names to field indexes of the given type
 	entityType := value.Type()
 
 	// Map the MySQL columns to the struct fields
 	fieldByColumn := map[string]int{}
-	allMatched := true
+	unmatched := []string{}
+	hidden := []string{}
 	for i, col := range cols {
-		fieldByColumn[col.Field] = cols[i].GetMatchingFieldIndex(entityType)
-		if 0 > fieldByColumn[col.Field] {
+		fieldByColumn[col.Field] = cols[i].matchingFieldIndex(entityType, config.naming())
+		if 0 > fieldByColumn[col.Field] && col.Invisible {
+			// invisible columns are left out of SELECT * too, so entities need not map them
+			hidden = append(hidden, col.Field)
+		} else if 0 > fieldByColumn[col.Field] {
 			// a negative index indicates that no matching field was found
-			allMatched = false
+			unmatched = append(unmatched, col.Field)
 		} else {
 			cols[i].ReadSqlStructTags(entityType.Field(fieldByColumn[col.Field]))
 		}
 	}
-	if !allMatched {
-		log.Printf("not all columns found match in table %v entity struct %v", tableName, entityType.Name())
-		return errors.New("not all columns matched entity struct")
+	if 0 < len(hidden) {
+		metadata.logf(LogInfo, "leaving out invisible columns %s of %s, which have no field in %s",
+			strings.Join(hidden, ","), tableName, entityType.Name())
+	}
+	if 0 < len(unmatched) && metadata.AllowUnmappedColumns {
+		metadata.logf(LogInfo, "leaving out columns %s of %s, which have no field in %s",
+			strings.Join(unmatched, ","), tableName, entityType.Name())
+		cols = dropUnmappedColumns(cols, fieldByColumn)
+	} else if 0 < len(unmatched) {
+		return fmt.Errorf("%w: table %s columns %s have no field in %s",
+			ErrColumnMismatch, tableName, strings.Join(unmatched, ","), entityType.Name())
+	} else if 0 < len(hidden) {
+		cols = dropUnmappedColumns(cols, fieldByColumn)
 	}
 
+	detectAutoTimes(cols, entityType, fieldByColumn)
+	detectEnums(cols, entityType, fieldByColumn)
+	virtualCols, fieldByVirtual, err := readVirtualColumns(entityType)
+	if nil != err {
+		return err
+	}
+	naturalKey, err := findNaturalKey(cols, metadata.NaturalKey)
+	if nil != err {
+		return err
+	}
+	*metadata = TableMetadata{
+		DB:             db,
+		Name:           tableName,
+		BaseName:       baseName,
+		Comment:        comment,
+		Columns:        cols,
+		EntityType:     entityType,
+		EntityTypeName: entityType.Name(),
+		FieldByColumn:  fieldByColumn,
+		VirtualColumns: virtualCols,
+		FieldByVirtual: fieldByVirtual,
+		ForeignKeys:    foreignKeys,
+		Indexes:        GroupIndexes(cols),
+		ServerVersion:  metadata.ServerVersion,
+
+		CheckConstraints:     metadata.CheckConstraints,
+		TagQueries:           metadata.TagQueries,
+		Policies:             metadata.Policies,
+		Logger:               metadata.Logger,
+		Observer:             metadata.Observer,
+		Tracer:               metadata.Tracer,
+		QueryTagger:          metadata.QueryTagger,
+		SQLCommenter:         metadata.SQLCommenter,
+		CacheStatements:      metadata.CacheStatements,
+		StatementCacheSize:   metadata.StatementCacheSize,
+		Replicas:             metadata.Replicas,
+		ReplicaWait:          metadata.ReplicaWait,
+		Breaker:              metadata.Breaker,
+		TableResolver:        metadata.TableResolver,
+		SoftDelete:           metadata.SoftDelete,
+		TablePrefix:          metadata.TablePrefix,
+		SoftDeleteColumn:     findSoftDeleteColumn(cols, metadata.SoftDelete),
+		VersionColumn:        findVersionColumn(cols),
+		TenantColumn:         findTenantColumn(cols),
+		ComputeGenerated:     metadata.ComputeGenerated,
+		ColumnOrder:          metadata.ColumnOrder,
+		Transforms:           metadata.Transforms,
+		ScanByName:           metadata.ScanByName,
+		AllowUnmappedColumns: metadata.AllowUnmappedColumns,
+		AllowExtraFields:     metadata.AllowExtraFields,
+		InformationSchema:    metadata.InformationSchema,
+		Dialect:              metadata.Dialect,
+		AdviseIndexes:        metadata.AdviseIndexes,
+		Config:               metadata.Config,
+		NaturalKey:           naturalKey,
+
+		gate: metadata.gate,
+	}
+	metadata.buildStatements()
+	metadata.checkNaturalKey()
+	if err = metadata.checkTransforms(); nil != err {
+		return err
+	}
+	if metadata.CacheStatements {
+		metadata.stmts = newStmtCache(metadata.StatementCacheSize)
+	}
+	if 0 < ACTIVITY_LOG_SIZE {
+		metadata.activity = newActivityLog(ACTIVITY_LOG_SIZE)
+	}
+	// fill in warnings for column types
+	metadata.Warnings, err = metadata.FieldTypeWarnings(value.Addr().Interface())
+	if extra := findExtraFields(entityType, fieldByColumn, fieldByVirtual); nil == err && 0 < len(extra) && !metadata.AllowExtraFields {
+		metadata.logf(LogWarn, "fields %s of %s have no column in %s", strings.Join(extra, ","), entityType.Name(), tableName)
+		for _, name := range extra {
+			metadata.Warnings = append(metadata.Warnings, SchemaWarning{Field: name, Kind: WARNING_EXTRA_FIELD, Severity: LogWarn})
+		}
+	}
+	if nil == err && config.strict() && 0 < len(metadata.Warnings) {
+		return fmt.Errorf("%w: %s %s", ErrColumnMismatch, tableName, metadata.Warnings)
+	}
+	return err
+}
+
+func (metadata *TableMetadata) buildStatements() {
+	// Generates the column lists and statements, in the order of metadata.Columns.
+	cols := metadata.Columns
+	// metadata read from the database alone has no entity type, and so no fields
+	field := func(col ColumnMetadata) reflect.Value {
+		return reflect.Value{}
+	}
+	if nil != metadata.EntityType {
+		value := reflect.New(metadata.EntityType).Elem()
+		field = func(col ColumnMetadata) reflect.Value {
+			return value.Field(metadata.FieldByColumn[col.Field])
+		}
+	}
+
+	// get the column names as a comma-separated list for use in SQL statements
+	selectCols := []ColumnMetadata{}
+	computedCols := []ColumnMetadata{}
+	computed := []GeneratedExpr{}
+	selectColNames := ""
+	separator := ""
+	for _, col := range cols {
+		if expr := computableGenerated(col, cols); metadata.ComputeGenerated && nil != expr {
+			computedCols = append(computedCols, col)
+			computed = append(computed, expr)
+			continue
+		}
+		selectCols = append(selectCols, col)
+		selectColNames += (separator + QuoteIdentifier(col.Field))
+		separator = ", "
+	}
+	virtualColNames := ""
+	for _, col := range metadata.VirtualColumns {
+		virtualColNames += (", (" + col.Expression + ") AS " + QuoteIdentifier(col.Field))
+	}
+	selectString := "SELECT " + selectColNames + virtualColNames + " FROM " + QuoteTableName(metadata.Name) + " "
+
 	// get column names for INSERT (not including id or explicitly excluded fields)
 	insertCols := []ColumnMetadata{}
 	insertColNames := ""
 	placeholders := ""
 	separator = ""
 	for _, col := range cols {
-		if col.AllowInsert(value.Field(fieldByColumn[col.Field])) {
+		if col.AllowInsert(field(col)) {
 			insertCols = append(insertCols, col)
-			insertColNames += (separator + "`" + col.Field + "`")
+			insertColNames += (separator + QuoteIdentifier(col.Field))
 			placeholders += (separator + "?")
 			separator = ", "
 		}
 	}
-	insertString := "INSERT INTO `" + tableName + "` (" + insertColNames + ") VALUES (" + placeholders + ") "
+	insertString := "INSERT INTO " + QuoteTableName(metadata.Name) + " (" + insertColNames + ") VALUES (" + placeholders + ") "
 
 	// get column names for UPDATE
 	updateCols := []ColumnMetadata{}
 	updateColNames := ""
 	separator = ""
 	for _, col := range cols {
-		if col.AllowUpdate(value.Field(fieldByColumn[col.Field])) {
+		if col.Version {
+			// the version is bumped by the server rather than set from the entity
+			updateColNames += (separator + QuoteIdentifier(col.Field) + "=" + QuoteIdentifier(col.Field) + "+1")
+			separator = ", "
+			continue
+		}
+		if col.AllowUpdate(field(col)) {
 			updateCols = append(updateCols, col)
-			updateColNames += (separator + "`" + col.Field + "`=?")
+			updateColNames += (separator + QuoteIdentifier(col.Field) + "=?")
 			separator = ", "
 		}
 	}
-	updateString := "UPDATE `" + tableName + "` SET " + updateColNames + " "
-	*metadata = TableMetadata{
-		Name:           tableName,
-		Columns:        cols,
-		InsertColumns:  insertCols,
-		UpdateColumns:  updateCols,
-		ColumnNames:    selectColNames,
-		SelectString:   selectString,
-		InsertString:   insertString,
-		UpdateString:   updateString,
-		EntityType:     entityType,
-		EntityTypeName: entityType.Name(),
-		FieldByColumn:  fieldByColumn,
-	}
-	// fill in warnings for column types
-	metadata.Warn, err = metadata.CheckFieldTypes(entity)
-	return err
+	updateString := "UPDATE " + QuoteTableName(metadata.Name) + " SET " + updateColNames + " "
+
+	metadata.SelectColumns = selectCols
+	metadata.ComputedColumns = computedCols
+	metadata.computed = computed
+	metadata.InsertColumns = insertCols
+	metadata.UpdateColumns = updateCols
+	metadata.ColumnNames = selectColNames
+	metadata.SelectString = selectString
+	metadata.InsertString = insertString
+	metadata.UpdateString = updateString
+	metadata.scan = metadata.compileScanPlan()
 }
 
 func GetTableMetadata(db *sql.DB, tableName string, entity interface{}) (*TableMetadata, error) {
@@ -418,141 +906,445 @@ func GetTableMetadata(db *sql.DB, tableName string, entity interface{}) (*TableM
 	return &metadata, err
 }
 
+func (metadata TableMetadata) PrefixedName(baseName string) string {
+	// Returns the name of another table of the same application, with the same prefix,
+	// which goes after the database of a qualified name, ex. billing.app_invoices.
+	if schema, table, ok := strings.Cut(baseName, "."); ok {
+		return schema + "." + metadata.TablePrefix + table
+	}
+	return metadata.TablePrefix + baseName
+}
+
 func (metadata TableMetadata) IsColumn(colname string) bool {
 	_, ok := metadata.FieldByColumn[colname]
 	return ok
 }
 
+func (metadata TableMetadata) selectColumns() []ColumnMetadata {
+	// Metadata built by hand may not have SelectColumns, in which case all columns are selected.
+	if nil == metadata.SelectColumns {
+		return metadata.Columns
+	}
+	return metadata.SelectColumns
+}
+
 func (metadata TableMetadata) ScanEntity(entity interface{}, rows *sql.Rows) error {
 	// check that this is a proper pointer to a struct
 	value, err := GetStructValue(entity)
 	if nil != err {
 		return err
 	}
-	values := make([]interface{}, len(metadata.Columns))
-	jsonValues := make([]string, len(metadata.Columns))
-	isJson := make([]bool, len(metadata.Columns))
+	if metadata.ScanByName {
+		return metadata.scanByName(entity, value, rows)
+	}
+	if nil != metadata.scan && value.Type() == metadata.EntityType {
+		return metadata.scanPlanned(entity, value, rows)
+	}
+	return metadata.scanColumns(entity, value, metadata.scanTargets(), rows)
+}
 
-	for i, col := range metadata.Columns {
-		j := metadata.FieldByColumn[col.Field]
+func (metadata TableMetadata) scanPlanned(entity interface{}, value reflect.Value, rows *sql.Rows) error {
+	// Like scanColumns, with the fields of the columns worked out in advance.
+	plan := metadata.scan
+	buffers := plan.buffers(value)
+	defer buffers.release()
+	err := rows.Scan(buffers.values...)
+	if nil != err {
+		return fmt.Errorf("failed to scan %s entity: %w", metadata.Name, err)
+	}
+	if plan.hasJson {
+		for i, j := range plan.fields {
+			if !plan.isJson[i] {
+				continue
+			}
+			buffers.json = append(buffers.json[:0], buffers.jsonValues[i]...)
+			err = json.Unmarshal(buffers.json, value.Field(j).Addr().Interface())
+			if nil != err {
+				return fmt.Errorf("cannot unmarshal json field %s: %w", metadata.scanTargets()[i].Field, err)
+			}
+		}
+	}
+	return metadata.afterScan(entity, value)
+}
+
+func (metadata TableMetadata) scanColumns(entity interface{}, value reflect.Value, cols []ColumnMetadata, rows *sql.Rows) error {
+	// Scans the row into the fields of cols, in order. Columns with no Field are discarded.
+	values := make([]interface{}, len(cols))
+	jsonValues := make([]string, len(cols))
+	isJson := make([]bool, len(cols))
+
+	for i, col := range cols {
+		if "" == col.Field {
+			values[i] = new(interface{})
+			continue
+		}
+		j := metadata.fieldIndex(col)
 		if j < 0 {
-			msg := "no matching field for column " + col.Field
-			return errors.New(msg)
+			return fmt.Errorf("%w: no matching field for column %s", ErrColumnMismatch, col.Field)
 		}
 		// If the field is string to be read into a struct, then
 		// scan the SQL output as a JSON string.
 		// This will then be converted after Scan is complete.
-		if value.Field(j).Kind() == reflect.Struct {
+		if IsJsonType(value.Field(j).Type()) {
 			isJson[i] = true
 			values[i] = &jsonValues[i]
 		} else {
-			values[i] = value.Field(j).Addr().Interface()
+			values[i] = scanDestination(col, value.Field(j))
 		}
 	}
-	err = rows.Scan(values...)
+	err := rows.Scan(values...)
 	if nil != err {
-		log.Printf("failed to scan entity\n%v", err)
-		return err
+		return fmt.Errorf("failed to scan %s entity: %w", metadata.Name, err)
 	}
 	// For marked JSON field, convert JSON into the struct
-	for i, col := range metadata.Columns {
+	for i, col := range cols {
 		if isJson[i] {
-			j := metadata.FieldByColumn[col.Field]
+			j := metadata.fieldIndex(col)
 			err = json.Unmarshal([]byte(jsonValues[i]), value.Field(j).Addr().Interface())
 			if nil != err {
-				return errors.New("cannot unmarshal json field")
+				return fmt.Errorf("cannot unmarshal json field %s: %w", col.Field, err)
 			}
 		}
 	}
-	return nil
+	return metadata.afterScan(entity, value)
 }
 
-func (metadata TableMetadata) GetRows(clause string, v ...interface{}) (*sql.Rows, error) {
-	query := metadata.SelectString + clause
-	rows, err := metadata.DB.Query(query, v...)
+func (metadata TableMetadata) afterScan(entity interface{}, value reflect.Value) error {
+	metadata.countRowScanned()
+	err := metadata.transformRead(value)
+	if nil != err {
+		return err
+	}
+	// Generated columns left out of the SELECT are evaluated from the scanned fields
+	err = metadata.computeGeneratedColumns(value)
+	if nil != err {
+		return err
+	}
+	return metadata.afterLoad(entity)
+}
+
+func (metadata TableMetadata) query(ctx context.Context, query string, v ...interface{}) (*sql.Rows, error) {
+	if err := metadata.gate.enter(); nil != err {
+		return nil, err
+	}
+	defer metadata.gate.leave()
+	if err := metadata.Breaker.allow(); nil != err {
+		return nil, fmt.Errorf("%w: %s", err, metadata.Name)
+	}
+	if err := metadata.checkTenant(ctx); nil != err {
+		return nil, err
+	}
+	query, err := metadata.resolveTable(ctx, query)
 	if nil != err {
-		log.Printf("error making given query\n%v\n%v", query, err)
-		if nil != rows {
+		return nil, err
+	}
+	policy := metadata.GetOperationPolicy(ctx)
+	query = policy.applySelectOptions(policy.applyPriority(query))
+	replica := metadata.replica(ctx)
+	// the statement cache only prepares on the primary
+	var stmt *sql.Stmt
+	release := func() {}
+	if nil == replica {
+		stmt, release = metadata.prepared(ctx, query)
+	}
+	defer release()
+	ctx, end := metadata.startStatementSpan(ctx, query)
+	// tagged within the span, for a QueryTagger to see its trace
+	query = metadata.tagQuery(ctx, query)
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		// The timeout only bounds running the query: the rows outlive this call, and
+		// are read with qctx for as long as the caller takes, until ctx is done.
+		qctx, stop := policy.executionTimeout(ctx)
+		var rows *sql.Rows
+		var err error
+		if nil != stmt {
+			rows, err = txStmt(qctx, stmt).QueryContext(qctx, v...)
+		} else if nil != replica {
+			rows, err = replica.QueryContext(qctx, query, v...)
+		} else {
+			rows, err = metadata.conn(ctx).QueryContext(qctx, query, v...)
+		}
+		if !stop() && nil == err {
+			// the timeout fired just as the query returned, so the rows are cancelled
 			rows.Close()
+			err = context.DeadlineExceeded
+		}
+		if nil == err {
+			metadata.Breaker.record(nil)
+			metadata.recordStatement(start, query, v, -1, nil)
+			end(-1, nil)
+			return rows, nil
+		}
+		if !policy.retry(ctx, query, attempt, err) {
+			metadata.Breaker.record(err)
+			metadata.recordStatement(start, query, v, -1, err)
+			end(-1, err)
+			return nil, err
 		}
+	}
+}
+
+func (metadata TableMetadata) exec(ctx context.Context, query string, v ...interface{}) (sql.Result, error) {
+	if err := metadata.gate.enter(); nil != err {
 		return nil, err
 	}
+	defer metadata.gate.leave()
+	if err := metadata.Breaker.allow(); nil != err {
+		return nil, fmt.Errorf("%w: %s", err, metadata.Name)
+	}
+	if err := metadata.checkTenant(ctx); nil != err {
+		return nil, err
+	}
+	query, err := metadata.resolveTable(ctx, query)
+	if nil != err {
+		return nil, err
+	}
+	policy := metadata.GetOperationPolicy(ctx)
+	query = policy.applyPriority(query)
+	stmt, release := metadata.prepared(ctx, query)
+	defer release()
+	ctx, end := metadata.startStatementSpan(ctx, query)
+	// tagged within the span, for a QueryTagger to see its trace
+	query = metadata.tagQuery(ctx, query)
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		qctx, cancel := policy.withTimeout(ctx)
+		var result sql.Result
+		var err error
+		if nil != stmt {
+			result, err = txStmt(qctx, stmt).ExecContext(qctx, v...)
+		} else {
+			result, err = metadata.conn(ctx).ExecContext(qctx, query, v...)
+		}
+		cancel()
+		if !policy.retry(ctx, query, attempt, err) {
+			rows := int64(-1)
+			if nil == err {
+				if nil == GetTx(ctx) {
+					// in a transaction, RunInTx records the write on commit
+					metadata.recordWrite(ctx)
+				}
+				rows, _ = result.RowsAffected()
+			}
+			metadata.Breaker.record(err)
+			metadata.recordStatement(start, query, v, rows, err)
+			end(rows, err)
+			return result, err
+		}
+	}
+}
+
+func (metadata TableMetadata) GetRows(clause string, v ...interface{}) (*sql.Rows, error) {
+	return metadata.GetRowsContext(context.Background(), clause, v...)
+}
+
+func (metadata TableMetadata) GetRowsContext(ctx context.Context, clause string, v ...interface{}) (*sql.Rows, error) {
+	metadata.adviseIndex(clause)
+	clause, v = metadata.scopeClause(ctx, clause, v)
+	query := metadata.SelectString + clause
+	rows, err := metadata.query(ctx, query, v...)
+	if nil != err {
+		return nil, fmt.Errorf("error making given query %s: %w", query, err)
+	}
 	return rows, nil
 }
 
 func (metadata TableMetadata) GetEntity(entity interface{}, clause string, v ...interface{}) (interface{}, error) {
-	// Note that this returns the first matching database row.
-	// It does not detect multiple results.
+	return metadata.GetEntityContext(context.Background(), entity, clause, v...)
+}
+
+func (metadata TableMetadata) GetEntityContext(ctx context.Context, entity interface{}, clause string, v ...interface{}) (interface{}, error) {
+	// Scans the first matching row into entity, or returns an error wrapping
+	// ErrNotFound if none matched. Other matching rows are ignored, as the clause may
+	// order them - GetEntityByColumn returns ErrMultipleRows for those.
+	entity, _, err := metadata.getEntity(ctx, entity, clause, v...)
+	return entity, err
+}
+
+func (metadata TableMetadata) getEntity(ctx context.Context, entity interface{}, clause string, v ...interface{}) (interface{}, bool, error) {
+	// Scans the first matching row into entity, and also reports whether more rows matched.
+	metadata.adviseIndex(clause)
+	clause, v = metadata.scopeClause(ctx, clause, v)
 	query := metadata.SelectString + clause
-	rows, err := metadata.DB.Query(query, v...)
+	rows, err := metadata.query(ctx, query, v...)
+	if nil != err {
+		return nil, false, fmt.Errorf("error making given query %s: %w", query, err)
+	}
 	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); nil != err {
+			return nil, false, fmt.Errorf("error making given query %s: %w", query, err)
+		}
+		// No entity was found - return nil with ErrNotFound
+		return nil, false, fmt.Errorf("%w in %s", ErrNotFound, metadata.Name)
+	}
+	err = metadata.ScanEntity(entity, rows)
 	if nil != err {
-		log.Printf("error making given query\n%v\n%v", query, err)
-		return nil, err
-	} else if rows.Next() {
-		return entity, metadata.ScanEntity(entity, rows)
-	} else {
-		// No entity was found - return nil to indicate blank
-		return nil, nil
+		return nil, false, err
 	}
+	return entity, rows.Next(), nil
 }
 
 func (metadata TableMetadata) GetEntityById(entity interface{}, id uint) (interface{}, error) {
-	return metadata.GetEntity(entity, " WHERE id = ?", id)
+	return metadata.GetEntityByIdContext(context.Background(), entity, id)
+}
+
+func (metadata TableMetadata) GetEntityByIdContext(ctx context.Context, entity interface{}, id uint) (interface{}, error) {
+	return metadata.GetEntityContext(ctx, entity, " WHERE id = ?", id)
 }
 
 func (metadata TableMetadata) GetEntityByColumn(entity interface{}, colname string, v interface{}) (interface{}, error) {
+	return metadata.GetEntityByColumnContext(context.Background(), entity, colname, v)
+}
+
+func (metadata TableMetadata) GetEntityByColumnContext(ctx context.Context, entity interface{}, colname string, v interface{}) (interface{}, error) {
+	// This returns ErrMultipleRows, along with the first row, if the value is not unique.
 	if !metadata.IsColumn(colname) {
-		log.Printf("invalid column name for given table %v.%v", metadata.Name, colname)
-		return nil, errors.New("invalid column name")
+		return nil, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+	}
+	entity, more, err := metadata.getEntity(ctx, entity, " WHERE "+QuoteIdentifier(colname)+" = ?", v)
+	if nil == err && more {
+		err = fmt.Errorf("%w for %s.%s", ErrMultipleRows, metadata.Name, colname)
+	}
+	return entity, err
+}
+
+func GetSliceValue(dest interface{}) (reflect.Value, reflect.Type, error) {
+	// The destination for GetEntities should be a pointer to a slice of structs,
+	// or of pointers to structs. This returns the slice and the struct type.
+	v := reflect.ValueOf(dest)
+	if reflect.Ptr == v.Kind() && reflect.Slice == v.Elem().Kind() {
+		elemType := v.Elem().Type().Elem()
+		if reflect.Ptr == elemType.Kind() {
+			elemType = elemType.Elem()
+		}
+		if reflect.Struct == elemType.Kind() {
+			return v.Elem(), elemType, nil
+		}
 	}
-	return metadata.GetEntity(entity, " WHERE `"+colname+"` = ?", v)
+	return reflect.Value{}, nil, fmt.Errorf("%w: require pointer to slice of structs, got %T", ErrInvalidEntity, dest)
+}
+
+func (metadata TableMetadata) GetEntities(dest interface{}, clause string, v ...interface{}) error {
+	return metadata.GetEntitiesContext(context.Background(), dest, clause, v...)
+}
+
+func (metadata TableMetadata) GetEntitiesContext(ctx context.Context, dest interface{}, clause string, v ...interface{}) error {
+	// Appends every matching row to dest, a pointer to a slice of entities.
+	slice, elemType, err := GetSliceValue(dest)
+	if nil != err {
+		return err
+	}
+	rows, err := metadata.GetRowsContext(ctx, clause, v...)
+	if nil != err {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entity := reflect.New(elemType)
+		err = metadata.ScanEntity(entity.Interface(), rows)
+		if nil != err {
+			return err
+		}
+		if reflect.Ptr == slice.Type().Elem().Kind() {
+			slice.Set(reflect.Append(slice, entity))
+		} else {
+			slice.Set(reflect.Append(slice, entity.Elem()))
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return fmt.Errorf("get %s entities: %w", metadata.Name, err)
+	}
+	return nil
 }
 
 func (metadata TableMetadata) GetColumnValue(value reflect.Value, col ColumnMetadata) (interface{}, error) {
 	j := metadata.FieldByColumn[col.Field]
-	if value.Field(j).Type().Kind() == reflect.Struct {
+	if err := col.checkEnum(metadata.Name, value.Field(j)); nil != err {
+		return nil, err
+	}
+	v, err := metadata.transformWrite(col, value.Field(j).Interface())
+	if nil != err {
+		return nil, err
+	}
+	if err = col.checkAllowedValues(metadata.Name, v); nil != err {
+		return nil, err
+	}
+	if values, ok := v.([]string); ok && isSetField(col, value.Field(j).Type()) {
+		if nil == values && "YES" == col.Nullable {
+			return nil, nil
+		}
+		return strings.Join(values, ","), nil
+	}
+	if IsJsonType(value.Field(j).Type()) {
 		// Convert entity struct field into JSON for insert/update in database.
 		// The value is converted into a byte array.
-		jsonByteValue, err := json.Marshal(value.Field(j).Addr().Interface())
+		jsonByteValue, err := json.Marshal(v)
 		if err != nil {
-			return "{}", errors.New("unable to convert struct field to json")
+			return "{}", fmt.Errorf("unable to convert struct field %s to json: %w", col.Field, err)
 		}
 		return jsonByteValue, nil
 	}
-	return value.Field(j).Interface(), nil
+	return v, nil
 }
 
 // TODO: create a GetEntityByColumns that allows multiple column specifications
 // GetEntityByColumns(entity interface{}, match map[string]interface{}) (interface{}, error) {
 
-func (metadata TableMetadata) insertEntityValue(entity interface{}, value reflect.Value) (uint, error) {
+func (metadata TableMetadata) insertEntityValue(ctx context.Context, entity interface{}, value reflect.Value) (uint, error) {
+	result, err := metadata.insertEntityMode(ctx, entity, value, InsertDefault)
+	return result.Id, err
+}
+
+func (metadata TableMetadata) insertEntityMode(ctx context.Context, entity interface{}, value reflect.Value, mode InsertMode) (InsertResult, error) {
+	if err := metadata.beforeInsert(ctx, entity); nil != err {
+		return InsertResult{}, err
+	}
+	if err := metadata.applyWriteDefaults(ctx, value, true); nil != err {
+		return InsertResult{}, err
+	}
+	metadata.stampTimes(value, true)
+	if err := metadata.fillTenant(ctx, value); nil != err {
+		return InsertResult{}, err
+	}
 	values := make([]interface{}, len(metadata.InsertColumns))
 	for i, col := range metadata.InsertColumns {
 		columnValue, err := metadata.GetColumnValue(value, col)
 		if nil != err {
-			return uint(0), err
+			return InsertResult{}, err
 		}
 		values[i] = columnValue
 	}
-	result, err := metadata.DB.Exec(metadata.InsertString, values...)
+	result, err := metadata.exec(ctx, mode.statement(metadata.InsertString), values...)
 	if nil != err {
-		return 0, err
+		return InsertResult{}, fmt.Errorf("insert into %s: %w", metadata.Name, err)
 	}
-	id, err := result.LastInsertId()
+	inserted, err := mode.interpret(result)
 	if nil != err {
-		return 0, err
+		return InsertResult{}, fmt.Errorf("insert into %s: %w", metadata.Name, err)
+	}
+	if inserted.Ignored {
+		// an ignored row leaves the entity as it was, and has nothing to call back about
+		return inserted, nil
 	}
-	SetValueId(value, uint(id))
-	return uint(id), nil
+	metadata.setInsertedId(value, inserted.Id)
+	return inserted, metadata.afterInsert(ctx, entity)
 }
 
-func (metadata TableMetadata) updateEntityValue(entity interface{}, value reflect.Value) error {
+func (metadata TableMetadata) updateEntityValue(ctx context.Context, entity interface{}, value reflect.Value) error {
 	// This requires an entity id field
 	id := GetValueId(value)
 	if 0 == id {
-		return errors.New("no defined id for update")
+		return fmt.Errorf("%w for update of %s", ErrNoPrimaryKey, metadata.Name)
+	}
+	if err := metadata.beforeUpdate(ctx, entity); nil != err {
+		return err
 	}
+	if err := metadata.applyWriteDefaults(ctx, value, false); nil != err {
+		return err
+	}
+	metadata.stampTimes(value, false)
 	// Collect the values for the update query
 	values := make([]interface{}, len(metadata.UpdateColumns)+1)
 	for i, col := range metadata.UpdateColumns {
@@ -563,42 +1355,58 @@ func (metadata TableMetadata) updateEntityValue(entity interface{}, value reflec
 		values[i] = columnValue
 	}
 	values[len(metadata.UpdateColumns)] = id
-	q := metadata.UpdateString + " WHERE id = ?"
-	result, err := metadata.DB.Exec(q, values...)
+	q, values := metadata.scopeTenantRow(ctx, metadata.UpdateString+" WHERE id = ?", values)
+	if "" != metadata.VersionColumn {
+		if err := metadata.updateVersioned(ctx, value, q, values); nil != err {
+			return err
+		}
+		return metadata.afterUpdate(ctx, entity)
+	}
+	result, err := metadata.exec(ctx, q, values...)
 	if nil != err {
-		return err
+		return fmt.Errorf("update %s: %w", metadata.Name, err)
 	}
 	rows, err := result.RowsAffected()
 	if nil != err {
-		return err
+		return fmt.Errorf("update %s: %w", metadata.Name, err)
 	}
 	if 1 != rows {
-		log.Printf("update modified more or less than one row %v\n%v", rows, q)
-		return nil
+		metadata.logf(LogWarn, "update modified more or less than one row %v\n%v", rows, q)
 	}
-	return nil
-
+	return metadata.afterUpdate(ctx, entity)
 }
 
 func (metadata TableMetadata) InsertEntity(entity interface{}) (uint, error) {
+	return metadata.InsertEntityContext(context.Background(), entity)
+}
+
+func (metadata TableMetadata) InsertEntityContext(ctx context.Context, entity interface{}) (uint, error) {
 	// check that this is a proper pointer to a struct
 	value, err := GetStructValue(entity)
 	if nil != err {
 		return 0, err
 	}
-	return metadata.insertEntityValue(entity, value)
+	return metadata.insertEntityValue(ctx, entity, value)
 }
 
 func (metadata TableMetadata) UpdateEntity(entity interface{}) error {
+	return metadata.UpdateEntityContext(context.Background(), entity)
+}
+
+func (metadata TableMetadata) UpdateEntityContext(ctx context.Context, entity interface{}) error {
 	// check that this is a proper pointer to a struct
 	value, err := GetStructValue(entity)
 	if nil != err {
 		return err
 	}
-	return metadata.updateEntityValue(entity, value)
+	return metadata.updateEntityValue(ctx, entity, value)
 }
 
 func (metadata TableMetadata) SaveEntity(entity interface{}) (uint, error) {
+	return metadata.SaveEntityContext(context.Background(), entity)
+}
+
+func (metadata TableMetadata) SaveEntityContext(ctx context.Context, entity interface{}) (uint, error) {
 	// check that this is a proper pointer to a struct
 	value, err := GetStructValue(entity)
 	if nil != err {
@@ -606,15 +1414,8 @@ func (metadata TableMetadata) SaveEntity(entity interface{}) (uint, error) {
 	}
 	id := GetValueId(value)
 	if 0 == id {
-		return metadata.insertEntityValue(entity, value)
+		return metadata.insertEntityValue(ctx, entity, value)
 	} else {
-		return id, metadata.updateEntityValue(entity, value)
+		return id, metadata.updateEntityValue(ctx, entity, value)
 	}
 }
-
-func (metadata TableMetadata) DeleteEntity(entity interface{}) error {
-
-	// TODO: determine from indexes all unique keys, and delete based on that,
-	// or delete based only on id field
-	return errors.New("not implemented yet")
-}
@@ -1,11 +1,19 @@
 package mysqlmeta
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -76,3 +84,1863 @@ func TestGetColumns(t *testing.T) {
 		t.Fatalf("error getting metadata\n%v", err)
 	}
 }
+
+func TestQueryComment(t *testing.T) {
+	ctx := WithQueryTag(context.Background(), "svc", "checkout")
+	ctx = WithQueryTag(ctx, "rid", "abc*/123")
+	if c := QueryComment(ctx); c != "/* svc=checkout rid=abc123 */" {
+		t.Fatalf("unexpected query comment %q", c)
+	}
+	metadata := TableMetadata{TagQueries: true}
+	if q := metadata.tagQuery(ctx, "SELECT 1 "); q != "SELECT 1 /* svc=checkout rid=abc123 */" {
+		t.Fatalf("unexpected tagged query %q", q)
+	}
+}
+
+func TestSQLComment(t *testing.T) {
+	metadata := TableMetadata{TagQueries: true, SQLCommenter: true, QueryTagger: func(ctx context.Context) []QueryTag {
+		return []QueryTag{{Key: "traceparent", Value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, {Key: "app", Value: "default"}}
+	}}
+	ctx := WithQueryTag(context.Background(), "route", "/cart's items*/")
+	ctx = WithQueryTag(ctx, "app", "checkout")
+	expected := "SELECT 1 /*app='checkout',route='%2Fcart%27s%20items%2A%2F',traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/"
+	if q := metadata.tagQuery(ctx, "SELECT 1 "); expected != q {
+		t.Fatalf("unexpected commented query %q", q)
+	}
+	metadata.SQLCommenter = false
+	if q := metadata.tagQuery(ctx, "SELECT 1 "); "SELECT 1 /* traceparent=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 route=cartsitems app=checkout */" != q {
+		t.Fatalf("unexpected tagged query %q", q)
+	}
+	if "" != SQLComment(nil) {
+		t.Fatal("expected no comment without tags")
+	}
+}
+
+func TestIsJsonType(t *testing.T) {
+	if IsJsonType(reflect.TypeOf(sql.NullString{})) {
+		t.Fatalf("sql.NullString should be passed through, not treated as json")
+	}
+	if !IsJsonType(reflect.TypeOf(struct{ Name string }{})) {
+		t.Fatalf("plain struct should be treated as json")
+	}
+	if !IsJsonType(reflect.TypeOf(valuerOnly{})) || !IsJsonType(reflect.TypeOf(scannerOnly{})) {
+		t.Fatalf("a struct that is only a Valuer or only a Scanner should be treated as json")
+	}
+}
+
+type valuerOnly struct{ Name string }
+
+func (v valuerOnly) Value() (driver.Value, error) { return v.Name, nil }
+
+type scannerOnly struct{ Name string }
+
+func (s *scannerOnly) Scan(src interface{}) error { return nil }
+
+func TestApplyPriority(t *testing.T) {
+	low := OperationPolicy{Priority: PriorityLow}
+	if q := low.applyPriority("UPDATE `test` SET `name`=? "); q != "UPDATE LOW_PRIORITY `test` SET `name`=? " {
+		t.Fatalf("unexpected low priority update %q", q)
+	}
+	if q := low.applyPriority("SELECT `id` FROM `test` "); q != "SELECT `id` FROM `test` " {
+		t.Fatalf("select should not accept low priority %q", q)
+	}
+	high := OperationPolicy{Priority: PriorityHigh}
+	if q := high.applyPriority("SELECT `id` FROM `test` "); q != "SELECT HIGH_PRIORITY `id` FROM `test` " {
+		t.Fatalf("unexpected high priority select %q", q)
+	}
+}
+
+func TestApplySelectOptions(t *testing.T) {
+	analytics := OperationPolicy{Priority: PriorityHigh, BufferResult: true, BigSelects: true}
+	q := analytics.applySelectOptions(analytics.applyPriority("SELECT `id` FROM `test` "))
+	if "SELECT /*+ SET_VAR(sql_big_selects=ON) */ HIGH_PRIORITY SQL_BUFFER_RESULT `id` FROM `test` " != q {
+		t.Fatalf("unexpected analytics select %q", q)
+	}
+	if q = analytics.applySelectOptions("DELETE FROM `test` "); "DELETE FROM `test` " != q {
+		t.Fatalf("delete should be left alone %q", q)
+	}
+	analytics.MaxExecutionTime = 1500*time.Millisecond + time.Microsecond
+	q = analytics.applySelectOptions("SELECT `id` FROM `test` ")
+	if "SELECT /*+ MAX_EXECUTION_TIME(1501) SET_VAR(sql_big_selects=ON) */ SQL_BUFFER_RESULT `id` FROM `test` " != q {
+		t.Fatalf("unexpected limited select %q", q)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := OperationPolicy{Retries: 1}
+	ctx := context.Background()
+	deadlock := fmt.Errorf("update: %w", &mysql.MySQLError{Number: ER_LOCK_DEADLOCK})
+	if !policy.retry(ctx, "UPDATE `test` SET `name`=? ", 0, deadlock) || policy.retry(ctx, "UPDATE `test` SET `name`=? ", 1, deadlock) {
+		t.Fatal("expected a single retry after a deadlock")
+	}
+	if !policy.retry(ctx, "INSERT INTO `test` (`name`) VALUES (?) ", 0, &mysql.MySQLError{Number: ER_LOCK_WAIT_TIMEOUT}) {
+		t.Fatal("expected an insert rolled back by a lock wait timeout to be retried")
+	}
+	if policy.retry(ctx, "INSERT INTO `test` (`name`) VALUES (?) ", 0, driver.ErrBadConn) {
+		t.Fatal("an insert may have been applied before the connection dropped")
+	}
+	if policy.retry(ctx, "DELETE FROM `test` ", 0, &mysql.MySQLError{Number: ER_DUP_ENTRY}) || IsTransientError(errors.New("deadlock")) {
+		t.Fatal("only transient errors should be retried")
+	}
+	if policy.retry(WithTx(ctx, &sql.Tx{}), "DELETE FROM `test` ", 0, deadlock) {
+		t.Fatal("a deadlock rolls back the whole transaction")
+	}
+}
+
+func TestWithMaxExecutionTime(t *testing.T) {
+	metadata := TableMetadata{Policies: map[OperationClass]OperationPolicy{OperationInteractive: {MaxExecutionTime: time.Second}}}
+	if policy := metadata.GetOperationPolicy(context.Background()); time.Second != policy.MaxExecutionTime {
+		t.Fatalf("unexpected limit %v", policy.MaxExecutionTime)
+	}
+	ctx := WithMaxExecutionTime(context.Background(), 0)
+	if q := metadata.GetOperationPolicy(ctx).applySelectOptions("SELECT `id` FROM `test` "); "SELECT `id` FROM `test` " != q {
+		t.Fatalf("limit should be lifted %q", q)
+	}
+}
+
+func TestGeneratedExpr(t *testing.T) {
+	expr, err := ParseGeneratedExpr("concat(`first_name`,_utf8mb4' ',upper(`last_name`))")
+	if nil != err {
+		t.Fatalf("error parsing expression\n%v", err)
+	}
+	v, err := expr.Eval(map[string]interface{}{"first_name": "Ada", "last_name": "Lovelace"})
+	if nil != err || "Ada LOVELACE" != v {
+		t.Fatalf("unexpected value %v\n%v", v, err)
+	}
+	expr, err = ParseGeneratedExpr("((`price` * `qty`) - 1)")
+	if nil != err {
+		t.Fatalf("error parsing expression\n%v", err)
+	}
+	v, err = expr.Eval(map[string]interface{}{"price": 2.5, "qty": int64(4)})
+	if nil != err || 9.0 != v {
+		t.Fatalf("unexpected value %v\n%v", v, err)
+	}
+	if _, err = ParseGeneratedExpr("json_unquote(json_extract(`doc`,_utf8mb4'$.id'))"); nil == err {
+		t.Fatalf("expected unsupported function to fail")
+	}
+}
+
+func TestParseColumnDefault(t *testing.T) {
+	null := ParseColumnDefault(sql.NullString{}, "varchar(255)", "")
+	if DefaultNone != null.Kind {
+		t.Fatalf("expected no default, got %v", null)
+	}
+	ts := ParseColumnDefault(sql.NullString{String: "CURRENT_TIMESTAMP(3)", Valid: true}, "datetime(3)", "DEFAULT_GENERATED")
+	if DefaultCurrentTimestamp != ts.Kind || 3 != ts.Precision {
+		t.Fatalf("unexpected timestamp default %v", ts)
+	}
+	n := ParseColumnDefault(sql.NullString{String: "-5", Valid: true}, "int(11)", "")
+	if DefaultLiteral != n.Kind || int64(-5) != n.Value {
+		t.Fatalf("unexpected int default %v", n)
+	}
+	bit := ParseColumnDefault(sql.NullString{String: "b'101'", Valid: true}, "bit(3)", "")
+	if uint64(5) != bit.Value {
+		t.Fatalf("unexpected bit default %v", bit)
+	}
+	quoted := ParseColumnDefault(sql.NullString{String: "'it''s'", Valid: true}, "varchar(10)", "")
+	if "it's" != quoted.Value || "'it''s'" != quoted.String() {
+		t.Fatalf("unexpected quoted default %v", quoted)
+	}
+	cases := []struct {
+		raw        string
+		columnType string
+		extra      string
+		expected   ColumnDefault
+	}{
+		{"now", "varchar(16)", "", ColumnDefault{Kind: DefaultLiteral, Value: "now"}},
+		{"localtime", "enum('localtime','utc')", "", ColumnDefault{Kind: DefaultLiteral, Value: "localtime"}},
+		{"CURRENT_TIMESTAMP", "timestamp", "", ColumnDefault{Kind: DefaultCurrentTimestamp}},
+		{"now()", "datetime", "DEFAULT_GENERATED", ColumnDefault{Kind: DefaultCurrentTimestamp}},
+	}
+	for _, c := range cases {
+		def := ParseColumnDefault(sql.NullString{String: c.raw, Valid: true}, c.columnType, c.extra)
+		if !reflect.DeepEqual(c.expected, def) {
+			t.Fatalf("%s %q: unexpected default %+v", c.columnType, c.raw, def)
+		}
+	}
+}
+
+func TestIntegerColumnMax(t *testing.T) {
+	expected := map[string]uint64{
+		"int(11)":                    2147483647,
+		"int(10) unsigned":           4294967295,
+		"tinyint(3) unsigned":        255,
+		"bigint(20) unsigned":        18446744073709551615,
+		"smallint unsigned zerofill": 65535,
+	}
+	for columnType, max := range expected {
+		if v, ok := IntegerColumnMax(columnType); !ok || v != max {
+			t.Fatalf("unexpected max for %s: %v", columnType, v)
+		}
+	}
+	if _, ok := IntegerColumnMax("varchar(255)"); ok {
+		t.Fatalf("varchar should not have an integer max")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	db := mustGetDB(t)
+	db.Exec("DROP TABLE IF EXISTS test")
+	mustExec(t, &db, "CREATE TABLE test (id INT, value BOOL, name VARCHAR(255))")
+	type entity struct {
+		Id    int
+		Value bool
+		Name  string
+	}
+	registry := &Registry{}
+	first, err := registry.Register(&db, "test", &entity{})
+	if nil != err {
+		t.Fatalf("error registering\n%v", err)
+	}
+	second, ok := registry.Lookup(entity{})
+	if !ok || first != second {
+		t.Fatalf("lookup did not return the registered metadata")
+	}
+	if _, err = registry.Register(&db, "other", &entity{}); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("expected ErrAlreadyRegistered, got %v", err)
+	}
+
+	type declared struct {
+		Id   int
+		Name string
+	}
+	if err = registry.Declare(&db, "test", declared{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, ok = registry.Lookup(declared{}); ok {
+		t.Fatalf("declared table should not be fetched before WarmUp")
+	}
+	if err = registry.WarmUp(context.Background()); nil != err {
+		t.Fatalf("error warming up\n%v", err)
+	}
+	if _, ok = registry.Lookup(declared{}); !ok {
+		t.Fatalf("declared table was not fetched by WarmUp")
+	}
+}
+
+func TestRegistryWarmUp(t *testing.T) {
+	type entity struct {
+		Id int
+	}
+	registry := &Registry{}
+	if _, err := registry.Get(entity{}); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("expected ErrNotRegistered, got %v", err)
+	}
+	if err := registry.Declare(nil, "test", &entity{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := registry.Declare(nil, "other", &entity{}); !errors.Is(err, ErrAlreadyRegistered) {
+		t.Fatalf("expected ErrAlreadyRegistered, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := registry.WarmUp(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, ok := registry.Lookup(entity{}); ok {
+		t.Fatalf("table should not be fetched")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	db := mustGetDB(t)
+	db.Exec("DROP TABLE IF EXISTS test")
+	mustExec(t, &db, "CREATE TABLE test (id INT)")
+	type entity struct {
+		Id   int
+		Name string
+	}
+	registry := &Registry{}
+	before, err := registry.Register(&db, "test", &entity{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	checksums, err := GetSchemaChecksums(context.Background(), &db, "test")
+	if nil != err || "" == checksums["test"] {
+		t.Fatalf("unexpected checksums %v %v", checksums, err)
+	}
+	mustExec(t, &db, "ALTER TABLE test ADD COLUMN name VARCHAR(255)")
+	altered, err := GetSchemaChecksums(context.Background(), &db, "test")
+	if nil != err || checksums["test"] == altered["test"] {
+		t.Fatalf("checksum did not change %v %v", altered, err)
+	}
+	if err = registry.Refresh(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	after, _ := registry.Lookup(entity{})
+	if before.IsColumn("name") || !after.IsColumn("name") {
+		t.Fatalf("unexpected columns before %v after %v", before.Columns, after.Columns)
+	}
+}
+
+func TestRefreshErrors(t *testing.T) {
+	metadata := &TableMetadata{Name: "product"}
+	if err := metadata.Refresh(context.Background()); !errors.Is(err, ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity, got %v", err)
+	}
+	registry := &Registry{}
+	if err := registry.Refresh(context.Background(), metadata); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("expected ErrNotRegistered, got %v", err)
+	}
+	if _, err := GetSchemaChecksums(context.Background(), nil, "bad name"); !errors.Is(err, ErrInvalidTableName) {
+		t.Fatalf("expected ErrInvalidTableName, got %v", err)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	for version, expected := range map[string]ServerVersion{
+		"8.0.35":                {Flavor: FLAVOR_MYSQL, Major: 8, Minor: 0, Patch: 35},
+		"5.7.44-log":            {Flavor: FLAVOR_MYSQL, Major: 5, Minor: 7, Patch: 44},
+		"10.11.6-MariaDB-log":   {Flavor: FLAVOR_MARIADB, Major: 10, Minor: 11, Patch: 6},
+		"5.5.5-10.6.12-MariaDB": {Flavor: FLAVOR_MARIADB, Major: 10, Minor: 6, Patch: 12},
+	} {
+		parsed, err := ParseServerVersion(version)
+		expected.Version = version
+		if nil != err || expected != *parsed {
+			t.Fatalf("unexpected version %+v for %s %v", parsed, version, err)
+		}
+	}
+	tidb, _ := ParseServerVersion("8.0.11-TiDB-v7.5.0")
+	if FLAVOR_TIDB != tidb.Flavor || 7 != tidb.Major || 5 != tidb.Minor || tidb.Supports(FEATURE_EXPLAIN_ANALYZE) {
+		t.Fatalf("unexpected TiDB version %+v", tidb)
+	}
+	if _, err := ParseServerVersion("unknown"); nil == err {
+		t.Fatalf("expected an error")
+	}
+	mysql57, _ := ParseServerVersion("5.7.44")
+	mysql8, _ := ParseServerVersion("8.0.35")
+	mariadb, _ := ParseServerVersion("10.11.6-MariaDB")
+	if mysql57.Supports(FEATURE_CHECK_CONSTRAINTS) || !mysql8.Supports(FEATURE_CHECK_CONSTRAINTS) ||
+		!mysql57.Supports(FEATURE_JSON) || mariadb.Supports(FEATURE_EXPLAIN_ANALYZE) ||
+		!mariadb.Supports(FEATURE_INVISIBLE_COLUMNS) {
+		t.Fatalf("unexpected features")
+	}
+	var unknown *ServerVersion
+	if !unknown.Supports(FEATURE_EXPLAIN_ANALYZE) || "unknown" != unknown.String() {
+		t.Fatalf("an unknown version should support everything")
+	}
+	metadata := TableMetadata{Name: "product", ServerVersion: mysql57}
+	if _, err := metadata.ExplainAnalyze(context.Background(), ""); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	mariadb, _ := ParseServerVersion("10.11.6-MariaDB")
+	for raw, expected := range map[string]ColumnDefault{
+		"NULL":                {Kind: DefaultNone},
+		"'it''s'":             {Kind: DefaultLiteral, Value: "it's"},
+		"current_timestamp()": {Kind: DefaultCurrentTimestamp},
+		"uuid()":              {Kind: DefaultExpression, Expression: "uuid()"},
+	} {
+		value, extra := mariadb.normalizeDefault(sql.NullString{String: raw, Valid: true}, "")
+		if def := ParseColumnDefault(value, "varchar(36)", extra); !reflect.DeepEqual(expected, def) {
+			t.Fatalf("unexpected default %+v for %s", def, raw)
+		}
+	}
+	value, _ := mariadb.normalizeDefault(sql.NullString{String: "5", Valid: true}, "")
+	if def := ParseColumnDefault(value, "int", ""); int64(5) != def.Value {
+		t.Fatalf("unexpected default %+v", def)
+	}
+	mysql8, _ := ParseServerVersion("8.0.35")
+	if value, extra := mysql8.normalizeDefault(sql.NullString{String: "'x'", Valid: true}, ""); "'x'" != value.String || "" != extra {
+		t.Fatalf("MySQL defaults should be left as they are")
+	}
+}
+
+func TestULID(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	id := NewULIDAt(now)
+	parsed, err := ParseULID(id.String())
+	if nil != err || parsed != id {
+		t.Fatalf("ULID did not round trip %v %v\n%v", id, parsed, err)
+	}
+	if !id.Time().Equal(now) {
+		t.Fatalf("unexpected ULID time %v", id.Time())
+	}
+	later := NewULIDAt(now.Add(time.Millisecond))
+	if later.String() <= id.String() {
+		t.Fatalf("ULIDs should sort by time")
+	}
+	uuid, _ := ParseUUID("6ccd780c-baba-1026-9564-5b8c656024db")
+	stored, _ := SwappedUUID(uuid).Value()
+	if "1026baba6ccd780c95645b8c656024db" != hex.EncodeToString(stored.([]byte)) {
+		t.Fatalf("unexpected swapped UUID %x", stored)
+	}
+	var scanned SwappedUUID
+	if err = scanned.Scan(stored); nil != err || UUID(scanned) != uuid {
+		t.Fatalf("swapped UUID did not round trip %v", scanned)
+	}
+}
+
+func TestScopeClause(t *testing.T) {
+	expected := map[string]string{
+		"":                                    " WHERE x IS NULL ",
+		" ORDER BY id LIMIT 1":                " WHERE x IS NULL ORDER BY id LIMIT 1",
+		" WHERE a = ? OR b = 'limit' LIMIT 1": " WHERE (x IS NULL) AND (a = ? OR b = 'limit') LIMIT 1",
+		" WHERE (a = ? OR b = ?)":             " WHERE (x IS NULL) AND ((a = ? OR b = ?)) ",
+	}
+	for clause, scoped := range expected {
+		if s := ScopeClause(clause, "x IS NULL"); s != scoped {
+			t.Fatalf("unexpected scoped clause for %q: %q", clause, s)
+		}
+	}
+	// the argument of the condition goes after those of placeholders ahead of the WHERE
+	clause, args := ScopeClauseArgs(" JOIN tag ON tag.name = ? AND tag.note = '?' WHERE body = ? LIMIT ?", "org_id = ?",
+		[]interface{}{"urgent", "b", 1}, 7)
+	if " JOIN tag ON tag.name = ? AND tag.note = '?' WHERE (org_id = ?) AND (body = ?) LIMIT ?" != clause ||
+		!reflect.DeepEqual([]interface{}{"urgent", 7, "b", 1}, args) {
+		t.Fatalf("unexpected scoped clause %q %v", clause, args)
+	}
+	clause, args = ScopeClauseArgs(" JOIN tag ON tag.name = ? ORDER BY id LIMIT ?", "org_id = ?", []interface{}{"urgent", 1}, 7)
+	if " JOIN tag ON tag.name = ? WHERE org_id = ? ORDER BY id LIMIT ?" != clause ||
+		!reflect.DeepEqual([]interface{}{"urgent", 7, 1}, args) {
+		t.Fatalf("unexpected scoped clause %q %v", clause, args)
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	condition := KeysetCondition([]string{"created_at", "id"}, true)
+	if "((`created_at` < ?) OR (`created_at` = ? AND `id` < ?))" != condition {
+		t.Fatalf("unexpected keyset condition %s", condition)
+	}
+	args := keysetArgs([]interface{}{"t", 7})
+	if 3 != len(args) || "t" != args[0] || "t" != args[1] || 7 != args[2] {
+		t.Fatalf("unexpected keyset args %v", args)
+	}
+	metadata := TableMetadata{Columns: []ColumnMetadata{
+		{Field: "id", Indexes: []IndexMetadata{{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"}}},
+		{Field: "created_at", Indexes: []IndexMetadata{{KeyName: "idx_created", SeqInIndex: 1, ColumnName: "created_at"}}},
+	}}
+	metadata.Columns[0].Indexes = append(metadata.Columns[0].Indexes,
+		IndexMetadata{KeyName: "idx_created", SeqInIndex: 2, ColumnName: "id"})
+	if name, ok := metadata.IndexCovering("created_at", "id"); !ok || "idx_created" != name {
+		t.Fatalf("expected idx_created to cover (created_at, id), got %v", name)
+	}
+	if _, ok := metadata.IndexCovering("id", "created_at"); ok {
+		t.Fatalf("(id, created_at) should not be covered")
+	}
+}
+
+func TestLoaderFor(t *testing.T) {
+	metadata := &TableMetadata{Name: "product"}
+	ctx := WithLoaders(context.Background())
+	loader := LoaderFor(ctx, metadata)
+	if loader != LoaderFor(ctx, metadata) {
+		t.Fatalf("expected the context to share one loader per table")
+	}
+	if loader == LoaderFor(WithLoaders(context.Background()), metadata) {
+		t.Fatalf("expected a new loader for a new request")
+	}
+	type product struct{ Id uint }
+	err := loader.Prime(&product{Id: 3})
+	if nil != err {
+		t.Fatal(err)
+	}
+	entity, err := loader.Load(ctx, 3)
+	if nil != err || 3 != entity.(*product).Id {
+		t.Fatalf("expected the primed entity, got %v %v", entity, err)
+	}
+}
+
+type hookedProduct struct {
+	Id   uint
+	Name string
+}
+
+func (product *hookedProduct) AfterLoad() error {
+	if "" == product.Name {
+		return ErrInvalidEntity
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	metadata := TableMetadata{Name: "product"}
+	if err := metadata.afterLoad(&hookedProduct{Name: "x"}); nil != err {
+		t.Fatal(err)
+	}
+	if err := metadata.afterLoad(&hookedProduct{}); !errors.Is(err, ErrInvalidEntity) {
+		t.Fatalf("expected the AfterLoad error, got %v", err)
+	}
+	if err := metadata.beforeInsert(context.Background(), &hookedProduct{}); nil != err {
+		t.Fatalf("expected no BeforeInsert hook, got %v", err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	if nil != GetSession(context.Background()) {
+		t.Fatalf("expected no session without WithSession")
+	}
+	session := GetSession(WithSession(context.Background()))
+	ready, err := session.ReplicaReady(context.Background(), nil, time.Second)
+	if !ready || nil != err {
+		t.Fatalf("expected a replica to be usable before any write, got %v %v", ready, err)
+	}
+	session.wrote = true
+	ready, err = session.ReplicaReady(context.Background(), nil, time.Second)
+	if ready || nil != err {
+		t.Fatalf("expected the primary after a write without a token, got %v %v", ready, err)
+	}
+}
+
+func TestReorderColumns(t *testing.T) {
+	type product struct {
+		Id    uint
+		Name  string
+		Price float64
+	}
+	metadata := &TableMetadata{
+		Name:          "product",
+		Columns:       []ColumnMetadata{{Field: "id", Extra: "auto_increment"}, {Field: "name"}, {Field: "price"}},
+		EntityType:    reflect.TypeOf(product{}),
+		FieldByColumn: map[string]int{"id": 0, "name": 1, "price": 2},
+	}
+	err := metadata.ReorderColumns([]string{"price", "id"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "SELECT `price`, `id`, `name` FROM `product` " != metadata.SelectString {
+		t.Fatalf("unexpected select %s", metadata.SelectString)
+	}
+	if "INSERT INTO `product` (`price`, `name`) VALUES (?, ?) " != metadata.InsertString {
+		t.Fatalf("unexpected insert %s", metadata.InsertString)
+	}
+	if err = metadata.ReorderColumns([]string{"cost"}); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+}
+
+func TestRelatedColumn(t *testing.T) {
+	order := TableMetadata{Name: "app_order", BaseName: "order"}
+	line := &TableMetadata{Name: "app_order_line", FieldByColumn: map[string]int{"id": 0, "order_id": 1}}
+	if colname, err := order.RelatedColumn(line); nil != err || "order_id" != colname {
+		t.Fatalf("expected order_id by name, got %v %v", colname, err)
+	}
+	line.ForeignKeys = []ForeignKeyMetadata{{
+		Columns: []string{"parent"}, ReferencedTable: "app_order", ReferencedColumns: []string{"id"},
+	}}
+	if colname, err := order.RelatedColumn(line); nil != err || "parent" != colname {
+		t.Fatalf("expected parent from the foreign key, got %v %v", colname, err)
+	}
+	if id, ok := relatedId(reflect.ValueOf(sql.NullInt64{Int64: 4, Valid: true})); !ok || 4 != id {
+		t.Fatalf("expected id 4, got %v %v", id, ok)
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	type user struct {
+		Id    uint
+		Email string
+	}
+	metadata := TableMetadata{
+		Name:          "user",
+		FieldByColumn: map[string]int{"id": 0, "email": 1},
+		Transforms:    map[string]ColumnTransform{"email": Lowercase},
+	}
+	entity := user{Email: " Ann@Example.COM "}
+	v, err := metadata.GetColumnValue(reflect.ValueOf(&entity).Elem(), ColumnMetadata{Field: "email"})
+	if nil != err || "ann@example.com" != v {
+		t.Fatalf("expected the written email to be lower case, got %v %v", v, err)
+	}
+	err = metadata.transformRead(reflect.ValueOf(&entity).Elem())
+	if nil != err || "ann@example.com" != entity.Email {
+		t.Fatalf("expected the read email to be lower case, got %v %v", entity.Email, err)
+	}
+}
+
+func TestVirtualColumns(t *testing.T) {
+	type person struct {
+		Id        uint
+		FirstName string
+		LastName  string
+		FullName  string `sqlexpr:"CONCAT(first_name, ' ', last_name)"`
+	}
+	entityType := reflect.TypeOf(person{})
+	virtualCols, fieldByVirtual, err := readVirtualColumns(entityType)
+	if nil != err {
+		t.Fatal(err)
+	}
+	metadata := &TableMetadata{
+		Name:           "person",
+		Columns:        []ColumnMetadata{{Field: "id", Extra: "auto_increment"}, {Field: "first_name"}, {Field: "last_name"}},
+		EntityType:     entityType,
+		FieldByColumn:  map[string]int{"id": 0, "first_name": 1, "last_name": 2},
+		VirtualColumns: virtualCols,
+		FieldByVirtual: fieldByVirtual,
+	}
+	metadata.buildStatements()
+	expected := "SELECT `id`, `first_name`, `last_name`, (CONCAT(first_name, ' ', last_name)) AS `full_name` FROM `person` "
+	if expected != metadata.SelectString {
+		t.Fatalf("unexpected select %s", metadata.SelectString)
+	}
+	if "INSERT INTO `person` (`first_name`, `last_name`) VALUES (?, ?) " != metadata.InsertString {
+		t.Fatalf("unexpected insert %s", metadata.InsertString)
+	}
+	if 3 != metadata.fieldIndex(metadata.scanTargets()[3]) {
+		t.Fatalf("expected full_name to scan into FullName")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	metadata := TableMetadata{
+		Name:    "product",
+		Columns: []ColumnMetadata{{Field: "id"}, {Field: "name"}, {Field: "description"}},
+	}
+	partial, err := metadata.Select("id", "name")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "SELECT `id`, `name` FROM `product` " != partial.SelectString || 2 != len(partial.scanTargets()) {
+		t.Fatalf("unexpected partial select %s", partial.SelectString)
+	}
+	if _, err = metadata.Select("price"); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+}
+
+type testStatus int8
+
+func TestEnum(t *testing.T) {
+	RegisterEnum(map[testStatus]string{1: "active", 2: "closed"})
+	if name, ok := EnumName(testStatus(2)); !ok || "closed" != name {
+		t.Fatalf("expected closed, got %v %v", name, ok)
+	}
+	type account struct {
+		Id     uint
+		Status testStatus
+	}
+	cols := []ColumnMetadata{{Field: "id"}, {Field: "status"}}
+	detectEnums(cols, reflect.TypeOf(account{}), map[string]int{"id": 0, "status": 1})
+	if 2 != len(cols[1].Enum) || nil != cols[0].Enum {
+		t.Fatalf("expected status to be an enum column, got %v", cols)
+	}
+	if err := cols[1].checkEnum("account", reflect.ValueOf(testStatus(3))); !errors.Is(err, ErrInvalidEnumValue) {
+		t.Fatalf("expected ErrInvalidEnumValue, got %v", err)
+	}
+}
+
+func TestAllowedValues(t *testing.T) {
+	if values := parseAllowedValues("enum('new','it''s','a,b')"); !reflect.DeepEqual([]string{"new", "it's", "a,b"}, values) {
+		t.Fatalf("unexpected enum values %q", values)
+	}
+	if values := parseAllowedValues("varchar(32)"); nil != values {
+		t.Fatalf("expected no values, got %q", values)
+	}
+	status := ColumnMetadata{Field: "status", ColumnType: "enum('new','paid')", AllowedValues: []string{"new", "paid"}}
+	tags := ColumnMetadata{Field: "tags", ColumnType: "set('red','blue')", AllowedValues: []string{"red", "blue"}}
+	for _, valid := range []interface{}{"paid", "PAID", (*string)(nil), sql.NullString{}} {
+		if err := status.checkAllowedValues("order", valid); nil != err {
+			t.Fatalf("unexpected error for %v: %v", valid, err)
+		}
+	}
+	if err := status.checkAllowedValues("order", "shipped"); !errors.Is(err, ErrInvalidEnumValue) {
+		t.Fatalf("expected ErrInvalidEnumValue, got %v", err)
+	}
+	if err := tags.checkAllowedValues("order", "red,blue"); nil != err {
+		t.Fatal(err)
+	}
+	if err := tags.checkAllowedValues("order", []string{}); nil != err {
+		t.Fatal(err)
+	}
+	if err := tags.checkAllowedValues("order", []string{"red", "green"}); !errors.Is(err, ErrInvalidEnumValue) {
+		t.Fatalf("expected ErrInvalidEnumValue, got %v", err)
+	}
+	if "[]string" != GoFieldType(ColumnMetadata{ColumnType: "set('red','blue')", Nullable: "YES"}) {
+		t.Fatal("expected SET columns to be []string fields")
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	base := Config{
+		Strict:   Bool(true),
+		Policies: map[OperationClass]OperationPolicy{OperationInteractive: {Timeout: time.Second}},
+	}
+	config := base.Merge(Config{
+		Strict:   Bool(false),
+		Policies: map[OperationClass]OperationPolicy{OperationBulk: {Retries: 1}},
+	})
+	if config.strict() || 2 != len(config.Policies) || nil != config.CacheStatements {
+		t.Fatalf("unexpected merged config %+v", config)
+	}
+	if !base.strict() || 1 != len(base.Policies) {
+		t.Fatalf("merge should not change the base config")
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	before := TableMetadata{Name: "product", Columns: []ColumnMetadata{
+		{Field: "id", ColumnType: "int unsigned", Indexes: []IndexMetadata{{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"}}},
+		{Field: "price", ColumnType: "int"},
+	}}
+	after := TableMetadata{Name: "product", Columns: []ColumnMetadata{
+		{Field: "id", ColumnType: "int unsigned", Indexes: []IndexMetadata{{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"}}},
+		{Field: "price", ColumnType: "decimal(10,2)"},
+		{Field: "name", ColumnType: "varchar(64)", Indexes: []IndexMetadata{{KeyName: "idx_name", NonUnique: true, SeqInIndex: 1, ColumnName: "name"}}},
+	}}
+	if before.Snapshot().Fingerprint != before.Snapshot().Fingerprint {
+		t.Fatalf("expected a stable fingerprint")
+	}
+	changes := DiffSnapshots([]SchemaSnapshot{before.Snapshot()}, []SchemaSnapshot{after.Snapshot()})
+	expected := []string{
+		"product: column added name (varchar(64))",
+		"product: column changed price (int -> decimal(10,2))",
+		"product: index added idx_name ((name))",
+	}
+	if len(expected) != len(changes) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	for i, change := range changes {
+		if expected[i] != change.String() {
+			t.Fatalf("unexpected change %q", change.String())
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 123000, time.UTC)
+	cols := []string{"created_at", "id"}
+	s, err := EncodeCursor(cols, true, []interface{}{at, uint(42)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	keys, err := DecodeCursor(s, cols, true)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !at.Equal(keys[0].(time.Time)) || uint64(42) != keys[1] {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if _, err = DecodeCursor(s, cols, false); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor for another direction, got %v", err)
+	}
+	if _, err = DecodeCursor("not a cursor", cols, true); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestLike(t *testing.T) {
+	if `50\% off\_now \\o/` != EscapeLike(`50% off_now \o/`) {
+		t.Fatalf("unexpected escape %s", EscapeLike(`50% off_now \o/`))
+	}
+	sql, args, err := Contains("name", "50%").Collate("utf8mb4_0900_ai_ci").SQL()
+	if nil != err || "`name` LIKE ? COLLATE utf8mb4_0900_ai_ci" != sql || `%50\%%` != args[0] {
+		t.Fatalf("unexpected condition %s %v %v", sql, args, err)
+	}
+	if _, _, err = StartsWith("name`; DROP", "x").SQL(); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+}
+
+func TestWhere(t *testing.T) {
+	metadata := TableMetadata{Name: "product", FieldByColumn: map[string]int{"id": 0, "status": 1, "org_id": 2, "name": 3}}
+	where := Where().Eq("status", 1).In("org_id", []interface{}{4, 5}).Like(StartsWith("name", "a_")).
+		OrderBy("id", Desc).Limit(10)
+	clause, args, err := where.Clause(metadata)
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := " WHERE `status` = ? AND `org_id` IN (?, ?) AND `name` LIKE ? ORDER BY `id` DESC LIMIT ?"
+	if expected != clause || 5 != len(args) || `a\_%` != args[3] || 10 != args[4] {
+		t.Fatalf("unexpected clause %s %v", clause, args)
+	}
+	if _, _, err = Where().Eq("status; DROP TABLE product", 1).Clause(metadata); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	if clause, _, _ = Where().In("id", nil).Clause(metadata); " WHERE FALSE" != clause {
+		t.Fatalf("expected an empty IN to match nothing, got %s", clause)
+	}
+}
+
+func TestRecentStatements(t *testing.T) {
+	if "SELECT `id` FROM `product` WHERE `id` IN (?+) AND `name` = ?" !=
+		Fingerprint("SELECT `id` FROM `product` WHERE `id` IN (?, ?, ?) AND `name` = 'x' /* svc=api */") {
+		t.Fatalf("unexpected fingerprint")
+	}
+	metadata := TableMetadata{Name: "product", activity: newActivityLog(2)}
+	for i := 0; i < 3; i++ {
+		metadata.recordStatement(time.Now(), "DELETE FROM `product` WHERE id = "+string(rune('1'+i)), nil, 1, nil)
+	}
+	statements := metadata.RecentStatements()
+	if 2 != len(statements) || "DELETE FROM `product` WHERE id = ?" != statements[1].Fingerprint {
+		t.Fatalf("unexpected statements %+v", statements)
+	}
+}
+
+func TestNaturalKey(t *testing.T) {
+	cols := []ColumnMetadata{{Field: "id"}, {Field: "tenant_id", NaturalKey: true}, {Field: "external_id", NaturalKey: true}}
+	key, err := findNaturalKey(cols, nil)
+	if nil != err || "tenant_id,external_id" != strings.Join(key, ",") {
+		t.Fatalf("unexpected natural key %v %v", key, err)
+	}
+	if _, err = findNaturalKey(cols, []string{"sku"}); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	type item struct {
+		Id         uint
+		TenantId   uint
+		ExternalId string
+	}
+	metadata := TableMetadata{
+		Name:          "item",
+		FieldByColumn: map[string]int{"id": 0, "tenant_id": 1, "external_id": 2},
+		NaturalKey:    key,
+	}
+	values, err := metadata.NaturalKeyOf(&item{TenantId: 3, ExternalId: "A-17"})
+	if nil != err || uint(3) != values["tenant_id"] || "A-17" != values["external_id"] {
+		t.Fatalf("unexpected key values %v %v", values, err)
+	}
+}
+
+func TestLockedReadNeedsTransaction(t *testing.T) {
+	metadata := TableMetadata{Name: "account"}
+	var entity struct{ Id uint }
+	_, err := metadata.GetEntityByIdForUpdate(context.Background(), &entity, 1)
+	if !errors.Is(err, ErrNoTransaction) {
+		t.Fatalf("expected ErrNoTransaction, got %v", err)
+	}
+}
+
+func TestWriteDefaults(t *testing.T) {
+	type order struct {
+		Id        uint
+		Status    string `sql:"status,insert-default=pending"`
+		Priority  int    `sql:"priority,insert-default=3"`
+		UpdatedBy uint   `sql:"updated_by,update-default=ctx:user_id"`
+	}
+	entityType := reflect.TypeOf(order{})
+	cols := []ColumnMetadata{{Field: "id"}, {Field: "status"}, {Field: "priority"}, {Field: "updated_by"}}
+	for i := range cols {
+		cols[i].ReadSqlStructTags(entityType.Field(i))
+	}
+	metadata := TableMetadata{
+		Name:          "order",
+		Columns:       cols,
+		FieldByColumn: map[string]int{"id": 0, "status": 1, "priority": 2, "updated_by": 3},
+	}
+	entity := order{Priority: 1}
+	if err := metadata.applyWriteDefaults(context.Background(), reflect.ValueOf(&entity).Elem(), true); nil != err {
+		t.Fatal(err)
+	}
+	if "pending" != entity.Status || 1 != entity.Priority || 0 != entity.UpdatedBy {
+		t.Fatalf("unexpected insert defaults %+v", entity)
+	}
+	ctx := WithDefaultValue(context.Background(), "user_id", uint(42))
+	if err := metadata.applyWriteDefaults(ctx, reflect.ValueOf(&entity).Elem(), false); nil != err {
+		t.Fatal(err)
+	}
+	if 42 != entity.UpdatedBy {
+		t.Fatalf("unexpected update default %+v", entity)
+	}
+}
+
+func TestPartialUpdate(t *testing.T) {
+	type product struct {
+		Id      uint
+		Name    string
+		Price   int
+		Version int
+	}
+	cols := []ColumnMetadata{{Field: "id"}, {Field: "name"}, {Field: "price"}, {Field: "version", Version: true}}
+	metadata := TableMetadata{
+		Name:          "product",
+		Columns:       cols,
+		UpdateColumns: cols[1:3],
+		FieldByColumn: map[string]int{"id": 0, "name": 1, "price": 2, "version": 3},
+	}
+	partial, err := metadata.updating([]string{"price"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "UPDATE `product` SET `price`=?, `version`=`version`+1 " != partial.UpdateString {
+		t.Fatalf("unexpected update %q", partial.UpdateString)
+	}
+	if _, err = metadata.updating([]string{"id"}); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	before := product{Id: 1, Name: "lamp", Price: 1000}
+	after := before
+	after.Price = 1299
+	changed, err := metadata.ChangedColumns(&before, &after)
+	if nil != err || "price" != strings.Join(changed, ",") {
+		t.Fatalf("unexpected changed columns %v %v", changed, err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	registry := &Registry{}
+	metadata := TableMetadata{Name: "product", gate: &registry.gate}
+	stopped := false
+	err := registry.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		stopped = true
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = registry.Shutdown(ctx); nil != err || !stopped {
+		t.Fatalf("unexpected shutdown %v %v", stopped, err)
+	}
+	if _, err = metadata.query(context.Background(), "SELECT 1"); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expected ErrShutdown, got %v", err)
+	}
+	if err = registry.Go(func(ctx context.Context) {}); !errors.Is(err, ErrShutdown) {
+		t.Fatalf("expected ErrShutdown, got %v", err)
+	}
+}
+
+func TestUpdateWhereChecksColumns(t *testing.T) {
+	metadata := TableMetadata{Name: "product", FieldByColumn: map[string]int{"id": 0, "price": 1}}
+	_, err := metadata.UpdateWhere(map[string]interface{}{"price; DROP TABLE product": 0}, "WHERE id = ?", 1)
+	if !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	if n, err := metadata.UpdateWhere(nil, "WHERE id = ?", 1); 0 != n || nil != err {
+		t.Fatalf("empty update should do nothing %v %v", n, err)
+	}
+}
+
+func TestIncrementColumnChecksArguments(t *testing.T) {
+	metadata := TableMetadata{
+		Name:          "product",
+		Columns:       []ColumnMetadata{{Field: "id", Key: "PRI"}, {Field: "stock"}},
+		FieldByColumn: map[string]int{"id": 0, "stock": 1},
+	}
+	if err := metadata.IncrementColumn(1, "stok", 1); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	if err := metadata.IncrementColumn(1, "stock", "1"); !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	if err := metadata.IncrementColumn(1, "stock", 0); nil != err {
+		t.Fatalf("zero increment should do nothing, got %v", err)
+	}
+}
+
+func TestIsMySQLError(t *testing.T) {
+	err := fmt.Errorf("insert into tag: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	if !isMySQLError(err, ER_DUP_ENTRY) {
+		t.Fatalf("expected a duplicate key error")
+	}
+	if isMySQLError(errors.New("Duplicate entry"), ER_DUP_ENTRY) {
+		t.Fatalf("only driver errors should match")
+	}
+}
+
+type fakeResult struct{ id, rows int64 }
+
+func (result fakeResult) LastInsertId() (int64, error) { return result.id, nil }
+func (result fakeResult) RowsAffected() (int64, error) { return result.rows, nil }
+
+func TestInsertModes(t *testing.T) {
+	insert := "INSERT INTO `event` (`name`) VALUES (?) "
+	if q := InsertIgnore.statement(insert); "INSERT IGNORE INTO `event` (`name`) VALUES (?) " != q {
+		t.Fatalf("unexpected insert ignore %q", q)
+	}
+	if q := InsertReplace.statement(insert); "REPLACE INTO `event` (`name`) VALUES (?) " != q || isIdempotent(q) {
+		t.Fatalf("unexpected replace %q", q)
+	}
+	if result, _ := InsertIgnore.interpret(fakeResult{id: 0, rows: 0}); !result.Ignored {
+		t.Fatalf("expected ignored row %+v", result)
+	}
+	if result, _ := InsertReplace.interpret(fakeResult{id: 7, rows: 2}); !result.Replaced || 7 != result.Id {
+		t.Fatalf("expected replaced row %+v", result)
+	}
+	if result, _ := InsertReplace.interpret(fakeResult{id: 8, rows: 1}); result.Replaced || 8 != result.Id {
+		t.Fatalf("expected inserted row %+v", result)
+	}
+}
+
+func TestColumnFieldMatching(t *testing.T) {
+	type customer struct {
+		Id       uint
+		Name     string
+		Greeting string `sql:"-"`
+		Orders   []uint
+		secret   string
+	}
+	fieldByColumn := map[string]int{"id": 0, "name": 1, "legacy_code": -1}
+	cols := dropUnmappedColumns([]ColumnMetadata{{Field: "id"}, {Field: "legacy_code"}, {Field: "name"}}, fieldByColumn)
+	if 2 != len(cols) || "name" != cols[1].Field || (TableMetadata{FieldByColumn: fieldByColumn}).IsColumn("legacy_code") {
+		t.Fatalf("unexpected mapped columns %v %v", cols, fieldByColumn)
+	}
+	extra := findExtraFields(reflect.TypeOf(customer{}), fieldByColumn, nil)
+	if "Orders" != strings.Join(extra, ",") {
+		t.Fatalf("unexpected extra fields %v", extra)
+	}
+}
+
+func TestExcludedField(t *testing.T) {
+	type product struct {
+		Id    uint
+		Price int    `sql:"-"`
+		Cache []uint `sql:"-" sqlexpr:"1"`
+	}
+	entityType := reflect.TypeOf(product{})
+	if j := (ColumnMetadata{Field: "price"}).matchingFieldIndex(entityType, DefaultNaming); -1 != j {
+		t.Fatalf("excluded field should not match, got %d", j)
+	}
+	virtualCols, _, err := readVirtualColumns(entityType)
+	if nil != err || 0 != len(virtualCols) {
+		t.Fatalf("excluded field should not be virtual %v %v", virtualCols, err)
+	}
+}
+
+func TestNaming(t *testing.T) {
+	for field, column := range map[string]string{"OrderId": "order_id", "OrderID": "order_id", "APIKey": "api_key",
+		"HTTPStatus": "http_status", "Address2": "address2", "Id": "id"} {
+		if got := DefaultNaming.ColumnName(field); column != got {
+			t.Fatalf("expected column %s for %s, got %s", column, field, got)
+		}
+	}
+	if got := GoNaming.FieldName("customer_api_key"); "CustomerAPIKey" != got {
+		t.Fatalf("unexpected field name %s", got)
+	}
+	if got := DefaultNaming.FieldName("order_id"); "OrderId" != got {
+		t.Fatalf("unexpected field name %s", got)
+	}
+	type order struct {
+		OrderID uint
+		APIKey  string
+	}
+	if j := (ColumnMetadata{Field: "api_key"}).matchingFieldIndex(reflect.TypeOf(order{}), DefaultNaming); 1 != j {
+		t.Fatalf("expected api_key to match APIKey, got %d", j)
+	}
+}
+
+func TestSqlTagColumnMatching(t *testing.T) {
+	type user struct {
+		Id       uint
+		UserName string `sql:"usr_nm,no-update"`
+		Version  int    `sql:"version"`
+		Status   string `sql:"insert-default=new"`
+	}
+	entityType := reflect.TypeOf(user{})
+	for colname, expected := range map[string]int{"usr_nm": 1, "user_name": -1, "version": 2, "status": 3} {
+		if j := (ColumnMetadata{Field: colname}).matchingFieldIndex(entityType, DefaultNaming); expected != j {
+			t.Fatalf("expected %s to match field %d, got %d", colname, expected, j)
+		}
+	}
+	var col ColumnMetadata
+	col.ReadSqlStructTags(entityType.Field(3))
+	if "new" != col.InsertDefault || "" != col.StructField {
+		t.Fatalf("unexpected tags %+v", col)
+	}
+}
+
+func TestAttachIndexes(t *testing.T) {
+	cols := attachIndexes([]ColumnMetadata{{Field: "id"}, {Field: "sku"}}, []IndexMetadata{
+		{KeyName: "PRIMARY", ColumnName: "id"},
+		{KeyName: "sku", ColumnName: "sku"},
+		{KeyName: "functional", ColumnName: ""},
+	})
+	if 1 != len(cols[0].Indexes) || "sku" != cols[1].Indexes[0].KeyName {
+		t.Fatalf("unexpected indexes %+v", cols)
+	}
+	if _, err := GetSchemaColumns(nil, "product", "bad name"); !errors.Is(err, ErrInvalidTableName) {
+		t.Fatalf("expected ErrInvalidTableName, got %v", err)
+	}
+	if _, err := GetColumnsContext(context.Background(), nil, "product", "bad name"); !errors.Is(err, ErrInvalidTableName) {
+		t.Fatalf("expected ErrInvalidTableName, got %v", err)
+	}
+	if tables, err := GetColumnsContext(context.Background(), nil); nil != err || 0 != len(tables) {
+		t.Fatalf("unexpected %v %v", tables, err)
+	}
+}
+
+func TestGeneratedColumnsNotWritten(t *testing.T) {
+	metadata := TableMetadata{Name: "line", Columns: []ColumnMetadata{
+		{Field: "id", Extra: "auto_increment"},
+		{Field: "price"},
+		{Field: "total", Extra: "VIRTUAL GENERATED"},
+		{Field: "tax", Extra: "STORED GENERATED"},
+		{Field: "token", Extra: "DEFAULT_GENERATED"},
+	}}
+	metadata.buildStatements()
+	if "INSERT INTO `line` (`price`, `token`) VALUES (?, ?) " != metadata.InsertString {
+		t.Fatalf("unexpected insert %q", metadata.InsertString)
+	}
+	if "UPDATE `line` SET `price`=?, `token`=? " != metadata.UpdateString {
+		t.Fatalf("unexpected update %q", metadata.UpdateString)
+	}
+	if !metadata.Columns[2].IsGenerated() || metadata.Columns[4].IsGenerated() {
+		t.Fatalf("DEFAULT_GENERATED is an expression default, not a generated column")
+	}
+}
+
+func TestStatementsWithoutEntity(t *testing.T) {
+	metadata := TableMetadata{Name: "product", Columns: []ColumnMetadata{{Field: "id", Extra: "auto_increment"}, {Field: "name"}}}
+	metadata.buildStatements()
+	if "INSERT INTO `product` (`name`) VALUES (?) " != metadata.InsertString {
+		t.Fatalf("unexpected insert %q", metadata.InsertString)
+	}
+	if "SELECT `id`, `name` FROM `product` " != metadata.SelectString {
+		t.Fatalf("unexpected select %q", metadata.SelectString)
+	}
+}
+
+func TestGroupIndexes(t *testing.T) {
+	prefix := uint(10)
+	cols := []ColumnMetadata{
+		{Field: "id", Indexes: []IndexMetadata{{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id", IndexType: "BTREE"}}},
+		{Field: "tenant_id", Indexes: []IndexMetadata{{KeyName: "tenant_sku", SeqInIndex: 1, ColumnName: "tenant_id"}}},
+		{Field: "sku", Indexes: []IndexMetadata{
+			{KeyName: "tenant_sku", SeqInIndex: 2, ColumnName: "sku", SubPart: &prefix},
+			{KeyName: "by_sku", NonUnique: true, SeqInIndex: 1, ColumnName: "sku"},
+		}},
+	}
+	indexes := GroupIndexes(cols)
+	if 3 != len(indexes) || !indexes[0].IsPrimary() || "by_sku" != indexes[1].Name {
+		t.Fatalf("unexpected index order %+v", indexes)
+	}
+	if "tenant_id,sku" != strings.Join(indexes[2].Columns, ",") || 10 != indexes[2].SubParts[1] {
+		t.Fatalf("unexpected index columns %+v", indexes[2])
+	}
+	metadata := TableMetadata{Name: "product", Columns: cols}
+	if unique := metadata.UniqueIndexes(); 2 != len(unique) || "tenant_sku" != unique[1].Name {
+		t.Fatalf("unexpected unique indexes %+v", unique)
+	}
+}
+
+func TestParseTableOptions(t *testing.T) {
+	ddl := "CREATE TABLE `product` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `price` decimal(10,2) NOT NULL DEFAULT '0.00' COMMENT 'in (EUR), net',\n" +
+		"  `category_id` int unsigned NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  CONSTRAINT `product_category` FOREIGN KEY (`category_id`) REFERENCES `category` (`id`) ON DELETE CASCADE,\n" +
+		"  CONSTRAINT `positive_price` CHECK ((`price` > 0))\n" +
+		") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci COMMENT='it''s ENGINE=MyISAM'\n" +
+		"/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 4 */"
+	options, err := ParseTableOptions(ddl)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "InnoDB" != options.Engine || 42 != options.AutoIncrement || "utf8mb4" != options.Charset ||
+		"utf8mb4_0900_ai_ci" != options.Collation || "it's ENGINE=MyISAM" != options.Comment {
+		t.Fatalf("unexpected options %+v", options)
+	}
+	if "PARTITION BY HASH (`id`)\nPARTITIONS 4" != options.Partitioning {
+		t.Fatalf("unexpected partitioning %q", options.Partitioning)
+	}
+	if 2 != len(options.Constraints) || "FOREIGN KEY" != options.Constraints[0].Kind ||
+		"((`price` > 0))" != options.Constraints[1].Definition {
+		t.Fatalf("unexpected constraints %+v", options.Constraints)
+	}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	ddl := "CREATE TABLE `product` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `price` decimal(10,2) NOT NULL,\n" +
+		"  `discount` decimal(10,2) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  CONSTRAINT `positive_price` CHECK ((`price` > 0)),\n" +
+		"  CONSTRAINT `small_discount` CHECK ((`discount` < `price`)) /*!80016 NOT ENFORCED */\n" +
+		") ENGINE=InnoDB"
+	metadata, err := ParseCreateTable(ddl, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := []CheckConstraint{
+		{Name: "positive_price", Clause: "(`price` > 0)", Enforced: true},
+		{Name: "small_discount", Clause: "(`discount` < `price`)", Enforced: false},
+	}
+	if !reflect.DeepEqual(expected, metadata.CheckConstraints) {
+		t.Fatalf("unexpected check constraints %+v", metadata.CheckConstraints)
+	}
+	if check, ok := metadata.CheckConstraint("small_discount"); !ok || check.Enforced {
+		t.Fatalf("unexpected check constraint %+v %v", check, ok)
+	}
+	source, err := GenerateStructs(CodegenOptions{Package: "model"}, metadata)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(source), "// CHECK small_discount: (`discount` < `price`) (not enforced)\ntype Product struct") {
+		t.Fatalf("expected the check constraints in the struct comment, got\n%s", source)
+	}
+}
+
+func TestGetTableStatusNames(t *testing.T) {
+	statuses, err := GetTableStatus(context.Background(), nil)
+	if nil != err || 0 != len(statuses) {
+		t.Fatalf("expected no statuses, got %v %v", statuses, err)
+	}
+	_, err = GetTableStatus(context.Background(), nil, "product; DROP TABLE product")
+	if !errors.Is(err, ErrInvalidTableName) {
+		t.Fatalf("expected ErrInvalidTableName, got %v", err)
+	}
+}
+
+func TestColumnComments(t *testing.T) {
+	metadata := TableMetadata{Columns: []ColumnMetadata{
+		{Field: "id"},
+		{Field: "price", Comment: "in EUR, net"},
+	}}
+	comments := metadata.ColumnComments()
+	if 1 != len(comments) || "in EUR, net" != comments["price"] {
+		t.Fatalf("unexpected comments %v", comments)
+	}
+}
+
+func TestGenerateStructs(t *testing.T) {
+	metadata := &TableMetadata{
+		Name:    "order_line",
+		Comment: "one product of an order",
+		Columns: []ColumnMetadata{
+			{Field: "id", ColumnType: "int unsigned", Nullable: "NO"},
+			{Field: "order_id", ColumnType: "bigint unsigned", Nullable: "NO"},
+			{Field: "price", ColumnType: "decimal(10,2)", Nullable: "NO", Comment: "in EUR, net"},
+			{Field: "shipped_at", ColumnType: "datetime", Nullable: "YES"},
+			{Field: "2fa", ColumnType: "tinyint(1) unsigned", Nullable: "NO"},
+			{Field: "total", ColumnType: "double", Nullable: "NO", Extra: "VIRTUAL GENERATED"},
+		},
+	}
+	source, err := GenerateStructs(CodegenOptions{Package: "shop", Naming: GoNaming}, metadata)
+	if nil != err {
+		t.Fatal(err)
+	}
+	// compare without the alignment of gofmt
+	generated := strings.Join(strings.Fields(string(source)), " ")
+	for _, expected := range []string{
+		"package shop import \"time\"",
+		"// OrderLine is one product of an order type OrderLine struct {",
+		"Id uint OrderID uint64 // in EUR, net Price string ShippedAt *time.Time",
+		"Column5 bool `sql:\"2fa\"` Total float64 `sql:\"no-insert,no-update\"` }",
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Fatalf("expected %q in generated code:\n%s", expected, source)
+		}
+	}
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	type Product struct {
+		Id        uint
+		Sku       string  `ddl:"type=varchar(32),unique=uq_sku"`
+		Price     float64 `ddl:"type=decimal(10,2),default=0,comment='in EUR, net'"`
+		Name      *string
+		CreatedAt time.Time `ddl:"default=CURRENT_TIMESTAMP"`
+		Version   uint      `sql:"version"`
+		Label     string    `sqlexpr:"CONCAT(sku, name)"`
+	}
+	metadata := TableMetadata{Comment: "what we sell", TableOptions: &TableOptions{Engine: "InnoDB"}}
+	if err := metadata.DescribeStruct("product", &Product{}); nil != err {
+		t.Fatal(err)
+	}
+	expected := "CREATE TABLE `product` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `sku` varchar(32) NOT NULL,\n" +
+		"  `price` decimal(10,2) NOT NULL DEFAULT 0 COMMENT 'in EUR, net',\n" +
+		"  `name` varchar(255) NULL,\n" +
+		"  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `version` int unsigned NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uq_sku` (`sku`)\n" +
+		") ENGINE=InnoDB COMMENT='what we sell'"
+	if ddl := metadata.CreateTableStatement(); expected != ddl {
+		t.Fatalf("unexpected DDL\n%s", ddl)
+	}
+	if "version" != metadata.VersionColumn || !metadata.Columns[4].AutoCreateTime {
+		t.Fatalf("expected the sql tags to be read, got %+v", metadata)
+	}
+}
+
+func TestComputeGenerated(t *testing.T) {
+	type line struct {
+		Id       uint
+		Qty      int
+		Price    string
+		Name     string
+		Total    int
+		Label    string
+		Net      string
+		Half     float64
+		Trimmed  string
+		Discount string
+	}
+	ddl := "CREATE TABLE `line` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `qty` int NOT NULL,\n" +
+		"  `price` decimal(10,2) NOT NULL,\n" +
+		"  `name` varchar(32) NOT NULL,\n" +
+		"  `total` int GENERATED ALWAYS AS ((`qty` * 2 + 1)) VIRTUAL,\n" +
+		"  `label` varchar(64) GENERATED ALWAYS AS (concat(upper(`name`),' x',`qty`)) VIRTUAL,\n" +
+		"  `net` decimal(10,2) GENERATED ALWAYS AS ((`price` * `qty`)) VIRTUAL,\n" +
+		"  `half` double GENERATED ALWAYS AS ((`qty` / 2)) VIRTUAL,\n" +
+		"  `trimmed` varchar(32) GENERATED ALWAYS AS (trim(`name`)) VIRTUAL,\n" +
+		"  `discount` varchar(32) GENERATED ALWAYS AS (concat(`price` * 0.9)) VIRTUAL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB"
+	metadata, err := ParseCreateTable(ddl, &line{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	metadata.ComputeGenerated = true
+	metadata.buildStatements()
+	computed := []string{}
+	for _, col := range metadata.ComputedColumns {
+		computed = append(computed, col.Field)
+	}
+	// DECIMAL and float values are read from the server, which formats them its way
+	if !reflect.DeepEqual([]string{"total", "label", "trimmed"}, computed) || len(computed) != len(metadata.computed) {
+		t.Fatalf("unexpected computed columns %v", computed)
+	}
+	if "SELECT `id`, `qty`, `price`, `name`, `net`, `half`, `discount` FROM `line` " != metadata.SelectString {
+		t.Fatalf("unexpected select %q", metadata.SelectString)
+	}
+	entity := line{Qty: 3, Price: "2.50", Name: "\tbolt "}
+	if err = metadata.computeGeneratedColumns(reflect.ValueOf(&entity).Elem()); nil != err {
+		t.Fatal(err)
+	}
+	// TRIM only removes spaces
+	if 7 != entity.Total || "\tBOLT  x3" != entity.Label || "\tbolt" != entity.Trimmed {
+		t.Fatalf("unexpected computed values %+v", entity)
+	}
+}
+
+func TestAlterStatements(t *testing.T) {
+	primary := IndexMetadata{KeyName: "PRIMARY", SeqInIndex: 1, ColumnName: "id"}
+	live := TableMetadata{Name: "product", Columns: []ColumnMetadata{
+		{Field: "id", ColumnType: "int(10) unsigned", Nullable: "NO", Extra: "auto_increment",
+			Indexes: []IndexMetadata{primary}},
+		{Field: "price", ColumnType: "int(11)", Nullable: "NO"},
+		{Field: "legacy", ColumnType: "varchar(10)", Nullable: "YES"},
+		{Field: "total", ColumnType: "int(11)", Nullable: "YES", Extra: "VIRTUAL GENERATED",
+			GenerationExpression: "`price` * 2"},
+	}}
+	desired := TableMetadata{Name: "product", Columns: []ColumnMetadata{
+		{Field: "id", ColumnType: "int unsigned", Nullable: "NO", Extra: "auto_increment",
+			Indexes: []IndexMetadata{primary}},
+		{Field: "sku", ColumnType: "varchar(32)", Nullable: "NO",
+			Indexes: []IndexMetadata{{KeyName: "uq_sku", SeqInIndex: 1, ColumnName: "sku"}}},
+		{Field: "price", ColumnType: "decimal(10,2)", Nullable: "NO"},
+		{Field: "total", ColumnType: "int", Nullable: "YES"},
+	}}
+	expected := []string{
+		"ALTER TABLE `product` ADD COLUMN `sku` varchar(32) NOT NULL AFTER `id`",
+		"ALTER TABLE `product` MODIFY COLUMN `price` decimal(10,2) NOT NULL",
+		"ALTER TABLE `product` DROP COLUMN `legacy`",
+		"ALTER TABLE `product` ADD UNIQUE KEY `uq_sku` (`sku`)",
+	}
+	if statements := AlterStatements(live, desired); !reflect.DeepEqual(expected, statements) {
+		t.Fatalf("unexpected statements\n%s", strings.Join(statements, "\n"))
+	}
+}
+
+func TestSnapshotEmptyDefault(t *testing.T) {
+	live, err := ParseCreateTable("CREATE TABLE `product` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `label` varchar(16) NOT NULL DEFAULT '',\n"+
+		"  `note` varchar(16) DEFAULT NULL,\n"+
+		"  `sku` varchar(16) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(live.Snapshot())
+	if nil != err {
+		t.Fatal(err)
+	}
+	var saved SchemaSnapshot
+	if err = json.Unmarshal(data, &saved); nil != err {
+		t.Fatal(err)
+	}
+	if nil == saved.Columns[1].DefaultValue || "" != *saved.Columns[1].DefaultValue || nil != saved.Columns[3].DefaultValue {
+		t.Fatalf("expected DEFAULT '' apart from no default, got %s", data)
+	}
+	// the saved snapshot as the desired schema changes nothing
+	if statements := AlterStatements(*live, saved.Metadata()); 0 != len(statements) {
+		t.Fatalf("unexpected statements\n%s", strings.Join(statements, "\n"))
+	}
+}
+
+func TestSchemaDrift(t *testing.T) {
+	metadata := TableMetadata{
+		Name: "product",
+		Columns: []ColumnMetadata{
+			{Field: "id", ColumnType: "int(10) unsigned", Nullable: "NO"},
+			{Field: "price", ColumnType: "int", Nullable: "NO"},
+			{Field: "name", ColumnType: "varchar(64)", Nullable: "NO"},
+		},
+		FieldByColumn: map[string]int{"id": 0, "price": 1, "name": 2},
+	}
+	live := []ColumnMetadata{
+		{Field: "id", ColumnType: "int unsigned", Nullable: "NO"},
+		{Field: "price", ColumnType: "decimal(10,2)", Nullable: "YES"},
+		{Field: "sku", ColumnType: "varchar(32)", Nullable: "NO"},
+	}
+	report := SchemaReport{Drifts: metadata.drift(live)}
+	expected := []string{
+		"product.price: type (expected int, got decimal(10,2))",
+		"product.price: nullable (expected NO, got YES)",
+		"product.name: missing column (expected varchar(64))",
+		"product.sku: unmapped column (got varchar(32))",
+	}
+	if len(expected) != len(report.Drifts) {
+		t.Fatalf("unexpected drift %v", report.Drifts)
+	}
+	for i, drift := range report.Drifts {
+		if expected[i] != drift.String() {
+			t.Fatalf("expected %q, got %q", expected[i], drift.String())
+		}
+	}
+	if !errors.Is(report.Err(), ErrColumnMismatch) || nil != (SchemaReport{}).Err() {
+		t.Fatalf("unexpected error %v", report.Err())
+	}
+}
+
+func TestFieldTypeWarnings(t *testing.T) {
+	type Product struct {
+		Id    uint
+		Price int64
+		Name  string
+		Sku   *string
+	}
+	metadata := TableMetadata{
+		Name: "product",
+		Columns: []ColumnMetadata{
+			{Field: "id", ColumnType: "int unsigned", Nullable: "NO"},
+			{Field: "price", ColumnType: "decimal(10,2)", Nullable: "NO"},
+			{Field: "name", ColumnType: "varchar(64)", Nullable: "YES"},
+			{Field: "sku", ColumnType: "varchar(32)", Nullable: "NO"},
+		},
+		FieldByColumn: map[string]int{"id": 0, "price": 1, "name": 2, "sku": 3},
+		Logger:        NopLogger{},
+	}
+	warnings, err := metadata.FieldTypeWarnings(&Product{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	warnings = append(warnings, SchemaWarning{Field: "Total", Kind: WARNING_EXTRA_FIELD, Severity: LogWarn})
+	if "Warning: mismatched type in columns price,name,sku; Warning: no column for fields Total" != warnings.String() {
+		t.Fatalf("unexpected string form %q", warnings.String())
+	}
+	if "int64" != warnings[0].Expected || LogError != warnings[1].Severity || LogInfo != warnings[2].Severity {
+		t.Fatalf("unexpected warnings %+v", warnings)
+	}
+	b, err := json.Marshal(warnings[1])
+	if nil != err || `{"column":"name","field":"Name","kind":"nullable","expected":"NOT NULL","actual":"NULL","severity":"error"}` != string(b) {
+		t.Fatalf("unexpected JSON %s %v", b, err)
+	}
+}
+
+func TestSaveLoadJSON(t *testing.T) {
+	type Product struct {
+		Id        uint
+		Sku       string `ddl:"type=varchar(32),unique=uq_sku" sql:"no-update"`
+		Price     int64  `ddl:"default=0"`
+		Note      *string
+		UpdatedAt time.Time
+	}
+	described := TableMetadata{}
+	if err := described.DescribeStruct("product", &Product{}); nil != err {
+		t.Fatal(err)
+	}
+	data, err := described.SaveJSON()
+	if nil != err {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTableMetadata(data, nil, &Product{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if described.InsertString != loaded.InsertString || described.UpdateString != loaded.UpdateString ||
+		!reflect.DeepEqual(described.FieldByColumn, loaded.FieldByColumn) || 2 != len(loaded.UniqueIndexes()) {
+		t.Fatalf("unexpected loaded metadata %+v", loaded)
+	}
+	if !loaded.Columns[4].AutoUpdateTime || !loaded.Columns[1].NoUpdate || int64(0) != loaded.Columns[2].Default.Value {
+		t.Fatalf("expected the struct to be read again, got %+v", loaded.Columns)
+	}
+}
+
+func TestParseCreateTable(t *testing.T) {
+	ddl := "CREATE TABLE `product` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `sku` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,\n" +
+		"  `price` decimal(10,2) NOT NULL DEFAULT '0.00' COMMENT 'in (EUR), net',\n" +
+		"  `name` varchar(64) DEFAULT NULL,\n" +
+		"  `category_id` int unsigned NOT NULL,\n" +
+		"  `total` decimal(12,2) GENERATED ALWAYS AS ((`price` * 2)) VIRTUAL,\n" +
+		"  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  UNIQUE KEY `uq_sku` (`sku`),\n" +
+		"  KEY `ix_category_name` (`category_id`,`name`(10)) COMMENT 'by category',\n" +
+		"  CONSTRAINT `product_category` FOREIGN KEY (`category_id`) REFERENCES `category` (`id`) ON DELETE CASCADE\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='what we sell'"
+	metadata, err := ParseCreateTable(ddl, nil)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "product" != metadata.Name || "what we sell" != metadata.Comment || 7 != len(metadata.Columns) {
+		t.Fatalf("unexpected metadata %+v", metadata)
+	}
+	sku, price, name := metadata.Columns[1], metadata.Columns[2], metadata.Columns[3]
+	if "varchar(32)" != sku.ColumnType || "utf8mb4_bin" != sku.Collation || "UNI" != sku.Key || "NO" != sku.Nullable {
+		t.Fatalf("unexpected sku %+v", sku)
+	}
+	if 0.0 != price.Default.Value || "in (EUR), net" != price.Comment || "YES" != name.Nullable || "" != name.DefaultValue {
+		t.Fatalf("unexpected columns %+v %+v", price, name)
+	}
+	total, updated := metadata.Columns[5], metadata.Columns[6]
+	if !total.IsGenerated() || "(`price` * 2)" != total.GenerationExpression || !updated.IsOnUpdateCurrentTimestamp() ||
+		DefaultCurrentTimestamp != updated.Default.Kind {
+		t.Fatalf("unexpected columns %+v %+v", total, updated)
+	}
+	index, ok := metadata.Index("ix_category_name")
+	if !ok || !reflect.DeepEqual([]string{"category_id", "name"}, index.Columns) || 10 != index.SubParts[1] ||
+		"by category" != index.Comment || "PRI" != metadata.Columns[0].Key {
+		t.Fatalf("unexpected index %+v", index)
+	}
+	if 1 != len(metadata.ForeignKeys) || "CASCADE" != metadata.ForeignKeys[0].DeleteRule ||
+		"category" != metadata.ForeignKeys[0].ReferencedTable {
+		t.Fatalf("unexpected foreign keys %+v", metadata.ForeignKeys)
+	}
+	if ddl := metadata.CreateTableStatement(); !strings.Contains(ddl, "`price` decimal(10,2) NOT NULL DEFAULT 0 COMMENT 'in (EUR), net'") {
+		t.Fatalf("unexpected round trip\n%s", ddl)
+	}
+}
+
+func TestParseFixtures(t *testing.T) {
+	yaml := "# demo data\n" +
+		"category:\n" +
+		"  - id: 1\n" +
+		"    name: 'Tools, hand'\n" +
+		"product:\n" +
+		"  - sku: A-1 # first\n" +
+		"    category_id: 1\n" +
+		"    active: true\n" +
+		"    name: ~\n" +
+		"  -\n" +
+		"    sku: \"B\\t2\"\n" +
+		"tag: []\n"
+	fixtures, err := ParseFixtures([]byte(yaml))
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := Fixtures{
+		{Table: "category", Rows: []map[string]interface{}{{"id": "1", "name": "Tools, hand"}}},
+		{Table: "product", Rows: []map[string]interface{}{
+			{"sku": "A-1", "category_id": "1", "active": true, "name": nil},
+			{"sku": "B\t2"},
+		}},
+		{Table: "tag", Rows: []map[string]interface{}{}},
+	}
+	if !reflect.DeepEqual(expected, fixtures) {
+		t.Fatalf("unexpected fixtures %+v", fixtures)
+	}
+	fixtures, err = ParseFixtures([]byte(`{"product": [{"sku": "A-1", "price": 2.50, "tags": ["a"]}], "category": []}`))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(fixtures) || "product" != fixtures[0].Table || "category" != fixtures[1].Table {
+		t.Fatalf("unexpected fixtures %+v", fixtures)
+	}
+	price, _ := fixtureValue(fixtures[0].Rows[0]["price"])
+	tags, _ := fixtureValue(fixtures[0].Rows[0]["tags"])
+	if "2.50" != price || `["a"]` != tags {
+		t.Fatalf("unexpected values %v %v", price, tags)
+	}
+	if _, err = ParseFixtures([]byte("product:\n  sku A-1\n")); !errors.Is(err, ErrInvalidFixtures) {
+		t.Fatalf("expected ErrInvalidFixtures, got %v", err)
+	}
+	unsupported := map[string]string{
+		"flow mapping":       "product:\n  - {sku: A-1, name: x}\n",
+		"flow sequence":      "product:\n  - tags: [a, b]\n",
+		"folded scalar":      "product:\n  - name: >\n      long\n      name\n",
+		"literal scalar":     "product:\n  - name: |\n      text\n",
+		"multi-line plain":   "product:\n  - name: long\n      name: x\n",
+		"multi-line quoted":  "product:\n  - name: 'long\n      name'\n",
+		"anchor":             "product:\n  - sku: &sku A-1\n",
+		"alias":              "product:\n  - sku: *sku\n",
+		"merge key":          "product:\n  - <<: *defaults\n",
+		"quoted key":         "product:\n  - \"sku\": A-1\n",
+		"quoted table":       "'product':\n  - sku: A-1\n",
+		"tag":                "product:\n  - sku: !!str 1\n",
+		"nested sequence":    "product:\n  - - sku: A-1\n",
+		"flow table mapping": "product: {sku: A-1}\n",
+	}
+	for name, yaml := range unsupported {
+		if _, err = ParseFixtures([]byte(yaml)); !errors.Is(err, ErrInvalidFixtures) {
+			t.Fatalf("%s: expected ErrInvalidFixtures, got %v", name, err)
+		}
+	}
+}
+
+func TestCSVValues(t *testing.T) {
+	cases := []struct {
+		columnType string
+		field      string
+		expected   interface{}
+	}{
+		{"int unsigned", "42", uint64(42)},
+		{"bigint", "-7", int64(-7)},
+		{"tinyint(1)", "true", true},
+		{"double", "2.5", 2.5},
+		{"decimal(10,2)", "19.99", "19.99"},
+		{"varchar(32)", "A-1", "A-1"},
+		{"point", "POINT(1 2)", "POINT(1 2)"},
+	}
+	for _, c := range cases {
+		value, err := csvValue(ColumnMetadata{Field: "f", ColumnType: c.columnType}, c.field)
+		if nil != err || c.expected != value {
+			t.Fatalf("%s %q: unexpected %#v %v", c.columnType, c.field, value, err)
+		}
+	}
+	if _, err := csvValue(ColumnMetadata{ColumnType: "int"}, "4x"); nil == err {
+		t.Fatal("expected an error for a malformed integer")
+	}
+	metadata := TableMetadata{Name: "product", Columns: []ColumnMetadata{{Field: "id", ColumnType: "int"}}}
+	_, err := metadata.ImportCSV(strings.NewReader("id,sku\n1,A-1\n"), CSVOptions{})
+	if !errors.Is(err, ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+}
+
+func TestAppendJSONObject(t *testing.T) {
+	cols := []ColumnMetadata{
+		{Field: "id", ColumnType: "int unsigned"},
+		{Field: "sku", ColumnType: "varchar(32)"},
+		{Field: "active", ColumnType: "tinyint(1)"},
+		{Field: "price", ColumnType: "decimal(10,2)"},
+		{Field: "attributes", ColumnType: "json"},
+		{Field: "name", ColumnType: "varchar(64)"},
+	}
+	values := []sql.RawBytes{[]byte("7"), []byte(`A"1`), []byte("0"), []byte("2.50"), []byte(`{"a": [1]}`), nil}
+	expected := `{"id":7,"sku":"A\"1","active":false,"price":2.50,"attributes":{"a": [1]},"name":null}`
+	if actual := string(appendJSONObject(nil, cols, values)); expected != actual {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestCompileScanPlan(t *testing.T) {
+	type dimensions struct{ Width, Height int }
+	type product struct {
+		Id         uint
+		Sku        string
+		Dimensions dimensions
+		Label      string `sqlexpr:"UPPER(sku)"`
+	}
+	ddl := "CREATE TABLE `product` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `sku` varchar(32) NOT NULL,\n" +
+		"  `dimensions` json DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		")"
+	metadata, err := ParseCreateTable(ddl, &product{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := &scanPlan{fields: []int{0, 1, 2, 3}, isJson: []bool{false, false, true, false}, hasJson: true,
+		isSet: []bool{false, false, false, false}}
+	if !reflect.DeepEqual(expected, metadata.scan) {
+		t.Fatalf("unexpected plan %+v", metadata.scan)
+	}
+	partial, err := metadata.Select("sku", "label")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if expected = (&scanPlan{fields: []int{1, 3}, isJson: []bool{false, false}, isSet: []bool{false, false}}); !reflect.DeepEqual(expected, partial.scan) {
+		t.Fatalf("unexpected partial plan %+v", partial.scan)
+	}
+	if nil != (TableMetadata{Columns: metadata.Columns}).compileScanPlan() {
+		t.Fatal("expected no plan without an entity type")
+	}
+}
+
+func TestAdviseIndex(t *testing.T) {
+	ddl := "CREATE TABLE `product` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `sku` varchar(32) NOT NULL,\n" +
+		"  `name` varchar(64) NOT NULL,\n" +
+		"  `price` double NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `ix_sku_name` (`sku`,`name`)\n" +
+		")"
+	type product struct {
+		Id    uint
+		Sku   string
+		Name  string
+		Price float64
+	}
+	metadata, err := ParseCreateTable(ddl, &product{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	cols := metadata.equalityColumns(" WHERE `product`.`name` = ? AND price <=> ? AND bogus = 1 ORDER BY sku = ?")
+	if !reflect.DeepEqual([]string{"name", "price"}, cols) {
+		t.Fatalf("unexpected columns %v", cols)
+	}
+	for _, clause := range []string{" WHERE sku = ? AND price = ?", " WHERE id IN (?, ?)", " WHERE price > ?", ""} {
+		if err = metadata.AdviseIndex(clause); nil != err {
+			t.Fatalf("%q: unexpected %v", clause, err)
+		}
+	}
+	if err = metadata.AdviseIndex(" WHERE name = ? AND price = ?"); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("expected ErrNotIndexed, got %v", err)
+	}
+}
+
+func TestIndexHint(t *testing.T) {
+	ddl := "CREATE TABLE `orders` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `org_id` int unsigned NOT NULL,\n" +
+		"  `created` datetime NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `idx_org_created` (`org_id`,`created`)\n" +
+		")"
+	type orders struct {
+		Id      uint
+		OrgId   uint
+		Created time.Time
+	}
+	metadata, err := ParseCreateTable(ddl, &orders{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	query, err := metadata.SelectStringWithHint("force index (`idx_org_created`)")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if metadata.SelectString+"FORCE INDEX (`idx_org_created`) " != query {
+		t.Fatalf("unexpected select %q", query)
+	}
+	hint, err := metadata.IndexHint("USE INDEX () IGNORE KEY FOR ORDER  BY (PRIMARY, idx_org_created)")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "USE INDEX () IGNORE INDEX FOR ORDER BY (PRIMARY, `idx_org_created`)" != hint {
+		t.Fatalf("unexpected hint %q", hint)
+	}
+	for _, hint := range []string{"", "FORCE INDEX (ix_missing)", "FORCE INDEX ()", "FORCE INDEX (id); DROP TABLE x"} {
+		if _, err = metadata.SelectStringWithHint(hint); !errors.Is(err, ErrInvalidIndexHint) {
+			t.Fatalf("%q: expected ErrInvalidIndexHint, got %v", hint, err)
+		}
+	}
+	hinted, err := metadata.WithIndexHint("USE INDEX (idx_org_created)")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err = hinted.WithIndexHint("USE INDEX (PRIMARY)"); !errors.Is(err, ErrInvalidIndexHint) {
+		t.Fatalf("expected ErrInvalidIndexHint, got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := &CircuitBreaker{Threshold: 2, CoolDown: 10 * time.Millisecond}
+	metadata := TableMetadata{Name: "test", Breaker: breaker}
+	timeout := fmt.Errorf("select: %w", context.DeadlineExceeded)
+	breaker.record(timeout)
+	breaker.record(&mysql.MySQLError{Number: ER_DUP_ENTRY})
+	breaker.record(timeout)
+	if CircuitClosed != breaker.State() {
+		t.Fatal("a duplicate key should break the run of failures")
+	}
+	breaker.record(timeout)
+	if _, err := metadata.query(context.Background(), "SELECT 1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if CircuitHalfOpen != breaker.State() {
+		t.Fatalf("expected half-open after the cool-down, got %s", breaker.State())
+	}
+	if nil != breaker.allow() || !errors.Is(breaker.allow(), ErrCircuitOpen) {
+		t.Fatal("expected a single trial statement")
+	}
+	breaker.record(driver.ErrBadConn)
+	if CircuitOpen != breaker.State() {
+		t.Fatal("a failed trial should open the circuit again")
+	}
+	breaker.Reset()
+	if nil != breaker.allow() {
+		t.Fatal("expected Reset to close the circuit")
+	}
+}
+
+func TestCheckTableName(t *testing.T) {
+	for _, name := range []string{"orders", "orders2024", "pay$out", "analytics.events"} {
+		if err := CheckTableName(name); nil != err {
+			t.Fatalf("%q: unexpected %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a.b.c", ".events", "events`; DROP TABLE x", "my table"} {
+		if err := CheckTableName(name); !errors.Is(err, ErrInvalidTableName) {
+			t.Fatalf("%q: expected ErrInvalidTableName, got %v", name, err)
+		}
+	}
+	if q := QuoteTableName("analytics.events"); "`analytics`.`events`" != q {
+		t.Fatalf("unexpected quoted name %q", q)
+	}
+	if q := QuoteIdentifier("a`b"); "`a``b`" != q {
+		t.Fatalf("unexpected quoted name %q", q)
+	}
+}
+
+func TestQualifiedTableName(t *testing.T) {
+	type invoice struct {
+		Id    uint
+		Total float64
+	}
+	metadata := TableMetadata{TablePrefix: "app_"}
+	if err := metadata.DescribeStruct("billing.invoice", &invoice{}); nil != err {
+		t.Fatal(err)
+	}
+	if "billing.app_invoice" != metadata.Name || "SELECT `id`, `total` FROM `billing`.`app_invoice` " != metadata.SelectString {
+		t.Fatalf("unexpected table %s: %q", metadata.Name, metadata.SelectString)
+	}
+	filter, args, names, err := tableArgs([]string{"billing.invoice", "invoice"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "(TABLE_SCHEMA, TABLE_NAME) IN ((IFNULL(?, DATABASE()), ?), (IFNULL(?, DATABASE()), ?))" != filter ||
+		!reflect.DeepEqual([]interface{}{sql.NullString{String: "billing", Valid: true}, "invoice", sql.NullString{}, "invoice"}, args) {
+		t.Fatalf("unexpected filter %q %v", filter, args)
+	}
+	if found := names.lookup("billing", "invoice", true); !reflect.DeepEqual([]string{"billing.invoice", "invoice"}, found) {
+		t.Fatalf("unexpected names %v", found)
+	}
+	if found := names.lookup("billing", "invoice", false); !reflect.DeepEqual([]string{"billing.invoice"}, found) {
+		t.Fatalf("unexpected names %v", found)
+	}
+}
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Logf(level LogLevel, format string, v ...interface{}) {
+	l.messages = append(l.messages, level.String()+": "+fmt.Sprintf(format, v...))
+}
+
+func TestLogger(t *testing.T) {
+	for _, level := range []LogLevel{LogDebug, LogInfo, LogWarn, LogError} {
+		text, _ := level.MarshalText()
+		var parsed LogLevel
+		if err := parsed.UnmarshalText(text); nil != err || level != parsed {
+			t.Fatalf("expected %v to round trip, got %v %v", level, parsed, err)
+		}
+	}
+	var parsed LogLevel
+	if err := parsed.UnmarshalText([]byte("verbose")); nil == err {
+		t.Fatal("expected an error for an unknown level")
+	}
+	defer SetLogger(GetLogger())
+	SetLogger(nil)
+	if _, ok := GetLogger().(NopLogger); !ok {
+		t.Fatalf("expected a nil logger to silence output, got %T", GetLogger())
+	}
+	global := &recordingLogger{}
+	SetLogger(global)
+	metadata := TableMetadata{Name: "product"}
+	metadata.logf(LogWarn, "from %s", metadata.Name)
+	table := &recordingLogger{}
+	metadata.Logger = table
+	metadata.logf(LogInfo, "from %s", metadata.Name)
+	if !reflect.DeepEqual([]string{"warn: from product"}, global.messages) ||
+		!reflect.DeepEqual([]string{"info: from product"}, table.messages) {
+		t.Fatalf("expected the table logger to take precedence, got %v %v", global.messages, table.messages)
+	}
+}
@@ -0,0 +1,66 @@
+// Package mysqlmetaotel adds OpenTelemetry spans to mysqlmeta: one around each
+// statement run for a table, and one around each FetchTableMetadata, annotated with
+// the table, the operation and the rows affected.
+package mysqlmetaotel
+
+import (
+	"context"
+
+	"github.com/johnhanjukim/mysqlmeta"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// treat as const
+var INSTRUMENTATION_NAME = "github.com/johnhanjukim/mysqlmeta"
+
+// Options configures NewTracer.
+type Options struct {
+	// TracerProvider makes the spans, the global provider if nil
+	TracerProvider trace.TracerProvider
+	// OmitStatement leaves the SQL out of the spans, ex. when it would reveal too much
+	OmitStatement bool
+}
+
+type tracer struct {
+	tracer  trace.Tracer
+	options Options
+}
+
+func NewTracer(options Options) mysqlmeta.Tracer {
+	// Returns a tracer to set as the Tracer of a mysqlmeta.Config or TableMetadata:
+	//
+	//	mysqlmeta.SetConfig(mysqlmeta.Config{Tracer: mysqlmetaotel.NewTracer(mysqlmetaotel.Options{TracerProvider: provider})})
+	provider := options.TracerProvider
+	if nil == provider {
+		provider = otel.GetTracerProvider()
+	}
+	return tracer{tracer: provider.Tracer(INSTRUMENTATION_NAME), options: options}
+}
+
+func (t tracer) Start(ctx context.Context, span mysqlmeta.Span) (context.Context, func(int64, error)) {
+	// Spans are named as the operation and table, ex. "select product", with the
+	// database semantic conventions for their attributes.
+	attributes := []attribute.KeyValue{
+		attribute.String("db.system", "mysql"),
+		attribute.String("db.sql.table", span.Table),
+		attribute.String("db.operation", span.Operation),
+	}
+	if "" != span.Statement && !t.options.OmitStatement {
+		attributes = append(attributes, attribute.String("db.statement", span.Statement))
+	}
+	ctx, s := t.tracer.Start(ctx, span.Operation+" "+span.Table,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	return ctx, func(rowsAffected int64, err error) {
+		if 0 <= rowsAffected {
+			s.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
+		}
+		if nil != err {
+			s.RecordError(err)
+			s.SetStatus(codes.Error, err.Error())
+		}
+		s.End()
+	}
+}
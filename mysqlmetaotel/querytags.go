@@ -0,0 +1,28 @@
+package mysqlmetaotel
+
+import (
+	"context"
+
+	"github.com/johnhanjukim/mysqlmeta"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func QueryTags(ctx context.Context) []mysqlmeta.QueryTag {
+	// Returns the W3C trace context of the span in ctx as traceparent and tracestate
+	// tags, for the QueryTagger of a mysqlmeta.Config or TableMetadata, so that slow
+	// query logs can be matched to traces:
+	//
+	//	mysqlmeta.SetConfig(mysqlmeta.Config{TagQueries: mysqlmeta.Bool(true), SQLCommenter: mysqlmeta.Bool(true), QueryTagger: mysqlmetaotel.QueryTags})
+	span := trace.SpanContextFromContext(ctx)
+	if !span.IsValid() {
+		return nil
+	}
+	tags := []mysqlmeta.QueryTag{{
+		Key:   "traceparent",
+		Value: "00-" + span.TraceID().String() + "-" + span.SpanID().String() + "-" + span.TraceFlags().String(),
+	}}
+	if state := span.TraceState().String(); "" != state {
+		tags = append(tags, mysqlmeta.QueryTag{Key: "tracestate", Value: state})
+	}
+	return tags
+}
@@ -0,0 +1,107 @@
+// Package mysqlmetaprom exposes Prometheus metrics for mysqlmeta, labeled by table and
+// operation: the latency and errors of statements, and the rows affected and scanned.
+package mysqlmetaprom
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/johnhanjukim/mysqlmeta"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options configures NewMetrics.
+type Options struct {
+	// Registerer registers the metrics, prometheus.DefaultRegisterer if nil
+	Registerer prometheus.Registerer
+	// Namespace prefixes the metric names, "mysqlmeta" if empty
+	Namespace string
+	// Buckets are those of the latency histogram, prometheus.DefBuckets if nil
+	Buckets []float64
+}
+
+// Metrics is a mysqlmeta.Tracer that records metrics rather than spans. Set it as the
+// Tracer of a mysqlmeta.Config or TableMetadata, with mysqlmeta.Tracers to also trace.
+type Metrics struct {
+	duration     *prometheus.HistogramVec
+	errors       *prometheus.CounterVec
+	rowsAffected *prometheus.CounterVec
+	rowsScanned  *prometheus.CounterVec
+	// scanned caches the counter of each table, as rows are counted one at a time
+	scanned sync.Map
+}
+
+func NewMetrics(options Options) (*Metrics, error) {
+	// Makes and registers the metrics:
+	//
+	//	mysqlmeta_operation_duration_seconds{table, operation}
+	//	mysqlmeta_operation_errors_total{table, operation}
+	//	mysqlmeta_rows_affected_total{table, operation}
+	//	mysqlmeta_rows_scanned_total{table}
+	//
+	// The operation is "fetch" for FetchTableMetadata, else the statement verb.
+	registerer := options.Registerer
+	if nil == registerer {
+		registerer = prometheus.DefaultRegisterer
+	}
+	namespace := options.Namespace
+	if "" == namespace {
+		namespace = "mysqlmeta"
+	}
+	buckets := options.Buckets
+	if nil == buckets {
+		buckets = prometheus.DefBuckets
+	}
+	labels := []string{"table", "operation"}
+	metrics := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of the statements run by mysqlmeta, including retries.",
+			Buckets:   buckets,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operation_errors_total",
+			Help:      "Statements run by mysqlmeta that failed.",
+		}, labels),
+		rowsAffected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_affected_total",
+			Help:      "Rows affected by the statements run by mysqlmeta.",
+		}, labels),
+		rowsScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_scanned_total",
+			Help:      "Rows scanned into entities by mysqlmeta.",
+		}, []string{"table"}),
+	}
+	for _, collector := range []prometheus.Collector{metrics.duration, metrics.errors, metrics.rowsAffected, metrics.rowsScanned} {
+		if err := registerer.Register(collector); nil != err {
+			return nil, err
+		}
+	}
+	return metrics, nil
+}
+
+func (metrics *Metrics) Start(ctx context.Context, span mysqlmeta.Span) (context.Context, func(int64, error)) {
+	start := time.Now()
+	return ctx, func(rowsAffected int64, err error) {
+		metrics.duration.WithLabelValues(span.Table, span.Operation).Observe(time.Since(start).Seconds())
+		if nil != err {
+			metrics.errors.WithLabelValues(span.Table, span.Operation).Inc()
+		}
+		if 0 < rowsAffected {
+			metrics.rowsAffected.WithLabelValues(span.Table, span.Operation).Add(float64(rowsAffected))
+		}
+	}
+}
+
+func (metrics *Metrics) RowsScanned(table string, rows int) {
+	counter, ok := metrics.scanned.Load(table)
+	if !ok {
+		counter, _ = metrics.scanned.LoadOrStore(table, metrics.rowsScanned.WithLabelValues(table))
+	}
+	counter.(prometheus.Counter).Add(float64(rows))
+}
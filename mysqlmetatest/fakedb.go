@@ -0,0 +1,262 @@
+// Package mysqlmetatest helps to unit test code built on mysqlmeta without MySQL: it
+// makes metadata fixtures from DDL or structs, provides a fake database that records
+// the statements run on it, and compares generated statements with golden files.
+package mysqlmetatest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Statement is one query or exec run on a fake database, with its arguments.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Rows is a result set queued for the next query on a fake database.
+type Rows struct {
+	Columns []string
+	Values  [][]interface{}
+}
+
+// Result is queued for the next exec on a fake database.
+type Result struct {
+	LastInsertId int64
+	RowsAffected int64
+	Err          error
+}
+
+// Recorder holds the statements run on a fake database, and the results it will
+// return. Queries without queued rows return no rows, and execs without a queued
+// result affect one row with the next insert id.
+type Recorder struct {
+	mu           sync.Mutex
+	statements   []Statement
+	rows         []Rows
+	results      []Result
+	lastInsertId int64
+}
+
+func NewDB() (*sql.DB, *Recorder) {
+	// Returns a database whose statements are recorded rather than run.
+	recorder := &Recorder{}
+	return sql.OpenDB(connector{recorder}), recorder
+}
+
+func (recorder *Recorder) AddRows(columns []string, values ...[]interface{}) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.rows = append(recorder.rows, Rows{Columns: columns, Values: values})
+}
+
+func (recorder *Recorder) AddResult(result Result) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.results = append(recorder.results, result)
+}
+
+func (recorder *Recorder) Statements() []Statement {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]Statement{}, recorder.statements...)
+}
+
+func (recorder *Recorder) LastStatement() Statement {
+	// Returns the statement run last, or an empty one if there was none.
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if 0 == len(recorder.statements) {
+		return Statement{}
+	}
+	return recorder.statements[len(recorder.statements)-1]
+}
+
+func (recorder *Recorder) Reset() {
+	// Forgets the statements run, and the rows and results not yet returned.
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.statements = nil
+	recorder.rows = nil
+	recorder.results = nil
+}
+
+func (recorder *Recorder) record(query string, args []driver.NamedValue) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	recorder.statements = append(recorder.statements, Statement{Query: query, Args: values})
+}
+
+func (recorder *Recorder) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.record(query, args)
+	if 0 == len(recorder.rows) {
+		return &rows{}, nil
+	}
+	next := recorder.rows[0]
+	recorder.rows = recorder.rows[1:]
+	return &rows{columns: next.Columns, values: next.Values}, nil
+}
+
+func (recorder *Recorder) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.record(query, args)
+	if 0 < len(recorder.results) {
+		next := recorder.results[0]
+		recorder.results = recorder.results[1:]
+		if nil != next.Err {
+			return nil, next.Err
+		}
+		return result{next.LastInsertId, next.RowsAffected}, nil
+	}
+	id := int64(0)
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "INSERT") {
+		recorder.lastInsertId++
+		id = recorder.lastInsertId
+	}
+	return result{id, 1}, nil
+}
+
+type connector struct {
+	recorder *Recorder
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{c.recorder}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, fmt.Errorf("mysqlmetatest: open the fake database with NewDB")
+}
+
+type conn struct {
+	recorder *Recorder
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c.recorder, query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return tx{c.recorder}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.recorder.query(query, args)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.recorder.exec(query, args)
+}
+
+type tx struct {
+	recorder *Recorder
+}
+
+func (t tx) Commit() error {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	t.recorder.record("COMMIT", nil)
+	return nil
+}
+
+func (t tx) Rollback() error {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	t.recorder.record("ROLLBACK", nil)
+	return nil
+}
+
+type stmt struct {
+	recorder *Recorder
+	query    string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.recorder.exec(s.query, named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.recorder.query(s.query, named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.recorder.exec(s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.recorder.query(s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
+
+type result struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]interface{}
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	for i, value := range r.values[r.next] {
+		dest[i] = value
+	}
+	r.next++
+	return nil
+}
@@ -0,0 +1,66 @@
+package mysqlmetatest
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/johnhanjukim/mysqlmeta"
+)
+
+func Metadata(t testing.TB, db *sql.DB, ddl string, entity interface{}) *mysqlmeta.TableMetadata {
+	// Returns metadata for the entity from CREATE TABLE, as SHOW CREATE TABLE prints it,
+	// using db for its statements, ex. a database from NewDB. Fails the test on error.
+	t.Helper()
+	metadata, err := mysqlmeta.ParseCreateTable(ddl, entity)
+	if nil != err {
+		t.Fatalf("mysqlmetatest: %v", err)
+	}
+	metadata.DB = db
+	return metadata
+}
+
+func MetadataFromStruct(t testing.TB, db *sql.DB, tableName string, entity interface{}) *mysqlmeta.TableMetadata {
+	// Returns metadata for the entity from the struct alone, as DescribeStruct makes
+	// it, using db for its statements. Fails the test on error.
+	t.Helper()
+	metadata := &mysqlmeta.TableMetadata{}
+	if err := metadata.DescribeStruct(tableName, entity); nil != err {
+		t.Fatalf("mysqlmetatest: %v", err)
+	}
+	metadata.DB = db
+	return metadata
+}
+
+func golden(metadata *mysqlmeta.TableMetadata) string {
+	return "select: " + strings.TrimSpace(metadata.SelectString) + "\n" +
+		"insert: " + strings.TrimSpace(metadata.InsertString) + "\n" +
+		"update: " + strings.TrimSpace(metadata.UpdateString) + "\n"
+}
+
+func AssertGolden(t testing.TB, path string, metadata *mysqlmeta.TableMetadata) {
+	// Compares the SelectString, InsertString and UpdateString of the metadata with
+	// the golden file at path, one "select:", "insert:" and "update:" line each. With
+	// UPDATE_GOLDEN=1 in the environment, the file is written instead.
+	t.Helper()
+	actual := golden(metadata)
+	if "1" == os.Getenv("UPDATE_GOLDEN") {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+			t.Fatalf("mysqlmetatest: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0644); nil != err {
+			t.Fatalf("mysqlmetatest: %v", err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if nil != err {
+		t.Fatalf("mysqlmetatest: %v (run with UPDATE_GOLDEN=1 to write it)", err)
+	}
+	if string(expected) != actual {
+		t.Errorf("mysqlmetatest: statements of %s differ from %s\nexpected:\n%sactual:\n%s",
+			metadata.Name, path, expected, actual)
+	}
+}
@@ -0,0 +1,1523 @@
+package mysqlmetatest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/johnhanjukim/mysqlmeta"
+)
+
+type product struct {
+	Id    uint
+	Sku   string
+	Price float64
+	Name  *string
+}
+
+const PRODUCT_DDL = "CREATE TABLE `product` (\n" +
+	"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `sku` varchar(32) NOT NULL,\n" +
+	"  `price` double NOT NULL DEFAULT '0',\n" +
+	"  `name` varchar(64) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	") ENGINE=InnoDB"
+
+func TestRecorder(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	AssertGolden(t, "testdata/product.golden", metadata)
+
+	id, err := metadata.InsertEntity(&product{Sku: "A-1", Price: 2.5})
+	if nil != err {
+		t.Fatal(err)
+	}
+	statement := recorder.LastStatement()
+	if 1 != id || metadata.InsertString != statement.Query ||
+		!reflect.DeepEqual([]interface{}{"A-1", 2.5, nil}, statement.Args) {
+		t.Fatalf("unexpected insert %d %+v", id, statement)
+	}
+
+	recorder.AddRows([]string{"id", "sku", "price", "name"}, []interface{}{int64(1), "A-1", 2.5, nil})
+	found := product{}
+	if _, err = metadata.GetEntityById(&found, 1); nil != err {
+		t.Fatal(err)
+	}
+	if "A-1" != found.Sku || nil != found.Name || 2 != len(recorder.Statements()) {
+		t.Fatalf("unexpected entity %+v", found)
+	}
+}
+
+func TestEntityIter(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	recorder.AddRows([]string{"id", "sku", "price", "name"},
+		[]interface{}{int64(1), "A-1", 2.5, nil},
+		[]interface{}{int64(2), "B-2", 3.0, "bolt"},
+		[]interface{}{int64(3), "C-3", 4.0, nil})
+	skus := []string{}
+	for entity, err := range metadata.EntityIter(" ORDER BY id") {
+		if nil != err {
+			t.Fatal(err)
+		}
+		skus = append(skus, entity.(*product).Sku)
+		if 2 == len(skus) {
+			break
+		}
+	}
+	if !reflect.DeepEqual([]string{"A-1", "B-2"}, skus) {
+		t.Fatalf("unexpected skus %v", skus)
+	}
+	if "SELECT `id`, `sku`, `price`, `name` FROM `product`  ORDER BY id" != recorder.LastStatement().Query {
+		t.Fatalf("unexpected query %q", recorder.LastStatement().Query)
+	}
+}
+
+func TestForEachBatch(t *testing.T) {
+	db, recorder := NewDB()
+	table, err := mysqlmeta.TableFor[product](Metadata(t, db, PRODUCT_DDL, &product{}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	columns := []string{"id", "sku", "price", "name"}
+	recorder.AddRows(columns, []interface{}{int64(3), "A-1", 2.5, nil}, []interface{}{int64(5), "B-2", 3.0, nil})
+	recorder.AddRows(columns, []interface{}{int64(8), "C-3", 4.0, nil})
+	sizes := []int{}
+	progress, err := table.ForEachBatch(context.Background(), 2, "price > ?", func(batch []product) error {
+		sizes = append(sizes, len(batch))
+		return nil
+	}, 1.0)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if (mysqlmeta.BatchProgress{LastId: 8, Batches: 2, Rows: 3}) != progress || !reflect.DeepEqual([]int{2, 1}, sizes) {
+		t.Fatalf("unexpected progress %+v %v", progress, sizes)
+	}
+	statements := recorder.Statements()
+	expected := []interface{}{int64(5), 1.0, int64(2)}
+	if 2 != len(statements) || !reflect.DeepEqual(expected, statements[1].Args) {
+		t.Fatalf("unexpected statements %+v", statements)
+	}
+}
+
+func BenchmarkScanEntity(b *testing.B) {
+	db, recorder := NewDB()
+	metadata := Metadata(b, db, PRODUCT_DDL, &product{})
+	columns := []string{"id", "sku", "price", "name"}
+	values := make([][]interface{}, 1000)
+	for i := range values {
+		values[i] = []interface{}{int64(i + 1), "A-1", 2.5, "bolt"}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder.AddRows(columns, values...)
+		rows, err := metadata.GetRows("")
+		if nil != err {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+			if err = metadata.ScanEntity(&product{}, rows); nil != err {
+				b.Fatal(err)
+			}
+		}
+		rows.Close()
+		recorder.Reset()
+	}
+}
+
+type dimensions struct {
+	Width  int
+	Height int
+}
+
+type parcel struct {
+	Id         uint
+	Sku        string
+	Dimensions dimensions
+}
+
+func BenchmarkScanEntityJSON(b *testing.B) {
+	db, recorder := NewDB()
+	ddl := "CREATE TABLE `parcel` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `sku` varchar(32) NOT NULL,\n" +
+		"  `dimensions` text NOT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		")"
+	metadata := Metadata(b, db, ddl, &parcel{})
+	columns := []string{"id", "sku", "dimensions"}
+	values := make([][]interface{}, 1000)
+	for i := range values {
+		values[i] = []interface{}{int64(i + 1), "A-1", []byte(`{"Width": 20, "Height": 30}`)}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder.AddRows(columns, values...)
+		rows, err := metadata.GetRows("")
+		if nil != err {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+			if err = metadata.ScanEntity(&parcel{}, rows); nil != err {
+				b.Fatal(err)
+			}
+		}
+		rows.Close()
+		recorder.Reset()
+	}
+}
+
+func TestStatementCache(t *testing.T) {
+	db, recorder := NewDB()
+	data, err := Metadata(t, db, PRODUCT_DDL, &product{}).SaveJSON()
+	if nil != err {
+		t.Fatal(err)
+	}
+	metadata := &mysqlmeta.TableMetadata{CacheStatements: true, StatementCacheSize: 2}
+	if err = metadata.LoadJSON(data, db, &product{}); nil != err {
+		t.Fatal(err)
+	}
+	defer metadata.Close()
+	for _, clause := range []string{" WHERE id = ?", " WHERE sku = ?", " WHERE id = ?", " WHERE name = ?"} {
+		rows, err := metadata.GetRows(clause, 1)
+		if nil != err {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+	stats := metadata.StatementCacheStats()
+	expected := mysqlmeta.StatementCacheStats{Size: 2, Capacity: 2, Hits: 1, Misses: 3, Evictions: 1}
+	if expected != stats || 0.25 != stats.HitRate() || 4 != len(recorder.Statements()) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if err = metadata.InvalidateStatements(metadata.SelectString + " WHERE id = ?"); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != metadata.CachedStatements() {
+		t.Fatalf("expected one statement left, got %d", metadata.CachedStatements())
+	}
+}
+
+func TestQueryObserver(t *testing.T) {
+	db, _ := NewDB()
+	statements := []string{}
+	rows := []int64{}
+	observer := mysqlmeta.QueryObserverFunc(func(query string, args []interface{}, duration time.Duration, rowsAffected int64, err error) {
+		statements = append(statements, query)
+		rows = append(rows, rowsAffected)
+	})
+	metadata, err := mysqlmeta.ParseCreateTable(PRODUCT_DDL, &product{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	metadata.DB = db
+	metadata.Observer = mysqlmeta.Observers(observer, nil)
+	if _, err = metadata.InsertEntity(&product{Sku: "A-1"}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err = metadata.GetEntityById(&product{}, 1); nil == err {
+		t.Fatal("expected no entity")
+	}
+	if !reflect.DeepEqual([]string{metadata.InsertString, metadata.SelectString + " WHERE id = ?"}, statements) ||
+		!reflect.DeepEqual([]int64{1, -1}, rows) {
+		t.Fatalf("unexpected observed statements %q %v", statements, rows)
+	}
+}
+
+type spanRecorder struct {
+	spans   []mysqlmeta.Span
+	rows    []int64
+	scanned int
+}
+
+func (recorder *spanRecorder) RowsScanned(table string, rows int) {
+	recorder.scanned += rows
+}
+
+func (recorder *spanRecorder) Start(ctx context.Context, span mysqlmeta.Span) (context.Context, func(int64, error)) {
+	recorder.spans = append(recorder.spans, span)
+	return ctx, func(rowsAffected int64, err error) {
+		recorder.rows = append(recorder.rows, rowsAffected)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	spans := &spanRecorder{}
+	metadata.Tracer = spans
+	recorder.AddResult(Result{RowsAffected: 3})
+	if _, err := metadata.DeleteWhere(" WHERE price = ?", 0); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := metadata.GetRows(""); nil != err {
+		t.Fatal(err)
+	}
+	expected := []mysqlmeta.Span{
+		{Table: "product", Operation: "delete", Statement: "DELETE FROM `product`  WHERE price = ?"},
+		{Table: "product", Operation: "select", Statement: metadata.SelectString},
+	}
+	if !reflect.DeepEqual(expected, spans.spans) || !reflect.DeepEqual([]int64{3, -1}, spans.rows) {
+		t.Fatalf("unexpected spans %+v %v", spans.spans, spans.rows)
+	}
+}
+
+func TestTracers(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	first, second := &spanRecorder{}, &spanRecorder{}
+	metadata.Tracer = mysqlmeta.Tracers(first, nil, second)
+	recorder.AddRows([]string{"id", "sku", "price", "name"},
+		[]interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "B-2", 3.0, nil})
+	entities := []product{}
+	if err := metadata.GetEntities(&entities, ""); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(first.spans) || 1 != len(second.spans) || 2 != first.scanned || 2 != second.scanned {
+		t.Fatalf("unexpected spans %+v %+v", first, second)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	recorder.AddRows([]string{"id", "select_type", "table", "partitions", "type", "possible_keys", "key",
+		"key_len", "ref", "rows", "filtered", "Extra"},
+		[]interface{}{int64(1), "SIMPLE", "product", nil, "ALL", nil, nil, nil, nil, int64(1200), 10.0, "Using where"})
+	plan, err := metadata.Explain(" WHERE name = ?", "bolt")
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := mysqlmeta.ExplainRow{Id: 1, SelectType: "SIMPLE", Table: "product", Type: "ALL", Rows: 1200,
+		Filtered: 10, Extra: "Using where"}
+	if 1 != len(plan) || !reflect.DeepEqual(expected, plan[0]) || 1 != len(plan.FullTableScans()) {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	if "EXPLAIN "+metadata.SelectString+" WHERE name = ?" != recorder.LastStatement().Query {
+		t.Fatalf("unexpected query %q", recorder.LastStatement().Query)
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	db, primary := NewDB()
+	replicaDB1, replica1 := NewDB()
+	replicaDB2, replica2 := NewDB()
+	metadata, err := mysqlmeta.ParseCreateTable(PRODUCT_DDL, &product{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	metadata.DB = db
+	metadata.Replicas = []*sql.DB{replicaDB1, replicaDB2}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err = metadata.GetEntitiesContext(ctx, &[]product{}, ""); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if 1 != len(replica1.Statements()) || 1 != len(replica2.Statements()) || 0 != len(primary.Statements()) {
+		t.Fatalf("expected a read on each replica, got %v %v %v", replica1.Statements(), replica2.Statements(), primary.Statements())
+	}
+	if err = metadata.GetEntitiesContext(mysqlmeta.WithPrimary(ctx), &[]product{}, ""); nil != err {
+		t.Fatal(err)
+	}
+	// a read after a write of the session waits for a replica only with ReplicaWait
+	ctx = mysqlmeta.WithSession(ctx)
+	if _, err = metadata.InsertEntityContext(ctx, &product{Sku: "A-1"}); nil != err {
+		t.Fatal(err)
+	}
+	if err = metadata.GetEntitiesContext(ctx, &[]product{}, ""); nil != err {
+		t.Fatal(err)
+	}
+	queries := []string{}
+	for _, statement := range primary.Statements() {
+		queries = append(queries, statement.Query)
+	}
+	expected := []string{metadata.SelectString, metadata.InsertString, "SELECT @@GLOBAL.gtid_executed", metadata.SelectString}
+	if !reflect.DeepEqual(expected, queries) || 2 != len(replica1.Statements())+len(replica2.Statements()) {
+		t.Fatalf("unexpected primary statements %q", queries)
+	}
+}
+
+func TestTableResolver(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	metadata.TableResolver = mysqlmeta.ShardedTable("product_%02d", 4)
+	ctx := mysqlmeta.WithTenant(context.Background(), 6)
+	if err := metadata.GetEntitiesContext(ctx, &[]product{}, " WHERE `product`.`sku` = ?", "A-1"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := metadata.InsertEntityContext(ctx, &product{Sku: "A-1"}); nil != err {
+		t.Fatal(err)
+	}
+	statements := recorder.Statements()
+	if "SELECT `id`, `sku`, `price`, `name` FROM `product_02`  WHERE `product_02`.`sku` = ?" != statements[0].Query ||
+		"INSERT INTO `product_02` (`sku`, `price`, `name`) VALUES (?, ?, ?) " != statements[1].Query {
+		t.Fatalf("unexpected statements %q", statements)
+	}
+	if err := metadata.GetEntitiesContext(context.Background(), &[]product{}, ""); !errors.Is(err, mysqlmeta.ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+	metadata.TableResolver = mysqlmeta.TenantTable("product_%s")
+	if _, err := metadata.ResolveTableName(mysqlmeta.WithTenant(ctx, "a`b")); !errors.Is(err, mysqlmeta.ErrInvalidTableName) {
+		t.Fatalf("expected ErrInvalidTableName, got %v", err)
+	}
+	// a column named like its table keeps its name
+	type tag struct {
+		Id  uint
+		Tag string
+	}
+	tags := Metadata(t, db, "CREATE TABLE `tag` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `tag` varchar(32) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &tag{})
+	tags.TableResolver = mysqlmeta.ShardedTable("tag_%02d", 4)
+	recorder.Reset()
+	if err := tags.GetEntitiesContext(ctx, &[]tag{}, " WHERE `tag`.`tag` = ?", "red"); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := tags.InsertEntityContext(ctx, &tag{Tag: "red"}); nil != err {
+		t.Fatal(err)
+	}
+	statements = recorder.Statements()
+	if "SELECT `id`, `tag` FROM `tag_02`  WHERE `tag_02`.`tag` = ?" != statements[0].Query ||
+		"INSERT INTO `tag_02` (`tag`) VALUES (?) " != statements[1].Query {
+		t.Fatalf("unexpected statements %q", statements)
+	}
+}
+
+type tenantNote struct {
+	Id    uint
+	OrgId uint `sql:"tenant"`
+	Body  string
+}
+
+func TestTenantScope(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `note` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `org_id` int unsigned NOT NULL,\n"+
+		"  `body` text NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &tenantNote{})
+	if "org_id" != metadata.TenantColumn {
+		t.Fatalf("expected org_id as the tenant column, got %q", metadata.TenantColumn)
+	}
+	ctx := mysqlmeta.WithTenant(context.Background(), uint(7))
+	note := &tenantNote{Body: "hello"}
+	if _, err := metadata.InsertEntityContext(ctx, note); nil != err {
+		t.Fatal(err)
+	}
+	if 7 != note.OrgId {
+		t.Fatalf("expected the tenant to be filled in, got %d", note.OrgId)
+	}
+	if err := metadata.GetEntitiesContext(ctx, &[]tenantNote{}, " WHERE body = ? ORDER BY id", "hello"); nil != err {
+		t.Fatal(err)
+	}
+	if err := metadata.UpdateEntityContext(ctx, note); nil != err {
+		t.Fatal(err)
+	}
+	if err := metadata.DeleteEntityContext(ctx, note); nil != err {
+		t.Fatal(err)
+	}
+	expected := []Statement{
+		{Query: "INSERT INTO `note` (`org_id`, `body`) VALUES (?, ?) ", Args: []interface{}{int64(7), "hello"}},
+		{Query: "SELECT `id`, `org_id`, `body` FROM `note`  WHERE (`note`.`org_id` = ?) AND (body = ?) ORDER BY id", Args: []interface{}{int64(7), "hello"}},
+		{Query: "UPDATE `note` SET `body`=?  WHERE id = ? AND `org_id` = ?", Args: []interface{}{"hello", int64(1), int64(7)}},
+		{Query: "DELETE FROM `note` WHERE id = ? AND `org_id` = ?", Args: []interface{}{int64(1), int64(7)}},
+	}
+	if statements := recorder.Statements(); !reflect.DeepEqual(expected, statements) {
+		t.Fatalf("unexpected statements %q", statements)
+	}
+	// the tenant binds to its own placeholder after those of a JOIN
+	join := " JOIN tag ON tag.note_id = note.id AND tag.name = ? WHERE note.body = ?"
+	if err := metadata.GetEntitiesContext(ctx, &[]tenantNote{}, join, "urgent", "b"); nil != err {
+		t.Fatal(err)
+	}
+	statement := recorder.LastStatement()
+	if !strings.HasSuffix(statement.Query, " JOIN tag ON tag.note_id = note.id AND tag.name = ? WHERE (`note`.`org_id` = ?) AND (note.body = ?) ") ||
+		!reflect.DeepEqual([]interface{}{"urgent", int64(7), "b"}, statement.Args) {
+		t.Fatalf("unexpected join %s %v", statement.Query, statement.Args)
+	}
+	if _, err := metadata.CountContext(ctx, " JOIN tag ON tag.name = ?", "urgent"); nil != err {
+		t.Fatal(err)
+	}
+	if args := recorder.LastStatement().Args; !reflect.DeepEqual([]interface{}{"urgent", int64(7)}, args) {
+		t.Fatalf("unexpected count args %v", args)
+	}
+	if err := metadata.GetEntitiesContext(context.Background(), &[]tenantNote{}, ""); !errors.Is(err, mysqlmeta.ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+	if _, err := metadata.InsertEntityContext(mysqlmeta.WithTenant(context.Background(), uint(8)), &tenantNote{OrgId: 7}); !errors.Is(err, mysqlmeta.ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity, got %v", err)
+	}
+	if err := metadata.Unscoped().GetEntitiesContext(context.Background(), &[]tenantNote{}, ""); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantIntoStringColumn(t *testing.T) {
+	type workspaceNote struct {
+		Id        uint
+		Workspace string `sql:"tenant"`
+		Body      string
+	}
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `workspace_note` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `workspace` varchar(32) NOT NULL,\n"+
+		"  `body` text NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &workspaceNote{})
+	// an integer tenant is written out in digits, not converted to the rune "A"
+	note := &workspaceNote{Body: "hello"}
+	if _, err := metadata.InsertEntityContext(mysqlmeta.WithTenant(context.Background(), 65), note); nil != err || "65" != note.Workspace {
+		t.Fatalf("expected the tenant 65, got %q %v", note.Workspace, err)
+	}
+	if args := recorder.LastStatement().Args; "65" != args[0] {
+		t.Fatalf("unexpected insert args %v", args)
+	}
+	note = &workspaceNote{Body: "hello"}
+	if _, err := metadata.InsertEntityContext(mysqlmeta.WithTenant(context.Background(), uint8(7)), note); nil != err || "7" != note.Workspace {
+		t.Fatalf("expected the tenant 7, got %q %v", note.Workspace, err)
+	}
+	if _, err := metadata.InsertEntityContext(mysqlmeta.WithTenant(context.Background(), 1.5), &workspaceNote{}); !errors.Is(err, mysqlmeta.ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity for a float tenant, got %v", err)
+	}
+}
+
+func TestTenantImportAndUpdateWhere(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `note` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `org_id` int unsigned NOT NULL,\n"+
+		"  `body` text NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &tenantNote{})
+	ctx := mysqlmeta.WithTenant(context.Background(), uint(7))
+	if _, err := metadata.ImportCSVContext(ctx, strings.NewReader("body\nhello\n"), mysqlmeta.CSVOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	if _, err := metadata.ImportCSVContext(ctx, strings.NewReader("org_id,body\n7,hi\n"), mysqlmeta.CSVOptions{}); nil != err {
+		t.Fatal(err)
+	}
+	var inserts []Statement
+	for _, statement := range recorder.Statements() {
+		if strings.HasPrefix(statement.Query, "INSERT") {
+			inserts = append(inserts, statement)
+		}
+	}
+	expected := []Statement{
+		{Query: "INSERT INTO `note` (`body`, `org_id`) VALUES (?, ?)", Args: []interface{}{"hello", int64(7)}},
+		{Query: "INSERT INTO `note` (`org_id`, `body`) VALUES (?, ?)", Args: []interface{}{int64(7), "hi"}},
+	}
+	if !reflect.DeepEqual(expected, inserts) {
+		t.Fatalf("unexpected inserts %q", inserts)
+	}
+	recorder.Reset()
+	_, err := metadata.ImportCSVContext(ctx, strings.NewReader("org_id,body\n8,hi\n"), mysqlmeta.CSVOptions{})
+	if !errors.Is(err, mysqlmeta.ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity, got %v", err)
+	}
+	_, err = metadata.ImportCSV(strings.NewReader("body\nhello\n"), mysqlmeta.CSVOptions{})
+	if !errors.Is(err, mysqlmeta.ErrNoTenant) {
+		t.Fatalf("expected ErrNoTenant, got %v", err)
+	}
+	for _, colname := range []string{"org_id", "id"} {
+		_, err = metadata.UpdateWhereContext(ctx, map[string]interface{}{colname: 8}, "WHERE body = ?", "hi")
+		if !errors.Is(err, mysqlmeta.ErrInvalidColumn) {
+			t.Fatalf("expected ErrInvalidColumn for %s, got %v", colname, err)
+		}
+	}
+	for _, statement := range recorder.Statements() {
+		if !strings.HasPrefix(statement.Query, "ROLLBACK") && !strings.HasPrefix(statement.Query, "BEGIN") {
+			t.Fatalf("expected nothing to be written, got %q", statement)
+		}
+	}
+	if _, err = metadata.UpdateWhereContext(ctx, map[string]interface{}{"body": "bye"}, "WHERE body = ?", "hi"); nil != err {
+		t.Fatal(err)
+	}
+	expected = []Statement{{Query: "UPDATE `note` SET `body`=? WHERE (`note`.`org_id` = ?) AND (body = ?) ",
+		Args: []interface{}{"bye", int64(7), "hi"}}}
+	if statement := recorder.LastStatement(); !reflect.DeepEqual(expected[0], statement) {
+		t.Fatalf("unexpected update %#v", statement)
+	}
+}
+
+func TestShowIndexesByName(t *testing.T) {
+	db, recorder := NewDB()
+	cols := []mysqlmeta.ColumnMetadata{{Field: "id"}, {Field: "sku"}}
+	// MySQL 8 adds Visible and Expression, and functional indexes have no column
+	recorder.AddRows([]string{"Table", "Non_unique", "Key_name", "Seq_in_index", "Column_name", "Collation",
+		"Cardinality", "Sub_part", "Packed", "Null", "Index_type", "Comment", "Index_comment", "Visible", "Expression"},
+		[]interface{}{"product", int64(0), "PRIMARY", int64(1), "id", "A", int64(10), nil, nil, "", "BTREE", "", "", "YES", nil},
+		[]interface{}{"product", int64(1), "functional", int64(1), nil, "A", nil, nil, nil, "YES", "BTREE", "", "", "YES", "lower(`sku`)"},
+	)
+	cols, err := mysqlmeta.GetIndexes(db, "product", cols)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(cols[0].Indexes) || "PRIMARY" != cols[0].Indexes[0].KeyName || 10 != cols[0].Indexes[0].Cardinality {
+		t.Fatalf("unexpected indexes %+v", cols)
+	}
+	recorder.AddRows([]string{"Table", "Key_name"}, []interface{}{"product", "PRIMARY"})
+	if _, err = mysqlmeta.GetIndexes(db, "product", cols); nil == err {
+		t.Fatalf("expected an error for missing columns")
+	}
+}
+
+func TestVitessDialect(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	metadata.Dialect = mysqlmeta.DialectVitess
+	recorder.AddResult(Result{RowsAffected: 1})
+	entity := product{Id: 7, Sku: "A-1"}
+	if _, err := metadata.InsertEntity(&entity); nil != err {
+		t.Fatal(err)
+	}
+	if 7 != entity.Id {
+		t.Fatalf("the id should be kept when vtgate returns none, got %d", entity.Id)
+	}
+	recorder.AddResult(Result{LastInsertId: 9, RowsAffected: 1})
+	if _, err := metadata.InsertEntity(&entity); nil != err || 9 != entity.Id {
+		t.Fatalf("unexpected id %d %v", entity.Id, err)
+	}
+}
+
+func TestInsertServerFilledColumns(t *testing.T) {
+	type order struct {
+		OrderId  uint
+		Number   string
+		PlacedAt time.Time
+		Token    string `sql:"token,insert"`
+	}
+	ddl := "CREATE TABLE `order` (\n" +
+		"  `order_id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `number` varchar(32) NOT NULL,\n" +
+		"  `placed_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"  `token` varchar(36) NOT NULL DEFAULT (uuid()),\n" +
+		"  PRIMARY KEY (`order_id`)\n" +
+		") ENGINE=InnoDB"
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, ddl, &order{})
+	if "INSERT INTO `order` (`number`, `token`) VALUES (?, ?) " != metadata.InsertString {
+		t.Fatalf("unexpected insert %q", metadata.InsertString)
+	}
+	recorder.AddResult(Result{LastInsertId: 12, RowsAffected: 1})
+	entity := order{Number: "A-1", Token: "t"}
+	if _, err := metadata.InsertEntity(&entity); nil != err || 12 != entity.OrderId {
+		t.Fatalf("unexpected id %d %v", entity.OrderId, err)
+	}
+}
+
+func TestLiteralDefaultsInserted(t *testing.T) {
+	type schedule struct {
+		ScheduleId uint
+		Start      string
+		Zone       string
+	}
+	ddl := "CREATE TABLE `schedule` (\n" +
+		"  `schedule_id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `start` varchar(16) NOT NULL DEFAULT 'now',\n" +
+		"  `zone` enum('localtime','utc') NOT NULL DEFAULT 'localtime',\n" +
+		"  PRIMARY KEY (`schedule_id`)\n" +
+		") ENGINE=InnoDB"
+	db, _ := NewDB()
+	metadata := Metadata(t, db, ddl, &schedule{})
+	if "INSERT INTO `schedule` (`start`, `zone`) VALUES (?, ?) " != metadata.InsertString {
+		t.Fatalf("unexpected insert %q", metadata.InsertString)
+	}
+	if strings.Contains(metadata.UpdateString, "`schedule_id`=") {
+		t.Fatalf("expected the primary key not to be updated, got %q", metadata.UpdateString)
+	}
+}
+
+func TestEnumAndSetColumns(t *testing.T) {
+	type order struct {
+		Id     uint
+		Status string
+		Tags   []string
+	}
+	ddl := "CREATE TABLE `order` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `status` enum('new','paid') NOT NULL DEFAULT 'new',\n" +
+		"  `tags` set('gift','rush') DEFAULT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB"
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, ddl, &order{})
+	if !reflect.DeepEqual([]string{"gift", "rush"}, metadata.Columns[2].AllowedValues) || 0 != len(metadata.Warnings) {
+		t.Fatalf("unexpected columns %+v %v", metadata.Columns, metadata.Warnings)
+	}
+	if _, err := metadata.InsertEntity(&order{Status: "paid", Tags: []string{"gift", "rush"}}); nil != err {
+		t.Fatal(err)
+	}
+	if args := recorder.LastStatement().Args; !reflect.DeepEqual([]interface{}{"paid", "gift,rush"}, args) {
+		t.Fatalf("unexpected args %v", args)
+	}
+	recorder.Reset()
+	if _, err := metadata.InsertEntity(&order{Status: "shipped"}); !errors.Is(err, mysqlmeta.ErrInvalidEnumValue) {
+		t.Fatalf("expected ErrInvalidEnumValue, got %v", err)
+	}
+	if _, err := metadata.InsertEntity(&order{Status: "new", Tags: []string{"fragile"}}); !errors.Is(err, mysqlmeta.ErrInvalidEnumValue) {
+		t.Fatalf("expected ErrInvalidEnumValue, got %v", err)
+	}
+	if 0 != len(recorder.Statements()) {
+		t.Fatalf("expected no statements, got %+v", recorder.Statements())
+	}
+	recorder.AddRows([]string{"id", "status", "tags"},
+		[]interface{}{int64(1), "paid", []byte("gift,rush")}, []interface{}{int64(2), "new", nil})
+	found := []order{}
+	if err := metadata.GetEntities(&found, ""); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(found) || !reflect.DeepEqual([]string{"gift", "rush"}, found[0].Tags) || nil != found[1].Tags {
+		t.Fatalf("unexpected entities %+v", found)
+	}
+}
+
+func TestInvisibleColumns(t *testing.T) {
+	type account struct {
+		Id    uint
+		Email string
+		Audit string
+	}
+	ddl := "CREATE TABLE `account` (\n" +
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `email` varchar(64) NOT NULL,\n" +
+		"  `audit` varchar(64) NOT NULL DEFAULT '' /*!80023 INVISIBLE */,\n" +
+		"  `shard_hint` int NOT NULL DEFAULT '0' /*!80023 INVISIBLE */,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB"
+	db, _ := NewDB()
+	metadata := Metadata(t, db, ddl, &account{})
+	if "SELECT `id`, `email`, `audit` FROM `account` " != metadata.SelectString {
+		t.Fatalf("unexpected select %q", metadata.SelectString)
+	}
+	audit := metadata.Columns[2]
+	if 3 != len(metadata.Columns) || !audit.Invisible {
+		t.Fatalf("unexpected columns %+v", metadata.Columns)
+	}
+	if definition := audit.ColumnDefinition(); "`audit` varchar(64) NOT NULL DEFAULT '' /*!80023 INVISIBLE */" != definition {
+		t.Fatalf("unexpected definition %q", definition)
+	}
+}
+
+func TestRegistryConcurrentLookup(t *testing.T) {
+	type account struct {
+		Id    uint
+		Email string
+	}
+	db, recorder := NewDB()
+	recorder.AddRows([]string{"VERSION()"}, []interface{}{"8.0.35"})
+	recorder.AddRows([]string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"},
+		[]interface{}{"id", "int unsigned", nil, "NO", "PRI", nil, "auto_increment", "", ""},
+		[]interface{}{"email", "varchar(64)", "utf8mb4_0900_ai_ci", "NO", "", nil, "", "", ""})
+	recorder.AddRows([]string{"Table", "Non_unique", "Key_name", "Seq_in_index", "Column_name", "Collation",
+		"Cardinality", "Sub_part", "Packed", "Null", "Index_type", "Comment", "Index_comment"})
+	registry := &mysqlmeta.Registry{}
+	if err := registry.Declare(db, "account", &account{}); nil != err {
+		t.Fatal(err)
+	}
+	// run with -race: Lookup and Tables must not read the entry while Get fills it in
+	done := make(chan *mysqlmeta.TableMetadata)
+	for i := 0; i < 4; i++ {
+		go func() {
+			metadata, err := registry.Get(&account{})
+			if nil != err {
+				t.Error(err)
+			}
+			done <- metadata
+		}()
+		go func() {
+			if metadata, ok := registry.Lookup(&account{}); ok && "account" != metadata.Name {
+				t.Errorf("unexpected metadata %+v", metadata)
+			}
+			registry.Tables()
+			done <- nil
+		}()
+	}
+	var fetched *mysqlmeta.TableMetadata
+	for i := 0; i < 8; i++ {
+		if metadata := <-done; nil != metadata {
+			if nil != fetched && fetched != metadata {
+				t.Fatal("expected the metadata to be shared")
+			}
+			fetched = metadata
+		}
+	}
+	if metadata, ok := registry.Lookup(&account{}); !ok || fetched != metadata {
+		t.Fatalf("unexpected lookup %v %v", metadata, ok)
+	}
+}
+
+func TestQueryTimeoutLeavesRows(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	if timeout := metadata.GetOperationPolicy(context.Background()).Timeout; 0 != timeout {
+		t.Fatalf("expected no timeout without a class, got %v", timeout)
+	}
+	ctx := mysqlmeta.WithOperationClass(context.Background(), mysqlmeta.OperationInteractive)
+	if timeout := metadata.GetOperationPolicy(ctx).Timeout; 5*time.Second != timeout {
+		t.Fatalf("expected the default timeout of the class, got %v", timeout)
+	}
+	metadata.Policies = map[mysqlmeta.OperationClass]mysqlmeta.OperationPolicy{
+		mysqlmeta.OperationInteractive: {Timeout: 20 * time.Millisecond},
+	}
+	recorder.AddRows([]string{"id", "sku", "price", "name"},
+		[]interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "B-2", 3.0, nil})
+	rows, err := metadata.GetRowsContext(ctx, "")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	// a slow reader takes longer than the timeout of the query
+	time.Sleep(60 * time.Millisecond)
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	if err = rows.Err(); nil != err || 2 != count {
+		t.Fatalf("expected 2 rows, got %d %v", count, err)
+	}
+}
+
+func TestGetEntityErrors(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	found := product{}
+	if entity, err := metadata.GetEntityById(&found, 1); !errors.Is(err, mysqlmeta.ErrNotFound) || nil != entity {
+		t.Fatalf("expected ErrNotFound, got %v %v", entity, err)
+	}
+	columns := []string{"id", "sku", "price", "name"}
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "A-1", 3.0, nil})
+	entity, err := metadata.GetEntityByColumn(&found, "sku", "A-1")
+	if !errors.Is(err, mysqlmeta.ErrMultipleRows) || &found != entity || 1 != found.Id {
+		t.Fatalf("expected ErrMultipleRows with the first row, got %+v %v", found, err)
+	}
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "A-1", 3.0, nil})
+	found = product{}
+	if _, err = metadata.GetEntity(&found, " WHERE sku = ? ORDER BY id", "A-1"); nil != err || 1 != found.Id {
+		t.Fatalf("expected the first row, got %+v %v", found, err)
+	}
+}
+
+func TestGetEntitiesAfterLastPage(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	columns := []string{"id", "sku", "price", "name"}
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "B-2", 3.0, nil})
+	recorder.AddRows(columns, []interface{}{int64(3), "C-3", 4.0, nil})
+	entities := []product{}
+	next, err := metadata.GetEntitiesAfterKeys(&entities, []string{"id"}, nil, 2, false)
+	if nil != err || !reflect.DeepEqual([]interface{}{uint(2)}, next) {
+		t.Fatalf("expected the keys of the full page, got %v %v", next, err)
+	}
+	// the final page is short, so there is nothing after it
+	next, err = metadata.GetEntitiesAfterKeys(&entities, []string{"id"}, next, 2, false)
+	if nil != err || nil != next || 3 != len(entities) {
+		t.Fatalf("expected nil keys after the last page, got %v %v %d", next, err, len(entities))
+	}
+	if statement := recorder.LastStatement(); !strings.Contains(statement.Query, "WHERE ((`id` > ?)) ORDER BY `id` LIMIT ?") {
+		t.Fatalf("unexpected statement %s", statement.Query)
+	}
+	// a limit of zero fails rather than returning the keys of a row already in dest
+	for _, limit := range []int{0, -1} {
+		if next, err = metadata.GetEntitiesAfterKeys(&entities, []string{"id"}, nil, limit, false); nil == err || nil != next {
+			t.Fatalf("expected an error for a limit of %d, got %v %v", limit, next, err)
+		}
+	}
+	if cursor, err := metadata.GetEntitiesAfterCursor(&[]product{}, "", 0, false); nil == err || "" != cursor {
+		t.Fatalf("expected an error for a limit of 0, got %q %v", cursor, err)
+	}
+	recorder.AddRows(columns, []interface{}{int64(4), "D-4", 5.0, nil})
+	entities = []product{}
+	if after, err := metadata.GetEntitiesAfter(&entities, "id", uint(3), 2); nil != err || nil != after || 1 != len(entities) {
+		t.Fatalf("expected nil after the last page, got %v %v", after, err)
+	}
+}
+
+func TestGetEntitiesByIds(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	defer func(max int) { mysqlmeta.MAX_IN_VALUES = max }(mysqlmeta.MAX_IN_VALUES)
+	mysqlmeta.MAX_IN_VALUES = 2
+	columns := []string{"id", "sku", "price", "name"}
+	// rows come back in the order of the table, not of the ids
+	recorder.AddRows(columns, []interface{}{int64(3), "C-3", 4.0, nil}, []interface{}{int64(5), "E-5", 6.0, nil})
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil})
+	recorder.AddRows(columns, []interface{}{int64(5), "E-5", 6.0, nil})
+	entities := []product{}
+	if err := metadata.GetEntitiesByIds(&entities, []uint{5, 3, 9, 1, 5}); nil != err {
+		t.Fatal(err)
+	}
+	ids := []uint{}
+	for _, entity := range entities {
+		ids = append(ids, entity.Id)
+	}
+	// the missing 9 is skipped and the duplicate 5 is kept
+	if !reflect.DeepEqual([]uint{5, 3, 1, 5}, ids) {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+	statements := recorder.Statements()
+	if 3 != len(statements) {
+		t.Fatalf("expected one query per MAX_IN_VALUES ids, got %d", len(statements))
+	}
+	if !strings.HasSuffix(statements[0].Query, "WHERE id IN (?, ?)") || !reflect.DeepEqual([]interface{}{int64(5), int64(3)}, statements[0].Args) {
+		t.Fatalf("unexpected first query %s %v", statements[0].Query, statements[0].Args)
+	}
+	if !strings.HasSuffix(statements[2].Query, "WHERE id IN (?)") || !reflect.DeepEqual([]interface{}{int64(5)}, statements[2].Args) {
+		t.Fatalf("unexpected last query %s %v", statements[2].Query, statements[2].Args)
+	}
+	recorder.Reset()
+	recorder.AddRows(columns, []interface{}{int64(7), "G-7", 8.0, nil})
+	found, err := metadata.GetEntityMapByIds([]uint{7, 8})
+	if nil != err || 1 != len(found) || 7 != found[7].(*product).Id {
+		t.Fatalf("expected only the found id in the map, got %v %v", found, err)
+	}
+	if _, ok := found[8]; ok {
+		t.Fatal("expected the missing id to be absent")
+	}
+}
+
+func TestTable(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	if _, err := mysqlmeta.TableFor[tenantNote](metadata); !errors.Is(err, mysqlmeta.ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity for another struct, got %v", err)
+	}
+	table, err := mysqlmeta.TableFor[product](metadata)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	columns := []string{"id", "sku", "price", "name"}
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil})
+	found, err := table.GetById(ctx, 1)
+	if nil != err || "A-1" != found.Sku {
+		t.Fatalf("unexpected product %+v %v", found, err)
+	}
+	if _, err = table.GetById(ctx, 2); !errors.Is(err, mysqlmeta.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "B-2", 3.0, nil})
+	listed, err := table.List(ctx, " WHERE price > ?", 1)
+	if nil != err || 2 != len(listed) || "B-2" != listed[1].Sku {
+		t.Fatalf("unexpected products %+v %v", listed, err)
+	}
+	id, err := table.Insert(ctx, &product{Sku: "C-3", Price: 4})
+	if nil != err || 0 == id {
+		t.Fatalf("unexpected insert %d %v", id, err)
+	}
+	if statement := recorder.LastStatement(); !strings.HasPrefix(statement.Query, "INSERT INTO `product`") {
+		t.Fatalf("unexpected statement %s", statement.Query)
+	}
+}
+
+func TestStatementCacheClose(t *testing.T) {
+	db, recorder := NewDB()
+	data, err := Metadata(t, db, PRODUCT_DDL, &product{}).SaveJSON()
+	if nil != err {
+		t.Fatal(err)
+	}
+	metadata := &mysqlmeta.TableMetadata{CacheStatements: true}
+	if err = metadata.LoadJSON(data, db, &product{}); nil != err {
+		t.Fatal(err)
+	}
+	columns := []string{"id", "sku", "price", "name"}
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil})
+	if _, err = metadata.GetEntityById(&product{}, 1); nil != err || 1 != metadata.CachedStatements() {
+		t.Fatalf("expected the query to be prepared, got %d %v", metadata.CachedStatements(), err)
+	}
+	if err = metadata.Close(); nil != err || 0 != metadata.CachedStatements() {
+		t.Fatalf("expected Close to empty the cache, got %d %v", metadata.CachedStatements(), err)
+	}
+	// queries after Close still run, but are no longer prepared
+	recorder.AddRows(columns, []interface{}{int64(2), "B-2", 3.0, nil})
+	found := product{}
+	if _, err = metadata.GetEntityById(&found, 2); nil != err || "B-2" != found.Sku {
+		t.Fatalf("unexpected product after Close %+v %v", found, err)
+	}
+	if stats := metadata.StatementCacheStats(); 0 != stats.Size || 1 != stats.Misses {
+		t.Fatalf("expected nothing prepared after Close, got %+v", stats)
+	}
+}
+
+func TestRegistryTablePrefix(t *testing.T) {
+	type account struct {
+		Id    uint
+		Email string
+	}
+	db, recorder := NewDB()
+	recorder.AddRows([]string{"VERSION()"}, []interface{}{"8.0.35"})
+	recorder.AddRows([]string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"},
+		[]interface{}{"id", "int unsigned", nil, "NO", "PRI", nil, "auto_increment", "", ""},
+		[]interface{}{"email", "varchar(64)", "utf8mb4_0900_ai_ci", "NO", "", nil, "", "", ""})
+	recorder.AddRows([]string{"Table", "Non_unique", "Key_name", "Seq_in_index", "Column_name", "Collation",
+		"Cardinality", "Sub_part", "Packed", "Null", "Index_type", "Comment", "Index_comment"})
+	registry := &mysqlmeta.Registry{TablePrefix: "app_"}
+	if err := registry.Declare(db, "account", &account{}); nil != err {
+		t.Fatal(err)
+	}
+	metadata, err := registry.Get(&account{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "app_account" != metadata.Name || "account" != metadata.BaseName || "app_" != metadata.TablePrefix {
+		t.Fatalf("unexpected names %s %s %s", metadata.Name, metadata.BaseName, metadata.TablePrefix)
+	}
+	if "app_invoice" != metadata.PrefixedName("invoice") {
+		t.Fatalf("unexpected prefixed name %s", metadata.PrefixedName("invoice"))
+	}
+	for _, statement := range recorder.Statements() {
+		if strings.Contains(statement.Query, "SHOW") && !strings.Contains(statement.Query, "`app_account`") {
+			t.Fatalf("expected the prefixed table in %s", statement.Query)
+		}
+	}
+	if !strings.Contains(metadata.SelectString, "FROM `app_account`") {
+		t.Fatalf("unexpected select %s", metadata.SelectString)
+	}
+}
+
+func TestAutoTimestamps(t *testing.T) {
+	type event struct {
+		Id        uint
+		Name      string
+		CreatedAt time.Time
+		UpdatedAt *time.Time
+	}
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `event` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(64) NOT NULL,\n"+
+		"  `created_at` datetime NOT NULL,\n"+
+		"  `updated_at` datetime NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &event{})
+	before := time.Now()
+	entity := event{Name: "launch"}
+	if _, err := metadata.InsertEntity(&entity); nil != err {
+		t.Fatal(err)
+	}
+	if entity.CreatedAt.Before(before) || nil == entity.UpdatedAt || !entity.UpdatedAt.Equal(entity.CreatedAt) {
+		t.Fatalf("expected both times set on insert, got %+v", entity)
+	}
+	if args := recorder.LastStatement().Args; 3 != len(args) {
+		t.Fatalf("expected the times in the insert, got %v", args)
+	}
+	created := entity.CreatedAt
+	time.Sleep(time.Millisecond)
+	entity.Id = 1
+	if err := metadata.UpdateEntity(&entity); nil != err {
+		t.Fatal(err)
+	}
+	if !entity.CreatedAt.Equal(created) || !entity.UpdatedAt.After(created) {
+		t.Fatalf("expected only updated_at to change on update, got %+v", entity)
+	}
+	if query := recorder.LastStatement().Query; strings.Contains(query, "`created_at`") || !strings.Contains(query, "`updated_at`") {
+		t.Fatalf("unexpected update %s", query)
+	}
+}
+
+func TestGetEntitiesGroupedBy(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	if _, err := metadata.GetEntitiesGroupedBy("colour", []interface{}{"red"}); !errors.Is(err, mysqlmeta.ErrInvalidColumn) {
+		t.Fatalf("expected ErrInvalidColumn, got %v", err)
+	}
+	columns := []string{"id", "sku", "price", "name"}
+	recorder.AddRows(columns,
+		[]interface{}{int64(1), "A-1", 2.5, nil},
+		[]interface{}{int64(2), "B-2", 3.0, "bolt"},
+		[]interface{}{int64(3), "A-1", 4.0, nil})
+	grouped, err := metadata.GetEntitiesGroupedBy("sku", []interface{}{"A-1", "B-2", "C-3"})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(grouped) || 2 != len(grouped["A-1"]) || 3 != grouped["A-1"][1].(*product).Id || 1 != len(grouped["B-2"]) {
+		t.Fatalf("unexpected groups %v", grouped)
+	}
+	if statement := recorder.LastStatement(); !strings.HasSuffix(statement.Query, "WHERE `sku` IN (?, ?, ?)") || 3 != len(statement.Args) {
+		t.Fatalf("unexpected statement %s %v", statement.Query, statement.Args)
+	}
+	// a nullable column groups its NULL rows under nil
+	recorder.AddRows(columns, []interface{}{int64(1), "A-1", 2.5, nil}, []interface{}{int64(2), "B-2", 3.0, "bolt"})
+	table, err := mysqlmeta.TableFor[product](metadata)
+	if nil != err {
+		t.Fatal(err)
+	}
+	byName, err := table.GroupedBy(context.Background(), "name", []interface{}{nil, "bolt"})
+	if nil != err || 1 != len(byName[nil]) || "B-2" != byName["bolt"][0].Sku {
+		t.Fatalf("unexpected groups %v %v", byName, err)
+	}
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	type document struct {
+		Id      uint
+		Body    string
+		Version uint `sql:"version"`
+	}
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `document` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `body` text NOT NULL,\n"+
+		"  `version` int unsigned NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &document{})
+	if "version" != metadata.VersionColumn {
+		t.Fatalf("expected the version column, got %q", metadata.VersionColumn)
+	}
+	entity := document{Id: 1, Body: "draft", Version: 3}
+	if err := metadata.UpdateEntity(&entity); nil != err || 4 != entity.Version {
+		t.Fatalf("expected the version bumped, got %d %v", entity.Version, err)
+	}
+	statement := recorder.LastStatement()
+	if !strings.HasSuffix(statement.Query, "WHERE id = ? AND `version` = ?") || int64(3) != statement.Args[len(statement.Args)-1] {
+		t.Fatalf("unexpected update %s %v", statement.Query, statement.Args)
+	}
+	// another writer bumped the version first, so no row matches
+	recorder.AddResult(Result{RowsAffected: 0})
+	entity.Body = "final"
+	if err := metadata.UpdateEntity(&entity); !errors.Is(err, mysqlmeta.ErrStaleEntity) || 4 != entity.Version {
+		t.Fatalf("expected ErrStaleEntity with the version kept, got %d %v", entity.Version, err)
+	}
+}
+
+func TestGetForeignKeys(t *testing.T) {
+	db, recorder := NewDB()
+	recorder.AddRows([]string{"CONSTRAINT_NAME", "COLUMN_NAME", "other_schema", "REFERENCED_TABLE_SCHEMA",
+		"REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "UPDATE_RULE", "DELETE_RULE"},
+		[]interface{}{"line_order", "order_id", int64(0), "shop", "order", "id", "RESTRICT", "CASCADE"},
+		[]interface{}{"line_variant", "product_id", int64(0), "shop", "variant", "product_id", "NO ACTION", "RESTRICT"},
+		[]interface{}{"line_variant", "variant_no", int64(0), "shop", "variant", "no", "NO ACTION", "RESTRICT"},
+		[]interface{}{"line_tax", "tax_id", int64(1), "billing", "tax", "id", "RESTRICT", "SET NULL"})
+	keys, err := mysqlmeta.GetForeignKeys(db, "shop.line")
+	if nil != err {
+		t.Fatal(err)
+	}
+	expected := []mysqlmeta.ForeignKeyMetadata{
+		{ConstraintName: "line_order", Columns: []string{"order_id"}, ReferencedTable: "order",
+			ReferencedColumns: []string{"id"}, UpdateRule: "RESTRICT", DeleteRule: "CASCADE"},
+		{ConstraintName: "line_variant", Columns: []string{"product_id", "variant_no"}, ReferencedTable: "variant",
+			ReferencedColumns: []string{"product_id", "no"}, UpdateRule: "NO ACTION", DeleteRule: "RESTRICT"},
+		{ConstraintName: "line_tax", Columns: []string{"tax_id"}, ReferencedTable: "billing.tax",
+			ReferencedColumns: []string{"id"}, UpdateRule: "RESTRICT", DeleteRule: "SET NULL"},
+	}
+	if !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("unexpected foreign keys %+v", keys)
+	}
+	if args := recorder.LastStatement().Args; 2 != len(args) || "line" != args[1] {
+		t.Fatalf("unexpected args %v", args)
+	}
+	metadata := mysqlmeta.TableMetadata{ForeignKeys: keys}
+	if to := metadata.ForeignKeysTo("variant"); 1 != len(to) || "line_variant" != to[0].ConstraintName {
+		t.Fatalf("unexpected keys to variant %+v", to)
+	}
+	if _, err = mysqlmeta.GetForeignKeys(db, "bad name"); nil == err {
+		t.Fatal("expected an invalid table name to fail")
+	}
+}
+
+func TestScanEntityByName(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	// a join selects columns in its own order, with a column of another table
+	// and the repeated id of the joined table
+	recorder.AddRows([]string{"price", "category", "id", "sku", "id"},
+		[]interface{}{2.5, "tools", int64(1), "A-1", int64(40)})
+	rows, err := db.Query("SELECT p.price, c.name AS category, p.id, p.sku, c.id FROM product p JOIN category c")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	name := "kept"
+	found := product{Name: &name}
+	if !rows.Next() {
+		t.Fatal("expected a row")
+	}
+	if err = metadata.ScanEntityByName(&found, rows); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != found.Id || "A-1" != found.Sku || 2.5 != found.Price || nil == found.Name || "kept" != *found.Name {
+		t.Fatalf("unexpected product %+v", found)
+	}
+	if err = metadata.ScanEntityByName(product{}, rows); nil == err {
+		t.Fatal("expected an error scanning into a non-pointer")
+	}
+}
+
+func TestCountAndExists(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	recorder.AddRows([]string{"COUNT(*)"}, []interface{}{int64(42)})
+	count, err := metadata.Count(" WHERE price < ?", 10)
+	if nil != err || 42 != count {
+		t.Fatalf("unexpected count %d %v", count, err)
+	}
+	if query := recorder.LastStatement().Query; "SELECT COUNT(*) FROM `product`  WHERE price < ?" != query {
+		t.Fatalf("unexpected count query %q", query)
+	}
+	recorder.AddRows([]string{"1"}, []interface{}{int64(1)})
+	if exists, err := metadata.Exists(" WHERE sku = ?", "A-1"); nil != err || !exists {
+		t.Fatalf("expected a row to exist, got %v %v", exists, err)
+	}
+	if query := recorder.LastStatement().Query; !strings.HasSuffix(query, "WHERE sku = ? LIMIT 1") {
+		t.Fatalf("unexpected exists query %q", query)
+	}
+	// no row at all means nothing matched
+	if exists, err := metadata.Exists(" WHERE sku = ?", "Z-9"); nil != err || exists {
+		t.Fatalf("expected no row to exist, got %v %v", exists, err)
+	}
+}
+
+func TestGetPage(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	entities := []product{}
+	if _, err := metadata.GetPage(&entities, "", 1, 0); nil == err {
+		t.Fatal("expected an error for a page size of 0")
+	}
+	if _, err := metadata.GetPage(&entities, " WHERE price > ? ORDER BY sku", 3, 20, 1); nil != err {
+		t.Fatal(err)
+	}
+	// the count runs alongside the page, so the statements come in either order
+	queries := map[string][]interface{}{}
+	for _, statement := range recorder.Statements() {
+		queries[statement.Query] = statement.Args
+	}
+	if args, ok := queries["SELECT COUNT(*) FROM `product`  WHERE price > ? "]; !ok || !reflect.DeepEqual([]interface{}{int64(1)}, args) {
+		t.Fatalf("expected the count without ORDER BY, got %v", queries)
+	}
+	if args, ok := queries[metadata.SelectString+" WHERE price > ? ORDER BY sku LIMIT ? OFFSET ?"]; !ok ||
+		!reflect.DeepEqual([]interface{}{int64(1), int64(20), int64(40)}, args) {
+		t.Fatalf("expected the third page of 20, got %v", queries)
+	}
+	recorder.Reset()
+	if _, err := metadata.GetPage(&entities, "", 0, 20); nil != err {
+		t.Fatal(err)
+	}
+	for _, statement := range recorder.Statements() {
+		if strings.Contains(statement.Query, "OFFSET") && int64(0) != statement.Args[1] {
+			t.Fatalf("expected a page below 1 to be the first, got %v", statement.Args)
+		}
+	}
+}
+
+// doneContext is a long-lived context that is not one of the context package, so
+// that each context registered with it holds a goroutine until it is cancelled.
+type doneContext struct {
+	context.Context
+	done chan struct{}
+}
+
+func (ctx doneContext) Done() <-chan struct{} {
+	return ctx.done
+}
+
+func TestQueryTimeoutReleasesContext(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	metadata.Policies = map[mysqlmeta.OperationClass]mysqlmeta.OperationPolicy{
+		mysqlmeta.OperationInteractive: {Timeout: time.Minute},
+	}
+	parent := doneContext{Context: context.Background(), done: make(chan struct{})}
+	defer close(parent.done)
+	ctx := mysqlmeta.WithOperationClass(parent, mysqlmeta.OperationInteractive)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		recorder.AddRows([]string{"id", "sku", "price", "name"}, []interface{}{int64(1), "A-1", 2.5, nil})
+		if err := metadata.GetEntitiesContext(ctx, &[]product{}, ""); nil != err {
+			t.Fatal(err)
+		}
+	}
+	// nothing is left watching the context once the rows are closed
+	for wait := 0; runtime.NumGoroutine() > before+2; wait++ {
+		if 100 == wait {
+			t.Fatalf("expected no goroutines left watching, got %d more", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIncrementColumn(t *testing.T) {
+	type counter struct {
+		Id      uint
+		OrgId   uint   `sql:"tenant"`
+		Sku     string `sql:"no-update"`
+		Stock   *int
+		Doubled int
+		Version uint `sql:"version"`
+	}
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `counter` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `org_id` int unsigned NOT NULL,\n"+
+		"  `sku` varchar(32) NOT NULL,\n"+
+		"  `stock` int DEFAULT NULL,\n"+
+		"  `doubled` int GENERATED ALWAYS AS ((`stock` * 2)) VIRTUAL,\n"+
+		"  `version` int unsigned NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &counter{})
+	ctx := mysqlmeta.WithTenant(context.Background(), uint(7))
+	if err := metadata.IncrementColumnContext(ctx, 3, "stock", -2); nil != err {
+		t.Fatal(err)
+	}
+	expected := Statement{
+		Query: "UPDATE `counter` SET `stock` = COALESCE(`stock`, 0) + ?, `version`=`version`+1 WHERE (`counter`.`org_id` = ?) AND (id = ?) ",
+		Args:  []interface{}{int64(-2), int64(7), int64(3)},
+	}
+	if statement := recorder.LastStatement(); !reflect.DeepEqual(expected, statement) {
+		t.Fatalf("unexpected statement %q %v", statement.Query, statement.Args)
+	}
+	recorder.Reset()
+	for _, colname := range []string{"id", "org_id", "sku", "doubled"} {
+		if err := metadata.IncrementColumnContext(ctx, 3, colname, 1); !errors.Is(err, mysqlmeta.ErrInvalidColumn) {
+			t.Fatalf("expected ErrInvalidColumn for %s, got %v", colname, err)
+		}
+	}
+	if statements := recorder.Statements(); 0 != len(statements) {
+		t.Fatalf("expected no statements for rejected columns, got %q", statements)
+	}
+	recorder.AddResult(Result{RowsAffected: 0})
+	if err := metadata.IncrementColumnContext(ctx, 4, "stock", 1); !errors.Is(err, mysqlmeta.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRegistryRefreshKeepsStatements(t *testing.T) {
+	type account struct {
+		Id    uint
+		Email string
+	}
+	db, recorder := NewDB()
+	describe := func() {
+		recorder.AddRows([]string{"VERSION()"}, []interface{}{"8.0.35"})
+		recorder.AddRows([]string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"},
+			[]interface{}{"id", "int unsigned", nil, "NO", "PRI", nil, "auto_increment", "", ""},
+			[]interface{}{"email", "varchar(64)", "utf8mb4_0900_ai_ci", "NO", "", nil, "", "", ""})
+		recorder.AddRows([]string{"Table", "Non_unique", "Key_name", "Seq_in_index", "Column_name", "Collation",
+			"Cardinality", "Sub_part", "Packed", "Null", "Index_type", "Comment", "Index_comment"})
+	}
+	describe()
+	cache := true
+	registry := &mysqlmeta.Registry{Config: mysqlmeta.Config{CacheStatements: &cache}}
+	current, err := registry.Register(db, "account", &account{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if _, err = current.GetEntityById(&account{}, 1); nil != err && !errors.Is(err, mysqlmeta.ErrNotFound) {
+		t.Fatal(err)
+	}
+	describe()
+	if err = registry.Refresh(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	if refreshed, _ := registry.Lookup(&account{}); current == refreshed {
+		t.Fatal("expected new metadata after Refresh")
+	}
+	// the metadata returned before still uses its prepared statements
+	if _, err = current.GetEntityById(&account{}, 1); nil != err && !errors.Is(err, mysqlmeta.ErrNotFound) {
+		t.Fatal(err)
+	}
+	if stats := current.StatementCacheStats(); 1 != stats.Size || 1 != stats.Hits {
+		t.Fatalf("expected the old statements to stay prepared, got %+v", stats)
+	}
+}
+
+func TestFindOrCreate(t *testing.T) {
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, PRODUCT_DDL, &product{})
+	columns := []string{"id", "sku", "price", "name"}
+	match := map[string]interface{}{"sku": "A-1"}
+	inserts := func() int {
+		count := 0
+		for _, statement := range recorder.Statements() {
+			if strings.HasPrefix(statement.Query, "INSERT") {
+				count++
+			}
+		}
+		return count
+	}
+
+	recorder.AddRows(columns, []interface{}{int64(4), "A-1", 2.5, nil})
+	found := product{Sku: "A-1"}
+	if inserted, err := metadata.FindOrCreate(&found, match); nil != err || inserted || 4 != found.Id || 0 != inserts() {
+		t.Fatalf("expected the existing row, got %+v %v %v", found, inserted, err)
+	}
+
+	recorder.Reset()
+	created := product{Sku: "A-1", Price: 3}
+	if inserted, err := metadata.FindOrCreate(&created, match); nil != err || !inserted || 0 == created.Id || 1 != inserts() {
+		t.Fatalf("expected an insert, got %+v %v %v", created, inserted, err)
+	}
+
+	// another caller inserts the row between the read and the insert
+	recorder.Reset()
+	recorder.AddResult(Result{Err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'A-1' for key 'uq_sku'"}})
+	recorder.AddRows(columns) // nothing found at first
+	recorder.AddRows(columns, []interface{}{int64(9), "A-1", 5.0, nil})
+	raced := product{Sku: "A-1", Price: 3}
+	if inserted, err := metadata.FindOrCreate(&raced, match); nil != err || inserted || 9 != raced.Id || 5.0 != raced.Price {
+		t.Fatalf("expected the row of the other caller, got %+v %v %v", raced, inserted, err)
+	}
+	if statements := recorder.Statements(); 3 != len(statements) || !strings.HasPrefix(statements[2].Query, "SELECT") {
+		t.Fatalf("expected a read after the duplicate insert, got %q", statements)
+	}
+
+	recorder.Reset()
+	recorder.AddRows(columns, []interface{}{int64(4), "A-1", 2.5, nil}, []interface{}{int64(5), "A-1", 2.5, nil})
+	if _, err := metadata.FindOrCreate(&product{Sku: "A-1"}, match); !errors.Is(err, mysqlmeta.ErrMultipleRows) || 0 != inserts() {
+		t.Fatalf("expected ErrMultipleRows without an insert, got %v", err)
+	}
+}
+
+type orderLine struct {
+	Id      uint
+	OrderId uint
+	Sku     string
+}
+
+type shipment struct {
+	Id      uint
+	OrderId uint
+	Carrier string
+}
+
+type order struct {
+	Id        uint
+	Lines     []orderLine
+	LinePtrs  []*orderLine
+	Shipment  *shipment
+	Delivered shipment
+}
+
+func TestLoadRelated(t *testing.T) {
+	db, recorder := NewDB()
+	orders := Metadata(t, db, "CREATE TABLE `order` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &order{})
+	lines := Metadata(t, db, "CREATE TABLE `order_line` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `order_id` int unsigned NOT NULL,\n"+
+		"  `sku` varchar(32) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &orderLine{})
+	shipments := Metadata(t, db, "CREATE TABLE `shipment` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `order_id` int unsigned NOT NULL,\n"+
+		"  `carrier` varchar(32) NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &shipment{})
+	lineColumns := []string{"id", "order_id", "sku"}
+	shipmentColumns := []string{"id", "order_id", "carrier"}
+	// order 3 has no children, and holds stale ones to be reset
+	parents := []order{{Id: 1}, {Id: 2}, {Id: 3, Lines: []orderLine{{Id: 99}}, Shipment: &shipment{Id: 99}, Delivered: shipment{Id: 99}}}
+
+	recorder.AddRows(lineColumns,
+		[]interface{}{int64(10), int64(1), "A-1"}, []interface{}{int64(11), int64(2), "B-2"}, []interface{}{int64(12), int64(1), "C-3"})
+	if err := orders.LoadRelated(&parents, lines, "Lines"); nil != err {
+		t.Fatal(err)
+	}
+	if statement := recorder.LastStatement(); !strings.HasSuffix(statement.Query, "WHERE `order_id` IN (?, ?, ?)") {
+		t.Fatalf("expected one query for all the parents, got %s", statement.Query)
+	}
+	if 2 != len(parents[0].Lines) || "C-3" != parents[0].Lines[1].Sku || 1 != len(parents[1].Lines) || 0 != len(parents[2].Lines) {
+		t.Fatalf("unexpected lines %+v", parents)
+	}
+	recorder.AddRows(lineColumns, []interface{}{int64(11), int64(2), "B-2"})
+	if err := orders.LoadRelated(&parents, lines, "LinePtrs"); nil != err {
+		t.Fatal(err)
+	}
+	if 0 != len(parents[0].LinePtrs) || 1 != len(parents[1].LinePtrs) || 11 != parents[1].LinePtrs[0].Id {
+		t.Fatalf("unexpected line pointers %+v", parents)
+	}
+
+	recorder.AddRows(shipmentColumns, []interface{}{int64(20), int64(2), "post"})
+	if err := orders.LoadRelated(&parents, shipments, "Shipment"); nil != err {
+		t.Fatal(err)
+	}
+	if nil != parents[0].Shipment || "post" != parents[1].Shipment.Carrier || nil != parents[2].Shipment {
+		t.Fatalf("unexpected shipments %+v", parents)
+	}
+	recorder.AddRows(shipmentColumns, []interface{}{int64(20), int64(2), "post"})
+	if err := orders.LoadRelated(&parents, shipments, "Delivered"); nil != err {
+		t.Fatal(err)
+	}
+	if (shipment{}) != parents[0].Delivered || 20 != parents[1].Delivered.Id || (shipment{}) != parents[2].Delivered {
+		t.Fatalf("unexpected deliveries %+v", parents)
+	}
+
+	// a single parent, whose has-one relation matches two rows
+	recorder.AddRows(shipmentColumns, []interface{}{int64(20), int64(2), "post"}, []interface{}{int64(21), int64(2), "courier"})
+	if err := orders.LoadRelated(&parents[1], shipments, "Shipment"); !errors.Is(err, mysqlmeta.ErrMultipleRows) {
+		t.Fatalf("expected ErrMultipleRows, got %v", err)
+	}
+	if err := orders.LoadRelated(&parents, shipments, "Lines"); !errors.Is(err, mysqlmeta.ErrInvalidEntity) {
+		t.Fatalf("expected ErrInvalidEntity for a field of another type, got %v", err)
+	}
+}
+
+// cents is both a Valuer and a Scanner, so it is handed to the driver as it is.
+type cents struct{ amount int64 }
+
+func (c cents) Value() (driver.Value, error) { return c.amount, nil }
+
+func (c *cents) Scan(src interface{}) error {
+	n, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into cents", src)
+	}
+	c.amount = n
+	return nil
+}
+
+// caption is only a Valuer, so it is stored as JSON like any other struct.
+type caption struct{ Text string }
+
+func (c caption) Value() (driver.Value, error) { return c.Text, nil }
+
+func TestPassThroughRoundTrip(t *testing.T) {
+	type invoice struct {
+		Id      uint
+		Memo    sql.NullString
+		Total   cents
+		Caption caption
+	}
+	db, recorder := NewDB()
+	metadata := Metadata(t, db, "CREATE TABLE `invoice` (\n"+
+		"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `memo` varchar(64) DEFAULT NULL,\n"+
+		"  `total` bigint NOT NULL,\n"+
+		"  `caption` text NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		")", &invoice{})
+	written := invoice{Memo: sql.NullString{String: "paid", Valid: true}, Total: cents{1250}, Caption: caption{"March"}}
+	if _, err := metadata.InsertEntity(&written); nil != err {
+		t.Fatal(err)
+	}
+	args := recorder.LastStatement().Args
+	if !reflect.DeepEqual([]interface{}{"paid", int64(1250), []byte(`{"Text":"March"}`)}, args) {
+		t.Fatalf("unexpected insert args %#v", args)
+	}
+	recorder.AddRows([]string{"id", "memo", "total", "caption"},
+		[]interface{}{int64(1), nil, int64(1250), `{"Text":"March"}`})
+	read := invoice{}
+	if _, err := metadata.GetEntityById(&read, 1); nil != err {
+		t.Fatal(err)
+	}
+	if read.Memo.Valid || (cents{1250}) != read.Total || (caption{"March"}) != read.Caption {
+		t.Fatalf("unexpected invoice %+v", read)
+	}
+}
@@ -0,0 +1,58 @@
+package mysqlmeta
+
+import (
+	"strings"
+)
+
+// NamingStrategy relates column names to struct field names. A field matches a
+// column when either name converts to the other.
+type NamingStrategy interface {
+	// FieldName returns the struct field name for a column, ex. "OrderId" for "order_id"
+	FieldName(column string) string
+	// ColumnName returns the column name for a struct field, ex. "order_id" for "OrderID"
+	ColumnName(field string) string
+}
+
+// treat as const - initialisms written in upper case by Go convention
+var COMMON_INITIALISMS = []string{"ACL", "API", "CPU", "CSS", "DNS", "HTML", "HTTP", "HTTPS", "ID", "IP",
+	"JSON", "SQL", "SSH", "TCP", "TLS", "TTL", "UDP", "UI", "UID", "URI", "URL", "UTF8", "UUID", "XML"}
+
+// SnakeCaseNaming maps snake_case columns to CamelCase fields. Field names are made
+// with the Initialisms in upper case, ex. "OrderID" rather than "OrderId" if "ID" is
+// listed, while column names are made from either form.
+type SnakeCaseNaming struct {
+	Initialisms []string
+}
+
+// DefaultNaming makes field names such as OrderId and ApiKey, and matches them or
+// OrderID and APIKey to the columns order_id and api_key.
+var DefaultNaming NamingStrategy = SnakeCaseNaming{}
+
+// GoNaming makes field names with the COMMON_INITIALISMS, such as OrderID and APIKey.
+var GoNaming NamingStrategy = SnakeCaseNaming{Initialisms: COMMON_INITIALISMS}
+
+func (naming SnakeCaseNaming) FieldName(column string) string {
+	words := strings.Split(column, "_")
+	for i, word := range words {
+		upper := strings.ToUpper(word)
+		if naming.isInitialism(upper) {
+			words[i] = upper
+		} else if "" != word {
+			words[i] = upper[:1] + word[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+func (naming SnakeCaseNaming) ColumnName(field string) string {
+	return CamelCaseToSnakeCase(field)
+}
+
+func (naming SnakeCaseNaming) isInitialism(word string) bool {
+	for _, initialism := range naming.Initialisms {
+		if initialism == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func findNaturalKey(cols []ColumnMetadata, declared []string) ([]string, error) {
+	// The NaturalKey option wins, otherwise the columns tagged sql:"natural-key"
+	// make up the key, in column order.
+	if 0 < len(declared) {
+		for _, colname := range declared {
+			found := false
+			for _, col := range cols {
+				found = found || colname == col.Field
+			}
+			if !found {
+				return nil, fmt.Errorf("%w: natural key column %s", ErrInvalidColumn, colname)
+			}
+		}
+		return declared, nil
+	}
+	key := []string{}
+	for _, col := range cols {
+		if col.NaturalKey {
+			key = append(key, col.Field)
+		}
+	}
+	if 0 == len(key) {
+		return nil, nil
+	}
+	return key, nil
+}
+
+func (metadata TableMetadata) checkNaturalKey() {
+	// A natural key is only useful for lookups if it is unique, so warn when no
+	// unique index has exactly its columns.
+	if 0 == len(metadata.NaturalKey) {
+		return
+	}
+	want := append([]string{}, metadata.NaturalKey...)
+	sort.Strings(want)
+	for _, index := range metadata.UniqueIndexes() {
+		sorted := append([]string{}, index.Columns...)
+		sort.Strings(sorted)
+		if strings.Join(sorted, ",") == strings.Join(want, ",") {
+			return
+		}
+	}
+	metadata.logf(LogWarn, "natural key (%s) of %s is not a unique index",
+		strings.Join(metadata.NaturalKey, ", "), metadata.Name)
+}
+
+func (metadata TableMetadata) GetEntityByKey(entity interface{}, key map[string]interface{}) (interface{}, error) {
+	return metadata.GetEntityByKeyContext(context.Background(), entity, key)
+}
+
+func (metadata TableMetadata) GetEntityByKeyContext(ctx context.Context, entity interface{}, key map[string]interface{}) (interface{}, error) {
+	// Returns the row matching all the column values of key, ex. {"tenant_id": 3,
+	// "external_id": "A-17"}. Like GetEntityByColumn, this returns ErrMultipleRows,
+	// along with the first row, if the key is not unique.
+	if 0 == len(key) {
+		return nil, fmt.Errorf("%w: empty key for %s", ErrInvalidColumn, metadata.Name)
+	}
+	colnames := make([]string, 0, len(key))
+	for colname := range key {
+		if !metadata.IsColumn(colname) {
+			return nil, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+		colnames = append(colnames, colname)
+	}
+	// a stable order keeps the statement text, and so any cached statement, the same
+	sort.Strings(colnames)
+	terms := make([]string, len(colnames))
+	args := make([]interface{}, len(colnames))
+	for i, colname := range colnames {
+		terms[i] = QuoteIdentifier(colname) + " = ?"
+		args[i] = key[colname]
+	}
+	entity, more, err := metadata.getEntity(ctx, entity, " WHERE "+strings.Join(terms, " AND "), args...)
+	if nil == err && more {
+		err = fmt.Errorf("%w for %s (%s)", ErrMultipleRows, metadata.Name, strings.Join(colnames, ", "))
+	}
+	return entity, err
+}
+
+func (metadata TableMetadata) NaturalKeyOf(entity interface{}) (map[string]interface{}, error) {
+	// Returns the natural key values of an entity, to pass to GetEntityByKey.
+	if 0 == len(metadata.NaturalKey) {
+		return nil, fmt.Errorf("%w for %s", ErrNoNaturalKey, metadata.Name)
+	}
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return nil, err
+	}
+	key := make(map[string]interface{}, len(metadata.NaturalKey))
+	for _, colname := range metadata.NaturalKey {
+		key[colname] = value.Field(metadata.FieldByColumn[colname]).Interface()
+	}
+	return key, nil
+}
+
+func (metadata TableMetadata) GetEntityByNaturalKey(entity interface{}) (interface{}, error) {
+	return metadata.GetEntityByNaturalKeyContext(context.Background(), entity)
+}
+
+func (metadata TableMetadata) GetEntityByNaturalKeyContext(ctx context.Context, entity interface{}) (interface{}, error) {
+	// Fills in the entity from the row with the same natural key, ex. to find the
+	// id of an entity received from another system.
+	key, err := metadata.NaturalKeyOf(entity)
+	if nil != err {
+		return nil, err
+	}
+	return metadata.GetEntityByKeyContext(ctx, entity, key)
+}
@@ -0,0 +1,46 @@
+package mysqlmeta
+
+import (
+	"time"
+)
+
+// QueryObserver is told of every statement run through a TableMetadata, once it has
+// run, ex. for slow-query logging, auditing or debugging. Statements that read the
+// schema, ex. in FetchTableMetadata, are not observed. OnQuery is called from the
+// goroutine running the statement, so it should be quick and safe for concurrent use.
+type QueryObserver interface {
+	// OnQuery receives the statement as sent, with its tag comment, the duration
+	// including retries, and the rows affected, or -1 for queries and errors
+	OnQuery(query string, args []interface{}, duration time.Duration, rowsAffected int64, err error)
+}
+
+// QueryObserverFunc lets a function be a QueryObserver.
+type QueryObserverFunc func(query string, args []interface{}, duration time.Duration, rowsAffected int64, err error)
+
+func (f QueryObserverFunc) OnQuery(query string, args []interface{}, duration time.Duration, rowsAffected int64, err error) {
+	f(query, args, duration, rowsAffected, err)
+}
+
+// queryObservers tells each of several observers, in order.
+type queryObservers []QueryObserver
+
+func (observers queryObservers) OnQuery(query string, args []interface{}, duration time.Duration, rowsAffected int64, err error) {
+	for _, observer := range observers {
+		observer.OnQuery(query, args, duration, rowsAffected, err)
+	}
+}
+
+func Observers(observers ...QueryObserver) QueryObserver {
+	// Returns an observer that tells each of the observers, in order, ex. to both
+	// log slow queries and audit writes. Nil observers are skipped.
+	combined := queryObservers{}
+	for _, observer := range observers {
+		if nil != observer {
+			combined = append(combined, observer)
+		}
+	}
+	if 1 == len(combined) {
+		return combined[0]
+	}
+	return combined
+}
@@ -0,0 +1,79 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+func (col ColumnMetadata) introspected() ColumnMetadata {
+	// Returns only what the database tells of the column, leaving out what comes from
+	// the entity struct, which is read again when the metadata is loaded.
+	return ColumnMetadata{
+		Field:                col.Field,
+		ColumnType:           col.ColumnType,
+		Nullable:             col.Nullable,
+		Key:                  col.Key,
+		DefaultValue:         col.DefaultValue,
+		Extra:                col.Extra,
+		Indexes:              col.Indexes,
+		Default:              ParseColumnDefault(sql.NullString{String: col.DefaultValue, Valid: DefaultNone != col.Default.Kind}, col.ColumnType, col.Extra),
+		GenerationExpression: col.GenerationExpression,
+		NumericPrecision:     col.NumericPrecision,
+		NumericScale:         col.NumericScale,
+		CharacterMaxLength:   col.CharacterMaxLength,
+		CharacterSet:         col.CharacterSet,
+		Collation:            col.Collation,
+		Comment:              col.Comment,
+	}
+}
+
+func (metadata TableMetadata) SaveJSON() ([]byte, error) {
+	// Returns the metadata as JSON for LoadJSON, ex. to commit alongside tests that
+	// run without MySQL.
+	return json.MarshalIndent(metadata, "", "  ")
+}
+
+func (metadata *TableMetadata) LoadJSON(data []byte, db *sql.DB, entity interface{}) error {
+	// Fills in the metadata from JSON made by SaveJSON rather than from the database,
+	// as FetchTableMetadata would: the options set beforehand are kept, and the entity
+	// struct is matched to the saved columns again, so it may have changed since.
+	// The columns keep their saved order. db is only used by the queries made later,
+	// and may be nil for metadata that only builds statements.
+	if (nil != metadata) && ("" != metadata.Name) {
+		return nil
+	}
+	saved := TableMetadata{}
+	if err := json.Unmarshal(data, &saved); nil != err {
+		return fmt.Errorf("load metadata: %w", err)
+	}
+	if err := CheckTableName(saved.Name); nil != err {
+		return err
+	}
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	if "" == metadata.TablePrefix {
+		metadata.TablePrefix = saved.TablePrefix
+	}
+	config := metadata.applyConfig()
+	cols := make([]ColumnMetadata, len(saved.Columns))
+	for i, col := range saved.Columns {
+		cols[i] = col.introspected()
+	}
+	err = metadata.describe(db, saved.Name, saved.BaseName, value, cols, saved.ForeignKeys, saved.Comment, config)
+	if "" != metadata.Name {
+		metadata.CreateTable = saved.CreateTable
+		metadata.TableOptions = saved.TableOptions
+		metadata.CheckConstraints = saved.CheckConstraints
+		metadata.Status = saved.Status
+	}
+	return err
+}
+
+func LoadTableMetadata(data []byte, db *sql.DB, entity interface{}) (*TableMetadata, error) {
+	metadata := TableMetadata{}
+	err := metadata.LoadJSON(data, db, entity)
+	return &metadata, err
+}
@@ -0,0 +1,300 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// OperationClass classifies a call so that API traffic and batch jobs can share
+// one TableMetadata while getting different timeouts, retries and priorities.
+type OperationClass int
+
+const (
+	OperationInteractive OperationClass = iota
+	OperationBackground
+	OperationBulk
+	// OperationAnalytics is for reporting queries, which are long and large but rare
+	OperationAnalytics
+)
+
+func (class OperationClass) String() string {
+	switch class {
+	case OperationInteractive:
+		return "interactive"
+	case OperationBackground:
+		return "background"
+	case OperationBulk:
+		return "bulk"
+	case OperationAnalytics:
+		return "analytics"
+	}
+	return "unknown"
+}
+
+type Priority int
+
+const (
+	PriorityDefault Priority = iota
+	PriorityLow
+	PriorityHigh
+)
+
+type OperationPolicy struct {
+	// Timeout is applied to running each statement unless the context already has an
+	// earlier deadline, but not to reading the rows of a query.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Retries is the number of extra attempts made after a deadlock, a lock wait timeout
+	// or a dropped connection, outside transactions. INSERT and REPLACE are only
+	// retried after the server rolled them back, not after a dropped connection.
+	Retries      int           `json:"retries,omitempty"`
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty"`
+	// Priority adds LOW_PRIORITY or HIGH_PRIORITY where MySQL accepts it for the statement.
+	Priority Priority `json:"priority,omitempty"`
+	// BufferResult adds SQL_BUFFER_RESULT to SELECTs, so that table locks are released
+	// before the rows are sent to a slow reader.
+	BufferResult bool `json:"buffer_result,omitempty"`
+	// BigSelects lets SELECTs examine more than max_join_size rows, with a SET_VAR
+	// hint that only lasts for the statement (MySQL 8.0.3 and later).
+	BigSelects bool `json:"big_selects,omitempty"`
+	// MaxExecutionTime has the server kill SELECTs that run longer, with the
+	// MAX_EXECUTION_TIME hint (MySQL 5.7.8 and later). Unlike Timeout, which only
+	// abandons the query on the client, it stops the work on the server too.
+	MaxExecutionTime time.Duration `json:"max_execution_time,omitempty"`
+}
+
+// treat as const - per-table overrides go in TableMetadata.Policies. The Timeout of
+// a default only applies to calls whose context has a class - see WithOperationClass.
+var DefaultOperationPolicies = map[OperationClass]OperationPolicy{
+	OperationInteractive: {Timeout: 5 * time.Second},
+	OperationBackground:  {Timeout: 30 * time.Second, Retries: 2, RetryBackoff: 100 * time.Millisecond},
+	OperationBulk:        {Timeout: 5 * time.Minute, Retries: 3, RetryBackoff: time.Second, Priority: PriorityLow},
+	OperationAnalytics:   {Timeout: 30 * time.Minute, Retries: 1, RetryBackoff: time.Second, BigSelects: true},
+}
+
+type operationClassKey struct{}
+
+func WithOperationClass(ctx context.Context, class OperationClass) context.Context {
+	return context.WithValue(ctx, operationClassKey{}, class)
+}
+
+func GetOperationClass(ctx context.Context) OperationClass {
+	// Calls without an explicit class are treated as interactive.
+	if nil == ctx {
+		return OperationInteractive
+	}
+	class, _ := ctx.Value(operationClassKey{}).(OperationClass)
+	return class
+}
+
+type maxExecutionTimeKey struct{}
+
+func WithMaxExecutionTime(ctx context.Context, limit time.Duration) context.Context {
+	// Overrides the MaxExecutionTime of the policy for the SELECTs run with the
+	// context, 0 for no limit.
+	return context.WithValue(ctx, maxExecutionTimeKey{}, limit)
+}
+
+func (metadata TableMetadata) GetOperationPolicy(ctx context.Context) OperationPolicy {
+	// The policy of the class of the context from Policies, or else the default one.
+	// The default timeouts only apply to calls that chose a class, so that a caller
+	// who never did is not cut off by one.
+	class := GetOperationClass(ctx)
+	policy, ok := metadata.Policies[class]
+	if !ok {
+		policy = DefaultOperationPolicies[class]
+		if nil == ctx || nil == ctx.Value(operationClassKey{}) {
+			policy.Timeout = 0
+		}
+	}
+	if nil != ctx {
+		if limit, ok := ctx.Value(maxExecutionTimeKey{}).(time.Duration); ok {
+			policy.MaxExecutionTime = limit
+		}
+	}
+	return policy
+}
+
+func (policy OperationPolicy) executionTimeout(ctx context.Context) (context.Context, func() bool) {
+	// Returns the context to run a query with, cancelled if the query has not returned
+	// within the timeout, and what stops the timer once it has, false if it already
+	// fired. The rows of the query are read with the context, so it must not be
+	// cancelled after the query returned.
+	if 0 >= policy.Timeout {
+		return ctx, func() bool { return true }
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= policy.Timeout {
+		return ctx, func() bool { return true }
+	}
+	qctx := &queryContext{Context: ctx, done: make(chan struct{})}
+	stopWatching := context.AfterFunc(ctx, func() { qctx.cancel(ctx.Err()) })
+	timer := time.AfterFunc(policy.Timeout, func() { qctx.cancel(context.DeadlineExceeded) })
+	return qctx, func() bool {
+		stopWatching()
+		return timer.Stop()
+	}
+}
+
+// queryContext is the context of a query with a timeout. Unlike a context from
+// context.WithCancel, it is not registered with its parent once the query returned:
+// the contexts derived from it, ex. by sql.Rows, watch the parent through AfterFunc,
+// and so stop watching once they are cancelled, when the rows are closed.
+type queryContext struct {
+	context.Context
+	done  chan struct{}
+	once  sync.Once
+	mutex sync.Mutex
+	err   error
+}
+
+func (qctx *queryContext) cancel(err error) {
+	qctx.once.Do(func() {
+		qctx.mutex.Lock()
+		qctx.err = err
+		qctx.mutex.Unlock()
+		close(qctx.done)
+	})
+}
+
+func (qctx *queryContext) Done() <-chan struct{} {
+	return qctx.done
+}
+
+func (qctx *queryContext) Err() error {
+	qctx.mutex.Lock()
+	defer qctx.mutex.Unlock()
+	if nil != qctx.err {
+		return qctx.err
+	}
+	return qctx.Context.Err()
+}
+
+func (qctx *queryContext) AfterFunc(f func()) func() bool {
+	// Lets context.WithCancel watch the parent, as the timer is stopped or has fired
+	// by the time the rows of the query derive their context.
+	return context.AfterFunc(qctx.Context, f)
+}
+
+func (policy OperationPolicy) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if 0 >= policy.Timeout {
+		return ctx, func() {}
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= policy.Timeout {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, policy.Timeout)
+}
+
+func (policy OperationPolicy) applyPriority(query string) string {
+	// MySQL only accepts HIGH_PRIORITY on SELECT and INSERT, and LOW_PRIORITY on
+	// INSERT, UPDATE and DELETE, so anything else is left untouched.
+	keyword := ""
+	switch policy.Priority {
+	case PriorityLow:
+		keyword = "LOW_PRIORITY"
+	case PriorityHigh:
+		keyword = "HIGH_PRIORITY"
+	default:
+		return query
+	}
+	for _, verb := range []string{"SELECT", "INSERT", "UPDATE", "DELETE"} {
+		if !strings.HasPrefix(query, verb+" ") {
+			continue
+		}
+		if ("SELECT" == verb && PriorityLow == policy.Priority) ||
+			(("UPDATE" == verb || "DELETE" == verb) && PriorityHigh == policy.Priority) {
+			return query
+		}
+		return verb + " " + keyword + " " + strings.TrimPrefix(query, verb+" ")
+	}
+	return query
+}
+
+func (policy OperationPolicy) applySelectOptions(query string) string {
+	// The optimizer hint must directly follow SELECT, and SQL_BUFFER_RESULT must
+	// follow HIGH_PRIORITY.
+	if !strings.HasPrefix(query, "SELECT ") || !(policy.BufferResult || policy.BigSelects || 0 < policy.MaxExecutionTime) {
+		return query
+	}
+	rest := strings.TrimPrefix(query, "SELECT ")
+	modifiers := ""
+	if strings.HasPrefix(rest, "HIGH_PRIORITY ") {
+		modifiers = "HIGH_PRIORITY "
+		rest = strings.TrimPrefix(rest, "HIGH_PRIORITY ")
+	}
+	if policy.BufferResult {
+		modifiers += "SQL_BUFFER_RESULT "
+	}
+	hints := []string{}
+	if 0 < policy.MaxExecutionTime {
+		// in milliseconds, rounded up so that a limit under 1ms is not taken as none
+		millis := (policy.MaxExecutionTime + time.Millisecond - 1) / time.Millisecond
+		hints = append(hints, "MAX_EXECUTION_TIME("+strconv.FormatInt(int64(millis), 10)+")")
+	}
+	if policy.BigSelects {
+		hints = append(hints, "SET_VAR(sql_big_selects=ON)")
+	}
+	if 0 < len(hints) {
+		modifiers = "/*+ " + strings.Join(hints, " ") + " */ " + modifiers
+	}
+	return "SELECT " + modifiers + rest
+}
+
+func isIdempotent(query string) bool {
+	// An INSERT or REPLACE may have been applied before the error was seen, so it is
+	// only retried after an error that rolled it back.
+	return !strings.HasPrefix(query, "INSERT ") && !strings.HasPrefix(query, "REPLACE ")
+}
+
+func isRolledBack(err error) bool {
+	// Reports whether the server rolled back the statement, so that running it again
+	// cannot apply it twice.
+	return isMySQLError(err, ER_LOCK_DEADLOCK) || isMySQLError(err, ER_LOCK_WAIT_TIMEOUT)
+}
+
+func IsTransientError(err error) bool {
+	// Reports whether the statement failed for a reason that may go away if it is run
+	// again: a deadlock, a lock wait timeout or a dropped connection. The policies
+	// retry these by themselves - see OperationPolicy.Retries.
+	return errors.Is(err, driver.ErrBadConn) || isRolledBack(err)
+}
+
+// treat as const - MySQL server error numbers
+var ER_DUP_ENTRY uint16 = 1062
+var ER_NO_SUCH_TABLE uint16 = 1146
+var ER_LOCK_WAIT_TIMEOUT uint16 = 1205
+var ER_LOCK_DEADLOCK uint16 = 1213
+
+func isMySQLError(err error, number uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && number == mysqlErr.Number
+}
+
+func (policy OperationPolicy) retry(ctx context.Context, query string, attempt int, err error) bool {
+	// Waits out the backoff and returns true if the statement should be attempted again.
+	if nil == err || attempt >= policy.Retries || !IsTransientError(err) {
+		return false
+	}
+	if !isIdempotent(query) && !isRolledBack(err) {
+		return false
+	}
+	if nil != GetTx(ctx) {
+		// a failed connection or a deadlock takes the transaction with it, so only the
+		// caller can retry
+		return false
+	}
+	timer := time.NewTimer(policy.RetryBackoff * time.Duration(attempt+1))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,43 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func (metadata TableMetadata) GetPage(dest interface{}, clause string, page, perPage int, v ...interface{}) (int64, error) {
+	return metadata.GetPageContext(context.Background(), dest, clause, page, perPage, v...)
+}
+
+func (metadata TableMetadata) GetPageContext(ctx context.Context, dest interface{}, clause string, page, perPage int, v ...interface{}) (int64, error) {
+	// Appends page number page (counting from 1) of perPage entities to dest, and
+	// returns the total number of matching rows from a COUNT query run alongside.
+	// The clause may have a WHERE and an ORDER BY, but not its own LIMIT.
+	if 0 >= perPage {
+		return 0, fmt.Errorf("page of %s: invalid page size %d", metadata.Name, perPage)
+	}
+	if 1 > page {
+		page = 1
+	}
+	countClause := clause
+	if at, _ := findTopLevel(clause, "ORDER BY"); 0 <= at {
+		countClause = clause[:at]
+	}
+	type countResult struct {
+		total int64
+		err   error
+	}
+	counted := make(chan countResult, 1)
+	go func() {
+		total, err := metadata.CountContext(ctx, countClause, v...)
+		counted <- countResult{total, err}
+	}()
+	pageArgs := append(append([]interface{}{}, v...), perPage, (page-1)*perPage)
+	err := metadata.GetEntitiesContext(ctx, dest, strings.TrimRight(clause, " ")+" LIMIT ? OFFSET ?", pageArgs...)
+	result := <-counted
+	if nil != err {
+		return 0, err
+	}
+	return result.total, result.err
+}
@@ -0,0 +1,390 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// treat as const
+var SQL_CREATE_TABLE = regexp.MustCompile("(?is)^\\s*CREATE\\s+(?:TEMPORARY\\s+)?TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?(?:`[^`]+`\\.)?`?(\\w+)`?")
+var SQL_INDEX_DEFINITION = regexp.MustCompile("(?is)^(PRIMARY KEY|UNIQUE(?: KEY| INDEX)?|KEY|INDEX|FULLTEXT(?: KEY| INDEX)?|SPATIAL(?: KEY| INDEX)?)\\s*(`[^`]+`|\\w+)?\\s*(?:USING (\\w+)\\s*)?(\\(.*\\))(.*)$")
+var SQL_FOREIGN_KEY = regexp.MustCompile("(?is)^FOREIGN KEY\\s*\\((.*?)\\)\\s*REFERENCES\\s*(?:`[^`]+`\\.)?`?(\\w+)`?\\s*\\((.*?)\\)(.*)$")
+var SQL_REFERENTIAL_ACTION = regexp.MustCompile("(?i)ON (UPDATE|DELETE) (CASCADE|SET NULL|SET DEFAULT|RESTRICT|NO ACTION)")
+var SQL_INDEX_COMMENT = regexp.MustCompile("(?i)COMMENT '((?:[^'\\\\]|''|\\\\.)*)'")
+var SQL_INDEX_USING = regexp.MustCompile("(?i)USING (\\w+)")
+var SQL_KEY_PART = regexp.MustCompile("^`?([^`(]+)`?(?:\\((\\d+)\\))?(?: (?:ASC|DESC))?$")
+
+func tokenizeDefinition(definition string) []string {
+	// Splits a column definition at the spaces outside of quotes and parentheses,
+	// ex. "`price` decimal(10,2) COMMENT 'in EUR'" into "`price`", "decimal(10,2)",
+	// "COMMENT" and "'in EUR'".
+	tokens := []string{}
+	depth := 0
+	var quote byte
+	token := ""
+	for i := 0; i < len(definition); i++ {
+		c := definition[i]
+		switch {
+		case 0 != quote:
+			if '\\' == c && '`' != quote && i+1 < len(definition) {
+				token += string(c)
+				i++
+				c = definition[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case '\'' == c || '"' == c || '`' == c:
+			quote = c
+		case '(' == c:
+			depth++
+		case ')' == c:
+			depth--
+		case (' ' == c || '\t' == c || '\n' == c) && 0 == depth:
+			if "" != token {
+				tokens = append(tokens, token)
+			}
+			token = ""
+			continue
+		}
+		token += string(c)
+	}
+	if "" != token {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func unquoteSQLString(s string) string {
+	// Unquotes a string literal as written by SHOW CREATE TABLE, or returns s as it is.
+	if 2 > len(s) || ('\'' != s[0] && '"' != s[0]) || s[len(s)-1] != s[0] {
+		return s
+	}
+	quote := s[0]
+	s = s[1 : len(s)-1]
+	unquoted := ""
+	for i := 0; i < len(s); i++ {
+		switch {
+		case '\\' == s[i] && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				unquoted += "\n"
+			case 't':
+				unquoted += "\t"
+			case '0':
+				unquoted += "\x00"
+			default:
+				unquoted += string(s[i])
+			}
+		case quote == s[i] && i+1 < len(s) && quote == s[i+1]:
+			unquoted += string(quote)
+			i++
+		default:
+			unquoted += string(s[i])
+		}
+	}
+	return unquoted
+}
+
+func stripParentheses(s string) string {
+	if 2 <= len(s) && '(' == s[0] && ')' == s[len(s)-1] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func parseColumnDefinition(definition string) (ColumnMetadata, error) {
+	// Parses a column of CREATE TABLE into what SHOW FULL COLUMNS would show of it.
+	tokens := tokenizeDefinition(definition)
+	if 2 > len(tokens) || '`' != tokens[0][0] {
+		return ColumnMetadata{}, fmt.Errorf("%w: column definition %q", ErrInvalidDDL, definition)
+	}
+	col := ColumnMetadata{Field: strings.Trim(tokens[0], "`"), Nullable: "YES"}
+	columnType := tokens[1]
+	if at := strings.Index(columnType, "("); 0 <= at {
+		columnType = strings.ToLower(columnType[:at]) + columnType[at:]
+	} else {
+		columnType = strings.ToLower(columnType)
+	}
+	i := 2
+	for ; i < len(tokens); i++ {
+		word := strings.ToLower(tokens[i])
+		if "unsigned" != word && "zerofill" != word {
+			break
+		}
+		columnType += " " + word
+	}
+	col.ColumnType = columnType
+	extra := []string{}
+	defaultValue := sql.NullString{}
+	next := func() string {
+		// the token after the current one, or "" at the end
+		if i+1 < len(tokens) {
+			i++
+			return tokens[i]
+		}
+		return ""
+	}
+	for ; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "NOT":
+			if "NULL" == strings.ToUpper(next()) {
+				col.Nullable = "NO"
+			}
+		case "NULL":
+			col.Nullable = "YES"
+		case "DEFAULT":
+			value := next()
+			switch {
+			case "NULL" == strings.ToUpper(value):
+			case strings.HasPrefix(value, "("):
+				// MySQL 8 expression defaults
+				defaultValue = sql.NullString{String: stripParentheses(value), Valid: true}
+				extra = append(extra, "DEFAULT_GENERATED")
+			default:
+				defaultValue = sql.NullString{String: unquoteSQLString(value), Valid: true}
+			}
+		case "AUTO_INCREMENT":
+			extra = append(extra, "auto_increment")
+		case "ON":
+			if "UPDATE" == strings.ToUpper(next()) {
+				extra = append(extra, "on update "+next())
+			}
+		case "COMMENT":
+			col.Comment = unquoteSQLString(next())
+		case "COLLATE":
+			col.Collation = next()
+		case "CHARACTER":
+			next()
+			col.CharacterSet = next()
+		case "CHARSET":
+			col.CharacterSet = next()
+		case "AS":
+			col.GenerationExpression = stripParentheses(next())
+			storage := "VIRTUAL"
+			if i+1 < len(tokens) && "STORED" == strings.ToUpper(tokens[i+1]) {
+				storage = "STORED"
+			}
+			extra = append(extra, storage+" GENERATED")
+		case "PRIMARY":
+			col.Key = "PRI"
+			col.Nullable = "NO"
+		case "UNIQUE":
+			if "" == col.Key {
+				col.Key = "UNI"
+			}
+		case "INVISIBLE":
+			extra = append(extra, "INVISIBLE")
+		}
+	}
+	col.Extra = strings.Join(extra, " ")
+	col.Invisible = isInvisibleExtra(col.Extra)
+	col.AllowedValues = parseAllowedValues(col.ColumnType)
+	if defaultValue.Valid {
+		col.DefaultValue = defaultValue.String
+	}
+	col.Default = ParseColumnDefault(defaultValue, col.ColumnType, col.Extra)
+	return col, nil
+}
+
+func parseIndexDefinition(tableName string, m []string) ([]IndexMetadata, error) {
+	// Parses the parts of an index matched by SQL_INDEX_DEFINITION.
+	kind := strings.ToUpper(strings.Fields(m[1])[0])
+	name := strings.Trim(m[2], "`")
+	indexType := strings.ToUpper(m[3])
+	switch kind {
+	case "PRIMARY":
+		name = "PRIMARY"
+	case "FULLTEXT", "SPATIAL":
+		indexType = kind
+	}
+	if u := SQL_INDEX_USING.FindStringSubmatch(m[5]); nil != u && "" == indexType {
+		// SHOW CREATE TABLE writes USING after the key parts
+		indexType = strings.ToUpper(u[1])
+	}
+	if "" == indexType {
+		indexType = "BTREE"
+	}
+	comment := ""
+	if c := SQL_INDEX_COMMENT.FindStringSubmatch(m[5]); nil != c {
+		comment = unquoteSQLString("'" + c[1] + "'")
+	}
+	parts := []IndexMetadata{}
+	for i, part := range splitTopLevel(stripParentheses(m[4])) {
+		p := SQL_KEY_PART.FindStringSubmatch(part)
+		if nil == p {
+			return nil, fmt.Errorf("%w: key part %q of %s", ErrInvalidDDL, part, tableName)
+		}
+		if "" == name {
+			// MySQL names an index after its first column
+			name = p[1]
+		}
+		ind := IndexMetadata{
+			TableName:    tableName,
+			NonUnique:    "PRIMARY" != kind && "UNIQUE" != kind,
+			KeyName:      name,
+			SeqInIndex:   uint(i + 1),
+			ColumnName:   p[1],
+			IndexType:    indexType,
+			IndexComment: comment,
+		}
+		if "" != p[2] {
+			subPart, _ := strconv.ParseUint(p[2], 10, 32)
+			n := uint(subPart)
+			ind.SubPart = &n
+		}
+		parts = append(parts, ind)
+	}
+	return parts, nil
+}
+
+func parseForeignKey(name string, definition string) (ForeignKeyMetadata, bool) {
+	m := SQL_FOREIGN_KEY.FindStringSubmatch(definition)
+	if nil == m {
+		return ForeignKeyMetadata{}, false
+	}
+	columns := func(list string) []string {
+		names := []string{}
+		for _, column := range splitTopLevel(list) {
+			names = append(names, strings.Trim(column, "`"))
+		}
+		return names
+	}
+	key := ForeignKeyMetadata{
+		ConstraintName:    name,
+		Columns:           columns(m[1]),
+		ReferencedTable:   m[2],
+		ReferencedColumns: columns(m[3]),
+		UpdateRule:        "NO ACTION",
+		DeleteRule:        "NO ACTION",
+	}
+	for _, action := range SQL_REFERENTIAL_ACTION.FindAllStringSubmatch(m[4], -1) {
+		if "UPDATE" == strings.ToUpper(action[1]) {
+			key.UpdateRule = strings.ToUpper(action[2])
+		} else {
+			key.DeleteRule = strings.ToUpper(action[2])
+		}
+	}
+	return key, true
+}
+
+func parseCreateTableColumns(ddl string) (string, []ColumnMetadata, []ForeignKeyMetadata, error) {
+	// Returns the table name, the columns with their indexes attached, and the
+	// foreign keys of a CREATE TABLE statement.
+	m := SQL_CREATE_TABLE.FindStringSubmatch(ddl)
+	if nil == m {
+		return "", nil, nil, fmt.Errorf("%w: not a CREATE TABLE statement", ErrInvalidDDL)
+	}
+	tableName := m[1]
+	body, _, err := splitCreateTable(ddl)
+	if nil != err {
+		return "", nil, nil, err
+	}
+	cols := []ColumnMetadata{}
+	indexes := []IndexMetadata{}
+	foreignKeys := []ForeignKeyMetadata{}
+	for _, definition := range splitTopLevel(body) {
+		if "" == definition {
+			continue
+		}
+		if '`' == definition[0] {
+			col, err := parseColumnDefinition(definition)
+			if nil != err {
+				return "", nil, nil, err
+			}
+			cols = append(cols, col)
+			if "PRI" == col.Key || "UNI" == col.Key {
+				// inline PRIMARY KEY and UNIQUE
+				name := "PRIMARY"
+				if "UNI" == col.Key {
+					name = col.Field
+				}
+				indexes = append(indexes, IndexMetadata{TableName: tableName, KeyName: name, SeqInIndex: 1,
+					ColumnName: col.Field, IndexType: "BTREE"})
+			}
+			continue
+		}
+		if c := SQL_CONSTRAINT.FindStringSubmatch(definition); nil != c {
+			if key, ok := parseForeignKey(c[1], c[2]+" "+c[3]); ok {
+				foreignKeys = append(foreignKeys, key)
+			}
+			continue
+		}
+		if i := SQL_INDEX_DEFINITION.FindStringSubmatch(definition); nil != i {
+			parts, err := parseIndexDefinition(tableName, i)
+			if nil != err {
+				return "", nil, nil, err
+			}
+			indexes = append(indexes, parts...)
+			continue
+		}
+		if key, ok := parseForeignKey("", definition); ok {
+			foreignKeys = append(foreignKeys, key)
+		}
+	}
+	cols = attachIndexes(cols, indexes)
+	for i := range cols {
+		cols[i].Key = columnKey(cols[i])
+	}
+	return tableName, cols, foreignKeys, nil
+}
+
+func columnKey(col ColumnMetadata) string {
+	// The Key of SHOW COLUMNS: PRI for a column of the primary key, UNI for the first
+	// column of a unique index, MUL for the first column of any other index.
+	key := ""
+	for _, ind := range col.Indexes {
+		switch {
+		case "PRIMARY" == ind.KeyName:
+			return "PRI"
+		case 1 == ind.SeqInIndex && !ind.NonUnique:
+			key = "UNI"
+		case 1 == ind.SeqInIndex && "" == key:
+			key = "MUL"
+		}
+	}
+	return key
+}
+
+func ParseCreateTable(ddl string, entity interface{}) (*TableMetadata, error) {
+	// Builds the metadata from a CREATE TABLE statement, ex. from a schema file, in
+	// place of querying the database. With a nil entity, as from GetAllTableMetadata,
+	// the metadata describes the table and its statements but cannot scan entities.
+	tableName, cols, foreignKeys, err := parseCreateTableColumns(ddl)
+	if nil != err {
+		return nil, err
+	}
+	options, err := ParseTableOptions(ddl)
+	if nil != err {
+		return nil, err
+	}
+	metadata := TableMetadata{}
+	if nil == entity {
+		metadata = TableMetadata{
+			Name:        tableName,
+			BaseName:    tableName,
+			Comment:     options.Comment,
+			Columns:     cols,
+			Indexes:     GroupIndexes(cols),
+			ForeignKeys: foreignKeys,
+		}
+		metadata.buildStatements()
+	} else {
+		value, err := GetStructValue(entity)
+		if nil != err {
+			return nil, err
+		}
+		config := metadata.applyConfig()
+		err = metadata.describe(nil, tableName, tableName, value, cols, foreignKeys, options.Comment, config)
+		if nil != err {
+			return &metadata, err
+		}
+	}
+	metadata.CreateTable = ddl
+	metadata.TableOptions = &options
+	metadata.CheckConstraints = options.checkConstraints()
+	return &metadata, nil
+}
@@ -0,0 +1,55 @@
+package mysqlmeta
+
+import (
+	"fmt"
+)
+
+func (metadata TableMetadata) Select(colnames ...string) (TableMetadata, error) {
+	// Returns a copy of the metadata that selects and scans only the named columns
+	// (which may include virtual ones), leaving the other fields of entities zero,
+	// ex. to skip the BLOB and TEXT columns of a wide table. Insert and update are
+	// unchanged. The method is not called SelectColumns as that is the field.
+	if 0 == len(colnames) {
+		return metadata, fmt.Errorf("%w: no columns selected from %s", ErrInvalidColumn, metadata.Name)
+	}
+	byName := map[string]ColumnMetadata{}
+	for _, col := range metadata.Columns {
+		byName[col.Field] = col
+	}
+	for _, col := range metadata.VirtualColumns {
+		byName[col.Field] = col
+	}
+	selectCols := []ColumnMetadata{}
+	virtualCols := []ColumnMetadata{}
+	selectColNames := ""
+	virtualColNames := ""
+	separator := ""
+	for _, colname := range colnames {
+		col, ok := byName[colname]
+		if !ok {
+			return metadata, fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+		delete(byName, colname)
+		if "" != col.Expression {
+			virtualCols = append(virtualCols, col)
+			virtualColNames += (", (" + col.Expression + ") AS " + QuoteIdentifier(col.Field))
+			continue
+		}
+		selectCols = append(selectCols, col)
+		selectColNames += (separator + QuoteIdentifier(col.Field))
+		separator = ", "
+	}
+	if 0 == len(selectCols) {
+		// the virtual columns follow a comma, so there must be a table column first
+		return metadata, fmt.Errorf("%w: only virtual columns selected from %s", ErrInvalidColumn, metadata.Name)
+	}
+	metadata.SelectColumns = selectCols
+	metadata.VirtualColumns = virtualCols
+	// generated columns computed after scan may depend on columns no longer selected
+	metadata.ComputedColumns = nil
+	metadata.computed = nil
+	metadata.ColumnNames = selectColNames
+	metadata.SelectString = "SELECT " + selectColNames + virtualColNames + " FROM " + QuoteTableName(metadata.Name) + " "
+	metadata.scan = metadata.compileScanPlan()
+	return metadata, nil
+}
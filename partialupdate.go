@@ -0,0 +1,101 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+func (metadata TableMetadata) updating(colnames []string) (TableMetadata, error) {
+	// Returns a copy of the metadata whose UPDATE only sets the named columns, along
+	// with the auto-update-time and update-default columns, and bumps the version.
+	updatable := map[string]bool{}
+	for _, col := range metadata.UpdateColumns {
+		updatable[col.Field] = true
+	}
+	named := map[string]bool{}
+	for _, colname := range colnames {
+		if !updatable[colname] {
+			return metadata, fmt.Errorf("%w: %s.%s is not updatable", ErrInvalidColumn, metadata.Name, colname)
+		}
+		named[colname] = true
+	}
+	updateCols := []ColumnMetadata{}
+	updateColNames := ""
+	separator := ""
+	for _, col := range metadata.Columns {
+		if col.Version {
+			updateColNames += (separator + QuoteIdentifier(col.Field) + "=" + QuoteIdentifier(col.Field) + "+1")
+			separator = ", "
+			continue
+		}
+		if !updatable[col.Field] || !(named[col.Field] || col.AutoUpdateTime || "" != col.UpdateDefault) {
+			continue
+		}
+		updateCols = append(updateCols, col)
+		updateColNames += (separator + QuoteIdentifier(col.Field) + "=?")
+		separator = ", "
+	}
+	metadata.UpdateColumns = updateCols
+	metadata.UpdateString = "UPDATE " + QuoteTableName(metadata.Name) + " SET " + updateColNames + " "
+	return metadata, nil
+}
+
+func (metadata TableMetadata) UpdateEntityColumns(entity interface{}, colnames ...string) error {
+	return metadata.UpdateEntityColumnsContext(context.Background(), entity, colnames...)
+}
+
+func (metadata TableMetadata) UpdateEntityColumnsContext(ctx context.Context, entity interface{}, colnames ...string) error {
+	// Like UpdateEntity, but only writes the named columns, so that concurrent updates
+	// of other columns are not overwritten and the binlog holds just the change.
+	if 0 == len(colnames) {
+		return nil
+	}
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	partial, err := metadata.updating(colnames)
+	if nil != err {
+		return err
+	}
+	return partial.updateEntityValue(ctx, entity, value)
+}
+
+func (metadata TableMetadata) ChangedColumns(before, after interface{}) ([]string, error) {
+	// Lists the updatable columns whose fields differ between two copies of an entity,
+	// ex. one kept from when it was loaded, and the one modified since.
+	beforeValue, err := GetStructValue(before)
+	if nil != err {
+		return nil, err
+	}
+	afterValue, err := GetStructValue(after)
+	if nil != err {
+		return nil, err
+	}
+	if beforeValue.Type() != afterValue.Type() {
+		return nil, fmt.Errorf("%w: cannot compare %v with %v", ErrInvalidEntity, beforeValue.Type(), afterValue.Type())
+	}
+	changed := []string{}
+	for _, col := range metadata.UpdateColumns {
+		j := metadata.FieldByColumn[col.Field]
+		if !reflect.DeepEqual(beforeValue.Field(j).Interface(), afterValue.Field(j).Interface()) {
+			changed = append(changed, col.Field)
+		}
+	}
+	return changed, nil
+}
+
+func (metadata TableMetadata) UpdateChanged(before, after interface{}) error {
+	return metadata.UpdateChangedContext(context.Background(), before, after)
+}
+
+func (metadata TableMetadata) UpdateChangedContext(ctx context.Context, before, after interface{}) error {
+	// Updates only the columns changed from before to after, and runs no statement
+	// at all when nothing changed.
+	changed, err := metadata.ChangedColumns(before, after)
+	if nil != err {
+		return err
+	}
+	return metadata.UpdateEntityColumnsContext(ctx, after, changed...)
+}
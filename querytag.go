@@ -0,0 +1,139 @@
+package mysqlmeta
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type QueryTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type queryTagsKey struct{}
+
+func WithQueryTag(ctx context.Context, key, value string) context.Context {
+	// Returns a copy of ctx carrying key=value, replacing any earlier value for key.
+	// When TagQueries is set on a TableMetadata, the tags are appended as a
+	// comment to every statement run with this context, ex. /* svc=checkout rid=abc123 */
+	existing := QueryTags(ctx)
+	tags := make([]QueryTag, 0, len(existing)+1)
+	for _, tag := range existing {
+		if tag.Key != key {
+			tags = append(tags, tag)
+		}
+	}
+	tags = append(tags, QueryTag{Key: key, Value: value})
+	return context.WithValue(ctx, queryTagsKey{}, tags)
+}
+
+func QueryTags(ctx context.Context) []QueryTag {
+	if nil == ctx {
+		return nil
+	}
+	tags, _ := ctx.Value(queryTagsKey{}).([]QueryTag)
+	return tags
+}
+
+func sanitizeQueryTag(s string) string {
+	// Only a conservative set of characters is let through, so that a tag value
+	// can never close the comment or otherwise alter the statement.
+	return strings.Map(func(c rune) rune {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			return c
+		case strings.ContainsRune("_-.:@", c):
+			return c
+		}
+		return -1
+	}, s)
+}
+
+func QueryComment(ctx context.Context) string {
+	// Builds the sanitized comment for the tags in ctx, or "" if there are none.
+	return tagComment(QueryTags(ctx))
+}
+
+func tagComment(tags []QueryTag) string {
+	comment := ""
+	for _, tag := range tags {
+		key := sanitizeQueryTag(tag.Key)
+		value := sanitizeQueryTag(tag.Value)
+		if "" == key || "" == value {
+			continue
+		}
+		comment += " " + key + "=" + value
+	}
+	if "" == comment {
+		return ""
+	}
+	return "/*" + comment + " */"
+}
+
+func SQLComment(tags []QueryTag) string {
+	// Builds the comment for the tags in the sqlcommenter format: sorted by key, with
+	// the keys and the quoted values URL encoded, ex. /*app='checkout',route='%2Fcart'*/
+	// A later tag replaces an earlier one with the same key.
+	byKey := map[string]string{}
+	for _, tag := range tags {
+		if "" == tag.Key || "" == tag.Value {
+			continue
+		}
+		byKey[tag.Key] = tag.Value
+	}
+	if 0 == len(byKey) {
+		return ""
+	}
+	pairs := make([]string, 0, len(byKey))
+	for key, value := range byKey {
+		// QueryEscape leaves no quote or slash, so the comment cannot be closed early
+		pairs = append(pairs, sqlCommenterEscape(key)+"='"+sqlCommenterEscape(value)+"'")
+	}
+	sort.Strings(pairs)
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+func sqlCommenterEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func (metadata TableMetadata) queryComment(ctx context.Context) string {
+	// Returns the comment to append to statements run with ctx, "" if none. The tags
+	// of the QueryTagger come first, so that WithQueryTag can override them.
+	if !metadata.TagQueries {
+		return ""
+	}
+	tags := QueryTags(ctx)
+	if nil != metadata.QueryTagger {
+		tags = append(metadata.QueryTagger(ctx), tags...)
+	}
+	if metadata.SQLCommenter {
+		return SQLComment(tags)
+	}
+	return tagComment(dedupeQueryTags(tags))
+}
+
+func dedupeQueryTags(tags []QueryTag) []QueryTag {
+	// Keeps the last tag of each key, in the order the keys were last set.
+	last := map[string]int{}
+	for i, tag := range tags {
+		last[tag.Key] = i
+	}
+	deduped := make([]QueryTag, 0, len(last))
+	for i, tag := range tags {
+		if last[tag.Key] == i {
+			deduped = append(deduped, tag)
+		}
+	}
+	return deduped
+}
+
+func (metadata TableMetadata) tagQuery(ctx context.Context, query string) string {
+	comment := metadata.queryComment(ctx)
+	if "" == comment {
+		return query
+	}
+	return strings.TrimRight(query, " ") + " " + comment
+}
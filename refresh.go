@@ -0,0 +1,146 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+func (metadata *TableMetadata) Refresh(ctx context.Context) error {
+	// Reads the table again and rebuilds the mapping to the entity struct and the
+	// statements, ex. after an online migration added a column, keeping the options.
+	// The metadata is replaced in place, so no other goroutine may use it meanwhile;
+	// Registry.Refresh replaces shared metadata instead.
+	fresh, err := metadata.refreshed(ctx)
+	if nil != err {
+		return err
+	}
+	old := *metadata
+	*metadata = fresh
+	return old.Close()
+}
+
+func (metadata TableMetadata) refreshed(ctx context.Context) (TableMetadata, error) {
+	// Returns the metadata read again, with a statement cache of its own, leaving
+	// this metadata and its cached statements as they are.
+	if nil == metadata.EntityType {
+		return TableMetadata{}, fmt.Errorf("%w: %s was not fetched for an entity", ErrInvalidEntity, metadata.Name)
+	}
+	if err := ctx.Err(); nil != err {
+		return TableMetadata{}, err
+	}
+	// read the version again too, as the server may have been upgraded
+	serverVersions.Delete(metadata.DB)
+	fresh := metadata
+	config := fresh.applyConfig()
+	_, end := startSpan(ctx, fresh.Tracer, Span{Table: metadata.Name, Operation: "refresh"})
+	err := fresh.fetch(metadata.DB, metadata.Name, metadata.BaseName, reflect.New(metadata.EntityType).Elem(), config)
+	end(-1, err)
+	if nil != err {
+		return TableMetadata{}, err
+	}
+	return fresh, nil
+}
+
+func (registry *Registry) Refresh(ctx context.Context, entities ...interface{}) error {
+	// Refreshes the metadata of the registered entities, or of all of them if none
+	// are given. Lookup and Get then return the new metadata, while the metadata
+	// returned before keeps working with the old mapping, and its cached statements,
+	// which are not closed as it may still be in use.
+	keys := []reflect.Type{}
+	if 0 == len(entities) {
+		registry.entries.Range(func(key, value interface{}) bool {
+			keys = append(keys, key.(reflect.Type))
+			return true
+		})
+	}
+	for _, entity := range entities {
+		keys = append(keys, entityStructType(entity))
+	}
+	for _, key := range keys {
+		if err := registry.refresh(ctx, key); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (registry *Registry) refresh(ctx context.Context, key reflect.Type) error {
+	loaded, ok := registry.entries.Load(key)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrNotRegistered, key)
+	}
+	entry := loaded.(*registryEntry)
+	current, err := registry.fetch(key, entry)
+	if nil != err {
+		return err
+	}
+	fresh, err := current.refreshed(ctx)
+	if nil != err {
+		return err
+	}
+	refreshed := &registryEntry{db: entry.db, tableName: entry.tableName, declared: entry.declared, metadata: &fresh}
+	refreshed.once.Do(func() {})
+	refreshed.fetched.Store(&fresh)
+	registry.entries.CompareAndSwap(key, entry, refreshed)
+	return nil
+}
+
+func (registry *Registry) WatchSchema(interval time.Duration) error {
+	// Starts a worker, stopped by Shutdown, that compares the checksums of the
+	// registered tables in information_schema every interval and refreshes the
+	// metadata of those that changed.
+	return registry.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		checksums := registry.schemaChecksums(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := registry.schemaChecksums(ctx)
+			for _, metadata := range registry.Tables() {
+				before, ok := checksums[metadata.Name]
+				after, found := current[metadata.Name]
+				if !found && ok {
+					// the table could not be read, so compare with the old checksum next time
+					current[metadata.Name] = before
+				}
+				if !found || !ok || before == after {
+					continue
+				}
+				if err := registry.refresh(ctx, metadata.EntityType); nil != err {
+					logf(LogWarn, "cannot refresh the metadata of %s: %v", metadata.Name, err)
+					current[metadata.Name] = before
+					continue
+				}
+				logf(LogInfo, "refreshed the metadata of %s after a schema change", metadata.Name)
+			}
+			checksums = current
+		}
+	})
+}
+
+func (registry *Registry) schemaChecksums(ctx context.Context) map[string]string {
+	// The checksums of the registered tables by table name, read per database.
+	tableNames := map[*sql.DB][]string{}
+	for _, metadata := range registry.Tables() {
+		tableNames[metadata.DB] = append(tableNames[metadata.DB], metadata.Name)
+	}
+	checksums := map[string]string{}
+	for db, names := range tableNames {
+		sums, err := GetSchemaChecksums(ctx, db, names...)
+		if nil != err {
+			logf(LogWarn, "cannot read the checksums of %v: %v", names, err)
+			continue
+		}
+		for name, sum := range sums {
+			checksums[name] = sum
+		}
+	}
+	return checksums
+}
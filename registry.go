@@ -0,0 +1,210 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry shares one TableMetadata per entity struct type across goroutines,
+// fetching it exactly once no matter how many callers register concurrently.
+type Registry struct {
+	// TablePrefix is applied to every table registered, see TableMetadata.TablePrefix
+	TablePrefix string
+	// Config applies to every table registered, with TableConfigs overriding it by table name
+	Config       Config
+	TableConfigs map[string]Config
+	// Parallelism bounds the tables WarmUp fetches at once, WARM_UP_PARALLELISM if 0
+	Parallelism int
+
+	entries sync.Map // reflect.Type -> *registryEntry
+	gate    operationGate
+}
+
+type registryEntry struct {
+	once      sync.Once
+	db        *sql.DB
+	tableName string
+	declared  bool
+	metadata  *TableMetadata
+	err       error
+	// fetched publishes metadata once fetched successfully, for Lookup and Tables to
+	// read without waiting on once
+	fetched atomic.Pointer[TableMetadata]
+}
+
+// treat as const
+var WARM_UP_PARALLELISM = 4
+
+var DefaultRegistry = &Registry{}
+
+func Register(db *sql.DB, tableName string, entity interface{}) (*TableMetadata, error) {
+	return DefaultRegistry.Register(db, tableName, entity)
+}
+
+func Lookup(entity interface{}) (*TableMetadata, bool) {
+	return DefaultRegistry.Lookup(entity)
+}
+
+func entityStructType(entity interface{}) reflect.Type {
+	// Accepts a struct, a pointer to a struct or a reflect.Type of either.
+	t, ok := entity.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(entity)
+	}
+	for nil != t && reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+	return t
+}
+
+func (registry *Registry) Register(db *sql.DB, tableName string, entity interface{}) (*TableMetadata, error) {
+	// Returns the shared metadata for the entity type, fetching it on first use.
+	key, entry, err := registry.entry(db, tableName, entity, false)
+	if nil != err {
+		return nil, err
+	}
+	return registry.fetch(key, entry)
+}
+
+func (registry *Registry) Declare(db *sql.DB, tableName string, entity interface{}) error {
+	// Registers the entity type without fetching its metadata, which Get then fetches
+	// on first use, or WarmUp for all declared tables at once. Declaring every table
+	// and calling Get starts fast; calling WarmUp at startup fails fast on a table
+	// that does not match its struct.
+	_, _, err := registry.entry(db, tableName, entity, true)
+	return err
+}
+
+func (registry *Registry) Get(entity interface{}) (*TableMetadata, error) {
+	// Returns the metadata for a registered or declared entity type, fetching it on
+	// first use.
+	key := entityStructType(entity)
+	if nil == key {
+		return nil, fmt.Errorf("%w: %T", ErrNotRegistered, entity)
+	}
+	loaded, ok := registry.entries.Load(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNotRegistered, key)
+	}
+	return registry.fetch(key, loaded.(*registryEntry))
+}
+
+func (registry *Registry) WarmUp(ctx context.Context) error {
+	// Fetches the metadata of every declared table that has not been fetched yet,
+	// Parallelism tables at a time, and returns the errors of all that failed. No
+	// more tables are started once ctx is done.
+	parallelism := registry.Parallelism
+	if parallelism <= 0 {
+		parallelism = WARM_UP_PARALLELISM
+	}
+	keys := []reflect.Type{}
+	entries := []*registryEntry{}
+	registry.entries.Range(func(key, value interface{}) bool {
+		keys = append(keys, key.(reflect.Type))
+		entries = append(entries, value.(*registryEntry))
+		return true
+	})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	errs := []error{}
+	slots := make(chan struct{}, parallelism)
+	for i := range entries {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if nil != ctx.Err() {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("warm up: %w", ctx.Err()))
+			mu.Unlock()
+			break
+		}
+		wg.Add(1)
+		go func(key reflect.Type, entry *registryEntry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if _, err := registry.fetch(key, entry); nil != err {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", entry.tableName, err))
+				mu.Unlock()
+			}
+		}(keys[i], entries[i])
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (registry *Registry) entry(db *sql.DB, tableName string, entity interface{}, declared bool) (reflect.Type, *registryEntry, error) {
+	key := entityStructType(entity)
+	if nil == key || reflect.Struct != key.Kind() {
+		return nil, nil, fmt.Errorf("%w: cannot register %T", ErrInvalidEntity, entity)
+	}
+	loaded, _ := registry.entries.LoadOrStore(key, &registryEntry{db: db, tableName: tableName, declared: declared})
+	entry := loaded.(*registryEntry)
+	if entry.tableName != tableName {
+		return nil, nil, fmt.Errorf("%w: %v is registered to table %s, not %s",
+			ErrAlreadyRegistered, key, entry.tableName, tableName)
+	}
+	return key, entry, nil
+}
+
+func (registry *Registry) fetch(key reflect.Type, entry *registryEntry) (*TableMetadata, error) {
+	entry.once.Do(func() {
+		metadata := &TableMetadata{
+			TablePrefix: registry.TablePrefix,
+			Config:      registry.Config.Merge(registry.TableConfigs[entry.tableName]),
+			gate:        &registry.gate,
+		}
+		entry.err = metadata.FetchTableMetadata(entry.db, entry.tableName, reflect.New(key).Interface())
+		entry.metadata = metadata
+		if nil == entry.err {
+			entry.fetched.Store(metadata)
+		}
+	})
+	if nil != entry.err {
+		// forget the failure so that a later call can try again, keeping a declared
+		// table declared
+		if entry.declared {
+			registry.entries.CompareAndSwap(key, entry, &registryEntry{db: entry.db, tableName: entry.tableName, declared: true})
+		} else {
+			registry.entries.CompareAndDelete(key, entry)
+		}
+		return nil, entry.err
+	}
+	return entry.metadata, nil
+}
+
+func (registry *Registry) Lookup(entity interface{}) (*TableMetadata, bool) {
+	// Returns the metadata for an entity type that has been successfully registered.
+	loaded, ok := registry.entries.Load(entityStructType(entity))
+	if !ok {
+		return nil, false
+	}
+	metadata := loaded.(*registryEntry).fetched.Load()
+	return metadata, nil != metadata
+}
+
+func (registry *Registry) Tables() []*TableMetadata {
+	// Returns all successfully registered metadata, ordered by table name.
+	tables := []*TableMetadata{}
+	registry.entries.Range(func(key, value interface{}) bool {
+		if metadata, ok := registry.Lookup(key); ok {
+			tables = append(tables, metadata)
+		}
+		return true
+	})
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables
+}
+
+func (registry *Registry) AutoIncrementReport(ctx context.Context) ([]AutoIncrementUsage, error) {
+	return AutoIncrementReport(ctx, registry.Tables()...)
+}
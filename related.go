@@ -0,0 +1,153 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+)
+
+func (metadata TableMetadata) RelatedColumn(child *TableMetadata) (string, error) {
+	// Returns the column of child that references the id of this table: the column of
+	// a single-column foreign key to it, or else one named after it, ex. order_id.
+	for _, key := range child.ForeignKeysTo(metadata.Name) {
+		if 1 == len(key.Columns) && "id" == key.ReferencedColumns[0] {
+			return key.Columns[0], nil
+		}
+	}
+	colname := metadata.BaseName + "_id"
+	if "" == metadata.BaseName {
+		colname = metadata.Name + "_id"
+	}
+	if child.IsColumn(colname) {
+		return colname, nil
+	}
+	return "", fmt.Errorf("%w: no column of %s references %s", ErrInvalidColumn, child.Name, metadata.Name)
+}
+
+func relatedId(v reflect.Value) (uint, bool) {
+	// Converts a foreign key field to an id, ex. from int64, *uint or sql.NullInt64.
+	if !v.IsValid() {
+		return 0, false
+	}
+	if reflect.Ptr == v.Kind() {
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(v.Uint()), true
+	}
+	if valuer, ok := v.Interface().(driver.Valuer); ok {
+		if id, err := valuer.Value(); nil == err {
+			if n, ok := id.(int64); ok {
+				return uint(n), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (metadata TableMetadata) LoadRelated(parents interface{}, child *TableMetadata, field string) error {
+	return metadata.LoadRelatedContext(context.Background(), parents, child, field)
+}
+
+func (metadata TableMetadata) LoadRelatedContext(ctx context.Context, parents interface{}, child *TableMetadata, field string) error {
+	// Fills in the named field of each parent with its child entities, fetched with
+	// one WHERE fk IN (...) query for all the parents rather than one per parent.
+	// parents is a pointer to an entity or to a slice of entities. A slice field,
+	// ex. Lines []OrderLine, is has-many; a struct or pointer field is has-one.
+	var values []reflect.Value
+	if slice, _, err := GetSliceValue(parents); nil == err {
+		for i := 0; i < slice.Len(); i++ {
+			value := slice.Index(i)
+			if reflect.Ptr == value.Kind() {
+				value = value.Elem()
+			}
+			values = append(values, value)
+		}
+	} else {
+		value, err := GetStructValue(parents)
+		if nil != err {
+			return err
+		}
+		values = append(values, value)
+	}
+	if 0 == len(values) {
+		return nil
+	}
+	target, ok := values[0].Type().FieldByName(field)
+	if !ok {
+		return fmt.Errorf("%w: %v has no field %s", ErrInvalidEntity, values[0].Type(), field)
+	}
+	hasMany := reflect.Slice == target.Type.Kind()
+	childType := target.Type
+	if hasMany {
+		childType = childType.Elem()
+	}
+	isPtr := reflect.Ptr == childType.Kind()
+	if isPtr {
+		childType = childType.Elem()
+	}
+	if childType != child.EntityType {
+		return fmt.Errorf("%w: field %s holds %v, not %s entities %v",
+			ErrInvalidEntity, field, target.Type, child.Name, child.EntityType)
+	}
+	colname, err := metadata.RelatedColumn(child)
+	if nil != err {
+		return err
+	}
+
+	ids := make([]interface{}, 0, len(values))
+	seen := map[uint]bool{}
+	for _, value := range values {
+		id := GetValueId(value)
+		if 0 != id && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	grouped, err := child.GetEntitiesGroupedByContext(ctx, colname, ids)
+	if nil != err {
+		return err
+	}
+	byId := make(map[uint][]interface{}, len(grouped))
+	for key, entities := range grouped {
+		if id, ok := relatedId(reflect.ValueOf(key)); ok {
+			byId[id] = append(byId[id], entities...)
+		}
+	}
+
+	for _, value := range values {
+		entities := byId[GetValueId(value)]
+		dest := value.FieldByIndex(target.Index)
+		if hasMany {
+			slice := reflect.MakeSlice(target.Type, 0, len(entities))
+			for _, entity := range entities {
+				if isPtr {
+					slice = reflect.Append(slice, reflect.ValueOf(entity))
+				} else {
+					slice = reflect.Append(slice, reflect.ValueOf(entity).Elem())
+				}
+			}
+			dest.Set(slice)
+			continue
+		}
+		if 1 < len(entities) {
+			return fmt.Errorf("%w: %s for %s id %d", ErrMultipleRows, child.Name, metadata.Name, GetValueId(value))
+		}
+		switch {
+		case 0 == len(entities):
+			dest.Set(reflect.Zero(target.Type))
+		case isPtr:
+			dest.Set(reflect.ValueOf(entities[0]))
+		default:
+			dest.Set(reflect.ValueOf(entities[0]).Elem())
+		}
+	}
+	return nil
+}
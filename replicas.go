@@ -0,0 +1,45 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+)
+
+// replicaTurn picks the replicas in turn, shared by all tables as they usually
+// share the replicas.
+var replicaTurn atomic.Uint64
+
+type primaryKey struct{}
+
+func WithPrimary(ctx context.Context) context.Context {
+	// Returns a context whose reads go to the primary rather than the Replicas, ex.
+	// for a read that must see a write just made by another request.
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func (metadata TableMetadata) replica(ctx context.Context) *sql.DB {
+	// Returns the replica to run a SELECT on, or nil to run it on the primary: in a
+	// transaction, with a context from WithPrimary, or when the replica has not caught
+	// up with the writes of the context's Session within ReplicaWait.
+	if 0 == len(metadata.Replicas) || nil != GetTx(ctx) {
+		return nil
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return nil
+	}
+	replica := metadata.Replicas[(replicaTurn.Add(1)-1)%uint64(len(metadata.Replicas))]
+	if session := GetSession(ctx); nil != session && session.Wrote() {
+		if 0 >= metadata.ReplicaWait {
+			return nil
+		}
+		ready, err := session.ReplicaReady(ctx, replica, metadata.ReplicaWait)
+		if nil != err {
+			metadata.logf(LogWarn, "read of %s falls back to the primary: %v", metadata.Name, err)
+		}
+		if !ready {
+			return nil
+		}
+	}
+	return replica
+}
@@ -0,0 +1,41 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+func (metadata TableMetadata) ScanEntityByName(entity interface{}, rows *sql.Rows) error {
+	// Like ScanEntity, but matches the result columns to fields by name, so rows can
+	// come from any SELECT, ex. SELECT * or a join selecting some of the columns.
+	// Columns that are not in the table are discarded, as are repeats of a name
+	// (ex. the id of a joined table), and fields with no column are left as they are.
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	return metadata.scanByName(entity, value, rows)
+}
+
+func (metadata TableMetadata) scanByName(entity interface{}, value reflect.Value, rows *sql.Rows) error {
+	names, err := rows.Columns()
+	if nil != err {
+		return fmt.Errorf("failed to scan %s entity: %w", metadata.Name, err)
+	}
+	byName := make(map[string]ColumnMetadata, len(metadata.Columns))
+	for _, col := range metadata.Columns {
+		byName[col.Field] = col
+	}
+	for _, col := range metadata.VirtualColumns {
+		byName[col.Field] = col
+	}
+	cols := make([]ColumnMetadata, len(names))
+	for i, name := range names {
+		if col, ok := byName[name]; ok {
+			cols[i] = col
+			delete(byName, name)
+		}
+	}
+	return metadata.scanColumns(entity, value, cols, rows)
+}
@@ -0,0 +1,89 @@
+package mysqlmeta
+
+import (
+	"reflect"
+	"sync"
+)
+
+// scanPlan is what ScanEntity works out once per metadata rather than for every row:
+// the field of each column of SelectString, and which fields are read from JSON or
+// split from a SET.
+type scanPlan struct {
+	// fields are the field indexes by column, -1 to discard the column
+	fields  []int
+	isJson  []bool
+	hasJson bool
+	isSet   []bool
+}
+
+func (metadata TableMetadata) compileScanPlan() *scanPlan {
+	// Returns nil without an entity type, or when a column has no field, for
+	// scanColumns to report the mismatch.
+	if nil == metadata.EntityType {
+		return nil
+	}
+	cols := metadata.scanTargets()
+	plan := &scanPlan{fields: make([]int, len(cols)), isJson: make([]bool, len(cols)), isSet: make([]bool, len(cols))}
+	for i, col := range cols {
+		if "" == col.Field {
+			plan.fields[i] = -1
+			continue
+		}
+		j := metadata.fieldIndex(col)
+		if j < 0 || j >= metadata.EntityType.NumField() {
+			return nil
+		}
+		plan.fields[i] = j
+		plan.isJson[i] = IsJsonType(metadata.EntityType.Field(j).Type)
+		plan.hasJson = plan.hasJson || plan.isJson[i]
+		plan.isSet[i] = isSetField(col, metadata.EntityType.Field(j).Type)
+	}
+	return plan
+}
+
+// scanBuffers hold the scan destinations of a row, reused through scanBufferPool so
+// that scanning a large result set does not allocate them for every row.
+type scanBuffers struct {
+	values     []interface{}
+	jsonValues []string
+	// json is the text of a JSON field, as Unmarshal takes it
+	json    []byte
+	discard interface{}
+}
+
+var scanBufferPool = sync.Pool{New: func() interface{} { return &scanBuffers{} }}
+
+func (plan *scanPlan) buffers(value reflect.Value) *scanBuffers {
+	// Returns pooled buffers with the scan destinations for a row: the addresses of
+	// the fields, or of jsonValues for the fields read from JSON. Release them after
+	// the scan.
+	buffers := scanBufferPool.Get().(*scanBuffers)
+	if cap(buffers.values) < len(plan.fields) {
+		buffers.values = make([]interface{}, len(plan.fields))
+		buffers.jsonValues = make([]string, len(plan.fields))
+	}
+	buffers.values = buffers.values[:len(plan.fields)]
+	buffers.jsonValues = buffers.jsonValues[:len(plan.fields)]
+	for i, j := range plan.fields {
+		switch {
+		case j < 0:
+			buffers.values[i] = &buffers.discard
+		case plan.isJson[i]:
+			buffers.values[i] = &buffers.jsonValues[i]
+		case plan.isSet[i]:
+			buffers.values[i] = setField{values: value.Field(j).Addr().Interface().(*[]string)}
+		default:
+			buffers.values[i] = value.Field(j).Addr().Interface()
+		}
+	}
+	return buffers
+}
+
+func (buffers *scanBuffers) release() {
+	// Drops the references to the entity and the scanned values before pooling.
+	clear(buffers.values)
+	clear(buffers.jsonValues)
+	buffers.json = buffers.json[:0]
+	buffers.discard = nil
+	scanBufferPool.Put(buffers)
+}
@@ -0,0 +1,162 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// treat as const
+var SQL_INT_DISPLAY_WIDTH = regexp.MustCompile("(?i)^((?:tiny|small|medium|big)?int)\\(\\d+\\)")
+
+func comparableType(columnType string) string {
+	// The column type without what differs between servers for the same column, ie.
+	// the display width of integers before MySQL 8.0.19.
+	if "tinyint(1)" == columnType || "tinyint(1) unsigned" == columnType {
+		return columnType
+	}
+	return SQL_INT_DISPLAY_WIDTH.ReplaceAllString(columnType, "$1")
+}
+
+func comparableColumn(col ColumnMetadata) string {
+	col.ColumnType = comparableType(col.ColumnType)
+	return col.ColumnDefinition()
+}
+
+func AlterStatements(live, desired TableMetadata) []string {
+	// Returns the ALTER TABLE statements that change the live table into the desired
+	// one: changed and dropped indexes are dropped first, then columns are added in
+	// place or modified, columns that are not desired are dropped, and new or changed
+	// indexes are added. Generated columns of the live table are left as they are when
+	// the desired column has no expression, as with metadata from DescribeStruct.
+	alter := "ALTER TABLE " + QuoteTableName(live.Name) + " "
+	statements := []string{}
+	liveCols := map[string]ColumnMetadata{}
+	for _, col := range live.Columns {
+		liveCols[col.Field] = col
+	}
+	desiredCols := map[string]bool{}
+	for _, col := range desired.Columns {
+		desiredCols[col.Field] = true
+	}
+	liveIndexes := map[string]string{}
+	for _, index := range live.indexes() {
+		liveIndexes[index.Name] = index.Definition()
+	}
+	desiredIndexes := map[string]string{}
+	for _, index := range desired.indexes() {
+		desiredIndexes[index.Name] = index.Definition()
+	}
+	for _, name := range sortedKeys(liveIndexes) {
+		if desiredIndexes[name] == liveIndexes[name] {
+			continue
+		}
+		if "PRIMARY" == name {
+			statements = append(statements, alter+"DROP PRIMARY KEY")
+		} else {
+			statements = append(statements, alter+"DROP INDEX "+QuoteIdentifier(name))
+		}
+	}
+	position := "FIRST"
+	for _, col := range desired.Columns {
+		current, ok := liveCols[col.Field]
+		switch {
+		case !ok:
+			statements = append(statements, alter+"ADD COLUMN "+col.ColumnDefinition()+" "+position)
+		case current.IsGenerated() && "" == col.GenerationExpression:
+		case comparableColumn(current) != comparableColumn(col):
+			statements = append(statements, alter+"MODIFY COLUMN "+col.ColumnDefinition())
+		}
+		position = "AFTER " + QuoteIdentifier(col.Field)
+	}
+	for _, col := range live.Columns {
+		if !desiredCols[col.Field] {
+			statements = append(statements, alter+"DROP COLUMN "+QuoteIdentifier(col.Field))
+		}
+	}
+	for _, index := range desired.indexes() {
+		if liveIndexes[index.Name] != desiredIndexes[index.Name] {
+			statements = append(statements, alter+"ADD "+index.Definition())
+		}
+	}
+	return statements
+}
+
+func (snapshot SchemaSnapshot) Metadata() TableMetadata {
+	// Returns metadata with the columns and indexes of the snapshot, ex. to use a saved
+	// snapshot as the desired schema of a Migration.
+	metadata := TableMetadata{Name: snapshot.Table, BaseName: snapshot.Table, ForeignKeys: snapshot.ForeignKeys}
+	for _, col := range snapshot.Columns {
+		defaultValue := sql.NullString{}
+		if nil != col.DefaultValue {
+			defaultValue = sql.NullString{String: *col.DefaultValue, Valid: true}
+		}
+		metadata.Columns = append(metadata.Columns, ColumnMetadata{
+			Field:        col.Field,
+			ColumnType:   col.ColumnType,
+			Nullable:     col.Nullable,
+			DefaultValue: defaultValue.String,
+			Default:      ParseColumnDefault(defaultValue, col.ColumnType, col.Extra),
+			Extra:        col.Extra,
+		})
+	}
+	metadata.Indexes = []IndexDefinition{}
+	for _, index := range snapshot.Indexes {
+		metadata.Indexes = append(metadata.Indexes, IndexDefinition{Name: index.KeyName, Unique: index.Unique, Columns: index.Columns})
+	}
+	return metadata
+}
+
+// Migration changes the tables of a database to match desired metadata.
+type Migration struct {
+	DB *sql.DB
+	// DryRun only writes the statements to Out, rather than executing them
+	DryRun bool
+	// Out receives each statement before it is executed, if not nil
+	Out io.Writer
+}
+
+func (migration Migration) Statements(ctx context.Context, desired ...TableMetadata) ([]string, error) {
+	// Returns the statements that make the live tables match the desired ones:
+	// CREATE TABLE for those that do not exist, and AlterStatements for the others.
+	statements := []string{}
+	for _, table := range desired {
+		if err := CheckTableName(table.Name); nil != err {
+			return nil, err
+		}
+		cols, err := getSchemaTable(migration.DB, table.Name)
+		if errors.Is(err, ErrInvalidTableName) {
+			statements = append(statements, table.CreateTableStatement())
+			continue
+		} else if nil != err {
+			return nil, err
+		}
+		live := TableMetadata{Name: table.Name, Columns: cols, Indexes: GroupIndexes(cols)}
+		statements = append(statements, AlterStatements(live, table)...)
+	}
+	return statements, nil
+}
+
+func (migration Migration) Apply(ctx context.Context, desired ...TableMetadata) ([]string, error) {
+	// Executes the Statements in order, stopping at the first that fails, and returns
+	// those executed - or with DryRun, only writes them to Out and returns them all.
+	statements, err := migration.Statements(ctx, desired...)
+	if nil != err {
+		return nil, err
+	}
+	for i, statement := range statements {
+		if nil != migration.Out {
+			fmt.Fprintln(migration.Out, statement+";")
+		}
+		if migration.DryRun {
+			continue
+		}
+		if _, err = migration.DB.ExecContext(ctx, statement); nil != err {
+			return statements[:i], fmt.Errorf("migrate: %s: %w", statement, err)
+		}
+	}
+	return statements, nil
+}
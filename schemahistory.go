@@ -0,0 +1,321 @@
+package mysqlmeta
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// treat as const
+var SCHEMA_HISTORY_TABLE = "mysqlmeta_schema_history"
+
+// SchemaSnapshot is the part of a table's metadata that only changes with its
+// schema, ex. not index cardinalities, so that snapshots can be compared over time.
+type SchemaSnapshot struct {
+	Table       string               `json:"table"`
+	Columns     []SchemaColumn       `json:"columns"`
+	Indexes     []SchemaIndex        `json:"indexes,omitempty"`
+	ForeignKeys []ForeignKeyMetadata `json:"foreign_keys,omitempty"`
+	Fingerprint string               `json:"fingerprint"`
+}
+
+type SchemaColumn struct {
+	Field      string `json:"field"`
+	ColumnType string `json:"column_type"`
+	Nullable   string `json:"nullable,omitempty"`
+	// DefaultValue is nil without a default, to tell it apart from DEFAULT ''
+	DefaultValue *string `json:"default_value,omitempty"`
+	Extra        string  `json:"extra,omitempty"`
+}
+
+type SchemaIndex struct {
+	KeyName string   `json:"key_name"`
+	Unique  bool     `json:"unique,omitempty"`
+	Columns []string `json:"columns"`
+}
+
+func (metadata TableMetadata) Snapshot() SchemaSnapshot {
+	snapshot := SchemaSnapshot{Table: metadata.Name, ForeignKeys: metadata.ForeignKeys}
+	for _, col := range metadata.Columns {
+		var defaultValue *string
+		if DefaultNone != col.Default.Kind || "" != col.DefaultValue {
+			value := col.DefaultValue
+			defaultValue = &value
+		}
+		snapshot.Columns = append(snapshot.Columns, SchemaColumn{
+			Field:        col.Field,
+			ColumnType:   col.ColumnType,
+			Nullable:     col.Nullable,
+			DefaultValue: defaultValue,
+			Extra:        col.Extra,
+		})
+	}
+	for _, index := range metadata.indexes() {
+		snapshot.Indexes = append(snapshot.Indexes, SchemaIndex{KeyName: index.Name, Unique: index.Unique, Columns: index.Columns})
+	}
+	sort.Slice(snapshot.Indexes, func(i, j int) bool { return snapshot.Indexes[i].KeyName < snapshot.Indexes[j].KeyName })
+	snapshot.Fingerprint = snapshot.fingerprint()
+	return snapshot
+}
+
+func (snapshot SchemaSnapshot) fingerprint() string {
+	// A hash of the snapshot without its fingerprint, so equal schemas hash equal.
+	snapshot.Fingerprint = ""
+	b, _ := json.Marshal(snapshot)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// SchemaChange describes one difference between two snapshots, ex.
+// {Table: "product", Kind: "column changed", Name: "price", From: "int", To: "decimal(10,2)"}.
+type SchemaChange struct {
+	Table string `json:"table"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name,omitempty"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+func (change SchemaChange) String() string {
+	s := change.Table + ": " + change.Kind
+	if "" != change.Name {
+		s += " " + change.Name
+	}
+	switch {
+	case "" != change.From && "" != change.To:
+		s += " (" + change.From + " -> " + change.To + ")"
+	case "" != change.From || "" != change.To:
+		s += " (" + change.From + change.To + ")"
+	}
+	return s
+}
+
+func DiffSnapshots(from, to []SchemaSnapshot) []SchemaChange {
+	// Lists the tables, columns, indexes and foreign keys added, dropped or changed.
+	changes := []SchemaChange{}
+	before := map[string]SchemaSnapshot{}
+	for _, snapshot := range from {
+		before[snapshot.Table] = snapshot
+	}
+	after := map[string]SchemaSnapshot{}
+	for _, snapshot := range to {
+		after[snapshot.Table] = snapshot
+	}
+	for _, name := range sortedKeys(before, after) {
+		old, hadTable := before[name]
+		current, hasTable := after[name]
+		switch {
+		case !hasTable:
+			changes = append(changes, SchemaChange{Table: name, Kind: "table dropped"})
+		case !hadTable:
+			changes = append(changes, SchemaChange{Table: name, Kind: "table added"})
+		case old.Fingerprint != current.Fingerprint:
+			changes = append(changes, diffTable(old, current)...)
+		}
+	}
+	return changes
+}
+
+func diffTable(old, current SchemaSnapshot) []SchemaChange {
+	changes := []SchemaChange{}
+	describe := func(col SchemaColumn) string {
+		s := col.ColumnType
+		if "YES" == col.Nullable {
+			s += " null"
+		}
+		if nil != col.DefaultValue && "" == *col.DefaultValue {
+			s += " default ''"
+		} else if nil != col.DefaultValue {
+			s += " default " + *col.DefaultValue
+		}
+		if "" != col.Extra {
+			s += " " + col.Extra
+		}
+		return s
+	}
+	oldCols := map[string]string{}
+	for _, col := range old.Columns {
+		oldCols[col.Field] = describe(col)
+	}
+	newCols := map[string]string{}
+	for _, col := range current.Columns {
+		newCols[col.Field] = describe(col)
+	}
+	changes = append(changes, diffNamed(old.Table, "column", oldCols, newCols)...)
+
+	oldIndexes := map[string]string{}
+	for _, index := range old.Indexes {
+		oldIndexes[index.KeyName] = describeIndex(index.Unique, index.Columns)
+	}
+	newIndexes := map[string]string{}
+	for _, index := range current.Indexes {
+		newIndexes[index.KeyName] = describeIndex(index.Unique, index.Columns)
+	}
+	changes = append(changes, diffNamed(old.Table, "index", oldIndexes, newIndexes)...)
+
+	oldKeys := map[string]string{}
+	for _, key := range old.ForeignKeys {
+		oldKeys[key.ConstraintName] = describeForeignKey(key)
+	}
+	newKeys := map[string]string{}
+	for _, key := range current.ForeignKeys {
+		newKeys[key.ConstraintName] = describeForeignKey(key)
+	}
+	return append(changes, diffNamed(old.Table, "foreign key", oldKeys, newKeys)...)
+}
+
+func describeIndex(unique bool, columns []string) string {
+	s := "(" + strings.Join(columns, ", ") + ")"
+	if unique {
+		s = "unique " + s
+	}
+	return s
+}
+
+func describeForeignKey(key ForeignKeyMetadata) string {
+	return "(" + strings.Join(key.Columns, ", ") + ") references " + key.ReferencedTable +
+		" (" + strings.Join(key.ReferencedColumns, ", ") + ") on update " + key.UpdateRule +
+		" on delete " + key.DeleteRule
+}
+
+func diffNamed(table, kind string, before, after map[string]string) []SchemaChange {
+	changes := []SchemaChange{}
+	for _, name := range sortedKeys(before, after) {
+		old, had := before[name]
+		current, has := after[name]
+		switch {
+		case !has:
+			changes = append(changes, SchemaChange{Table: table, Kind: kind + " dropped", Name: name, From: old})
+		case !had:
+			changes = append(changes, SchemaChange{Table: table, Kind: kind + " added", Name: name, To: current})
+		case old != current:
+			changes = append(changes, SchemaChange{Table: table, Kind: kind + " changed", Name: name, From: old, To: current})
+		}
+	}
+	return changes
+}
+
+func sortedKeys[V any](maps ...map[string]V) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SchemaHistory stores snapshots in a table of the database, labelled by deploy,
+// ex. a version or build number, to answer what changed between two deploys.
+type SchemaHistory struct {
+	DB *sql.DB
+	// Table holds the history, SCHEMA_HISTORY_TABLE if empty
+	Table string
+}
+
+func (history SchemaHistory) table() string {
+	if "" == history.Table {
+		return SCHEMA_HISTORY_TABLE
+	}
+	return history.Table
+}
+
+func (history SchemaHistory) CreateTable(ctx context.Context) error {
+	if err := CheckTableName(history.table()); nil != err {
+		return err
+	}
+	_, err := history.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+QuoteTableName(history.table())+" ("+
+		"id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY, "+
+		"deploy varchar(64) NOT NULL, "+
+		"table_name varchar(64) NOT NULL, "+
+		"fingerprint char(64) NOT NULL, "+
+		"snapshot json NOT NULL, "+
+		"recorded_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP, "+
+		"UNIQUE KEY deploy_table (deploy, table_name))")
+	if nil != err {
+		return fmt.Errorf("create %s: %w", history.table(), err)
+	}
+	return nil
+}
+
+func (history SchemaHistory) Record(ctx context.Context, deploy string, snapshots ...SchemaSnapshot) error {
+	// Stores the snapshots under the deploy, replacing any recorded for it before.
+	if err := CheckTableName(history.table()); nil != err {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		b, err := json.Marshal(snapshot)
+		if nil != err {
+			return fmt.Errorf("snapshot of %s: %w", snapshot.Table, err)
+		}
+		_, err = history.DB.ExecContext(ctx, "REPLACE INTO "+QuoteTableName(history.table())+" "+
+			"(deploy, table_name, fingerprint, snapshot) VALUES (?, ?, ?, ?)",
+			deploy, snapshot.Table, snapshot.Fingerprint, b)
+		if nil != err {
+			return fmt.Errorf("record %s snapshot of %s: %w", deploy, snapshot.Table, err)
+		}
+	}
+	return nil
+}
+
+func (history SchemaHistory) Load(ctx context.Context, deploy string) ([]SchemaSnapshot, error) {
+	if err := CheckTableName(history.table()); nil != err {
+		return nil, err
+	}
+	rows, err := history.DB.QueryContext(ctx, "SELECT snapshot FROM "+QuoteTableName(history.table())+" "+
+		"WHERE deploy = ? ORDER BY table_name", deploy)
+	if nil != err {
+		return nil, fmt.Errorf("load %s snapshots: %w", deploy, err)
+	}
+	defer rows.Close()
+	snapshots := []SchemaSnapshot{}
+	for rows.Next() {
+		var b []byte
+		var snapshot SchemaSnapshot
+		if err = rows.Scan(&b); nil != err {
+			return nil, fmt.Errorf("load %s snapshots: %w", deploy, err)
+		}
+		if err = json.Unmarshal(b, &snapshot); nil != err {
+			return nil, fmt.Errorf("load %s snapshots: %w", deploy, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("load %s snapshots: %w", deploy, err)
+	}
+	if 0 == len(snapshots) {
+		return nil, fmt.Errorf("%w: no snapshots for deploy %s", ErrNotFound, deploy)
+	}
+	return snapshots, nil
+}
+
+func (history SchemaHistory) Diff(ctx context.Context, fromDeploy, toDeploy string) ([]SchemaChange, error) {
+	from, err := history.Load(ctx, fromDeploy)
+	if nil != err {
+		return nil, err
+	}
+	to, err := history.Load(ctx, toDeploy)
+	if nil != err {
+		return nil, err
+	}
+	return DiffSnapshots(from, to), nil
+}
+
+func (registry *Registry) Snapshots() []SchemaSnapshot {
+	// Returns snapshots of every registered table, ex. to Record at startup.
+	snapshots := []SchemaSnapshot{}
+	for _, metadata := range registry.Tables() {
+		snapshots = append(snapshots, metadata.Snapshot())
+	}
+	return snapshots
+}
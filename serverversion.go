@@ -0,0 +1,126 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ServerVersion is the version of the server a table was fetched from, parsed from
+// SELECT VERSION(), ex. "8.0.35", "10.11.6-MariaDB-log" or "8.0.11-TiDB-v7.5.0".
+// The number is that of the flavor, ex. 7.5.0 for TiDB rather than the MySQL version
+// it emulates. Aurora reports the version of MySQL it is compatible with, so it is
+// gated like that MySQL.
+type ServerVersion struct {
+	Version string `json:"version"`
+	Flavor  string `json:"flavor"`
+	Major   int    `json:"major"`
+	Minor   int    `json:"minor"`
+	Patch   int    `json:"patch"`
+}
+
+// Feature is a version-dependent feature of the server - see ServerVersion.Supports
+type Feature string
+
+// treat as const
+var FLAVOR_MYSQL = "mysql"
+var FLAVOR_MARIADB = "mariadb"
+var FLAVOR_TIDB = "tidb"
+var FEATURE_CHECK_CONSTRAINTS Feature = "CHECK constraints"
+var FEATURE_EXPLAIN_ANALYZE Feature = "EXPLAIN ANALYZE"
+var FEATURE_INVISIBLE_COLUMNS Feature = "invisible columns"
+var FEATURE_JSON Feature = "JSON functions"
+var SQL_SERVER_VERSION = regexp.MustCompile("^(\\d+)\\.(\\d+)(?:\\.(\\d+))?")
+
+// the first version of each flavor with the feature - a flavor missing has none
+var featureVersions = map[Feature]map[string][3]int{
+	FEATURE_CHECK_CONSTRAINTS: {FLAVOR_MYSQL: {8, 0, 16}, FLAVOR_MARIADB: {10, 2, 1}, FLAVOR_TIDB: {7, 2, 0}},
+	// the EXPLAIN ANALYZE of TiDB is a table rather than the tree of MySQL
+	FEATURE_EXPLAIN_ANALYZE:   {FLAVOR_MYSQL: {8, 0, 18}},
+	FEATURE_INVISIBLE_COLUMNS: {FLAVOR_MYSQL: {8, 0, 23}, FLAVOR_MARIADB: {10, 3, 3}},
+	FEATURE_JSON:              {FLAVOR_MYSQL: {5, 7, 8}, FLAVOR_MARIADB: {10, 2, 7}, FLAVOR_TIDB: {2, 1, 0}},
+}
+
+// the versions by database, as the server of a pool does not change between fetches
+var serverVersions sync.Map // *sql.DB -> *ServerVersion
+
+func ParseServerVersion(version string) (*ServerVersion, error) {
+	// Parses the result of SELECT VERSION().
+	parsed := &ServerVersion{Version: version, Flavor: FLAVOR_MYSQL}
+	number := version
+	if _, tidb, ok := strings.Cut(version, "-TiDB-v"); ok {
+		parsed.Flavor = FLAVOR_TIDB
+		number = tidb
+	} else if strings.Contains(strings.ToLower(version), "mariadb") {
+		parsed.Flavor = FLAVOR_MARIADB
+		// replication clients may see the version behind a 5.5.5- prefix
+		number = strings.TrimPrefix(number, "5.5.5-")
+	}
+	match := SQL_SERVER_VERSION.FindStringSubmatch(number)
+	if nil == match {
+		return nil, fmt.Errorf("cannot parse server version %q", version)
+	}
+	parsed.Major, _ = strconv.Atoi(match[1])
+	parsed.Minor, _ = strconv.Atoi(match[2])
+	parsed.Patch, _ = strconv.Atoi(match[3])
+	return parsed, nil
+}
+
+func GetServerVersion(ctx context.Context, db *sql.DB) (*ServerVersion, error) {
+	// Returns the version of the server of db, read once per database.
+	if cached, ok := serverVersions.Load(db); ok {
+		return cached.(*ServerVersion), nil
+	}
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); nil != err {
+		return nil, fmt.Errorf("server version: %w", err)
+	}
+	parsed, err := ParseServerVersion(version)
+	if nil != err {
+		return nil, err
+	}
+	serverVersions.Store(db, parsed)
+	return parsed, nil
+}
+
+func (version *ServerVersion) String() string {
+	if nil == version {
+		return "unknown"
+	}
+	return version.Version
+}
+
+func (version *ServerVersion) AtLeast(major, minor, patch int) bool {
+	// Compares the version number, whatever the flavor.
+	if nil == version {
+		return true
+	}
+	if version.Major != major {
+		return version.Major > major
+	}
+	if version.Minor != minor {
+		return version.Minor > minor
+	}
+	return version.Patch >= patch
+}
+
+func (version *ServerVersion) Supports(feature Feature) bool {
+	// Tells whether the server has the feature. The version of metadata that was not
+	// fetched from a server, ex. from DDL, is unknown and supports everything.
+	if nil == version {
+		return true
+	}
+	since, ok := featureVersions[feature][version.Flavor]
+	return ok && version.AtLeast(since[0], since[1], since[2])
+}
+
+func (metadata TableMetadata) checkFeature(feature Feature) error {
+	if metadata.ServerVersion.Supports(feature) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s on %s %s", ErrUnsupported, feature, metadata.ServerVersion.Flavor, metadata.ServerVersion)
+}
@@ -0,0 +1,98 @@
+package mysqlmeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// operationGate counts the statements in flight and the background workers of the
+// tables of a Registry, so that Shutdown can turn new ones away and wait for the rest.
+type operationGate struct {
+	mu         sync.Mutex
+	closed     bool
+	operations sync.WaitGroup
+	workers    sync.WaitGroup
+	workerCtx  context.Context
+	stop       context.CancelFunc
+}
+
+func (gate *operationGate) enter() error {
+	if nil == gate {
+		return nil
+	}
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	if gate.closed {
+		return ErrShutdown
+	}
+	gate.operations.Add(1)
+	return nil
+}
+
+func (gate *operationGate) leave() {
+	if nil != gate {
+		gate.operations.Done()
+	}
+}
+
+func (gate *operationGate) close() {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	gate.closed = true
+	if nil != gate.stop {
+		gate.stop()
+	}
+}
+
+func (registry *Registry) Go(worker func(ctx context.Context)) error {
+	// Runs a background worker, ex. a poller, whose context is cancelled when
+	// Shutdown begins. Shutdown then waits for the worker to return.
+	gate := &registry.gate
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	if gate.closed {
+		return ErrShutdown
+	}
+	if nil == gate.workerCtx {
+		gate.workerCtx, gate.stop = context.WithCancel(context.Background())
+	}
+	gate.workers.Add(1)
+	go func(ctx context.Context) {
+		defer gate.workers.Done()
+		worker(ctx)
+	}(gate.workerCtx)
+	return nil
+}
+
+func Shutdown(ctx context.Context) error {
+	return DefaultRegistry.Shutdown(ctx)
+}
+
+func (registry *Registry) Shutdown(ctx context.Context) error {
+	// Stops the registered tables from starting statements, which then fail with
+	// ErrShutdown, cancels the workers started with Go, and waits until ctx is done
+	// for the statements in flight and the workers to finish. A query counts as
+	// finished once it returns its rows. The cached prepared statements are closed
+	// either way.
+	gate := &registry.gate
+	gate.close()
+	done := make(chan struct{})
+	go func() {
+		gate.operations.Wait()
+		gate.workers.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("shutdown: %w", ctx.Err())
+	}
+	errs := []error{err}
+	for _, metadata := range registry.Tables() {
+		errs = append(errs, metadata.Close())
+	}
+	return errors.Join(errs...)
+}
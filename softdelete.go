@@ -0,0 +1,89 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// treat as const
+var SOFT_DELETE_COLUMN = "deleted_at"
+
+func findSoftDeleteColumn(cols []ColumnMetadata, enabled bool) string {
+	// A column tagged sql:"soft-delete" turns soft delete on by itself,
+	// otherwise the SoftDelete option looks for a deleted_at column.
+	for _, col := range cols {
+		if col.SoftDelete {
+			return col.Field
+		}
+	}
+	if enabled {
+		for _, col := range cols {
+			if SOFT_DELETE_COLUMN == col.Field {
+				return col.Field
+			}
+		}
+	}
+	return ""
+}
+
+func (metadata TableMetadata) Unscoped() TableMetadata {
+	// Returns a copy of the metadata whose queries include soft deleted rows and the
+	// rows of every tenant, and whose DeleteEntity removes rows rather than marking
+	// them deleted.
+	metadata.unscoped = true
+	return metadata
+}
+
+func (metadata TableMetadata) scopeClause(ctx context.Context, clause string, v []interface{}) (string, []interface{}) {
+	// Adds the automatic filters to the clause of a SELECT.
+	if metadata.unscoped {
+		return clause, v
+	}
+	clause, v = metadata.scopeTenant(ctx, clause, v)
+	if "" == metadata.SoftDeleteColumn {
+		return clause, v
+	}
+	return ScopeClauseArgs(clause, QuoteTableName(metadata.Name)+"."+QuoteIdentifier(metadata.SoftDeleteColumn)+" IS NULL", v)
+}
+
+func (metadata TableMetadata) DeleteEntity(entity interface{}) error {
+	return metadata.DeleteEntityContext(context.Background(), entity)
+}
+
+func (metadata TableMetadata) DeleteEntityContext(ctx context.Context, entity interface{}) error {
+	// Deletes the row with the entity's id, or with soft delete sets its deleted_at
+	// column (and field) to the current time instead.
+	value, err := GetStructValue(entity)
+	if nil != err {
+		return err
+	}
+	id := GetValueId(value)
+	if 0 == id {
+		return fmt.Errorf("%w for delete from %s", ErrNoPrimaryKey, metadata.Name)
+	}
+	if metadata.unscoped || "" == metadata.SoftDeleteColumn {
+		q, v := metadata.scopeTenantRow(ctx, "DELETE FROM "+QuoteTableName(metadata.Name)+" WHERE id = ?", []interface{}{id})
+		_, err = metadata.exec(ctx, q, v...)
+		if nil != err {
+			return fmt.Errorf("delete from %s: %w", metadata.Name, err)
+		}
+		return nil
+	}
+	now := time.Now()
+	q := "UPDATE " + QuoteTableName(metadata.Name) + " SET " + QuoteIdentifier(metadata.SoftDeleteColumn) + " = ? WHERE id = ? AND " +
+		QuoteIdentifier(metadata.SoftDeleteColumn) + " IS NULL"
+	q, v := metadata.scopeTenantRow(ctx, q, []interface{}{now, id})
+	_, err = metadata.exec(ctx, q, v...)
+	if nil != err {
+		return fmt.Errorf("soft delete from %s: %w", metadata.Name, err)
+	}
+	field := value.Field(metadata.FieldByColumn[metadata.SoftDeleteColumn])
+	if timeType == field.Type() {
+		field.Set(reflect.ValueOf(now))
+	} else if reflect.Ptr == field.Kind() && timeType == field.Type().Elem() {
+		field.Set(reflect.ValueOf(&now))
+	}
+	return nil
+}
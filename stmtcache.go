@@ -0,0 +1,224 @@
+package mysqlmeta
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// treat as const
+var STMT_CACHE_SIZE = 256
+
+// stmtCache holds lazily prepared statements keyed by their full query text, closing
+// the least recently used beyond its size. It is shared by pointer, so copies of a
+// TableMetadata use the same cache.
+type stmtCache struct {
+	mutex  sync.Mutex
+	size   int
+	stmts  map[string]*list.Element // of *cachedStmt, most recently used first
+	lru    *list.List
+	closed bool
+
+	hits, misses, evictions uint64
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sql.Stmt
+	// inUse counts the callers between get and release, so that a statement evicted
+	// meanwhile is only closed once they are done
+	inUse   int
+	evicted bool
+}
+
+// StatementCacheStats tells how well the statement cache of a table is working.
+type StatementCacheStats struct {
+	Size      int
+	Capacity  int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+func (stats StatementCacheStats) HitRate() float64 {
+	if 0 == stats.Hits+stats.Misses {
+		return 0
+	}
+	return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+}
+
+func newStmtCache(size int) *stmtCache {
+	if 0 >= size {
+		size = STMT_CACHE_SIZE
+	}
+	return &stmtCache{size: size, stmts: map[string]*list.Element{}, lru: list.New()}
+}
+
+func (cache *stmtCache) lookup(query string) (*cachedStmt, bool) {
+	// Must be called with the mutex held.
+	element, ok := cache.stmts[query]
+	if !ok {
+		return nil, false
+	}
+	cache.lru.MoveToFront(element)
+	entry := element.Value.(*cachedStmt)
+	entry.inUse++
+	return entry, true
+}
+
+func (cache *stmtCache) get(ctx context.Context, db *sql.DB, query string) (*cachedStmt, error) {
+	// Returns the statement for query, preparing it on first use. Call release when
+	// done with the statement.
+	cache.mutex.Lock()
+	entry, ok := cache.lookup(query)
+	closed := cache.closed
+	if ok {
+		cache.hits++
+	} else if !closed {
+		cache.misses++
+	}
+	cache.mutex.Unlock()
+	if ok {
+		return entry, nil
+	}
+	if closed {
+		return nil, errStmtCacheClosed
+	}
+	// prepare outside the lock, so a slow prepare does not hold up other queries
+	stmt, err := db.PrepareContext(ctx, query)
+	if nil != err {
+		return nil, err
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if existing, ok := cache.lookup(query); ok {
+		// another goroutine prepared the same query first
+		stmt.Close()
+		return existing, nil
+	}
+	if cache.closed {
+		stmt.Close()
+		return nil, errStmtCacheClosed
+	}
+	entry = &cachedStmt{query: query, stmt: stmt, inUse: 1}
+	cache.stmts[query] = cache.lru.PushFront(entry)
+	for cache.lru.Len() > cache.size {
+		cache.evict(cache.lru.Back())
+		cache.evictions++
+	}
+	return entry, nil
+}
+
+func (cache *stmtCache) evict(element *list.Element) error {
+	// Removes the statement, closing it unless it is in use. Must be called with the
+	// mutex held.
+	entry := element.Value.(*cachedStmt)
+	cache.lru.Remove(element)
+	delete(cache.stmts, entry.query)
+	entry.evicted = true
+	if 0 < entry.inUse {
+		return nil
+	}
+	return entry.stmt.Close()
+}
+
+func (cache *stmtCache) release(entry *cachedStmt) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry.inUse--
+	if entry.evicted && 0 == entry.inUse {
+		entry.stmt.Close()
+	}
+}
+
+func (cache *stmtCache) invalidate(queries ...string) error {
+	// Closes the statements of the queries, or all of them if none are given, so that
+	// they are prepared again on next use, ex. after the table was altered.
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	var errs []error
+	if 0 == len(queries) {
+		for element := cache.lru.Front(); nil != element; element = cache.lru.Front() {
+			errs = append(errs, cache.evict(element))
+		}
+		return errors.Join(errs...)
+	}
+	for _, query := range queries {
+		if element, ok := cache.stmts[query]; ok {
+			errs = append(errs, cache.evict(element))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (cache *stmtCache) close() error {
+	cache.mutex.Lock()
+	cache.closed = true
+	cache.mutex.Unlock()
+	return cache.invalidate()
+}
+
+func (cache *stmtCache) stats() StatementCacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return StatementCacheStats{
+		Size:      cache.lru.Len(),
+		Capacity:  cache.size,
+		Hits:      cache.hits,
+		Misses:    cache.misses,
+		Evictions: cache.evictions,
+	}
+}
+
+var errStmtCacheClosed = errors.New("statement cache is closed")
+
+func (metadata TableMetadata) prepared(ctx context.Context, query string) (*sql.Stmt, func()) {
+	// Returns the cached statement for query, or nil if it should be run unprepared,
+	// and the function to call once the statement has been run.
+	// Tagged queries carry a per-request comment, so caching them would never hit.
+	if nil == metadata.stmts || "" != metadata.queryComment(ctx) {
+		return nil, func() {}
+	}
+	entry, err := metadata.stmts.get(ctx, metadata.DB, query)
+	if nil != err {
+		if !errors.Is(err, errStmtCacheClosed) {
+			metadata.logf(LogWarn, "failed to prepare statement for %s, running unprepared\n%v", metadata.Name, err)
+		}
+		return nil, func() {}
+	}
+	return entry.stmt, func() { metadata.stmts.release(entry) }
+}
+
+func (metadata TableMetadata) Close() error {
+	// Closes any prepared statements cached for the table. Queries after Close
+	// are still run, but without preparing them.
+	if nil == metadata.stmts {
+		return nil
+	}
+	return metadata.stmts.close()
+}
+
+func (metadata TableMetadata) InvalidateStatements(queries ...string) error {
+	// Closes the cached statements of the queries, or all of them if none are given,
+	// to prepare them again on next use, ex. after the table was altered.
+	if nil == metadata.stmts {
+		return nil
+	}
+	return metadata.stmts.invalidate(queries...)
+}
+
+func (metadata TableMetadata) CachedStatements() int {
+	if nil == metadata.stmts {
+		return 0
+	}
+	return metadata.StatementCacheStats().Size
+}
+
+func (metadata TableMetadata) StatementCacheStats() StatementCacheStats {
+	if nil == metadata.stmts {
+		return StatementCacheStats{}
+	}
+	return metadata.stmts.stats()
+}
@@ -0,0 +1,236 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Table is a typed wrapper around TableMetadata for an entity struct T,
+// so that callers deal in *T rather than interface{} values.
+type Table[T any] struct {
+	Metadata *TableMetadata
+}
+
+func NewTable[T any](db *sql.DB, tableName string) (*Table[T], error) {
+	var entity T
+	metadata, err := GetTableMetadata(db, tableName, &entity)
+	if nil != err {
+		return nil, err
+	}
+	return &Table[T]{Metadata: metadata}, nil
+}
+
+func TableFor[T any](metadata *TableMetadata) (*Table[T], error) {
+	// Wraps metadata that was already fetched, checking it was fetched for T.
+	var entity T
+	value, err := GetStructValue(&entity)
+	if nil != err {
+		return nil, err
+	}
+	if value.Type() != metadata.EntityType {
+		return nil, fmt.Errorf("%w: metadata for %s is for %v, not %v",
+			ErrInvalidEntity, metadata.Name, metadata.EntityType, value.Type())
+	}
+	return &Table[T]{Metadata: metadata}, nil
+}
+
+func (table *Table[T]) Select(colnames ...string) (*Table[T], error) {
+	// Returns a table whose results only have the named columns filled in.
+	metadata, err := table.Metadata.Select(colnames...)
+	if nil != err {
+		return nil, err
+	}
+	return &Table[T]{Metadata: &metadata}, nil
+}
+
+func (table *Table[T]) Get(ctx context.Context, clause string, args ...interface{}) (*T, error) {
+	entity := new(T)
+	_, err := table.Metadata.GetEntityContext(ctx, entity, clause, args...)
+	if nil != err {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (table *Table[T]) GetById(ctx context.Context, id uint) (*T, error) {
+	entity := new(T)
+	_, err := table.Metadata.GetEntityByIdContext(ctx, entity, id)
+	if nil != err {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (table *Table[T]) Load(ctx context.Context, id uint) (*T, error) {
+	// Like GetById, but batched and cached through the context's Loader - see WithLoaders.
+	entity, err := LoaderFor(ctx, table.Metadata).Load(ctx, id)
+	if nil != err {
+		return nil, err
+	}
+	return entity.(*T), nil
+}
+
+func (table *Table[T]) GetByIds(ctx context.Context, ids []uint) ([]T, error) {
+	// Returns the entities in the order of ids, skipping ids with no row.
+	entities := []T{}
+	err := table.Metadata.GetEntitiesByIdsContext(ctx, &entities, ids)
+	if nil != err {
+		return nil, err
+	}
+	return entities, nil
+}
+
+func (table *Table[T]) MapByIds(ctx context.Context, ids []uint) (map[uint]*T, error) {
+	found, err := table.Metadata.GetEntityMapByIdsContext(ctx, ids)
+	if nil != err {
+		return nil, err
+	}
+	result := make(map[uint]*T, len(found))
+	for id, entity := range found {
+		result[id] = entity.(*T)
+	}
+	return result, nil
+}
+
+func (table *Table[T]) GetByIdForUpdate(ctx context.Context, id uint) (*T, error) {
+	// Reads and locks the row for the rest of the transaction of ctx.
+	entity := new(T)
+	_, err := table.Metadata.GetEntityByIdForUpdate(ctx, entity, id)
+	if nil != err {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (table *Table[T]) GetByKey(ctx context.Context, key map[string]interface{}) (*T, error) {
+	entity := new(T)
+	_, err := table.Metadata.GetEntityByKeyContext(ctx, entity, key)
+	if nil != err {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (table *Table[T]) GetByColumn(ctx context.Context, colname string, v interface{}) (*T, error) {
+	entity := new(T)
+	_, err := table.Metadata.GetEntityByColumnContext(ctx, entity, colname, v)
+	if nil != err {
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (table *Table[T]) List(ctx context.Context, clause string, args ...interface{}) ([]T, error) {
+	rows, err := table.Metadata.GetRowsContext(ctx, clause, args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	entities := []T{}
+	for rows.Next() {
+		var entity T
+		err = table.Metadata.ScanEntity(&entity, rows)
+		if nil != err {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("list %s: %w", table.Metadata.Name, err)
+	}
+	return entities, nil
+}
+
+func (table *Table[T]) Find(ctx context.Context, where *WhereBuilder) ([]T, error) {
+	entities := []T{}
+	err := table.Metadata.GetEntitiesWhereContext(ctx, &entities, where)
+	if nil != err {
+		return nil, err
+	}
+	return entities, nil
+}
+
+func (table *Table[T]) Page(ctx context.Context, clause string, page, perPage int, args ...interface{}) ([]T, int64, error) {
+	// Returns a page of entities along with the total number matching the clause.
+	entities := []T{}
+	total, err := table.Metadata.GetPageContext(ctx, &entities, clause, page, perPage, args...)
+	if nil != err {
+		return nil, 0, err
+	}
+	return entities, total, nil
+}
+
+func (table *Table[T]) After(ctx context.Context, cursor string, limit int, cols ...string) ([]T, string, error) {
+	// Returns the page after the cursor ("" for the first), in ascending order of cols
+	// (or id), with the cursor of the next page, which is "" after the last page.
+	entities := []T{}
+	next, err := table.Metadata.GetEntitiesAfterCursorContext(ctx, &entities, cursor, limit, false, cols...)
+	if nil != err {
+		return nil, "", err
+	}
+	return entities, next, nil
+}
+
+func (table *Table[T]) Count(ctx context.Context, clause string, args ...interface{}) (int64, error) {
+	return table.Metadata.CountContext(ctx, clause, args...)
+}
+
+func (table *Table[T]) Exists(ctx context.Context, clause string, args ...interface{}) (bool, error) {
+	return table.Metadata.ExistsContext(ctx, clause, args...)
+}
+
+func (table *Table[T]) Insert(ctx context.Context, entity *T) (uint, error) {
+	return table.Metadata.InsertEntityContext(ctx, entity)
+}
+
+func (table *Table[T]) InsertWithMode(ctx context.Context, entity *T, mode InsertMode) (InsertResult, error) {
+	return table.Metadata.InsertEntityWithModeContext(ctx, entity, mode)
+}
+
+func (table *Table[T]) Update(ctx context.Context, entity *T) error {
+	return table.Metadata.UpdateEntityContext(ctx, entity)
+}
+
+func (table *Table[T]) UpdateColumns(ctx context.Context, entity *T, colnames ...string) error {
+	return table.Metadata.UpdateEntityColumnsContext(ctx, entity, colnames...)
+}
+
+func (table *Table[T]) UpdateChanged(ctx context.Context, before, after *T) error {
+	return table.Metadata.UpdateChangedContext(ctx, before, after)
+}
+
+func (table *Table[T]) UpdateWhere(ctx context.Context, assignments map[string]interface{}, clause string, args ...interface{}) (int64, error) {
+	return table.Metadata.UpdateWhereContext(ctx, assignments, clause, args...)
+}
+
+func (table *Table[T]) DeleteWhere(ctx context.Context, clause string, args ...interface{}) (int64, error) {
+	return table.Metadata.DeleteWhereContext(ctx, clause, args...)
+}
+
+func (table *Table[T]) Increment(ctx context.Context, id uint, colname string, delta interface{}) error {
+	return table.Metadata.IncrementColumnContext(ctx, id, colname, delta)
+}
+
+func (table *Table[T]) FindOrCreate(ctx context.Context, entity *T, match map[string]interface{}) (bool, error) {
+	return table.Metadata.FindOrCreateContext(ctx, entity, match)
+}
+
+func (table *Table[T]) Save(ctx context.Context, entity *T) (uint, error) {
+	return table.Metadata.SaveEntityContext(ctx, entity)
+}
+
+func (table *Table[T]) GroupedBy(ctx context.Context, colname string, values []interface{}) (map[interface{}][]T, error) {
+	// Fetches the rows matching any of values in one query, grouped by the column value.
+	grouped, err := table.Metadata.GetEntitiesGroupedByContext(ctx, colname, values)
+	if nil != err {
+		return nil, err
+	}
+	result := make(map[interface{}][]T, len(grouped))
+	for key, entities := range grouped {
+		for _, entity := range entities {
+			result[key] = append(result[key], *entity.(*T))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TableStatus holds the statistics of a table from information_schema.TABLES, as
+// shown by SHOW TABLE STATUS. Rows is an estimate for InnoDB, and on MySQL 8 all of
+// them may be cached for up to information_schema_stats_expiry seconds.
+type TableStatus struct {
+	Engine        string `json:"engine,omitempty"`
+	RowFormat     string `json:"row_format,omitempty"`
+	Rows          uint64 `json:"rows"`
+	AvgRowLength  uint64 `json:"avg_row_length"`
+	DataLength    uint64 `json:"data_length"`
+	IndexLength   uint64 `json:"index_length"`
+	DataFree      uint64 `json:"data_free"`
+	AutoIncrement uint64 `json:"auto_increment,omitempty"`
+	Collation     string `json:"collation,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+}
+
+func GetTableStatus(ctx context.Context, db *sql.DB, tableNames ...string) (map[string]TableStatus, error) {
+	// Reads the status of several tables of the current schema in one query.
+	if 0 == len(tableNames) {
+		return map[string]TableStatus{}, nil
+	}
+	filter, args, names, err := tableArgs(tableNames)
+	if nil != err {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+SQL_TABLE_OF+", ENGINE, ROW_FORMAT, TABLE_ROWS, AVG_ROW_LENGTH, DATA_LENGTH, INDEX_LENGTH, "+
+			"DATA_FREE, AUTO_INCREMENT, TABLE_COLLATION, TABLE_COMMENT FROM information_schema.TABLES "+
+			"WHERE "+filter,
+		args...,
+	)
+	if nil != err {
+		return nil, fmt.Errorf("status of %v: %w", tableNames, err)
+	}
+	defer rows.Close()
+	statuses := map[string]TableStatus{}
+	for rows.Next() {
+		var schema, table string
+		var current bool
+		// views have no engine or statistics
+		var engine, rowFormat, collation, comment sql.NullString
+		var tableRows, avgRowLength, dataLength, indexLength, dataFree, autoIncrement sql.NullInt64
+		err = rows.Scan(&schema, &table, &current, &engine, &rowFormat, &tableRows, &avgRowLength, &dataLength, &indexLength,
+			&dataFree, &autoIncrement, &collation, &comment)
+		if nil != err {
+			return nil, fmt.Errorf("status of %s: %w", table, err)
+		}
+		status := TableStatus{
+			Engine:        engine.String,
+			RowFormat:     rowFormat.String,
+			Rows:          uint64(tableRows.Int64),
+			AvgRowLength:  uint64(avgRowLength.Int64),
+			DataLength:    uint64(dataLength.Int64),
+			IndexLength:   uint64(indexLength.Int64),
+			DataFree:      uint64(dataFree.Int64),
+			AutoIncrement: uint64(autoIncrement.Int64),
+			Collation:     collation.String,
+			Comment:       comment.String,
+		}
+		for _, name := range names.lookup(schema, table, current) {
+			statuses[name] = status
+		}
+	}
+	if err = rows.Err(); nil != err {
+		return nil, fmt.Errorf("status of %v: %w", tableNames, err)
+	}
+	return statuses, nil
+}
+
+func (metadata *TableMetadata) LoadTableStatus(ctx context.Context) error {
+	// Reads the current status of the table into Status. Call it again to refresh.
+	statuses, err := GetTableStatus(ctx, metadata.DB, metadata.Name)
+	if nil != err {
+		return err
+	}
+	status, ok := statuses[metadata.Name]
+	if !ok {
+		return fmt.Errorf("%w: no table %s in the current schema", ErrInvalidTableName, metadata.Name)
+	}
+	metadata.Status = &status
+	return nil
+}
+
+func (registry *Registry) TableStatus(ctx context.Context) (map[string]TableStatus, error) {
+	// Returns the status of every registered table, ex. for an operations dashboard.
+	names := []string{}
+	var db *sql.DB
+	for _, metadata := range registry.Tables() {
+		names = append(names, metadata.Name)
+		db = metadata.DB
+	}
+	if nil == db {
+		return map[string]TableStatus{}, nil
+	}
+	return GetTableStatus(ctx, db, names...)
+}
@@ -0,0 +1,239 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, tenant interface{}) context.Context {
+	// Returns a context for the statements of a tenant, ex. its id, which a
+	// TableResolver may use to pick its table.
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func GetTenant(ctx context.Context) (interface{}, bool) {
+	// Returns the tenant of the context, and false if it did not come from WithTenant.
+	if nil == ctx {
+		return nil, false
+	}
+	tenant := ctx.Value(tenantKey{})
+	return tenant, nil != tenant
+}
+
+// TableResolver returns the table that a statement run with the context goes to,
+// for tables split per tenant or shard that share their columns, or "" for the
+// table of the metadata.
+type TableResolver func(ctx context.Context) (string, error)
+
+func TenantTable(format string) TableResolver {
+	// Returns a resolver formatting the tenant of the context into the table name,
+	// ex. TenantTable("events_%s") sends the statements of tenant "acme" to events_acme.
+	return func(ctx context.Context) (string, error) {
+		tenant, ok := GetTenant(ctx)
+		if !ok {
+			return "", fmt.Errorf("%w for table %s", ErrNoTenant, format)
+		}
+		return fmt.Sprintf(format, tenant), nil
+	}
+}
+
+func ShardedTable(format string, shards int) TableResolver {
+	// Returns a resolver spreading the tenants of the contexts over a number of tables,
+	// ex. ShardedTable("events_%04d", 16) sends tenant 21 to events_0005. Integer
+	// tenants go to the shard of their remainder, others by the hash of their text.
+	return func(ctx context.Context) (string, error) {
+		tenant, ok := GetTenant(ctx)
+		if !ok {
+			return "", fmt.Errorf("%w for table %s", ErrNoTenant, format)
+		}
+		if 0 >= shards {
+			return "", fmt.Errorf("%w: %d shards for table %s", ErrInvalidTableName, shards, format)
+		}
+		return fmt.Sprintf(format, shardOf(tenant, shards)), nil
+	}
+}
+
+func shardOf(tenant interface{}, shards int) int {
+	value := reflect.ValueOf(tenant)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		shard := value.Int() % int64(shards)
+		if 0 > shard {
+			shard += int64(shards)
+		}
+		return int(shard)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(value.Uint() % uint64(shards))
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(fmt.Sprint(tenant)))
+	return int(hash.Sum32() % uint32(shards))
+}
+
+func (metadata TableMetadata) ResolveTableName(ctx context.Context) (string, error) {
+	// Returns the table that statements run with ctx go to: that of the TableResolver,
+	// with the TablePrefix, or the table of the metadata.
+	if nil == metadata.TableResolver {
+		return metadata.Name, nil
+	}
+	tableName, err := metadata.TableResolver(ctx)
+	if nil != err {
+		return "", err
+	}
+	if "" == tableName {
+		return metadata.Name, nil
+	}
+	tableName = metadata.PrefixedName(tableName)
+	if err = CheckTableName(tableName); nil != err {
+		return "", err
+	}
+	return tableName, nil
+}
+
+func (metadata TableMetadata) resolveTable(ctx context.Context, query string) (string, error) {
+	// Points the generated statement at the table of the TableResolver, rewriting only
+	// the references to the table so that a column of the same name is left alone.
+	if nil == metadata.TableResolver {
+		return query, nil
+	}
+	tableName, err := metadata.ResolveTableName(ctx)
+	if nil != err {
+		return "", fmt.Errorf("resolve table of %s: %w", metadata.Name, err)
+	}
+	if tableName == metadata.Name {
+		return query, nil
+	}
+	return replaceTableReferences(query, QuoteTableName(metadata.Name), QuoteTableName(tableName)), nil
+}
+
+// tableKeywords precede the table a statement reads or writes.
+var tableKeywords = []string{"FROM ", "INTO ", "UPDATE ", "JOIN "}
+
+func replaceTableReferences(query string, quoted string, replacement string) string {
+	// Replaces quoted where it names the table, after FROM, INTO, UPDATE or JOIN, or
+	// as the qualifier of a column, but not where it is a column itself.
+	var b strings.Builder
+	upper := strings.ToUpper(query)
+	last := 0
+	for at := 0; ; {
+		i := strings.Index(query[at:], quoted)
+		if 0 > i {
+			break
+		}
+		i += at
+		at = i + len(quoted)
+		isTable := strings.HasPrefix(query[at:], ".")
+		for _, keyword := range tableKeywords {
+			isTable = isTable || strings.HasSuffix(upper[:i], keyword)
+		}
+		if isTable {
+			b.WriteString(query[last:i])
+			b.WriteString(replacement)
+			last = at
+		}
+	}
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+func findTenantColumn(cols []ColumnMetadata) string {
+	// The column tagged sql:"tenant" scopes the statements of the table to the tenant
+	// of their context.
+	for _, col := range cols {
+		if col.Tenant {
+			return col.Field
+		}
+	}
+	return ""
+}
+
+func (metadata TableMetadata) checkTenant(ctx context.Context) error {
+	// Refuses statements without a tenant on a table scoped by tenant, rather than
+	// letting them see or change the rows of every tenant.
+	if metadata.unscoped || "" == metadata.TenantColumn {
+		return nil
+	}
+	if _, ok := GetTenant(ctx); !ok {
+		return fmt.Errorf("%w for %s", ErrNoTenant, metadata.Name)
+	}
+	return nil
+}
+
+func (metadata TableMetadata) scopeTenant(ctx context.Context, clause string, v []interface{}) (string, []interface{}) {
+	// Adds the tenant of the context to the WHERE of the clause.
+	if metadata.unscoped || "" == metadata.TenantColumn {
+		return clause, v
+	}
+	tenant, _ := GetTenant(ctx)
+	condition := QuoteTableName(metadata.Name) + "." + QuoteIdentifier(metadata.TenantColumn) + " = ?"
+	return ScopeClauseArgs(clause, condition, v, tenant)
+}
+
+func (metadata TableMetadata) scopeTenantRow(ctx context.Context, query string, v []interface{}) (string, []interface{}) {
+	// Adds the tenant of the context to a statement ending with the WHERE of a row.
+	if metadata.unscoped || "" == metadata.TenantColumn {
+		return query, v
+	}
+	tenant, _ := GetTenant(ctx)
+	return query + " AND " + QuoteIdentifier(metadata.TenantColumn) + " = ?", append(v, tenant)
+}
+
+func (metadata TableMetadata) fillTenant(ctx context.Context, value reflect.Value) error {
+	// Sets the tenant field of an entity to insert to the tenant of the context,
+	// failing if it holds another tenant.
+	if metadata.unscoped || "" == metadata.TenantColumn {
+		return nil
+	}
+	tenant, ok := GetTenant(ctx)
+	if !ok {
+		return fmt.Errorf("%w for insert into %s", ErrNoTenant, metadata.Name)
+	}
+	field := value.Field(metadata.FieldByColumn[metadata.TenantColumn])
+	tenantValue := reflect.ValueOf(tenant)
+	if reflect.String == field.Kind() {
+		// Convert would turn an integer into the character it encodes, ex. 65 into "A"
+		switch tenantValue.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			tenantValue = reflect.ValueOf(strconv.FormatInt(tenantValue.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			tenantValue = reflect.ValueOf(strconv.FormatUint(tenantValue.Uint(), 10))
+		case reflect.String:
+		default:
+			return fmt.Errorf("%w: tenant %v cannot be stored in %s.%s", ErrInvalidEntity, tenant, metadata.Name, metadata.TenantColumn)
+		}
+	}
+	if !tenantValue.Type().ConvertibleTo(field.Type()) {
+		return fmt.Errorf("%w: tenant %v cannot be stored in %s.%s", ErrInvalidEntity, tenant, metadata.Name, metadata.TenantColumn)
+	}
+	tenantValue = tenantValue.Convert(field.Type())
+	if !field.IsZero() && !reflect.DeepEqual(field.Interface(), tenantValue.Interface()) {
+		return fmt.Errorf("%w: entity of tenant %v inserted into %s for tenant %v", ErrInvalidEntity, field.Interface(), metadata.Name, tenant)
+	}
+	field.Set(tenantValue)
+	return nil
+}
+
+func (metadata TableMetadata) fillTenantValue(ctx context.Context, value interface{}) (interface{}, error) {
+	// Like fillTenant for a value of the tenant column rather than an entity: NULL
+	// takes the tenant of the context, and another tenant fails.
+	if metadata.unscoped || "" == metadata.TenantColumn {
+		return value, nil
+	}
+	tenant, ok := GetTenant(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%w for insert into %s", ErrNoTenant, metadata.Name)
+	}
+	if nil == value {
+		return tenant, nil
+	}
+	if fmt.Sprint(value) != fmt.Sprint(tenant) {
+		return nil, fmt.Errorf("%w: row of tenant %v inserted into %s for tenant %v", ErrInvalidEntity, value, metadata.Name, tenant)
+	}
+	return value, nil
+}
@@ -0,0 +1,69 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// treat as const
+var SQL_TIME_TYPE = regexp.MustCompile("(?i)^(datetime|timestamp|date|time)(\\(\\d+\\))?$")
+var CREATED_AT_COLUMN = "created_at"
+var UPDATED_AT_COLUMN = "updated_at"
+
+var nullTimeType = reflect.TypeOf(sql.NullTime{})
+
+func isTimeField(fieldType reflect.Type) bool {
+	if reflect.Ptr == fieldType.Kind() {
+		fieldType = fieldType.Elem()
+	}
+	return timeType == fieldType || nullTimeType == fieldType
+}
+
+func setFieldTime(field reflect.Value, now time.Time) {
+	switch {
+	case timeType == field.Type():
+		field.Set(reflect.ValueOf(now))
+	case reflect.Ptr == field.Kind() && timeType == field.Type().Elem():
+		field.Set(reflect.ValueOf(&now))
+	case nullTimeType == field.Type():
+		field.Set(reflect.ValueOf(sql.NullTime{Time: now, Valid: true}))
+	case reflect.Int64 == field.Kind():
+		// unix seconds
+		field.SetInt(now.Unix())
+	}
+}
+
+func (col ColumnMetadata) IsOnUpdateCurrentTimestamp() bool {
+	// The server sets these columns on every update, so they are left out of UPDATE.
+	return strings.Contains(strings.ToLower(col.Extra), "on update current_timestamp")
+}
+
+func detectAutoTimes(cols []ColumnMetadata, entityType reflect.Type, fieldByColumn map[string]int) {
+	// Columns named created_at and updated_at are managed automatically when their
+	// fields hold times, the same as with the auto-create-time and auto-update-time tags.
+	for i, col := range cols {
+		j, ok := fieldByColumn[col.Field]
+		if !ok || 0 > j || !isTimeField(entityType.Field(j).Type) {
+			continue
+		}
+		switch col.Field {
+		case CREATED_AT_COLUMN:
+			cols[i].AutoCreateTime = true
+		case UPDATED_AT_COLUMN:
+			cols[i].AutoUpdateTime = true
+		}
+	}
+}
+
+func (metadata TableMetadata) stampTimes(value reflect.Value, insert bool) {
+	// Sets created_at on insert, and updated_at on insert and update, to the current time.
+	now := time.Now()
+	for _, col := range metadata.Columns {
+		if (insert && col.AutoCreateTime) || col.AutoUpdateTime {
+			setFieldTime(value.Field(metadata.FieldByColumn[col.Field]), now)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package mysqlmeta
+
+import (
+	"context"
+	"strings"
+)
+
+// Span describes an operation for a Tracer.
+type Span struct {
+	Table string
+	// Operation is "fetch" for FetchTableMetadata, otherwise the verb of the
+	// statement in lower case, ex. "select", "insert", "update" or "delete"
+	Operation string
+	// Statement is empty for FetchTableMetadata
+	Statement string
+}
+
+// Tracer starts a span around each statement run for a table, and around
+// FetchTableMetadata, ex. to add them to OpenTelemetry traces - see the mysqlmetaotel
+// package. The returned function ends the span with the rows affected, or -1 for
+// queries and fetches, and the error if any.
+type Tracer interface {
+	Start(ctx context.Context, span Span) (context.Context, func(rowsAffected int64, err error))
+}
+
+// RowCounter is implemented by Tracers that also count the rows scanned into
+// entities, which ScanEntity reports one at a time.
+type RowCounter interface {
+	RowsScanned(table string, rows int)
+}
+
+// tracers starts a span with each of several tracers.
+type tracers []Tracer
+
+func (t tracers) Start(ctx context.Context, span Span) (context.Context, func(int64, error)) {
+	ends := make([]func(int64, error), len(t))
+	for i, tracer := range t {
+		ctx, ends[i] = tracer.Start(ctx, span)
+	}
+	return ctx, func(rowsAffected int64, err error) {
+		for i := len(ends) - 1; i >= 0; i-- {
+			ends[i](rowsAffected, err)
+		}
+	}
+}
+
+func (t tracers) RowsScanned(table string, rows int) {
+	for _, tracer := range t {
+		if counter, ok := tracer.(RowCounter); ok {
+			counter.RowsScanned(table, rows)
+		}
+	}
+}
+
+func Tracers(t ...Tracer) Tracer {
+	// Returns a tracer that starts a span with each of the tracers, in order, and
+	// ends them in reverse, ex. for both OpenTelemetry and metrics. Nil tracers are
+	// skipped.
+	combined := tracers{}
+	for _, tracer := range t {
+		if nil != tracer {
+			combined = append(combined, tracer)
+		}
+	}
+	if 1 == len(combined) {
+		return combined[0]
+	}
+	return combined
+}
+
+func (metadata TableMetadata) countRowScanned() {
+	if counter, ok := metadata.Tracer.(RowCounter); ok {
+		counter.RowsScanned(metadata.Name, 1)
+	}
+}
+
+func startSpan(ctx context.Context, tracer Tracer, span Span) (context.Context, func(int64, error)) {
+	if nil == tracer {
+		return ctx, func(int64, error) {}
+	}
+	return tracer.Start(ctx, span)
+}
+
+func statementOperation(query string) string {
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return strings.ToLower(verb)
+}
+
+func (metadata TableMetadata) startStatementSpan(ctx context.Context, query string) (context.Context, func(int64, error)) {
+	return startSpan(ctx, metadata.Tracer, Span{Table: metadata.Name, Operation: statementOperation(query), Statement: query})
+}
@@ -0,0 +1,106 @@
+package mysqlmeta
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TransformFunc converts a field value, ex. a string to lower case. It is given and
+// must return a value of the field's type.
+type TransformFunc func(v interface{}) (interface{}, error)
+
+// ColumnTransform holds the functions applied to a column's field after it is read
+// by ScanEntity (Read) and to the value written by InsertEntity and UpdateEntity
+// (Write), so that data hygiene rules live with the table. Either may be nil.
+type ColumnTransform struct {
+	Read  TransformFunc
+	Write TransformFunc
+}
+
+func StringTransform(f func(string) string) ColumnTransform {
+	// Applies f both ways to string, *string and sql.NullString fields.
+	apply := func(v interface{}) (interface{}, error) {
+		switch s := v.(type) {
+		case string:
+			return f(s), nil
+		case *string:
+			if nil == s {
+				return s, nil
+			}
+			t := f(*s)
+			return &t, nil
+		case sql.NullString:
+			if s.Valid {
+				s.String = f(s.String)
+			}
+			return s, nil
+		}
+		return nil, fmt.Errorf("%w: string transform of %T", ErrInvalidColumn, v)
+	}
+	return ColumnTransform{Read: apply, Write: apply}
+}
+
+// Common transforms - ex. metadata.Transforms = map[string]ColumnTransform{"email": mysqlmeta.Lowercase}
+var TrimSpace = StringTransform(strings.TrimSpace)
+var Lowercase = StringTransform(func(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+})
+
+func (metadata *TableMetadata) SetTransform(colname string, transform ColumnTransform) error {
+	// Sets the transform of a column of fetched metadata. Call this before the
+	// metadata is shared, as it is not safe alongside queries.
+	if !metadata.IsColumn(colname) {
+		return fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+	}
+	if nil == metadata.Transforms {
+		metadata.Transforms = map[string]ColumnTransform{}
+	}
+	metadata.Transforms[colname] = transform
+	return nil
+}
+
+func (metadata TableMetadata) checkTransforms() error {
+	for colname := range metadata.Transforms {
+		if !metadata.IsColumn(colname) {
+			return fmt.Errorf("%w: transform for %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) transformRead(value reflect.Value) error {
+	// Applies the Read transforms to the fields of a scanned entity.
+	for colname, transform := range metadata.Transforms {
+		if nil == transform.Read {
+			continue
+		}
+		field := value.Field(metadata.FieldByColumn[colname])
+		v, err := transform.Read(field.Interface())
+		if nil != err {
+			return fmt.Errorf("read transform of %s.%s: %w", metadata.Name, colname, err)
+		}
+		converted := reflect.ValueOf(v)
+		if !converted.IsValid() {
+			converted = reflect.Zero(field.Type())
+		}
+		if !converted.Type().AssignableTo(field.Type()) {
+			return fmt.Errorf("%w: read transform of %s.%s returned %T", ErrInvalidColumn, metadata.Name, colname, v)
+		}
+		field.Set(converted)
+	}
+	return nil
+}
+
+func (metadata TableMetadata) transformWrite(col ColumnMetadata, v interface{}) (interface{}, error) {
+	transform, ok := metadata.Transforms[col.Field]
+	if !ok || nil == transform.Write {
+		return v, nil
+	}
+	v, err := transform.Write(v)
+	if nil != err {
+		return nil, fmt.Errorf("write transform of %s.%s: %w", metadata.Name, col.Field, err)
+	}
+	return v, nil
+}
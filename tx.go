@@ -0,0 +1,74 @@
+package mysqlmeta
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type txKey struct{}
+
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	// Returns a context whose statements, through any TableMetadata, run in tx.
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func GetTx(ctx context.Context) *sql.Tx {
+	// Returns the transaction of the context, or nil if there is none.
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+func RunInTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	// Runs fn in a transaction, committing it if fn returns nil and rolling it back
+	// otherwise. Inside a transaction already, fn just joins it.
+	if nil != GetTx(ctx) {
+		return fn(ctx)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if nil != err {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	err = fn(WithTx(ctx, tx))
+	if nil != err {
+		if rollbackErr := tx.Rollback(); nil != rollbackErr {
+			logf(LogWarn, "rollback failed: %v", rollbackErr)
+		}
+		return err
+	}
+	if err = tx.Commit(); nil != err {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	// the writes are only visible to replicas once committed
+	if session := GetSession(ctx); nil != session {
+		if err = session.capture(ctx, db); nil != err {
+			logf(LogWarn, "read-your-writes falls back to the primary: %v", err)
+		}
+	}
+	return nil
+}
+
+func (metadata TableMetadata) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTx(ctx, metadata.DB, fn)
+}
+
+// conn is what a statement runs on - the database, or the transaction of the context.
+type conn interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (metadata TableMetadata) conn(ctx context.Context) conn {
+	if tx := GetTx(ctx); nil != tx {
+		return tx
+	}
+	return metadata.DB
+}
+
+func txStmt(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	// Rebinds a cached statement to the transaction of the context, if any.
+	if tx := GetTx(ctx); nil != tx && nil != stmt {
+		return tx.StmtContext(ctx, stmt)
+	}
+	return stmt
+}
@@ -0,0 +1,192 @@
+package mysqlmeta
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ULID is a time-ordered 128 bit identifier stored in a BINARY(16) column.
+// The first 48 bits are the creation time in milliseconds, so byte order,
+// and therefore index order in MySQL, follows creation order.
+type ULID [16]byte
+
+// UUID is stored in a BINARY(16) column in the byte order of UUID_TO_BIN(uuid).
+type UUID [16]byte
+
+// SwappedUUID is stored in the byte order of UUID_TO_BIN(uuid, 1), which moves
+// the time fields of a version 1 UUID to the front so that index order follows time.
+type SwappedUUID UUID
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func NewULID() ULID {
+	return NewULIDAt(time.Now())
+}
+
+func NewULIDAt(t time.Time) ULID {
+	var id ULID
+	ms := uint64(t.UnixMilli())
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	if _, err := rand.Read(id[6:]); nil != err {
+		panic("mysqlmeta: crypto/rand failed: " + err.Error())
+	}
+	return id
+}
+
+func (id ULID) Time() time.Time {
+	ms := uint64(id[0])<<40 | uint64(id[1])<<32 | uint64(binary.BigEndian.Uint32(id[2:6]))
+	return time.UnixMilli(int64(ms))
+}
+
+func (id ULID) String() string {
+	// Crockford base32 of 130 bits, the top two of which are always zero.
+	var out [26]byte
+	for i := range out {
+		bit := i*5 - 2
+		v := 0
+		for b := bit; b < bit+5; b++ {
+			v <<= 1
+			if 0 <= b && 0 != id[b/8]&(0x80>>(b%8)) {
+				v |= 1
+			}
+		}
+		out[i] = crockford[v]
+	}
+	return string(out[:])
+}
+
+func ParseULID(s string) (ULID, error) {
+	var id ULID
+	if 26 != len(s) {
+		return id, fmt.Errorf("%w: ULID %q must be 26 characters", ErrInvalidBinaryId, s)
+	}
+	s = strings.ToUpper(s)
+	for i := 0; i < len(s); i++ {
+		v := strings.IndexByte(crockford, s[i])
+		if 0 > v || (0 == i && 7 < v) {
+			return id, fmt.Errorf("%w: bad ULID %q", ErrInvalidBinaryId, s)
+		}
+		for k := 0; k < 5; k++ {
+			b := i*5 - 2 + k
+			if 0 <= b && 0 != v&(0x10>>k) {
+				id[b/8] |= 0x80 >> (b % 8)
+			}
+		}
+	}
+	return id, nil
+}
+
+func scanBinaryId(dst []byte, src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		if 16 != len(v) {
+			return fmt.Errorf("%w: expected 16 bytes, got %d", ErrInvalidBinaryId, len(v))
+		}
+		copy(dst, v)
+		return nil
+	case string:
+		return scanBinaryId(dst, []byte(v))
+	}
+	return fmt.Errorf("%w: cannot scan %T", ErrInvalidBinaryId, src)
+}
+
+func (id *ULID) Scan(src interface{}) error {
+	return scanBinaryId(id[:], src)
+}
+
+func (id ULID) Value() (driver.Value, error) {
+	return id[:], nil
+}
+
+func (id ULID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *ULID) UnmarshalText(text []byte) error {
+	parsed, err := ParseULID(string(text))
+	*id = parsed
+	return err
+}
+
+func (id UUID) String() string {
+	h := hex.EncodeToString(id[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func ParseUUID(s string) (UUID, error) {
+	var id UUID
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if nil != err || 16 != len(b) {
+		return id, fmt.Errorf("%w: bad UUID %q", ErrInvalidBinaryId, s)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+func (id *UUID) Scan(src interface{}) error {
+	return scanBinaryId(id[:], src)
+}
+
+func (id UUID) Value() (driver.Value, error) {
+	return id[:], nil
+}
+
+func (id UUID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	*id = parsed
+	return err
+}
+
+func swapUUID(b []byte) []byte {
+	// time_low(4) time_mid(2) time_hi(2) rest(8) <-> time_hi(2) time_mid(2) time_low(4) rest(8)
+	swapped := make([]byte, 0, 16)
+	swapped = append(swapped, b[6:8]...)
+	swapped = append(swapped, b[4:6]...)
+	swapped = append(swapped, b[0:4]...)
+	return append(swapped, b[8:]...)
+}
+
+func unswapUUID(b []byte) []byte {
+	unswapped := make([]byte, 0, 16)
+	unswapped = append(unswapped, b[4:8]...)
+	unswapped = append(unswapped, b[2:4]...)
+	unswapped = append(unswapped, b[0:2]...)
+	return append(unswapped, b[8:]...)
+}
+
+func (id SwappedUUID) String() string {
+	return UUID(id).String()
+}
+
+func (id *SwappedUUID) Scan(src interface{}) error {
+	var stored [16]byte
+	err := scanBinaryId(stored[:], src)
+	if nil != err {
+		return err
+	}
+	copy(id[:], unswapUUID(stored[:]))
+	return nil
+}
+
+func (id SwappedUUID) Value() (driver.Value, error) {
+	return swapUUID(id[:]), nil
+}
+
+func (id SwappedUUID) MarshalText() ([]byte, error) {
+	return UUID(id).MarshalText()
+}
+
+func (id *SwappedUUID) UnmarshalText(text []byte) error {
+	return (*UUID)(id).UnmarshalText(text)
+}
@@ -0,0 +1,43 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+func findVersionColumn(cols []ColumnMetadata) string {
+	// The column tagged sql:"version" holds the row version for optimistic locking.
+	for _, col := range cols {
+		if col.Version {
+			return col.Field
+		}
+	}
+	return ""
+}
+
+func (metadata TableMetadata) updateVersioned(ctx context.Context, value reflect.Value, q string, values []interface{}) error {
+	// Updates the row only if its version is still the one the entity was read with,
+	// and then bumps the version in the entity to match the row.
+	field := value.Field(metadata.FieldByColumn[metadata.VersionColumn])
+	q += " AND " + QuoteIdentifier(metadata.VersionColumn) + " = ?"
+	values = append(values, field.Interface())
+	result, err := metadata.exec(ctx, q, values...)
+	if nil != err {
+		return fmt.Errorf("update %s: %w", metadata.Name, err)
+	}
+	rows, err := result.RowsAffected()
+	if nil != err {
+		return fmt.Errorf("update %s: %w", metadata.Name, err)
+	}
+	if 0 == rows {
+		return fmt.Errorf("%w: %s id %v version %v", ErrStaleEntity, metadata.Name, GetValueId(value), field.Interface())
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(field.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(field.Uint() + 1)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package mysqlmeta
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+func readVirtualColumns(entityType reflect.Type) ([]ColumnMetadata, map[string]int, error) {
+	// Struct fields with a sqlexpr tag are filled from that SQL expression, ex.
+	// FullName string `sqlexpr:"CONCAT(first_name, ' ', last_name)"`. They are
+	// selected AS the snake case of the field name and are never inserted or updated.
+	cols := []ColumnMetadata{}
+	fieldByVirtual := map[string]int{}
+	for j := 0; j < entityType.NumField(); j++ {
+		field := entityType.Field(j)
+		expr := strings.TrimSpace(field.Tag.Get("sqlexpr"))
+		if "" == expr || isExcludedField(field) {
+			continue
+		}
+		if strings.Contains(expr, ";") {
+			return nil, nil, fmt.Errorf("%w: sqlexpr of field %s contains ;", ErrInvalidEntity, field.Name)
+		}
+		name := CamelCaseToSnakeCase(field.Name)
+		cols = append(cols, ColumnMetadata{Field: name, Expression: expr, StructField: field.Name})
+		fieldByVirtual[name] = j
+	}
+	return cols, fieldByVirtual, nil
+}
+
+func (metadata TableMetadata) fieldIndex(col ColumnMetadata) int {
+	if "" != col.Expression {
+		return metadata.FieldByVirtual[col.Field]
+	}
+	return metadata.FieldByColumn[col.Field]
+}
+
+func (metadata TableMetadata) scanTargets() []ColumnMetadata {
+	// The columns of SelectString, in order: the table columns then the virtual ones.
+	cols := metadata.selectColumns()
+	if 0 == len(metadata.VirtualColumns) {
+		return cols
+	}
+	targets := make([]ColumnMetadata, 0, len(cols)+len(metadata.VirtualColumns))
+	targets = append(targets, cols...)
+	return append(targets, metadata.VirtualColumns...)
+}
@@ -0,0 +1,59 @@
+package mysqlmeta
+
+import (
+	"strings"
+)
+
+// treat as const - the kinds of SchemaWarning
+var WARNING_TYPE = "type"
+var WARNING_NULLABLE = "nullable"
+var WARNING_EXTRA_FIELD = "extra field"
+
+// SchemaWarning is one mismatch between an entity struct and its table found by
+// FetchTableMetadata, ex. {Column: "price", Field: "Price", Kind: "type",
+// Expected: "int64", Actual: "decimal(10,2)"}. Expected is what the field needs and
+// Actual what the column is; an extra field has no column. The Severity is LogError
+// when reading rows will fail, ex. a NULL into a field that is not a pointer.
+type SchemaWarning struct {
+	Column   string   `json:"column,omitempty"`
+	Field    string   `json:"field,omitempty"`
+	Kind     string   `json:"kind"`
+	Expected string   `json:"expected,omitempty"`
+	Actual   string   `json:"actual,omitempty"`
+	Severity LogLevel `json:"severity"`
+}
+
+type SchemaWarnings []SchemaWarning
+
+func (warnings SchemaWarnings) String() string {
+	// Renders the warnings as the former TableMetadata.Warn string, ex.
+	// "Warning: mismatched type in columns price,name; Warning: no column for fields Total".
+	columns := []string{}
+	fields := []string{}
+	for _, warning := range warnings {
+		if WARNING_EXTRA_FIELD == warning.Kind {
+			fields = append(fields, warning.Field)
+		} else {
+			columns = append(columns, warning.Column)
+		}
+	}
+	parts := []string{}
+	if 0 < len(columns) {
+		parts = append(parts, "Warning: mismatched type in columns "+strings.Join(columns, ","))
+	}
+	if 0 < len(fields) {
+		parts = append(parts, "Warning: no column for fields "+strings.Join(fields, ","))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (warnings SchemaWarnings) MaxSeverity() LogLevel {
+	// Returns the highest severity, or LogDebug if there are no warnings.
+	severity := LogDebug
+	for _, warning := range warnings {
+		if warning.Severity > severity {
+			severity = warning.Severity
+		}
+	}
+	return severity
+}
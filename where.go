@@ -0,0 +1,187 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type SortOrder int
+
+const (
+	Asc SortOrder = iota
+	Desc
+)
+
+// WhereBuilder builds a clause for GetEntities and friends from conditions on named
+// columns, ex. Where().Eq("status", 1).In("org_id", ids).OrderBy("created_at", Desc).Limit(10).
+// The column names are checked against the table when the clause is built, and all
+// values are passed as placeholder arguments.
+type WhereBuilder struct {
+	conditions []whereCondition
+	orderBy    []whereOrder
+	limit      int
+	offset     int
+}
+
+type whereCondition struct {
+	column string
+	sql    string
+	args   []interface{}
+	like   *LikeCondition
+}
+
+type whereOrder struct {
+	column string
+	order  SortOrder
+}
+
+func Where() *WhereBuilder {
+	return &WhereBuilder{}
+}
+
+func (b *WhereBuilder) add(colname, op string, args ...interface{}) *WhereBuilder {
+	b.conditions = append(b.conditions, whereCondition{column: colname, sql: QuoteIdentifier(colname) + " " + op, args: args})
+	return b
+}
+
+func (b *WhereBuilder) Eq(colname string, v interface{}) *WhereBuilder {
+	return b.add(colname, "= ?", v)
+}
+
+func (b *WhereBuilder) NotEq(colname string, v interface{}) *WhereBuilder {
+	return b.add(colname, "<> ?", v)
+}
+
+func (b *WhereBuilder) Lt(colname string, v interface{}) *WhereBuilder {
+	return b.add(colname, "< ?", v)
+}
+
+func (b *WhereBuilder) Lte(colname string, v interface{}) *WhereBuilder {
+	return b.add(colname, "<= ?", v)
+}
+
+func (b *WhereBuilder) Gt(colname string, v interface{}) *WhereBuilder {
+	return b.add(colname, "> ?", v)
+}
+
+func (b *WhereBuilder) Gte(colname string, v interface{}) *WhereBuilder {
+	return b.add(colname, ">= ?", v)
+}
+
+func (b *WhereBuilder) In(colname string, values []interface{}) *WhereBuilder {
+	if 0 == len(values) {
+		// nothing is in an empty list, and IN () is a syntax error
+		b.conditions = append(b.conditions, whereCondition{column: colname, sql: "FALSE"})
+		return b
+	}
+	return b.add(colname, "IN "+InPlaceholders(len(values)), values...)
+}
+
+func (b *WhereBuilder) IsNull(colname string) *WhereBuilder {
+	return b.add(colname, "IS NULL")
+}
+
+func (b *WhereBuilder) IsNotNull(colname string) *WhereBuilder {
+	return b.add(colname, "IS NOT NULL")
+}
+
+func (b *WhereBuilder) Like(cond LikeCondition) *WhereBuilder {
+	// Adds a condition from Contains, StartsWith or EndsWith.
+	b.conditions = append(b.conditions, whereCondition{column: cond.Column, like: &cond})
+	return b
+}
+
+func (b *WhereBuilder) OrderBy(colname string, order SortOrder) *WhereBuilder {
+	b.orderBy = append(b.orderBy, whereOrder{colname, order})
+	return b
+}
+
+func (b *WhereBuilder) Limit(limit int) *WhereBuilder {
+	b.limit = limit
+	return b
+}
+
+func (b *WhereBuilder) Offset(offset int) *WhereBuilder {
+	b.offset = offset
+	return b
+}
+
+func (b *WhereBuilder) Clause(metadata TableMetadata) (string, []interface{}, error) {
+	// Returns the clause and its arguments, or ErrInvalidColumn for a column not in the table.
+	check := func(colname string) error {
+		if !metadata.IsColumn(colname) {
+			return fmt.Errorf("%w %s.%s", ErrInvalidColumn, metadata.Name, colname)
+		}
+		return nil
+	}
+	clause := ""
+	args := []interface{}{}
+	terms := []string{}
+	for _, cond := range b.conditions {
+		if err := check(cond.column); nil != err {
+			return "", nil, err
+		}
+		if nil != cond.like {
+			sql, likeArgs, err := cond.like.SQL()
+			if nil != err {
+				return "", nil, err
+			}
+			terms = append(terms, sql)
+			args = append(args, likeArgs...)
+			continue
+		}
+		terms = append(terms, cond.sql)
+		args = append(args, cond.args...)
+	}
+	if 0 < len(terms) {
+		clause += " WHERE " + strings.Join(terms, " AND ")
+	}
+	orders := []string{}
+	for _, order := range b.orderBy {
+		if err := check(order.column); nil != err {
+			return "", nil, err
+		}
+		direction := ""
+		if Desc == order.order {
+			direction = " DESC"
+		}
+		orders = append(orders, QuoteIdentifier(order.column)+direction)
+	}
+	if 0 < len(orders) {
+		clause += " ORDER BY " + strings.Join(orders, ", ")
+	}
+	if 0 < b.limit {
+		clause += " LIMIT ?"
+		args = append(args, b.limit)
+		if 0 < b.offset {
+			clause += " OFFSET ?"
+			args = append(args, b.offset)
+		}
+	}
+	return clause, args, nil
+}
+
+func (metadata TableMetadata) GetEntitiesWhere(dest interface{}, where *WhereBuilder) error {
+	return metadata.GetEntitiesWhereContext(context.Background(), dest, where)
+}
+
+func (metadata TableMetadata) GetEntitiesWhereContext(ctx context.Context, dest interface{}, where *WhereBuilder) error {
+	clause, args, err := where.Clause(metadata)
+	if nil != err {
+		return err
+	}
+	return metadata.GetEntitiesContext(ctx, dest, clause, args...)
+}
+
+func (metadata TableMetadata) GetEntityWhere(entity interface{}, where *WhereBuilder) (interface{}, error) {
+	return metadata.GetEntityWhereContext(context.Background(), entity, where)
+}
+
+func (metadata TableMetadata) GetEntityWhereContext(ctx context.Context, entity interface{}, where *WhereBuilder) (interface{}, error) {
+	clause, args, err := where.Clause(metadata)
+	if nil != err {
+		return nil, err
+	}
+	return metadata.GetEntityContext(ctx, entity, clause, args...)
+}
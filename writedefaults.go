@@ -0,0 +1,73 @@
+package mysqlmeta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Fields can be given a value to write when they are left zero, with tags such as
+// sql:"status,insert-default=pending" or sql:"updated_by,update-default=ctx:user_id".
+// A value starting with ctx: names a value set with WithDefaultValue, otherwise it
+// is a literal converted to the field's type; it cannot contain a comma.
+
+// treat as const
+var CONTEXT_DEFAULT_PREFIX = "ctx:"
+
+type defaultValuesKey struct{}
+
+func WithDefaultValue(ctx context.Context, key string, v interface{}) context.Context {
+	// Returns a copy of ctx carrying v for the ctx:key write defaults, ex. the id of
+	// the signed in user for sql:"updated_by,update-default=ctx:user_id".
+	existing, _ := ctx.Value(defaultValuesKey{}).(map[string]interface{})
+	values := make(map[string]interface{}, len(existing)+1)
+	for k, value := range existing {
+		values[k] = value
+	}
+	values[key] = v
+	return context.WithValue(ctx, defaultValuesKey{}, values)
+}
+
+func DefaultValue(ctx context.Context, key string) (interface{}, bool) {
+	if nil == ctx {
+		return nil, false
+	}
+	values, _ := ctx.Value(defaultValuesKey{}).(map[string]interface{})
+	v, ok := values[key]
+	return v, ok
+}
+
+func (metadata TableMetadata) applyWriteDefaults(ctx context.Context, value reflect.Value, insert bool) error {
+	// Sets the zero fields that have an insert-default (on insert) or update-default
+	// (on update). A ctx: default missing from the context leaves the field zero.
+	for _, col := range metadata.Columns {
+		def := col.UpdateDefault
+		if insert {
+			def = col.InsertDefault
+		}
+		if "" == def {
+			continue
+		}
+		field := value.Field(metadata.FieldByColumn[col.Field])
+		if !field.IsZero() {
+			continue
+		}
+		var v interface{} = def
+		if strings.HasPrefix(def, CONTEXT_DEFAULT_PREFIX) {
+			var ok bool
+			v, ok = DefaultValue(ctx, strings.TrimPrefix(def, CONTEXT_DEFAULT_PREFIX))
+			if !ok {
+				continue
+			}
+			if converted := reflect.ValueOf(v); converted.IsValid() && converted.Type().AssignableTo(field.Type()) {
+				field.Set(converted)
+				continue
+			}
+		}
+		if err := setFieldExprValue(field, v); nil != err {
+			return fmt.Errorf("write default for %s.%s: %w", metadata.Name, col.Field, err)
+		}
+	}
+	return nil
+}